@@ -0,0 +1,232 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+// minPasswordLength applies to passwords set on the account page.
+const minPasswordLength = 8
+
+type accountPageData struct {
+	Assets   string
+	Username string
+	Error    string
+	Message  string
+	CSRF     string
+	Password bool
+	Sessions []accountSession
+}
+
+type accountSession struct {
+	ID        string
+	SignedIn  string
+	ClientIP  string
+	UserAgent string
+	Current   bool
+}
+
+// handleAccount lets a signed-in user change their password and sign out
+// their other devices.
+func (s *server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg, err := s.loadConfig()
+	if err != nil {
+		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	session, user, ok := s.currentSession(r, cfg)
+	if !ok {
+		s.redirectToLogin(w, r)
+		return
+	}
+	if r.Method == http.MethodGet {
+		s.renderAccount(w, session, user, "", "")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.renderAccount(w, session, user, "invalid form submission", "")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("csrf")), []byte(accountCSRF(session))) != 1 {
+		s.renderAccount(w, session, user, "this page expired, try again", "")
+		return
+	}
+	switch r.FormValue("action") {
+	case "password":
+		errMsg, message := s.changePassword(r, cfg, session, user)
+		s.renderAccount(w, session, user, errMsg, message)
+	case "logout-others":
+		revoked, err := s.sessions.RevokeOthers(user.Username, session.Nonce)
+		if err != nil {
+			log.Printf("auth session store failed: %v", err)
+			s.renderAccount(w, session, user, "unable to sign out other devices", "")
+			return
+		}
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogout, User: user.Username, Outcome: auth.AuditSuccess, Reason: fmt.Sprintf("other devices (%d)", revoked)})
+		s.renderAccount(w, session, user, "", "Other devices were signed out.")
+	default:
+		s.renderAccount(w, session, user, "unknown action", "")
+	}
+}
+
+// changePassword checks the current password and has the host store the
+// new one in proxy.toml. It returns an error message or a success message
+// for the page.
+func (s *server) changePassword(r *http.Request, cfg proxy.Config, session auth.Session, user proxy.AuthUser) (string, string) {
+	if session.Provider != "" {
+		return "your password is managed by your single sign-on provider", ""
+	}
+	client := clientIP(r)
+	if !loginLimiter.Allow(client) {
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditRateLimit, User: user.Username, Outcome: auth.AuditDenied, Reason: "password change"})
+		return "too many attempts, try again soon", ""
+	}
+	start := time.Now()
+	if !checkPassword(user.Password, r.FormValue("current")) {
+		sleepToUniformDelay(start, 250*time.Millisecond)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditPasswordChange, User: user.Username, Outcome: auth.AuditFailure, Reason: "bad password"})
+		return "current password is incorrect", ""
+	}
+	password := r.FormValue("password")
+	if len(password) < minPasswordLength {
+		return fmt.Sprintf("new password must be at least %d characters", minPasswordLength), ""
+	}
+	if password != r.FormValue("confirm") {
+		return "new passwords do not match", ""
+	}
+	path, err := s.configFile()
+	if err != nil {
+		return "unable to change password", ""
+	}
+	change := proxy.PasswordChange{Username: user.Username, Current: user.Password, Password: password}
+	if err := requestPasswordChange(path, change); err != nil {
+		log.Printf("auth password change failed user=%s: %v", user.Username, err)
+		return "unable to change password", ""
+	}
+	if _, err := s.sessions.RevokeOthers(user.Username, session.Nonce); err != nil {
+		log.Printf("auth session store failed: %v", err)
+	}
+	log.Printf("auth password changed user=%s ip=%s", user.Username, client)
+	s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditPasswordChange, User: user.Username, Outcome: auth.AuditSuccess})
+	return "", "Password changed. Your other devices were signed out."
+}
+
+// currentSession returns the valid login session on r, if any.
+func (s *server) currentSession(r *http.Request, cfg proxy.Config) (auth.Session, proxy.AuthUser, bool) {
+	cookieName := strings.TrimSpace(cfg.Auth.CookieName)
+	if cookieName == "" {
+		cookieName = proxy.DefaultAuthCookieName()
+	}
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || strings.TrimSpace(cookie.Value) == "" {
+		return auth.Session{}, proxy.AuthUser{}, false
+	}
+	session, err := auth.VerifySession(cookie.Value, keyring(cfg))
+	if err != nil {
+		return auth.Session{}, proxy.AuthUser{}, false
+	}
+	if err := s.sessions.Check(session); err != nil {
+		if !errors.Is(err, auth.ErrRevokedSession) {
+			log.Printf("auth session store failed: %v", err)
+		}
+		return auth.Session{}, proxy.AuthUser{}, false
+	}
+	user, ok := sessionUser(cfg, session)
+	return session, user, ok
+}
+
+func (s *server) renderAccount(w http.ResponseWriter, session auth.Session, user proxy.AuthUser, errMsg string, message string) {
+	data := accountPageData{
+		Assets:   authPathPrefix + "/assets",
+		Username: user.Username,
+		Error:    errMsg,
+		Message:  message,
+		CSRF:     accountCSRF(session),
+		Password: session.Provider == "",
+	}
+	records, err := s.sessions.List(user.Username)
+	if err != nil {
+		log.Printf("auth session store failed: %v", err)
+	}
+	for _, record := range records {
+		data.Sessions = append(data.Sessions, accountSession{
+			ID:        record.ID(),
+			SignedIn:  time.Unix(record.IssuedAt, 0).UTC().Format("2006-01-02 15:04 MST"),
+			ClientIP:  record.ClientIP,
+			UserAgent: record.UserAgent,
+			Current:   record.Nonce == session.Nonce,
+		})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.accountTmpl.Execute(w, data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// accountCSRF derives the form token from the session nonce, which only the
+// cookie holder knows.
+func accountCSRF(session auth.Session) string {
+	sum := sha256.Sum256([]byte("account:" + session.Nonce))
+	return hex.EncodeToString(sum[:16])
+}
+
+// configFile is the proxy.toml path viberun-auth reads. Its state files
+// live next to it.
+func (s *server) configFile() (string, error) {
+	if path := strings.TrimSpace(s.configPath); path != "" {
+		return path, nil
+	}
+	return proxy.ConfigPath()
+}
+
+// requestPasswordChange asks the viberun-server daemon on the host to apply
+// change, since proxy.toml is read-only in the proxy container.
+func requestPasswordChange(configPath string, change proxy.PasswordChange) error {
+	socket := proxy.HostSocketPath(configPath)
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post("http://host/password", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("host: %s", strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
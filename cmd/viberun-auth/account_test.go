@@ -0,0 +1,160 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+// serveTestHost stands in for the viberun-server daemon, which writes
+// password changes to proxy.toml for viberun-auth.
+func serveTestHost(t *testing.T, configPath string) {
+	t.Helper()
+	socket := proxy.HostSocketPath(configPath)
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var change proxy.PasswordChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := proxy.UpdateConfig(configPath, func(cfg *proxy.Config) error {
+			return proxy.ApplyPasswordChange(cfg, change)
+		}); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+		}
+	})}
+	go func() { _ = host.Serve(listener) }()
+	t.Cleanup(func() { _ = host.Close() })
+}
+
+func TestAccountChangesPasswordAndSignsOutOthers(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "proxy.toml")
+	hash, err := proxy.HashPassword("old password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	cfg := proxy.Config{
+		BaseDomain: "example.com",
+		Apps:       map[string]proxy.AppAccess{"web": {Access: proxy.AccessPrivate}},
+		Users:      []proxy.AuthUser{{Username: "erin", Password: hash}},
+		Auth:       proxy.AuthConfig{SigningKey: "signing-secret"},
+	}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	serveTestHost(t, configPath)
+	s := &server{
+		configPath:  configPath,
+		loginTmpl:   template.Must(template.New("login").Parse("{{ .Error }}")),
+		accountTmpl: template.Must(template.New("account").Parse("{{ .Error }}|{{ .Message }}|{{ .CSRF }}|{{ len .Sessions }}")),
+		sessions:    auth.NewSessionStore(proxy.SessionStorePath(configPath)),
+		mfa:         auth.NewMFAStore(proxy.MFAStorePath(configPath)),
+	}
+	login := func() *http.Cookie {
+		form := url.Values{"username": {"erin"}, "password": {"old password"}, "redirect": {"/"}}
+		req := httptest.NewRequest(http.MethodPost, "https://web.example.com"+authPathPrefix+"/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		s.handleLogin(rec, req)
+		cookie := findCookie(rec.Result().Cookies(), "viberun_auth")
+		if cookie == nil {
+			t.Fatalf("login: expected session cookie, status %d", rec.Code)
+		}
+		return cookie
+	}
+	laptop := login()
+	phone := login()
+	account := func(cookie *http.Cookie, form url.Values) string {
+		method := http.MethodGet
+		var body *strings.Reader
+		if form != nil {
+			method = http.MethodPost
+			body = strings.NewReader(form.Encode())
+		} else {
+			body = strings.NewReader("")
+		}
+		req := httptest.NewRequest(method, "https://web.example.com"+authPathPrefix+"/account", body)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		s.handleAccount(rec, req)
+		if rec.Code != http.StatusOK {
+			return rec.Header().Get("Location")
+		}
+		return rec.Body.String()
+	}
+
+	if got := account(nil, nil); !strings.HasPrefix(got, authPathPrefix+"/login?redirect=") {
+		t.Fatalf("anonymous account: expected login redirect, got %q", got)
+	}
+	page := strings.Split(account(laptop, nil), "|")
+	if len(page) != 4 || page[3] != "2" {
+		t.Fatalf("account page: %q", page)
+	}
+	csrf := page[2]
+
+	if got := account(laptop, url.Values{"action": {"password"}, "csrf": {"forged"}, "current": {"old password"}, "password": {"new password"}, "confirm": {"new password"}}); !strings.HasPrefix(got, "this page expired") {
+		t.Fatalf("forged csrf: %q", got)
+	}
+	if got := account(laptop, url.Values{"action": {"password"}, "csrf": {csrf}, "current": {"wrong"}, "password": {"new password"}, "confirm": {"new password"}}); !strings.HasPrefix(got, "current password is incorrect") {
+		t.Fatalf("wrong current password: %q", got)
+	}
+	if got := account(laptop, url.Values{"action": {"password"}, "csrf": {csrf}, "current": {"old password"}, "password": {"new password"}, "confirm": {"new password"}}); !strings.HasPrefix(got, "|Password changed") {
+		t.Fatalf("change password: %q", got)
+	}
+
+	saved, err := proxy.LoadConfigFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFromPath: %v", err)
+	}
+	if user, _ := findUser(saved, "erin"); !checkPassword(user.Password, "new password") {
+		t.Fatalf("expected new password in proxy.toml")
+	}
+	if got := account(laptop, nil); !strings.HasSuffix(got, "|1") {
+		t.Fatalf("current device should stay signed in with one session: %q", got)
+	}
+	if got := account(phone, nil); !strings.HasPrefix(got, authPathPrefix+"/login") {
+		t.Fatalf("other device should be signed out, got %q", got)
+	}
+}
+
+func TestAccountTemplateRenders(t *testing.T) {
+	tmpl, err := template.ParseFiles(filepath.Join("..", "..", "config", "auth", "account.html"))
+	if err != nil {
+		t.Fatalf("ParseFiles: %v", err)
+	}
+	var out strings.Builder
+	data := accountPageData{Username: "erin", CSRF: "token", Password: true, Sessions: []accountSession{{ID: "abcd1234", Current: true}, {ID: "efgh5678", ClientIP: "203.0.113.7"}}}
+	if err := tmpl.Execute(&out, data); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	for _, want := range []string{"Signed in as erin", "this device", "Log out other devices", `name="csrf" value="token"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in account page", want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+// audit records an event for r, filling in the client IP, host and app.
+// Failures to write are logged and never block the request.
+func (s *server) audit(r *http.Request, cfg proxy.Config, event auth.AuditEvent) {
+	event.IP = clientIP(r)
+	event.Host = forwardedHost(r)
+	if app, ok := appForHost(cfg, event.Host); ok {
+		event.App = app
+	}
+	if err := s.auditLog.Record(event); err != nil {
+		log.Printf("auth audit log failed: %v", err)
+	}
+}
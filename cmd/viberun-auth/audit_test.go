@@ -0,0 +1,92 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+func TestAuditRecordsLoginsAndDenials(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "proxy.toml")
+	hash, err := proxy.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	cfg := proxy.Config{
+		BaseDomain: "example.com",
+		Apps: map[string]proxy.AppAccess{
+			"web":    {Access: proxy.AccessPrivate, AllowedUsers: []string{"dave"}},
+			"secret": {Access: proxy.AccessPrivate},
+		},
+		Users: []proxy.AuthUser{{Username: "dave", Password: hash}},
+		Auth:  proxy.AuthConfig{SigningKey: "signing-secret"},
+	}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	auditPath := proxy.AuditLogPath(configPath)
+	s := &server{
+		configPath: configPath,
+		loginTmpl:  template.Must(template.New("login").Parse("{{ .Error }}")),
+		sessions:   auth.NewSessionStore(proxy.SessionStorePath(configPath)),
+		mfa:        auth.NewMFAStore(proxy.MFAStorePath(configPath)),
+		auditLog:   auth.NewAuditLog(auditPath),
+	}
+
+	login := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"dave"}, "password": {password}, "redirect": {"/"}}
+		req := httptest.NewRequest(http.MethodPost, "https://web.example.com"+authPathPrefix+"/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "198.51.100.4:5000"
+		rec := httptest.NewRecorder()
+		s.handleLogin(rec, req)
+		return rec
+	}
+	login("wrong")
+	rec := login("correct horse")
+	session := findCookie(rec.Result().Cookies(), "viberun_auth")
+	if session == nil {
+		t.Fatalf("login: expected session cookie, status %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+authPathPrefix+"/verify", nil)
+	req.Header.Set("X-Forwarded-Host", "secret.example.com")
+	req.AddCookie(session)
+	s.handleVerify(httptest.NewRecorder(), req)
+
+	events, err := auth.ReadAuditLog(auditPath, auth.AuditFilter{User: "dave"})
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+	want := []auth.AuditEvent{
+		{Event: auth.AuditLogin, User: "dave", IP: "198.51.100.4", Host: "web.example.com", App: "web", Outcome: auth.AuditFailure, Reason: "bad password"},
+		{Event: auth.AuditLogin, User: "dave", IP: "198.51.100.4", Host: "web.example.com", App: "web", Outcome: auth.AuditSuccess},
+		{Event: auth.AuditVerify, User: "dave", Host: "secret.example.com", App: "secret", Outcome: auth.AuditDenied, Reason: "not allowed"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, event := range events {
+		if event.Time.IsZero() {
+			t.Fatalf("event %d has no time", i)
+		}
+		event.Time = want[i].Time
+		if want[i].IP == "" {
+			event.IP = ""
+		}
+		if event != want[i] {
+			t.Fatalf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+}
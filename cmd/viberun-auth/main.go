@@ -5,6 +5,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"html/template"
 	"log"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/shayne/viberun/internal/auth"
 	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/totp"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -25,15 +27,22 @@ const (
 )
 
 type server struct {
-	configPath string
-	assetsDir  string
-	loginTmpl  *template.Template
+	configPath  string
+	assetsDir   string
+	loginTmpl   *template.Template
+	accountTmpl *template.Template
+	sessions    *auth.SessionStore
+	mfa         *auth.MFAStore
+	auditLog    *auth.AuditLog
+	oidc        oidcCache
 }
 
 type loginPageData struct {
-	Assets   string
-	Redirect string
-	Error    string
+	Assets    string
+	Redirect  string
+	Error     string
+	Challenge string
+	SSO       bool
 }
 
 func main() {
@@ -62,11 +71,24 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load login template: %v", err)
 	}
+	accountTmpl, err := template.ParseFiles(filepath.Join(strings.TrimSpace(*assetsDir), "account.html"))
+	if err != nil {
+		log.Fatalf("failed to load account template: %v", err)
+	}
+
+	storeConfigPath := strings.TrimSpace(*configPath)
+	if storeConfigPath == "" {
+		storeConfigPath, _ = proxy.ConfigPath()
+	}
 
 	s := &server{
-		configPath: strings.TrimSpace(*configPath),
-		assetsDir:  strings.TrimSpace(*assetsDir),
-		loginTmpl:  tmpl,
+		configPath:  strings.TrimSpace(*configPath),
+		assetsDir:   strings.TrimSpace(*assetsDir),
+		loginTmpl:   tmpl,
+		accountTmpl: accountTmpl,
+		sessions:    auth.NewSessionStore(proxy.SessionStorePath(storeConfigPath)),
+		mfa:         auth.NewMFAStore(proxy.MFAStorePath(storeConfigPath)),
+		auditLog:    auth.NewAuditLog(proxy.AuditLogPath(storeConfigPath)),
 	}
 
 	mux := http.NewServeMux()
@@ -75,6 +97,10 @@ func main() {
 	mux.HandleFunc(authPathPrefix+"/login", s.handleLogin)
 	mux.HandleFunc(authPathPrefix+"/logout", s.handleLogout)
 	mux.HandleFunc(authPathPrefix+"/verify", s.handleVerify)
+	mux.HandleFunc(authPathPrefix+"/account", s.handleAccount)
+	mux.HandleFunc(authPathPrefix+"/oidc/start", s.handleOIDCStart)
+	mux.HandleFunc(authPathPrefix+"/oidc/callback", s.handleOIDCCallback)
+	mux.HandleFunc(authPathPrefix+"/share", s.handleShare)
 
 	srv := &http.Server{
 		Addr:              addr,
@@ -90,6 +116,10 @@ func main() {
 
 var loginLimiter = newRateLimiter(20, time.Minute)
 
+// challengeFailures bounds guesses against a single TOTP challenge, which
+// otherwise stays valid for its whole lifetime.
+var challengeFailures = newChallengeTracker(5)
+
 func (s *server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -110,44 +140,141 @@ func (s *server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 	redirect := r.FormValue("redirect")
 	client := clientIP(r)
-	if !loginLimiter.Allow(client) {
-		s.renderLogin(w, r, "too many attempts, try again soon", redirect)
-		return
-	}
-
 	cfg, err := s.loadConfig()
 	if err != nil {
 		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
 		return
 	}
-	if cfg.Auth.SigningKey == "" {
+	if !loginLimiter.Allow(client) {
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditRateLimit, User: username, Outcome: auth.AuditDenied, Reason: "login"})
+		s.renderLogin(w, r, "too many attempts, try again soon", redirect)
+		return
+	}
+	signingKey, ok := proxy.ActiveSigningKey(cfg)
+	if !ok {
 		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
 		return
 	}
+	if challenge := r.FormValue("challenge"); challenge != "" {
+		s.handleLoginCode(w, r, cfg, signingKey, challenge, redirect, start)
+		return
+	}
 	user, ok := findUser(cfg, username)
 	if !ok || !checkPassword(user.Password, password) {
 		sleepToUniformDelay(start, 250*time.Millisecond)
 		log.Printf("auth login failed user=%s ip=%s", username, client)
+		reason := "bad password"
+		if !ok {
+			reason = "unknown user"
+		}
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, User: username, Outcome: auth.AuditFailure, Reason: reason})
 		s.renderLogin(w, r, "invalid username or password", redirect)
 		return
 	}
+	if user.TOTPSecret != "" {
+		challenge, err := auth.NewChallenge(user.Username)
+		if err != nil {
+			http.Error(w, "unable to create session", http.StatusInternalServerError)
+			return
+		}
+		token, err := auth.SignChallenge(challenge, auth.SigningKey{ID: signingKey.ID, Secret: []byte(signingKey.Key)})
+		if err != nil {
+			http.Error(w, "unable to sign session", http.StatusInternalServerError)
+			return
+		}
+		s.renderChallenge(w, r, "", token, redirect)
+		return
+	}
+	s.completeLogin(w, r, cfg, signingKey, user, redirect)
+}
 
-	ttl := parseTTL(cfg.Auth.CookieTTL)
-	session, err := auth.NewSession(user.Username, ttl)
+// handleLoginCode is the second login step for users enrolled in TOTP.
+func (s *server) handleLoginCode(w http.ResponseWriter, r *http.Request, cfg proxy.Config, signingKey proxy.SigningKey, token string, redirect string, start time.Time) {
+	client := clientIP(r)
+	challenge, err := auth.VerifyChallenge(token, keyring(cfg))
+	if err != nil {
+		s.renderLogin(w, r, "sign-in timed out, try again", redirect)
+		return
+	}
+	if challengeFailures.Spent(challenge.Nonce) {
+		s.renderLogin(w, r, "sign-in timed out, try again", redirect)
+		return
+	}
+	user, ok := findUser(cfg, challenge.Username)
+	if !ok || user.TOTPSecret == "" {
+		s.renderLogin(w, r, "invalid username or password", redirect)
+		return
+	}
+	if !s.checkSecondFactor(user, r.FormValue("code")) {
+		sleepToUniformDelay(start, 250*time.Millisecond)
+		log.Printf("auth code failed user=%s ip=%s", user.Username, client)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, User: user.Username, Outcome: auth.AuditFailure, Reason: "bad code"})
+		if challengeFailures.Fail(challenge) {
+			s.renderLogin(w, r, "too many invalid codes, sign in again", redirect)
+			return
+		}
+		s.renderChallenge(w, r, "invalid code", token, redirect)
+		return
+	}
+	// A challenge completes one login; it cannot be replayed with a later code.
+	challengeFailures.Spend(challenge)
+	s.completeLogin(w, r, cfg, signingKey, user, redirect)
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code.
+// Each is consumed so it cannot be replayed.
+func (s *server) checkSecondFactor(user proxy.AuthUser, code string) bool {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		if err := s.mfa.UseStep(user.Username, step); err != nil {
+			if !errors.Is(err, auth.ErrCodeReused) {
+				log.Printf("auth mfa store failed: %v", err)
+			}
+			return false
+		}
+		return true
+	}
+	hash, ok := totp.MatchRecoveryCode(user.RecoveryCodes, code)
+	if !ok {
+		return false
+	}
+	if err := s.mfa.UseRecoveryCode(user.Username, hash); err != nil {
+		if !errors.Is(err, auth.ErrCodeReused) {
+			log.Printf("auth mfa store failed: %v", err)
+		}
+		return false
+	}
+	log.Printf("auth recovery code used user=%s", user.Username)
+	return true
+}
+
+func (s *server) completeLogin(w http.ResponseWriter, r *http.Request, cfg proxy.Config, signingKey proxy.SigningKey, user proxy.AuthUser, redirect string) {
+	session, err := auth.NewSession(user.Username, parseTTL(cfg.Auth.CookieTTL))
 	if err != nil {
 		http.Error(w, "unable to create session", http.StatusInternalServerError)
 		return
 	}
-	token, err := auth.SignSession(session, []byte(cfg.Auth.SigningKey))
+	s.issueSession(w, r, cfg, signingKey, session, auth.SafeRedirect(r, redirect))
+}
+
+// issueSession records session, sets its cookie and sends the browser to
+// redirect, which the caller has already checked.
+func (s *server) issueSession(w http.ResponseWriter, r *http.Request, cfg proxy.Config, signingKey proxy.SigningKey, session auth.Session, redirect string) {
+	client := clientIP(r)
+	token, err := auth.SignSession(session, auth.SigningKey{ID: signingKey.ID, Secret: []byte(signingKey.Key)})
 	if err != nil {
 		http.Error(w, "unable to sign session", http.StatusInternalServerError)
 		return
 	}
+	if err := s.sessions.Add(session, client, r.UserAgent()); err != nil {
+		log.Printf("auth session store failed: %v", err)
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
 
-	redirect = auth.SafeRedirect(r, redirect)
 	cookie := buildSessionCookie(r, cfg, token)
 	http.SetCookie(w, &cookie)
-	log.Printf("auth login success user=%s ip=%s", user.Username, client)
+	log.Printf("auth login success user=%s ip=%s", session.Username, client)
+	s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, User: session.Username, Outcome: auth.AuditSuccess, Reason: session.Provider})
 	http.Redirect(w, r, redirect, http.StatusFound)
 }
 
@@ -165,6 +292,15 @@ func (s *server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if cookieName == "" {
 		cookieName = proxy.DefaultAuthCookieName()
 	}
+	username := ""
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		if session, err := auth.VerifySession(cookie.Value, keyring(cfg)); err == nil {
+			username = session.Username
+			if err := s.sessions.RevokeNonce(session.Nonce); err != nil {
+				log.Printf("auth session store failed: %v", err)
+			}
+		}
+	}
 	http.SetCookie(w, &http.Cookie{
 		Name:     cookieName,
 		Value:    "",
@@ -174,6 +310,7 @@ func (s *server) handleLogout(w http.ResponseWriter, r *http.Request) {
 		MaxAge:   -1,
 	})
 	log.Printf("auth logout ip=%s", clientIP(r))
+	s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogout, User: username, Outcome: auth.AuditSuccess})
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
@@ -183,52 +320,124 @@ func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
 		return
 	}
+	if raw, ok := bearerToken(r); ok {
+		s.verifyAPIToken(w, r, cfg, raw)
+		return
+	}
 	cookieName := strings.TrimSpace(cfg.Auth.CookieName)
 	if cookieName == "" {
 		cookieName = proxy.DefaultAuthCookieName()
 	}
+	if !proxy.HasSigningKey(cfg) {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
 	cookie, err := r.Cookie(cookieName)
 	if err != nil || strings.TrimSpace(cookie.Value) == "" {
-		s.redirectToLogin(w, r)
+		s.redirectUnlessShared(w, r, cfg)
 		return
 	}
-	if cfg.Auth.SigningKey == "" {
-		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+	session, err := auth.VerifySession(cookie.Value, keyring(cfg))
+	if err != nil {
+		s.redirectUnlessShared(w, r, cfg)
 		return
 	}
-	session, err := auth.VerifySession(cookie.Value, []byte(cfg.Auth.SigningKey))
-	if err != nil {
-		s.redirectToLogin(w, r)
+	if err := s.sessions.Check(session); err != nil {
+		if errors.Is(err, auth.ErrRevokedSession) {
+			s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditVerify, User: session.Username, Outcome: auth.AuditDenied, Reason: "session revoked"})
+			s.redirectUnlessShared(w, r, cfg)
+			return
+		}
+		log.Printf("auth session store failed: %v", err)
+		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
 		return
 	}
-	if _, ok := findUser(cfg, session.Username); !ok {
-		s.redirectToLogin(w, r)
+	user, ok := sessionUser(cfg, session)
+	if !ok {
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditVerify, User: session.Username, Outcome: auth.AuditDenied, Reason: "unknown user"})
+		s.redirectUnlessShared(w, r, cfg)
 		return
 	}
 	app, ok := appForHost(cfg, forwardedHost(r))
+	if !ok || !appAllowed(cfg, r, app, user) {
+		if !s.verifyShare(w, r, cfg) {
+			s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditVerify, User: user.Username, Outcome: auth.AuditDenied, Reason: "not allowed"})
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+		return
+	}
+	setUserHeaders(w, cfg, user)
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifyAPIToken authorizes a non-browser request. Failures get a plain 401
+// instead of a redirect to the login page.
+func (s *server) verifyAPIToken(w http.ResponseWriter, r *http.Request, cfg proxy.Config, raw string) {
+	user, token, ok := proxy.LookupAPIToken(cfg, raw, time.Now())
 	if !ok {
+		log.Printf("auth token rejected ip=%s", clientIP(r))
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditVerify, Outcome: auth.AuditDenied, Reason: "invalid token"})
+		w.Header().Set("WWW-Authenticate", `Bearer realm="viberun"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	app, ok := appForHost(cfg, forwardedHost(r))
+	if !ok || !token.AllowsApp(app) || !appAllowed(cfg, r, app, user) {
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditVerify, User: user.Username, Outcome: auth.AuditDenied, Reason: "token " + token.ID + " not allowed"})
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
+	setUserHeaders(w, cfg, user)
+	w.WriteHeader(http.StatusOK)
+}
+
+// appAllowed reports whether user may open the forwarded request on app.
+// Extra ports can set their own access mode, so a request for one is
+// checked against the port's mode rather than the app's.
+func appAllowed(cfg proxy.Config, r *http.Request, app string, user proxy.AuthUser) bool {
 	access := proxy.EffectiveAppAccess(cfg, app)
 	if access.Disabled {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return
+		return false
 	}
-	if access.Access == proxy.AccessPrivate {
-		allowed := proxy.EffectiveAllowedUsers(cfg, app)
-		if !userAllowed(allowed, session.Username) {
-			http.Error(w, "forbidden", http.StatusForbidden)
-			return
-		}
+	mode := access.Access
+	if port, ok := portForRequest(cfg, r, app); ok {
+		mode = proxy.EffectivePortAccess(cfg, app, port)
+	}
+	return mode != proxy.AccessPrivate || proxy.UserCanAccess(cfg, app, user)
+}
+
+// portForRequest returns the extra port of app that the forwarded request
+// targets, by sub-host or by path prefix.
+func portForRequest(cfg proxy.Config, r *http.Request, app string) (string, bool) {
+	host := forwardedHost(r)
+	if idx := strings.LastIndex(host, ":"); idx > 0 {
+		host = host[:idx]
 	}
-	w.Header().Set("X-Viberun-User", session.Username)
-	if strings.TrimSpace(cfg.PrimaryUser) != "" && session.Username == cfg.PrimaryUser {
-		w.Header().Set("X-Viberun-Roles", "primary")
-	} else {
-		w.Header().Set("X-Viberun-Roles", "user")
+	if _, port, ok := proxy.AppForPortHost(cfg, host); ok {
+		return port, true
 	}
-	w.WriteHeader(http.StatusOK)
+	uri := strings.TrimSpace(r.Header.Get("X-Forwarded-Uri"))
+	if uri == "" {
+		return "", false
+	}
+	return proxy.PortForPath(cfg, app, uri)
+}
+
+func setUserHeaders(w http.ResponseWriter, cfg proxy.Config, user proxy.AuthUser) {
+	w.Header().Set("X-Viberun-User", user.Username)
+	w.Header().Set("X-Viberun-Roles", strings.Join(proxy.UserRoles(cfg, user), ","))
+}
+
+// bearerToken returns a viberun API token from the Authorization header.
+// Other bearer tokens belong to the app itself and fall through to the
+// session cookie.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, value, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	return value, proxy.IsAPIToken(value)
 }
 
 func (s *server) renderLogin(w http.ResponseWriter, r *http.Request, errMsg string, redirect string) {
@@ -241,6 +450,22 @@ func (s *server) renderLogin(w http.ResponseWriter, r *http.Request, errMsg stri
 		Redirect: redirect,
 		Error:    errMsg,
 	}
+	if cfg, err := s.loadConfig(); err == nil {
+		data.SSO = proxy.OIDCEnabled(cfg)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.loginTmpl.Execute(w, data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+func (s *server) renderChallenge(w http.ResponseWriter, r *http.Request, errMsg string, challenge string, redirect string) {
+	data := loginPageData{
+		Assets:    authPathPrefix + "/assets",
+		Redirect:  auth.SafeRedirect(r, redirect),
+		Error:     errMsg,
+		Challenge: challenge,
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := s.loginTmpl.Execute(w, data); err != nil {
 		http.Error(w, "template error", http.StatusInternalServerError)
@@ -265,6 +490,16 @@ func loadConfig(path string) (proxy.Config, error) {
 	return cfg, err
 }
 
+// keyring returns the keys that may verify a cookie right now.
+func keyring(cfg proxy.Config) auth.Keyring {
+	keys := proxy.VerifySigningKeys(cfg, time.Now())
+	ring := make(auth.Keyring, 0, len(keys))
+	for _, key := range keys {
+		ring = append(ring, auth.SigningKey{ID: key.ID, Secret: []byte(key.Key)})
+	}
+	return ring
+}
+
 func cookieDomain(cfg proxy.Config) string {
 	if value := strings.TrimSpace(cfg.Auth.CookieDomain); value != "" {
 		return value
@@ -343,6 +578,23 @@ func findUser(cfg proxy.Config, username string) (proxy.AuthUser, bool) {
 	return proxy.AuthUser{}, false
 }
 
+// sessionUser resolves the user a session belongs to. Single sign-on users
+// who are not in proxy.toml are rebuilt from the session while OIDC stays
+// configured.
+func sessionUser(cfg proxy.Config, session auth.Session) (proxy.AuthUser, bool) {
+	switch session.Provider {
+	case "":
+		return findUser(cfg, session.Username)
+	case auth.ProviderOIDC:
+		if !proxy.OIDCEnabled(cfg) {
+			return proxy.AuthUser{}, false
+		}
+		return proxy.OIDCUser(cfg, session.Username, session.Groups), true
+	default:
+		return proxy.AuthUser{}, false
+	}
+}
+
 func checkPassword(hash string, password string) bool {
 	hash = strings.TrimSpace(hash)
 	hash = strings.TrimPrefix(hash, "bcrypt:")
@@ -369,6 +621,9 @@ func appForHost(cfg proxy.Config, host string) (string, bool) {
 			return app, true
 		}
 	}
+	if app, _, ok := proxy.AppForPortHost(cfg, host); ok {
+		return app, true
+	}
 	base := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(cfg.BaseDomain), "."))
 	if base == "" {
 		return "", false
@@ -384,15 +639,6 @@ func appForHost(cfg proxy.Config, host string) (string, bool) {
 	return app, true
 }
 
-func userAllowed(users []string, username string) bool {
-	for _, user := range users {
-		if user == username {
-			return true
-		}
-	}
-	return false
-}
-
 type rateLimiter struct {
 	mu      sync.Mutex
 	entries map[string]*rateEntry
@@ -444,6 +690,64 @@ func (r *rateLimiter) Allow(key string) bool {
 	return true
 }
 
+// challengeTracker counts failed codes per challenge nonce. Entries are
+// dropped once their challenge has expired.
+type challengeTracker struct {
+	mu      sync.Mutex
+	entries map[string]*challengeEntry
+	limit   int
+}
+
+type challengeEntry struct {
+	failures  int
+	expiresAt int64
+}
+
+func newChallengeTracker(limit int) *challengeTracker {
+	return &challengeTracker{entries: map[string]*challengeEntry{}, limit: max(limit, 1)}
+}
+
+// Spent reports whether the challenge with nonce may no longer be used.
+func (c *challengeTracker) Spent(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entries[nonce]
+	return entry != nil && entry.failures >= c.limit
+}
+
+// Fail records a wrong code and reports whether the challenge is now spent.
+func (c *challengeTracker) Fail(challenge auth.Challenge) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(challenge)
+	entry.failures++
+	return entry.failures >= c.limit
+}
+
+// Spend marks the challenge as used.
+func (c *challengeTracker) Spend(challenge auth.Challenge) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry(challenge).failures = c.limit
+}
+
+// entry returns the record for challenge, pruning expired ones. The caller
+// holds c.mu.
+func (c *challengeTracker) entry(challenge auth.Challenge) *challengeEntry {
+	now := time.Now().UTC().Unix()
+	for nonce, entry := range c.entries {
+		if entry.expiresAt <= now {
+			delete(c.entries, nonce)
+		}
+	}
+	entry := c.entries[challenge.Nonce]
+	if entry == nil {
+		entry = &challengeEntry{expiresAt: challenge.ExpiresAt}
+		c.entries[challenge.Nonce] = entry
+	}
+	return entry
+}
+
 func clientIP(r *http.Request) string {
 	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
 		parts := strings.Split(forwarded, ",")
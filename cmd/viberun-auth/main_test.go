@@ -0,0 +1,60 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/auth"
+)
+
+func TestChallengeTrackerSpendsAfterFailures(t *testing.T) {
+	tracker := newChallengeTracker(3)
+	challenge, _ := auth.NewChallenge("dave")
+	for i := 1; i <= 3; i++ {
+		if tracker.Spent(challenge.Nonce) {
+			t.Fatalf("challenge spent after %d failures", i-1)
+		}
+		if spent := tracker.Fail(challenge); spent != (i == 3) {
+			t.Fatalf("failure %d: spent = %v", i, spent)
+		}
+	}
+	if !tracker.Spent(challenge.Nonce) {
+		t.Fatal("expected challenge to be spent")
+	}
+
+	used, _ := auth.NewChallenge("dave")
+	tracker.Spend(used)
+	if !tracker.Spent(used.Nonce) {
+		t.Fatal("expected a used challenge to be spent")
+	}
+
+	expired := auth.Challenge{Username: "dave", Nonce: "old", ExpiresAt: time.Now().Add(-time.Minute).Unix()}
+	tracker.Fail(expired)
+	other, _ := auth.NewChallenge("dave")
+	tracker.Fail(other)
+	if _, ok := tracker.entries["old"]; ok {
+		t.Fatal("expected expired challenge to be pruned")
+	}
+}
+
+func TestBearerTokenOnlyTakesAPITokens(t *testing.T) {
+	for header, want := range map[string]bool{
+		"Bearer vbr_abc123":  true,
+		"bearer  vbr_abc123": true,
+		"Bearer eyJhbGciOi":  false,
+		"Basic dmJyXw==":     false,
+		"":                   false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		req.Header.Set("Authorization", header)
+		if _, ok := bearerToken(req); ok != want {
+			t.Fatalf("bearerToken(%q) = %v, want %v", header, ok, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/oidc"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+const oidcFlowCookie = "viberun_oidc"
+
+// oidcCache holds the discovered provider until the OIDC settings in
+// proxy.toml change.
+type oidcCache struct {
+	mu       sync.Mutex
+	settings proxy.OIDCConfig
+	provider *oidc.Provider
+}
+
+func (c *oidcCache) get(ctx context.Context, settings proxy.OIDCConfig) (*oidc.Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil && c.settings.Issuer == settings.Issuer && c.settings.ClientID == settings.ClientID && c.settings.ClientSecret == settings.ClientSecret {
+		return c.provider, nil
+	}
+	provider, err := oidc.Discover(ctx, nil, settings.Issuer, settings.ClientID, settings.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	c.settings = settings
+	c.provider = provider
+	return provider, nil
+}
+
+func (s *server) handleOIDCStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg, err := s.loadConfig()
+	if err != nil {
+		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	redirect := r.URL.Query().Get("redirect")
+	if !proxy.OIDCEnabled(cfg) {
+		http.NotFound(w, r)
+		return
+	}
+	signingKey, ok := proxy.ActiveSigningKey(cfg)
+	if !ok {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	provider, err := s.oidc.get(r.Context(), cfg.Auth.OIDC)
+	if err != nil {
+		log.Printf("auth oidc discovery failed: %v", err)
+		s.renderLogin(w, r, "single sign-on is unavailable", redirect)
+		return
+	}
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		http.Error(w, "unable to start sign-in", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		http.Error(w, "unable to start sign-in", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		http.Error(w, "unable to start sign-in", http.StatusInternalServerError)
+		return
+	}
+	flow := auth.OIDCFlow{
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		Redirect:  oidcRedirectTarget(r, cfg, redirect),
+		ExpiresAt: time.Now().UTC().Add(auth.OIDCFlowTTL).Unix(),
+	}
+	token, err := auth.SignOIDCFlow(flow, auth.SigningKey{ID: signingKey.ID, Secret: []byte(signingKey.Key)})
+	if err != nil {
+		http.Error(w, "unable to start sign-in", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    token,
+		Path:     authPathPrefix + "/oidc",
+		Domain:   cookieDomain(cfg),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   isSecureRequest(r),
+		MaxAge:   int(auth.OIDCFlowTTL / time.Second),
+	})
+	http.Redirect(w, r, provider.AuthCodeURL(oidcCallbackURL(r, cfg), cfg.Auth.OIDC.Scopes, state, nonce, challenge), http.StatusFound)
+}
+
+func (s *server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	client := clientIP(r)
+	cfg, err := s.loadConfig()
+	if err != nil {
+		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !loginLimiter.Allow(client) {
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditRateLimit, Outcome: auth.AuditDenied, Reason: "oidc"})
+		s.renderLogin(w, r, "too many attempts, try again soon", "")
+		return
+	}
+	if !proxy.OIDCEnabled(cfg) {
+		http.NotFound(w, r)
+		return
+	}
+	signingKey, ok := proxy.ActiveSigningKey(cfg)
+	if !ok {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    "",
+		Path:     authPathPrefix + "/oidc",
+		Domain:   cookieDomain(cfg),
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		s.renderLogin(w, r, "sign-in timed out, try again", "")
+		return
+	}
+	flow, err := auth.VerifyOIDCFlow(cookie.Value, keyring(cfg))
+	query := r.URL.Query()
+	if err != nil || query.Get("state") != flow.State {
+		s.renderLogin(w, r, "sign-in timed out, try again", "")
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("auth oidc provider error=%s ip=%s", providerErr, client)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, Outcome: auth.AuditFailure, Reason: "oidc provider error " + providerErr})
+		s.renderLogin(w, r, "single sign-on was cancelled or denied", "")
+		return
+	}
+	provider, err := s.oidc.get(r.Context(), cfg.Auth.OIDC)
+	if err != nil {
+		log.Printf("auth oidc discovery failed: %v", err)
+		s.renderLogin(w, r, "single sign-on is unavailable", "")
+		return
+	}
+	rawIDToken, err := provider.Exchange(r.Context(), query.Get("code"), oidcCallbackURL(r, cfg), flow.Verifier)
+	if err != nil {
+		log.Printf("auth oidc exchange failed ip=%s: %v", client, err)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, Outcome: auth.AuditFailure, Reason: "oidc code exchange failed"})
+		s.renderLogin(w, r, "single sign-on failed", "")
+		return
+	}
+	claims, err := provider.Verify(r.Context(), rawIDToken, flow.Nonce, time.Now())
+	if err != nil {
+		log.Printf("auth oidc verify failed ip=%s: %v", client, err)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, Outcome: auth.AuditFailure, Reason: "oidc id token rejected"})
+		s.renderLogin(w, r, "single sign-on failed", "")
+		return
+	}
+	email := strings.TrimSpace(claims.String("email"))
+	if email == "" || !claims.EmailVerified(cfg.Auth.OIDC.AssumeEmailVerified) {
+		log.Printf("auth oidc login rejected sub=%s ip=%s: no verified email", claims.String("sub"), client)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, User: email, Outcome: auth.AuditFailure, Reason: "oidc email not verified"})
+		s.renderLogin(w, r, "your account has no verified email address", "")
+		return
+	}
+	user := proxy.OIDCUser(cfg, email, claims.Strings(proxy.OIDCGroupsClaim(cfg)))
+	session, err := auth.NewSession(user.Username, parseTTL(cfg.Auth.CookieTTL))
+	if err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
+	session.Provider = auth.ProviderOIDC
+	session.Groups = user.Groups
+	s.issueSession(w, r, cfg, signingKey, session, flow.Redirect)
+}
+
+// oidcCallbackURL is the configured redirect URL, or the callback on the
+// host being visited.
+func oidcCallbackURL(r *http.Request, cfg proxy.Config) string {
+	if value := strings.TrimSpace(cfg.Auth.OIDC.RedirectURL); value != "" {
+		return value
+	}
+	scheme := "http"
+	if isSecureRequest(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + forwardedHost(r) + authPathPrefix + "/oidc/callback"
+}
+
+// oidcRedirectTarget decides where to land after the callback. The callback
+// may be on a different app host than the one the user started from, so an
+// absolute URL is kept when it points at one of our apps.
+func oidcRedirectTarget(r *http.Request, cfg proxy.Config, raw string) string {
+	raw = strings.TrimSpace(raw)
+	if u, err := url.Parse(raw); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+		if _, ok := appForHost(cfg, u.Host); ok {
+			return u.String()
+		}
+	}
+	return auth.SafeRedirect(r, raw)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/oidc/oidctest"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+func TestOIDCLoginAgainstMockIssuer(t *testing.T) {
+	issuer, err := oidctest.NewIssuer("viberun", "s3cret")
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	defer issuer.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "proxy.toml")
+	cfg := proxy.Config{
+		BaseDomain: "example.com",
+		Apps: map[string]proxy.AppAccess{
+			"web":    {Access: proxy.AccessPrivate, AllowedGroups: []string{"team-a"}},
+			"secret": {Access: proxy.AccessPrivate},
+		},
+		Auth: proxy.AuthConfig{
+			SigningKey: "signing-secret",
+			OIDC:       proxy.OIDCConfig{Issuer: issuer.URL(), ClientID: "viberun", ClientSecret: "s3cret"},
+		},
+	}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	s := &server{
+		configPath: configPath,
+		loginTmpl:  template.Must(template.New("login").Parse("{{ .Error }}")),
+		sessions:   auth.NewSessionStore(proxy.SessionStorePath(configPath)),
+		mfa:        auth.NewMFAStore(proxy.MFAStorePath(configPath)),
+	}
+	issuer.SetClaims(map[string]any{"sub": "42", "email": "carol@example.com", "email_verified": true, "groups": []string{"Team-A", "not a group"}})
+
+	start := httptest.NewRequest(http.MethodGet, "https://web.example.com"+authPathPrefix+"/oidc/start?redirect="+url.QueryEscape("https://web.example.com/dash"), nil)
+	start.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	s.handleOIDCStart(rec, start)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("start: status %d body %s", rec.Code, rec.Body)
+	}
+	flowCookie := findCookie(rec.Result().Cookies(), oidcFlowCookie)
+	if flowCookie == nil {
+		t.Fatalf("start: expected flow cookie")
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callbackURL := resp.Header.Get("Location")
+
+	forged := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	rec = httptest.NewRecorder()
+	s.handleOIDCCallback(rec, forged)
+	if findCookie(rec.Result().Cookies(), "viberun_auth") != nil {
+		t.Fatalf("callback without the flow cookie must not log in")
+	}
+
+	callback := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	callback.Header.Set("X-Forwarded-Proto", "https")
+	callback.AddCookie(flowCookie)
+	rec = httptest.NewRecorder()
+	s.handleOIDCCallback(rec, callback)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://web.example.com/dash" {
+		t.Fatalf("callback: status %d location %q body %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	session := findCookie(rec.Result().Cookies(), "viberun_auth")
+	if session == nil {
+		t.Fatalf("callback: expected session cookie")
+	}
+
+	verify := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+authPathPrefix+"/verify", nil)
+		req.Header.Set("X-Forwarded-Host", host)
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		s.handleVerify(rec, req)
+		return rec
+	}
+	rec = verify("web.example.com")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Viberun-User") != "carol@example.com" || rec.Header().Get("X-Viberun-Roles") != "user,team-a" {
+		t.Fatalf("verify web: status %d headers %v", rec.Code, rec.Header())
+	}
+	if rec = verify("secret.example.com"); rec.Code != http.StatusForbidden {
+		t.Fatalf("verify secret: expected 403, got %d", rec.Code)
+	}
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+func TestExtraPortAccess(t *testing.T) {
+	cfg := proxy.Config{
+		BaseDomain:  "example.com",
+		PrimaryUser: "owner",
+		Apps: map[string]proxy.AppAccess{
+			"web": {Access: proxy.AccessPublic, Ports: map[string]proxy.PortRoute{
+				"api":   {Access: proxy.AccessPrivate},
+				"admin": {Path: "/admin", Access: proxy.AccessPrivate},
+			}},
+		},
+	}
+	request := func(host string, uri string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+authPathPrefix+"/verify", nil)
+		req.Header.Set("X-Forwarded-Host", host)
+		req.Header.Set("X-Forwarded-Uri", uri)
+		return req
+	}
+	if app, ok := appForHost(cfg, "api.web.example.com:443"); !ok || app != "web" {
+		t.Fatalf("appForHost sub-host = %q %v", app, ok)
+	}
+	owner := proxy.AuthUser{Username: "owner"}
+	guest := proxy.AuthUser{Username: "guest"}
+	for _, tc := range []struct {
+		host  string
+		uri   string
+		user  proxy.AuthUser
+		allow bool
+	}{
+		{"web.example.com", "/", guest, true},
+		{"web.example.com", "/admin/users", guest, false},
+		{"web.example.com", "/admin/users", owner, true},
+		{"api.web.example.com", "/", guest, false},
+		{"api.web.example.com", "/", owner, true},
+	} {
+		if got := appAllowed(cfg, request(tc.host, tc.uri), "web", tc.user); got != tc.allow {
+			t.Fatalf("appAllowed(%s%s, %s) = %v, want %v", tc.host, tc.uri, tc.user.Username, got, tc.allow)
+		}
+	}
+}
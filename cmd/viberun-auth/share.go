@@ -0,0 +1,121 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+// shareCookie holds a share link session. It is host-only, so unlike the
+// login cookie it is never sent to other apps.
+const shareCookie = "viberun_share"
+
+// handleShare exchanges a share link for a cookie scoped to the app's host.
+func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	client := clientIP(r)
+	cfg, err := s.loadConfig()
+	if err != nil {
+		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !loginLimiter.Allow(client) {
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditRateLimit, Outcome: auth.AuditDenied, Reason: "share"})
+		http.Error(w, "too many attempts, try again soon", http.StatusTooManyRequests)
+		return
+	}
+	signingKey, ok := proxy.ActiveSigningKey(cfg)
+	if !ok {
+		http.Error(w, "auth not configured", http.StatusServiceUnavailable)
+		return
+	}
+	grant, err := auth.VerifyShareGrant(r.URL.Query().Get("t"), keyring(cfg))
+	if err != nil {
+		log.Printf("auth share rejected ip=%s: %v", client, err)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, Outcome: auth.AuditFailure, Reason: "invalid share link"})
+		http.Error(w, "this link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+	app, ok := appForHost(cfg, forwardedHost(r))
+	now := time.Now()
+	link, found := proxy.FindShareLink(cfg, grant.App, grant.ID, now)
+	if !ok || app != grant.App || !found || proxy.EffectiveAppAccess(cfg, app).Disabled {
+		log.Printf("auth share rejected id=%s app=%s ip=%s", grant.ID, grant.App, client)
+		s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, User: "share:" + grant.ID, Outcome: auth.AuditFailure, Reason: "share link revoked or expired"})
+		http.Error(w, "this link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+	session, err := auth.NewSession("share:"+link.ID, time.Until(link.ExpiresAt))
+	if err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
+	session.Provider = auth.ProviderShare
+	session.App = app
+	token, err := auth.SignSession(session, auth.SigningKey{ID: signingKey.ID, Secret: []byte(signingKey.Key)})
+	if err != nil {
+		http.Error(w, "unable to sign session", http.StatusInternalServerError)
+		return
+	}
+	secure := isSecureRequest(r)
+	if cfg.Auth.CookieSecure != nil {
+		secure = *cfg.Auth.CookieSecure
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   secure,
+		Expires:  link.ExpiresAt,
+	})
+	log.Printf("auth share opened id=%s app=%s ip=%s", link.ID, app, client)
+	s.audit(r, cfg, auth.AuditEvent{Event: auth.AuditLogin, User: session.Username, Outcome: auth.AuditSuccess, Reason: auth.ProviderShare})
+	http.Redirect(w, r, link.Path, http.StatusFound)
+}
+
+// verifyShare authorizes a request that carries a share cookie instead of a
+// login. It reports false when there is no usable share cookie, so the
+// caller can fall back to the login redirect.
+func (s *server) verifyShare(w http.ResponseWriter, r *http.Request, cfg proxy.Config) bool {
+	cookie, err := r.Cookie(shareCookie)
+	if err != nil || strings.TrimSpace(cookie.Value) == "" {
+		return false
+	}
+	session, err := auth.VerifySession(cookie.Value, keyring(cfg))
+	if err != nil || session.Provider != auth.ProviderShare {
+		return false
+	}
+	app, ok := appForHost(cfg, forwardedHost(r))
+	if !ok || app != session.App {
+		return false
+	}
+	id := strings.TrimPrefix(session.Username, "share:")
+	if _, found := proxy.FindShareLink(cfg, app, id, time.Now()); !found || proxy.EffectiveAppAccess(cfg, app).Disabled {
+		return false
+	}
+	w.Header().Set("X-Viberun-User", session.Username)
+	w.Header().Set("X-Viberun-Roles", auth.ProviderShare)
+	w.WriteHeader(http.StatusOK)
+	return true
+}
+
+// redirectUnlessShared sends the browser to the login page unless it holds
+// a share cookie for this app.
+func (s *server) redirectUnlessShared(w http.ResponseWriter, r *http.Request, cfg proxy.Config) {
+	if !s.verifyShare(w, r, cfg) {
+		s.redirectToLogin(w, r)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/auth"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+func TestShareLinkOpensOnlyItsApp(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "proxy.toml")
+	cfg := proxy.Config{
+		BaseDomain: "example.com",
+		Apps: map[string]proxy.AppAccess{
+			"web":   {Access: proxy.AccessPrivate},
+			"other": {Access: proxy.AccessPrivate},
+		},
+		Auth: proxy.AuthConfig{SigningKey: "signing-secret"},
+	}
+	link, err := proxy.AddShareLink(&cfg, "web", "/demo", time.Hour, time.Now())
+	if err != nil {
+		t.Fatalf("AddShareLink: %v", err)
+	}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	signingKey, _ := proxy.ActiveSigningKey(cfg)
+	token, err := auth.SignShareGrant(auth.ShareGrant{ID: link.ID, App: "web", ExpiresAt: link.ExpiresAt.Unix()}, auth.SigningKey{ID: signingKey.ID, Secret: []byte(signingKey.Key)})
+	if err != nil {
+		t.Fatalf("SignShareGrant: %v", err)
+	}
+	s := &server{
+		configPath: configPath,
+		loginTmpl:  template.Must(template.New("login").Parse("{{ .Error }}")),
+		sessions:   auth.NewSessionStore(proxy.SessionStorePath(configPath)),
+		mfa:        auth.NewMFAStore(proxy.MFAStorePath(configPath)),
+	}
+
+	open := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "https://"+host+authPathPrefix+"/share?t="+url.QueryEscape(token), nil)
+		req.Header.Set("X-Forwarded-Host", host)
+		rec := httptest.NewRecorder()
+		s.handleShare(rec, req)
+		return rec
+	}
+	if rec := open("other.example.com"); rec.Code != http.StatusForbidden {
+		t.Fatalf("share on other host: expected 403, got %d", rec.Code)
+	}
+	rec := open("web.example.com")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/demo" {
+		t.Fatalf("share: status %d location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookie := findCookie(rec.Result().Cookies(), shareCookie)
+	if cookie == nil || cookie.Domain != "" {
+		t.Fatalf("share: expected host-only cookie, got %+v", cookie)
+	}
+
+	verify := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+authPathPrefix+"/verify", nil)
+		req.Header.Set("X-Forwarded-Host", host)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.handleVerify(rec, req)
+		return rec
+	}
+	if rec := verify("web.example.com"); rec.Code != http.StatusOK || rec.Header().Get("X-Viberun-User") != "share:"+link.ID {
+		t.Fatalf("verify web: status %d headers %v", rec.Code, rec.Header())
+	}
+	if rec := verify("other.example.com"); rec.Code == http.StatusOK {
+		t.Fatalf("verify other: share cookie must not open another app")
+	}
+
+	proxy.RemoveShareLink(&cfg, "web", link.ID)
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	if rec := verify("web.example.com"); rec.Code == http.StatusOK {
+		t.Fatalf("verify after revoke: expected rejection")
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+const (
+	appArchiveVersion      = 1
+	appArchiveManifestName = "viberun-export.json"
+	appArchiveHomePrefix   = "home/"
+)
+
+// appArchiveManifest is the first entry of an export archive. The home
+// subvolume follows under home/, so the archive is plain tar.gz and can be
+// inspected without viberun.
+type appArchiveManifest struct {
+	Version    int               `json:"version"`
+	App        string            `json:"app"`
+	Tag        string            `json:"tag"`
+	Snapshot   snapshotMeta      `json:"snapshot"`
+	Image      string            `json:"image,omitempty"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Limits     *appLimits        `json:"limits,omitempty"`
+	Schedule   *snapshotSchedule `json:"schedule,omitempty"`
+	Proxy      *appArchiveProxy  `json:"proxy,omitempty"`
+}
+
+type appArchiveProxy struct {
+	Access        string   `json:"access,omitempty"`
+	AllowedUsers  []string `json:"allowed_users,omitempty"`
+	AllowedGroups []string `json:"allowed_groups,omitempty"`
+	Disabled      bool     `json:"disabled,omitempty"`
+	CustomDomain  string   `json:"custom_domain,omitempty"`
+}
+
+// buildArchiveManifest collects the app config that travels with a snapshot.
+func buildArchiveManifest(app string, tag string) (appArchiveManifest, error) {
+	manifest := appArchiveManifest{
+		Version:    appArchiveVersion,
+		App:        app,
+		Tag:        tag,
+		Image:      defaultImageRef(),
+		ExportedAt: time.Now().UTC(),
+	}
+	metas, err := readSnapshotMetas(app)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Snapshot = metas[tag]
+	if limits, ok, err := readLimitsFile(appLimitsPath(app)); err != nil {
+		return manifest, err
+	} else if ok {
+		manifest.Limits = &limits
+	}
+	if schedule, ok, err := readSnapshotSchedule(app); err != nil {
+		return manifest, err
+	} else if ok {
+		manifest.Schedule = &schedule
+	}
+	proxyCfg, _, err := proxy.LoadConfig()
+	if err != nil {
+		return manifest, err
+	}
+	if access, ok := proxyCfg.Apps[app]; ok {
+		manifest.Proxy = &appArchiveProxy{
+			Access:        access.Access,
+			AllowedUsers:  access.AllowedUsers,
+			AllowedGroups: access.AllowedGroups,
+			Disabled:      access.Disabled,
+			CustomDomain:  access.CustomDomain,
+		}
+	}
+	return manifest, nil
+}
+
+// exportApp writes a snapshot archive to output, or stdout when output is
+// empty or "-". It returns the number of compressed bytes written.
+func exportApp(app string, tag string, output string) (int64, error) {
+	cfg, ok, err := ensureHomeVolume(app, false)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("app volume does not exist")
+	}
+	if err := ensureSnapshotExists(app, tag); err != nil {
+		return 0, err
+	}
+	manifest, err := buildArchiveManifest(app, tag)
+	if err != nil {
+		return 0, err
+	}
+	if output == "" || output == "-" {
+		counter := &countingWriter{w: os.Stdout}
+		err := writeAppArchive(counter, manifest, snapshotPathForTag(cfg, tag))
+		return counter.n, err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return 0, err
+	}
+	tmp := output + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	counter := &countingWriter{w: file}
+	err = writeAppArchive(counter, manifest, snapshotPathForTag(cfg, tag))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return counter.n, os.Rename(tmp, output)
+}
+
+func writeAppArchive(out io.Writer, manifest appArchiveManifest, root string) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    appArchiveManifestName,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: manifest.ExportedAt,
+		Format:  tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := writeHomeEntries(tw, root); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeHomeEntries(tw *tar.Writer, root string) error {
+	return filepath.WalkDir(root, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// Sockets and devices cannot be recreated meaningfully elsewhere.
+		if info.Mode()&(fs.ModeSocket|fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe) != 0 {
+			return nil
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(current); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = appArchiveHomePrefix + filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uname = ""
+		hdr.Gname = ""
+		hdr.Format = tar.FormatPAX
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(current)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, file)
+		_ = file.Close()
+		return err
+	})
+}
+
+// readArchiveManifest reads the leading manifest entry of an export archive.
+func readArchiveManifest(tr *tar.Reader) (appArchiveManifest, error) {
+	var manifest appArchiveManifest
+	hdr, err := tr.Next()
+	if err != nil {
+		return manifest, fmt.Errorf("invalid archive: %w", err)
+	}
+	if hdr.Name != appArchiveManifestName {
+		return manifest, fmt.Errorf("invalid archive: missing %s", appArchiveManifestName)
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	if manifest.Version > appArchiveVersion {
+		return manifest, fmt.Errorf("archive version %d is newer than this server supports (%d); update viberun", manifest.Version, appArchiveVersion)
+	}
+	return manifest, nil
+}
+
+// extractHomeEntries unpacks the home/ entries into root. Everything is
+// opened through an os.Root, and an entry replaces rather than follows a
+// symlink of the same name, so nothing is written outside root. Entries
+// are owned by uid and gid, whatever the archive claims, when running as
+// root, and setuid and setgid bits are dropped.
+func extractHomeEntries(tr *tar.Reader, root string, uid int, gid int) error {
+	chown := os.Geteuid() == 0
+	home, err := os.OpenRoot(root)
+	if err != nil {
+		return err
+	}
+	defer home.Close()
+	type dirTime struct {
+		name    string
+		modTime time.Time
+	}
+	dirs := []dirTime{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		if !strings.HasPrefix(hdr.Name, appArchiveHomePrefix) {
+			continue
+		}
+		rel := path.Clean(strings.TrimPrefix(hdr.Name, appArchiveHomePrefix))
+		if rel == "." {
+			continue
+		}
+		if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return fmt.Errorf("invalid archive entry: %s", hdr.Name)
+		}
+		name := filepath.FromSlash(rel)
+		mode := hdr.FileInfo().Mode() & (fs.ModePerm | fs.ModeSticky)
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink:
+		default:
+			continue
+		}
+		if err := home.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		if info, err := home.Lstat(name); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if err := home.Remove(name); err != nil {
+				return err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := home.MkdirAll(name, 0o755); err != nil {
+				return err
+			}
+			if err := home.Chmod(name, mode); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTime{name: name, modTime: hdr.ModTime})
+		case tar.TypeReg:
+			file, err := home.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			if err := home.Chmod(name, mode); err != nil {
+				return err
+			}
+			_ = home.Chtimes(name, hdr.ModTime, hdr.ModTime)
+		case tar.TypeSymlink:
+			_ = home.Remove(name)
+			if err := home.Symlink(hdr.Linkname, name); err != nil {
+				return err
+			}
+		}
+		if chown {
+			if err := home.Lchown(name, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = home.Chtimes(dirs[i].name, dirs[i].modTime, dirs[i].modTime)
+	}
+	return nil
+}
+
+// importApp rebuilds an app from an export archive on the host and starts
+// its container.
+func importApp(app string, containerName string, port int, source string) (appArchiveManifest, archiveDrops, error) {
+	var manifest appArchiveManifest
+	var drops archiveDrops
+	cfg := homeVolumeConfigForApp(app)
+	if _, err := os.Stat(cfg.FilePath); err == nil {
+		return manifest, drops, fmt.Errorf("app volume already exists; delete %s first or import under another name", app)
+	} else if !os.IsNotExist(err) {
+		return manifest, drops, err
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return manifest, drops, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return manifest, drops, fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	manifest, err = readArchiveManifest(tr)
+	if err != nil {
+		return manifest, drops, err
+	}
+	uid, gid, err := containerUserIDs(defaultImageRef())
+	if err != nil {
+		return manifest, drops, err
+	}
+	cfg, _, err = ensureHomeVolume(app, true)
+	if err != nil {
+		return manifest, drops, fmt.Errorf("failed to prepare app volume: %w", err)
+	}
+	if err := extractHomeEntries(tr, cfg.MountDir, uid, gid); err != nil {
+		_ = deleteHomeVolume(app)
+		return manifest, drops, fmt.Errorf("failed to extract archive: %w", err)
+	}
+	// The marker was written for the empty volume; reapply ACLs to the
+	// extracted tree.
+	_ = os.Remove(cfg.AclMarker)
+	if err := ensureHomeACL(cfg, uid, gid); err != nil {
+		return manifest, drops, err
+	}
+	drops, err = applyArchiveConfig(app, manifest)
+	if err != nil {
+		return manifest, drops, err
+	}
+	if err := dockerRun(containerName, app, port); err != nil {
+		return manifest, drops, fmt.Errorf("failed to create container: %w", err)
+	}
+	return manifest, drops, nil
+}
+
+// archiveDrops lists the proxy settings from an archive that could not be
+// applied on this host.
+type archiveDrops struct {
+	Users  []string
+	Domain string
+}
+
+func applyArchiveConfig(app string, manifest appArchiveManifest) (archiveDrops, error) {
+	var drops archiveDrops
+	if manifest.Limits != nil {
+		if err := writeLimitsFile(appLimitsPath(app), *manifest.Limits); err != nil {
+			return drops, err
+		}
+	}
+	if manifest.Schedule != nil {
+		if err := writeSnapshotSchedule(app, *manifest.Schedule); err != nil {
+			return drops, err
+		}
+	}
+	if manifest.Proxy == nil {
+		return drops, nil
+	}
+	_, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		if cfg.Apps == nil {
+			cfg.Apps = map[string]proxy.AppAccess{}
+		}
+		access := proxy.AppAccess{
+			Access:        manifest.Proxy.Access,
+			AllowedUsers:  manifest.Proxy.AllowedUsers,
+			AllowedGroups: manifest.Proxy.AllowedGroups,
+			Disabled:      manifest.Proxy.Disabled,
+			CustomDomain:  manifest.Proxy.CustomDomain,
+		}
+		if len(access.AllowedUsers) > 0 {
+			known := map[string]bool{}
+			for _, user := range proxy.Usernames(*cfg) {
+				known[user] = true
+			}
+			kept := make([]string, 0, len(access.AllowedUsers))
+			for _, user := range access.AllowedUsers {
+				if !known[user] {
+					drops.Users = append(drops.Users, user)
+					continue
+				}
+				kept = append(kept, user)
+			}
+			access.AllowedUsers = kept
+		}
+		if access.CustomDomain != "" {
+			for name, other := range cfg.Apps {
+				if name != app && strings.EqualFold(other.CustomDomain, access.CustomDomain) {
+					drops.Domain = access.CustomDomain
+					access.CustomDomain = ""
+					break
+				}
+			}
+		}
+		cfg.Apps[app] = access
+		return nil
+	})
+	return drops, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
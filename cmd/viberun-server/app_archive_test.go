@@ -0,0 +1,214 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+func TestAppArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "app", "data"), 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "app", "data", "db.sqlite"), []byte("rows"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink("app/data/db.sqlite", filepath.Join(src, "db")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	limits := appLimits{Memory: "2g"}
+	manifest := appArchiveManifest{
+		Version:    appArchiveVersion,
+		App:        "myapp",
+		Tag:        "v3",
+		Snapshot:   snapshotMeta{Name: "before-migration"},
+		ExportedAt: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+		Limits:     &limits,
+		Proxy:      &appArchiveProxy{Access: "private", AllowedUsers: []string{"alice"}},
+	}
+	var buf bytes.Buffer
+	if err := writeAppArchive(&buf, manifest, src); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	got, err := readArchiveManifest(tr)
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if got.App != "myapp" || got.Tag != "v3" || got.Snapshot.Name != "before-migration" {
+		t.Fatalf("unexpected manifest: %+v", got)
+	}
+	if got.Limits == nil || got.Limits.Memory != "2g" || got.Proxy == nil || got.Proxy.Access != "private" {
+		t.Fatalf("unexpected manifest config: %+v", got)
+	}
+	dest := t.TempDir()
+	if err := extractHomeEntries(tr, dest, os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "app", "data", "db.sqlite"))
+	if err != nil || string(data) != "rows" {
+		t.Fatalf("unexpected file: %q %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "run.sh"))
+	if err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("unexpected mode: %v %v", info, err)
+	}
+	info, err = os.Stat(filepath.Join(dest, "app", "data"))
+	if err != nil || info.Mode().Perm() != 0o750 {
+		t.Fatalf("unexpected dir mode: %v %v", info, err)
+	}
+	link, err := os.Readlink(filepath.Join(dest, "db"))
+	if err != nil || link != "app/data/db.sqlite" {
+		t.Fatalf("unexpected symlink: %q %v", link, err)
+	}
+}
+
+func TestExtractHomeEntriesRejectsTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	payload := []byte("x")
+	if err := tw.WriteHeader(&tar.Header{Name: "home/../../escape", Mode: 0o644, Size: int64(len(payload)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	_, _ = tw.Write(payload)
+	_ = tw.Close()
+	dest := t.TempDir()
+	if err := extractHomeEntries(tar.NewReader(&buf), dest, os.Getuid(), os.Getgid()); err == nil {
+		t.Fatalf("expected traversal error")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dest), "escape")); err == nil {
+		t.Fatalf("file escaped the destination")
+	}
+}
+
+func TestExtractHomeEntriesRejectsSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "home/link", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0o777})
+	payload := []byte("x")
+	_ = tw.WriteHeader(&tar.Header{Name: "home/link/escape", Mode: 0o644, Size: int64(len(payload)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write(payload)
+	_ = tw.Close()
+	if err := extractHomeEntries(tar.NewReader(&buf), t.TempDir(), os.Getuid(), os.Getgid()); err == nil {
+		t.Fatalf("expected symlink escape error")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escape")); err == nil {
+		t.Fatalf("file escaped through symlink")
+	}
+}
+
+func TestExtractHomeEntriesReplacesSymlinkWithSameName(t *testing.T) {
+	outside := t.TempDir()
+	victim := filepath.Join(outside, "victim")
+	if err := os.WriteFile(victim, []byte("safe"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "home/a", Typeflag: tar.TypeSymlink, Linkname: victim, Mode: 0o777})
+	_ = tw.WriteHeader(&tar.Header{Name: "home/d", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0o777})
+	payload := []byte("pwned")
+	_ = tw.WriteHeader(&tar.Header{Name: "home/a", Mode: 0o4755, Size: int64(len(payload)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write(payload)
+	_ = tw.WriteHeader(&tar.Header{Name: "home/d/", Mode: 0o777, Typeflag: tar.TypeDir})
+	_ = tw.Close()
+	dest := t.TempDir()
+	if err := extractHomeEntries(tar.NewReader(&buf), dest, os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "safe" {
+		t.Fatalf("file outside the home was changed: %q %v", data, err)
+	}
+	if info, err := os.Stat(outside); err != nil || info.Mode().Perm() == 0o777 {
+		t.Fatalf("directory outside the home was changed: %v %v", info, err)
+	}
+	info, err := os.Lstat(filepath.Join(dest, "a"))
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("expected a regular file in place of the link: %v %v", info, err)
+	}
+	if info.Mode()&os.ModeSetuid != 0 || info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected setuid to be dropped, got %v", info.Mode())
+	}
+	if info, err := os.Lstat(filepath.Join(dest, "d")); err != nil || !info.IsDir() {
+		t.Fatalf("expected a directory in place of the link: %v %v", info, err)
+	}
+}
+
+func TestReadArchiveManifestRejectsNewerVersion(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	payload := []byte(`{"version":99,"app":"myapp"}`)
+	_ = tw.WriteHeader(&tar.Header{Name: appArchiveManifestName, Mode: 0o644, Size: int64(len(payload))})
+	_, _ = tw.Write(payload)
+	_ = tw.Close()
+	if _, err := readArchiveManifest(tar.NewReader(&buf)); err == nil {
+		t.Fatalf("expected version error")
+	}
+}
+
+func TestParseActionExportImport(t *testing.T) {
+	for _, args := range [][]string{{"export", "v3"}, {"import", "/tmp/myapp.tar.gz"}} {
+		action, actionArgs, err := parseAction(args)
+		if err != nil {
+			t.Fatalf("parse %v: %v", args, err)
+		}
+		if action != args[0] || len(actionArgs) != 1 || actionArgs[0] != args[1] {
+			t.Fatalf("unexpected action %q args %v", action, actionArgs)
+		}
+	}
+	if _, _, err := parseAction([]string{"export"}); err == nil {
+		t.Fatalf("expected error for export without snapshot")
+	}
+}
+
+func TestApplyArchiveConfigReportsDroppedSettings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "proxy.toml")
+	t.Setenv("VIBERUN_PROXY_CONFIG_PATH", configPath)
+	cfg := proxy.Config{
+		Users: []proxy.AuthUser{{Username: "alice"}},
+		Apps:  map[string]proxy.AppAccess{"other": {CustomDomain: "shop.example.com"}},
+	}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	manifest := appArchiveManifest{Proxy: &appArchiveProxy{
+		Access:       "private",
+		AllowedUsers: []string{"alice", "bob"},
+		CustomDomain: "shop.example.com",
+	}}
+	drops, err := applyArchiveConfig("myapp", manifest)
+	if err != nil {
+		t.Fatalf("applyArchiveConfig: %v", err)
+	}
+	if len(drops.Users) != 1 || drops.Users[0] != "bob" || drops.Domain != "shop.example.com" {
+		t.Fatalf("unexpected drops: %+v", drops)
+	}
+	saved, err := proxy.LoadConfigFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFromPath: %v", err)
+	}
+	if access := saved.Apps["myapp"]; len(access.AllowedUsers) != 1 || access.CustomDomain != "" {
+		t.Fatalf("unexpected imported access: %+v", access)
+	}
+}
@@ -0,0 +1,842 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/hostcmd"
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+const (
+	backupConfigFilename  = "backup.json"
+	backupCatalogFilename = "catalog.json"
+	backupStreamExt       = ".btrfs"
+
+	// autoBackupInterval is how often the snapshot daemon ships new
+	// snapshots when automatic backups are on.
+	autoBackupInterval = 15 * time.Minute
+)
+
+// backupConfig is the host-wide backup target. Backups ship snapshots, so
+// an app is only backed up as often as it is snapshotted.
+type backupConfig struct {
+	Target string `json:"target,omitempty"`
+	Auto   bool   `json:"auto,omitempty"`
+}
+
+// backupCatalog records which snapshots of an app are on the target. It is
+// stored at <target>/<app>/catalog.json next to the streams.
+type backupCatalog struct {
+	App     string        `json:"app"`
+	Entries []backupEntry `json:"entries"`
+}
+
+// backupEntry is one send stream. Parent is empty for a full stream;
+// otherwise the stream only applies on top of the parent's snapshot.
+type backupEntry struct {
+	Tag        string       `json:"tag"`
+	Parent     string       `json:"parent,omitempty"`
+	File       string       `json:"file"`
+	Size       int64        `json:"size"`
+	BackedUpAt time.Time    `json:"backed_up_at"`
+	Meta       snapshotMeta `json:"meta,omitempty"`
+}
+
+// backupPlan is a snapshot to send and the parent it is a delta against.
+type backupPlan struct {
+	Tag    string
+	Parent string
+}
+
+// btrfsSend and btrfsReceive are variables so tests can run backups without
+// btrfs.
+var (
+	btrfsSend = func(snapshot string, parent string, out io.Writer) error {
+		args := []string{"send", "-q"}
+		if parent != "" {
+			args = append(args, "-p", parent)
+		}
+		args = append(args, snapshot)
+		cmd := hostcmd.Run("btrfs", args...)
+		cmd.Stdout = out
+		stderr := &strings.Builder{}
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("btrfs send: %w: %s", err, msg)
+			}
+			return fmt.Errorf("btrfs send: %w", err)
+		}
+		return nil
+	}
+	btrfsReceive = func(dir string, in io.Reader) error {
+		cmd := hostcmd.Run("btrfs", "receive", "-q", dir)
+		cmd.Stdin = in
+		stderr := &strings.Builder{}
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("btrfs receive: %w: %s", err, msg)
+			}
+			return fmt.Errorf("btrfs receive: %w", err)
+		}
+		return nil
+	}
+)
+
+func backupConfigPath() string {
+	return filepath.Join(filepath.Dir(homeVolumeBaseDir), backupConfigFilename)
+}
+
+func readBackupConfig() (backupConfig, error) {
+	path := backupConfigPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return backupConfig{}, nil
+		}
+		return backupConfig{}, err
+	}
+	var cfg backupConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return backupConfig{}, fmt.Errorf("invalid backup config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func writeBackupConfig(cfg backupConfig) error {
+	path := backupConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return os.WriteFile(path, data, 0o644)
+}
+
+// applyBackupSettings parses key=value pairs into cfg. A value of "none" or
+// an empty value clears the target.
+func applyBackupSettings(cfg *backupConfig, settings []string) error {
+	if len(settings) == 0 {
+		return fmt.Errorf("at least one key=value setting is required")
+	}
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q (expected key=value)", setting)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "target":
+			if value == "" || strings.EqualFold(value, "none") {
+				cfg.Target = ""
+				continue
+			}
+			if _, err := parseBackupTarget(value); err != nil {
+				return err
+			}
+			cfg.Target = value
+		case "auto":
+			switch strings.ToLower(value) {
+			case "on", "true", "yes":
+				cfg.Auto = true
+			case "off", "false", "no", "", "none":
+				cfg.Auto = false
+			default:
+				return fmt.Errorf("invalid auto value %q (use on or off)", value)
+			}
+		default:
+			return fmt.Errorf("unknown backup setting %q (use target or auto)", key)
+		}
+	}
+	return nil
+}
+
+func backupCatalogName(app string) string {
+	return path.Join(app, backupCatalogFilename)
+}
+
+func readBackupCatalog(store backupStore, app string) (backupCatalog, error) {
+	catalog := backupCatalog{App: app}
+	data, err := store.Read(backupCatalogName(app))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return catalog, nil
+		}
+		return catalog, fmt.Errorf("failed to read backup catalog: %w", err)
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return catalog, fmt.Errorf("invalid backup catalog for %s: %w", app, err)
+	}
+	for _, entry := range catalog.Entries {
+		if err := checkBackupEntry(entry); err != nil {
+			return catalog, fmt.Errorf("invalid backup catalog for %s: %w", app, err)
+		}
+	}
+	catalog.App = app
+	return catalog, nil
+}
+
+// checkBackupEntry rejects catalog entries whose names could point outside
+// the app's snapshots or backup directory. The catalog lives on the backup
+// target, which may be another host.
+func checkBackupEntry(entry backupEntry) error {
+	if !isCanonicalSnapshotTag(entry.Tag) {
+		return fmt.Errorf("bad snapshot tag %q", entry.Tag)
+	}
+	if entry.Parent != "" && !isCanonicalSnapshotTag(entry.Parent) {
+		return fmt.Errorf("bad parent tag %q for %s", entry.Parent, entry.Tag)
+	}
+	if entry.File != entry.Tag+backupStreamExt {
+		return fmt.Errorf("bad stream file %q for %s", entry.File, entry.Tag)
+	}
+	return nil
+}
+
+// isCanonicalSnapshotTag reports whether tag is exactly vN.
+func isCanonicalSnapshotTag(tag string) bool {
+	n, ok := parseSnapshotTag(tag)
+	return ok && tag == fmt.Sprintf("v%d", n)
+}
+
+func writeBackupCatalog(store backupStore, catalog backupCatalog) error {
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = store.Write(backupCatalogName(catalog.App), strings.NewReader(string(data)))
+	return err
+}
+
+func (c backupCatalog) entry(tag string) (backupEntry, bool) {
+	for _, entry := range c.Entries {
+		if entry.Tag == tag {
+			return entry, true
+		}
+	}
+	return backupEntry{}, false
+}
+
+// planBackups picks the local snapshots missing from the catalog, oldest
+// first. Each is sent as a delta against the newest earlier snapshot that is
+// both on the target and still on this host; otherwise it is sent in full.
+func planBackups(localTags []string, catalog backupCatalog) []backupPlan {
+	tags := versionedTags(localTags)
+	backedUp := map[string]bool{}
+	for _, entry := range catalog.Entries {
+		backedUp[entry.Tag] = true
+	}
+	plans := []backupPlan{}
+	parent := ""
+	for _, tag := range tags {
+		if backedUp[tag] {
+			parent = tag
+			continue
+		}
+		plans = append(plans, backupPlan{Tag: tag, Parent: parent})
+		parent = tag
+	}
+	return plans
+}
+
+// versionedTags returns the vN tags in version order.
+func versionedTags(tags []string) []string {
+	out := []string{}
+	for _, tag := range tags {
+		if _, ok := parseSnapshotTag(tag); ok {
+			out = append(out, tag)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		left, _ := parseSnapshotTag(out[i])
+		right, _ := parseSnapshotTag(out[j])
+		return left < right
+	})
+	return out
+}
+
+// backupChain returns the entries needed to rebuild tag, oldest first.
+func backupChain(catalog backupCatalog, tag string) ([]backupEntry, error) {
+	chain := []backupEntry{}
+	seen := map[string]bool{}
+	for current := tag; current != ""; {
+		if seen[current] {
+			return nil, fmt.Errorf("backup catalog for %s has a parent loop at %s", catalog.App, current)
+		}
+		seen[current] = true
+		entry, ok := catalog.entry(current)
+		if !ok {
+			if current == tag {
+				return nil, fmt.Errorf("snapshot %s is not backed up", tag)
+			}
+			return nil, fmt.Errorf("backup of %s needs %s, which is missing from the catalog", tag, current)
+		}
+		chain = append(chain, entry)
+		current = entry.Parent
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// resolveBackupRef maps "latest", a vN tag, or a snapshot name to a tag in
+// the catalog.
+func resolveBackupRef(catalog backupCatalog, ref string) (string, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if len(catalog.Entries) == 0 {
+		return "", fmt.Errorf("no backups found for %s", catalog.App)
+	}
+	if ref == "" || ref == "latest" {
+		tags := make([]string, 0, len(catalog.Entries))
+		for _, entry := range catalog.Entries {
+			tags = append(tags, entry.Tag)
+		}
+		tags = versionedTags(tags)
+		if len(tags) == 0 {
+			return "", fmt.Errorf("no backups found for %s", catalog.App)
+		}
+		return tags[len(tags)-1], nil
+	}
+	if _, ok := catalog.entry(ref); ok {
+		return ref, nil
+	}
+	for _, entry := range catalog.Entries {
+		if entry.Meta.Name != "" && entry.Meta.Name == ref {
+			return entry.Tag, nil
+		}
+	}
+	return "", fmt.Errorf("snapshot %s is not backed up", ref)
+}
+
+// sendBackups ships planned snapshots and rewrites the catalog after each
+// one, so an interrupted run keeps what it already sent.
+func sendBackups(store backupStore, app string, cfg homeVolumeConfig, plans []backupPlan, metas map[string]snapshotMeta, catalog *backupCatalog) ([]backupEntry, error) {
+	sent := []backupEntry{}
+	for _, plan := range plans {
+		parent := ""
+		if plan.Parent != "" {
+			parent = snapshotPathForTag(cfg, plan.Parent)
+		}
+		file := path.Join(app, plan.Tag+backupStreamExt)
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(btrfsSend(snapshotPathForTag(cfg, plan.Tag), parent, writer))
+		}()
+		size, err := store.Write(file, reader)
+		_ = reader.Close()
+		if err != nil {
+			return sent, fmt.Errorf("failed to send %s: %w", plan.Tag, err)
+		}
+		entry := backupEntry{
+			Tag:        plan.Tag,
+			Parent:     plan.Parent,
+			File:       path.Base(file),
+			Size:       size,
+			BackedUpAt: time.Now().UTC(),
+			Meta:       metas[plan.Tag],
+		}
+		catalog.Entries = append(catalog.Entries, entry)
+		if err := writeBackupCatalog(store, *catalog); err != nil {
+			return sent, fmt.Errorf("failed to update backup catalog: %w", err)
+		}
+		sent = append(sent, entry)
+	}
+	return sent, nil
+}
+
+// backupApp sends every local snapshot of app that the target is missing.
+// It holds the snapshot lock throughout, so retention cannot delete a
+// snapshot mid-send and concurrent runs do not overwrite the catalog.
+func backupApp(store backupStore, app string) ([]backupEntry, error) {
+	unlock, err := lockSnapshots(app)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	infos, err := listSnapshotInfos(app)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(infos))
+	for _, info := range infos {
+		tags = append(tags, info.Tag)
+	}
+	catalog, err := readBackupCatalog(store, app)
+	if err != nil {
+		return nil, err
+	}
+	plans := planBackups(tags, catalog)
+	if len(plans) == 0 {
+		return nil, nil
+	}
+	metas, err := readSnapshotMetas(app)
+	if err != nil {
+		return nil, err
+	}
+	return sendBackups(store, app, homeVolumeConfigForApp(app), plans, metas, &catalog)
+}
+
+// receiveBackupChain replays the chain for tag into the app's snapshots
+// directory and records each snapshot's metadata.
+func receiveBackupChain(store backupStore, app string, cfg homeVolumeConfig, catalog backupCatalog, tag string) ([]backupEntry, error) {
+	chain, err := backupChain(catalog, tag)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockSnapshots(app)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	for _, entry := range chain {
+		if _, err := os.Stat(snapshotPathForTag(cfg, entry.Tag)); err == nil {
+			continue
+		}
+		stream, err := store.Open(path.Join(app, entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open backup of %s: %w", entry.Tag, err)
+		}
+		err = btrfsReceive(cfg.SnapshotsDir, stream)
+		if closeErr := stream.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive %s: %w", entry.Tag, err)
+		}
+		if err := recordSnapshotMeta(app, entry.Tag, entry.Meta); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to record snapshot metadata: %v\n", err)
+		}
+	}
+	return chain, nil
+}
+
+// restoreAppFromBackup rebuilds a missing app from the backup target and
+// starts its container.
+func restoreAppFromBackup(store backupStore, app string, containerName string, port int, ref string) (string, []backupEntry, error) {
+	cfg := homeVolumeConfigForApp(app)
+	if _, err := os.Stat(cfg.FilePath); err == nil {
+		return "", nil, fmt.Errorf("app volume already exists; delete %s first", app)
+	} else if !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	catalog, err := readBackupCatalog(store, app)
+	if err != nil {
+		return "", nil, err
+	}
+	tag, err := resolveBackupRef(catalog, ref)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := backupChain(catalog, tag); err != nil {
+		return "", nil, err
+	}
+	cfg, _, err = ensureHomeVolume(app, true)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to prepare app volume: %w", err)
+	}
+	chain, err := receiveBackupChain(store, app, cfg, catalog, tag)
+	if err != nil {
+		_ = deleteHomeVolume(app)
+		return "", nil, err
+	}
+	if err := restoreHomeVolume(cfg, tag); err != nil {
+		_ = deleteHomeVolume(app)
+		return "", nil, err
+	}
+	_ = os.Remove(cfg.AclMarker)
+	uid, gid, err := containerUserIDs(defaultImageRef())
+	if err != nil {
+		return "", nil, err
+	}
+	if err := ensureHomeACL(cfg, uid, gid); err != nil {
+		return "", nil, err
+	}
+	if err := dockerRun(containerName, app, port); err != nil {
+		return "", nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	return tag, chain, nil
+}
+
+// backupApps lists apps that have a home volume on this host.
+func backupApps() ([]string, error) {
+	entries, err := os.ReadDir(homeVolumeBaseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	apps := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(homeVolumeConfigForApp(entry.Name()).FilePath); err == nil {
+			apps = append(apps, entry.Name())
+		}
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+// backupTargetApps lists apps that have a catalog on the target.
+func backupTargetApps(store backupStore) ([]string, error) {
+	names, err := store.List("")
+	if err != nil {
+		return nil, err
+	}
+	apps := []string{}
+	for _, name := range names {
+		if _, err := store.Read(backupCatalogName(name)); err == nil {
+			apps = append(apps, name)
+		}
+	}
+	return apps, nil
+}
+
+// appBackupStatus merges local snapshots with the catalog, oldest first.
+func appBackupStatus(app string, infos []SnapshotInfo, catalog backupCatalog) serverapi.AppBackupStatus {
+	status := serverapi.AppBackupStatus{App: app, Snapshots: []serverapi.BackupSnapshot{}}
+	byTag := map[string]*serverapi.BackupSnapshot{}
+	tags := []string{}
+	add := func(tag string) *serverapi.BackupSnapshot {
+		if item, ok := byTag[tag]; ok {
+			return item
+		}
+		item := &serverapi.BackupSnapshot{Tag: tag}
+		byTag[tag] = item
+		tags = append(tags, tag)
+		return item
+	}
+	for _, info := range infos {
+		item := add(info.Tag)
+		item.Local = true
+		item.Name = info.Name
+	}
+	for _, entry := range catalog.Entries {
+		item := add(entry.Tag)
+		item.BackedUp = true
+		item.Parent = entry.Parent
+		item.Size = entry.Size
+		item.BackedUpAt = entry.BackedUpAt
+		if item.Name == "" {
+			item.Name = entry.Meta.Name
+		}
+	}
+	for _, tag := range versionedTags(tags) {
+		status.Snapshots = append(status.Snapshots, *byTag[tag])
+	}
+	return status
+}
+
+func backupSnapshotResult(entry backupEntry) serverapi.BackupSnapshot {
+	return serverapi.BackupSnapshot{
+		Tag:        entry.Tag,
+		Name:       entry.Meta.Name,
+		Parent:     entry.Parent,
+		Size:       entry.Size,
+		BackedUpAt: entry.BackedUpAt,
+		Local:      true,
+		BackedUp:   true,
+	}
+}
+
+func writeAppBackupStatus(out io.Writer, status serverapi.AppBackupStatus) {
+	fmt.Fprintf(out, "%s:\n", status.App)
+	if len(status.Snapshots) == 0 {
+		fmt.Fprintln(out, "  no snapshots")
+		return
+	}
+	for _, item := range status.Snapshots {
+		label := item.Tag
+		if item.Name != "" {
+			label = fmt.Sprintf("%s (%s)", item.Tag, item.Name)
+		}
+		state := "not backed up"
+		switch {
+		case item.BackedUp && item.Parent != "":
+			state = fmt.Sprintf("backed up, delta from %s, %s", item.Parent, formatByteSize(item.Size))
+		case item.BackedUp:
+			state = fmt.Sprintf("backed up, full, %s", formatByteSize(item.Size))
+		}
+		if item.BackedUp && !item.Local {
+			state += ", backup only"
+		}
+		fmt.Fprintf(out, "  %s  %s\n", label, state)
+	}
+}
+
+func writeBackupRun(out io.Writer, run serverapi.BackupRun) {
+	for _, app := range run.Apps {
+		if app.Error != "" {
+			fmt.Fprintf(out, "%s: failed: %s\n", app.App, app.Error)
+			continue
+		}
+		if len(app.Sent) == 0 {
+			fmt.Fprintf(out, "%s: up to date\n", app.App)
+			continue
+		}
+		parts := make([]string, 0, len(app.Sent))
+		for _, item := range app.Sent {
+			parts = append(parts, fmt.Sprintf("%s (%s)", item.Tag, formatByteSize(item.Size)))
+		}
+		fmt.Fprintf(out, "%s: sent %s\n", app.App, strings.Join(parts, ", "))
+	}
+}
+
+// runBackups backs up each app and reports failures per app.
+func runBackups(store backupStore, apps []string) serverapi.BackupRun {
+	run := serverapi.BackupRun{Target: store.String(), Apps: []serverapi.AppBackupRun{}}
+	for _, app := range apps {
+		result := serverapi.AppBackupRun{App: app, Sent: []serverapi.BackupSnapshot{}}
+		sent, err := backupApp(store, app)
+		for _, entry := range sent {
+			result.Sent = append(result.Sent, backupSnapshotResult(entry))
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		run.Apps = append(run.Apps, result)
+	}
+	return run
+}
+
+func runFailed(run serverapi.BackupRun) error {
+	failed := []string{}
+	for _, app := range run.Apps {
+		if app.Error != "" {
+			failed = append(failed, app.App)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("backup failed for %s", strings.Join(failed, ", "))
+}
+
+// runScheduledBackups is called by the snapshot daemon when automatic
+// backups are on.
+func runScheduledBackups(out io.Writer) {
+	cfg, err := readBackupConfig()
+	if err != nil {
+		fmt.Fprintf(out, "backup: %v\n", err)
+		return
+	}
+	if !cfg.Auto || strings.TrimSpace(cfg.Target) == "" {
+		return
+	}
+	store, err := parseBackupTarget(cfg.Target)
+	if err != nil {
+		fmt.Fprintf(out, "backup: %v\n", err)
+		return
+	}
+	apps, err := backupApps()
+	if err != nil {
+		fmt.Fprintf(out, "backup: failed to list apps: %v\n", err)
+		return
+	}
+	for _, app := range runBackups(store, apps).Apps {
+		if app.Error != "" {
+			fmt.Fprintf(out, "backup: %s: %s\n", app.App, app.Error)
+			continue
+		}
+		for _, item := range app.Sent {
+			fmt.Fprintf(out, "backup: %s: sent %s\n", app.App, item.Tag)
+		}
+	}
+}
+
+func configuredBackupStore() (backupStore, error) {
+	cfg, err := readBackupConfig()
+	if err != nil {
+		return nil, err
+	}
+	return parseBackupTarget(cfg.Target)
+}
+
+func handleBackupCommand(args []string) error {
+	sub := "show"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch sub {
+	case "show":
+		cfg, err := readBackupConfig()
+		if err != nil {
+			return err
+		}
+		return printResult(serverapi.KindBackupConfig, serverapi.BackupConfig{Target: cfg.Target, Auto: cfg.Auto}, func(out io.Writer) {
+			target := cfg.Target
+			if target == "" {
+				target = "none"
+			}
+			auto := "off"
+			if cfg.Auto {
+				auto = "on"
+			}
+			fmt.Fprintf(out, "Target: %s\n", target)
+			fmt.Fprintf(out, "Automatic: %s\n", auto)
+		})
+	case "set":
+		cfg, err := readBackupConfig()
+		if err != nil {
+			return err
+		}
+		if err := applyBackupSettings(&cfg, args[1:]); err != nil {
+			return err
+		}
+		if err := writeBackupConfig(cfg); err != nil {
+			return fmt.Errorf("failed to save backup config: %w", err)
+		}
+		return printMessage("Backup settings updated.")
+	case "off":
+		cfg, err := readBackupConfig()
+		if err != nil {
+			return err
+		}
+		cfg.Auto = false
+		if err := writeBackupConfig(cfg); err != nil {
+			return fmt.Errorf("failed to save backup config: %w", err)
+		}
+		return printMessage("Automatic backups disabled.")
+	case "run":
+		store, err := configuredBackupStore()
+		if err != nil {
+			return err
+		}
+		apps := args[1:]
+		if len(apps) == 0 {
+			if apps, err = backupApps(); err != nil {
+				return err
+			}
+		}
+		run := runBackups(store, apps)
+		if err := printResult(serverapi.KindBackupRun, run, func(out io.Writer) {
+			writeBackupRun(out, run)
+		}); err != nil {
+			return err
+		}
+		return newSilentError(runFailed(run))
+	case "status":
+		store, err := configuredBackupStore()
+		if err != nil {
+			return err
+		}
+		apps := args[1:]
+		if len(apps) == 0 {
+			local, err := backupApps()
+			if err != nil {
+				return err
+			}
+			remote, err := backupTargetApps(store)
+			if err != nil {
+				return err
+			}
+			apps = mergeAppNames(local, remote)
+		}
+		status := serverapi.BackupStatus{Target: store.String(), Apps: []serverapi.AppBackupStatus{}}
+		for _, app := range apps {
+			item, err := loadAppBackupStatus(store, app)
+			if err != nil {
+				return fmt.Errorf("%s: %w", app, err)
+			}
+			status.Apps = append(status.Apps, item)
+		}
+		return printResult(serverapi.KindBackupStatus, status, func(out io.Writer) {
+			fmt.Fprintf(out, "Target: %s\n", status.Target)
+			for _, item := range status.Apps {
+				writeAppBackupStatus(out, item)
+			}
+		})
+	default:
+		return newUsageError("usage: viberun-server backup [show|set key=value...|off|run [app...]|status [app...]]")
+	}
+}
+
+func loadAppBackupStatus(store backupStore, app string) (serverapi.AppBackupStatus, error) {
+	infos, err := listSnapshotInfos(app)
+	if err != nil {
+		return serverapi.AppBackupStatus{}, err
+	}
+	catalog, err := readBackupCatalog(store, app)
+	if err != nil {
+		return serverapi.AppBackupStatus{}, err
+	}
+	return appBackupStatus(app, infos, catalog), nil
+}
+
+func mergeAppNames(lists ...[]string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, list := range lists {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isBackupRestore reports whether app backup args ask for a restore, which
+// needs the app's port and so runs later than the other backup actions.
+func isBackupRestore(args []string) bool {
+	return len(args) > 0 && strings.EqualFold(strings.TrimSpace(args[0]), "restore")
+}
+
+func handleAppBackupAction(app string, args []string) error {
+	sub := "status"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch sub {
+	case "status":
+		store, err := configuredBackupStore()
+		if err != nil {
+			return err
+		}
+		status, err := loadAppBackupStatus(store, app)
+		if err != nil {
+			return err
+		}
+		return printResult(serverapi.KindBackupStatus, serverapi.BackupStatus{Target: store.String(), Apps: []serverapi.AppBackupStatus{status}}, func(out io.Writer) {
+			fmt.Fprintf(out, "Target: %s\n", store.String())
+			writeAppBackupStatus(out, status)
+		})
+	case "run":
+		store, err := configuredBackupStore()
+		if err != nil {
+			return err
+		}
+		run := runBackups(store, []string{app})
+		if err := printResult(serverapi.KindBackupRun, run, func(out io.Writer) {
+			writeBackupRun(out, run)
+		}); err != nil {
+			return err
+		}
+		return newSilentError(runFailed(run))
+	default:
+		return newUsageError("usage: viberun-server <app> backup [status|run|restore [snapshot]]")
+	}
+}
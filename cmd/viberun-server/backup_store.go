@@ -0,0 +1,236 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sshMissingExit is the exit status the remote read command uses for a
+// missing file, so it can be told apart from ssh failures.
+const sshMissingExit = 3
+
+// backupStore is where backup streams and catalogs are kept. Names are
+// slash-separated paths relative to the target root.
+type backupStore interface {
+	// Read returns the whole file, or an error wrapping os.ErrNotExist.
+	Read(name string) ([]byte, error)
+	// Open streams a file; the caller closes it.
+	Open(name string) (io.ReadCloser, error)
+	// Write stores src under name. The file only appears once complete.
+	Write(name string, src io.Reader) (int64, error)
+	// List returns the entries directly under dir.
+	List(dir string) ([]string, error)
+	String() string
+}
+
+// parseBackupTarget accepts an absolute directory on this host or
+// [user@]host:/path for a host reachable over SSH.
+func parseBackupTarget(target string) (backupStore, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, errors.New("no backup target configured; run `viberun-server backup set target=<dir|host:/path>`")
+	}
+	if strings.HasPrefix(target, "/") {
+		return dirBackupStore{root: filepath.Clean(target)}, nil
+	}
+	host, dir, ok := strings.Cut(target, ":")
+	if !ok || strings.TrimSpace(host) == "" || !strings.HasPrefix(dir, "/") {
+		return nil, fmt.Errorf("invalid backup target %q (use /path or [user@]host:/path)", target)
+	}
+	if strings.ContainsAny(host, " \t/") || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("invalid backup host %q", host)
+	}
+	return sshBackupStore{host: host, root: path.Clean(dir)}, nil
+}
+
+type dirBackupStore struct {
+	root string
+}
+
+func (s dirBackupStore) path(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+func (s dirBackupStore) Read(name string) ([]byte, error) {
+	return os.ReadFile(s.path(name))
+}
+
+func (s dirBackupStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s dirBackupStore) Write(name string, src io.Reader) (int64, error) {
+	dest := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return 0, err
+	}
+	tmp := dest + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(file, src)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return n, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.Remove(tmp)
+		return n, err
+	}
+	return n, nil
+}
+
+func (s dirBackupStore) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(s.path(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s dirBackupStore) String() string {
+	return s.root
+}
+
+type sshBackupStore struct {
+	host string
+	root string
+}
+
+func (s sshBackupStore) path(name string) string {
+	return path.Join(s.root, name)
+}
+
+func (s sshBackupStore) command(script string) *exec.Cmd {
+	return exec.Command("ssh", "-o", "BatchMode=yes", "-o", "LogLevel=ERROR", s.host, script)
+}
+
+func (s sshBackupStore) run(script string, stdin io.Reader) ([]byte, error) {
+	cmd := s.command(script)
+	cmd.Stdin = stdin
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == sshMissingExit {
+			return nil, fmt.Errorf("%s:%s: %w", s.host, script, os.ErrNotExist)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ssh %s: %w: %s", s.host, err, msg)
+		}
+		return nil, fmt.Errorf("ssh %s: %w", s.host, err)
+	}
+	return out, nil
+}
+
+func (s sshBackupStore) Read(name string) ([]byte, error) {
+	p := shellQuote(s.path(name))
+	return s.run(fmt.Sprintf("test -e %s || exit %d; cat %s", p, sshMissingExit, p), nil)
+}
+
+func (s sshBackupStore) Open(name string) (io.ReadCloser, error) {
+	cmd := s.command("cat " + shellQuote(s.path(name)))
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &sshReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// Write uploads to a temp name first and renames in a second call, so an
+// interrupted upload never leaves a complete-looking stream behind.
+func (s sshBackupStore) Write(name string, src io.Reader) (int64, error) {
+	dest := s.path(name)
+	tmp := dest + ".tmp"
+	counter := &countingReader{r: src}
+	script := fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(path.Dir(dest)), shellQuote(tmp))
+	if _, err := s.run(script, counter); err != nil {
+		_, _ = s.run("rm -f "+shellQuote(tmp), nil)
+		return counter.n, err
+	}
+	if _, err := s.run(fmt.Sprintf("mv %s %s", shellQuote(tmp), shellQuote(dest)), nil); err != nil {
+		return counter.n, err
+	}
+	return counter.n, nil
+}
+
+func (s sshBackupStore) List(dir string) ([]string, error) {
+	p := shellQuote(s.path(dir))
+	out, err := s.run(fmt.Sprintf("test -d %s || exit 0; ls -1A %s", p, p), nil)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s sshBackupStore) String() string {
+	return s.host + ":" + s.root
+}
+
+type sshReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (r *sshReader) Close() error {
+	_ = r.ReadCloser.Close()
+	if err := r.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(r.stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
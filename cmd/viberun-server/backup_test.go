@@ -0,0 +1,284 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBackupTarget(t *testing.T) {
+	store, err := parseBackupTarget("/mnt/backup/")
+	if err != nil {
+		t.Fatalf("parse dir target: %v", err)
+	}
+	if _, ok := store.(dirBackupStore); !ok || store.String() != "/mnt/backup" {
+		t.Fatalf("unexpected dir store: %#v", store)
+	}
+	store, err = parseBackupTarget("backup@nas:/srv/viberun")
+	if err != nil {
+		t.Fatalf("parse ssh target: %v", err)
+	}
+	if got, ok := store.(sshBackupStore); !ok || got.host != "backup@nas" || got.root != "/srv/viberun" {
+		t.Fatalf("unexpected ssh store: %#v", store)
+	}
+	for _, target := range []string{"", "relative/dir", "nas:relative", ":/srv", "-oProxyCommand=x:/srv", "two words:/srv"} {
+		if _, err := parseBackupTarget(target); err == nil {
+			t.Fatalf("expected error for %q", target)
+		}
+	}
+}
+
+func TestApplyBackupSettings(t *testing.T) {
+	cfg := backupConfig{}
+	if err := applyBackupSettings(&cfg, []string{"target=nas:/srv/viberun", "auto=on"}); err != nil {
+		t.Fatalf("apply settings: %v", err)
+	}
+	if cfg != (backupConfig{Target: "nas:/srv/viberun", Auto: true}) {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if err := applyBackupSettings(&cfg, []string{"target=none", "auto=off"}); err != nil {
+		t.Fatalf("clear settings: %v", err)
+	}
+	if cfg != (backupConfig{}) {
+		t.Fatalf("expected cleared config, got %+v", cfg)
+	}
+	for _, setting := range []string{"target=relative", "auto=sometimes", "every=1h", "target"} {
+		if err := applyBackupSettings(&cfg, []string{setting}); err == nil {
+			t.Fatalf("expected error for %q", setting)
+		}
+	}
+}
+
+func TestPlanBackups(t *testing.T) {
+	catalog := backupCatalog{App: "myapp", Entries: []backupEntry{{Tag: "v1"}, {Tag: "v3", Parent: "v1"}}}
+	// v2 was pruned locally after v1 and v3 were backed up.
+	got := planBackups([]string{"v10", "v1", "v3", "v4", "snapshot-old"}, catalog)
+	want := []backupPlan{{Tag: "v4", Parent: "v3"}, {Tag: "v10", Parent: "v4"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("planBackups() = %+v, want %+v", got, want)
+	}
+	got = planBackups([]string{"v5", "v6"}, catalog)
+	want = []backupPlan{{Tag: "v5"}, {Tag: "v6", Parent: "v5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected a full stream when no backed-up parent is local, got %+v", got)
+	}
+	if got := planBackups([]string{"v1", "v3"}, catalog); len(got) != 0 {
+		t.Fatalf("expected nothing to send, got %+v", got)
+	}
+}
+
+func TestBackupChain(t *testing.T) {
+	catalog := backupCatalog{App: "myapp", Entries: []backupEntry{
+		{Tag: "v1"},
+		{Tag: "v2", Parent: "v1"},
+		{Tag: "v3", Parent: "v2"},
+		{Tag: "v5", Parent: "v4"},
+		{Tag: "v7", Parent: "v8"},
+		{Tag: "v8", Parent: "v7"},
+	}}
+	chain, err := backupChain(catalog, "v3")
+	if err != nil {
+		t.Fatalf("backupChain: %v", err)
+	}
+	tags := []string{}
+	for _, entry := range chain {
+		tags = append(tags, entry.Tag)
+	}
+	if !reflect.DeepEqual(tags, []string{"v1", "v2", "v3"}) {
+		t.Fatalf("unexpected chain: %v", tags)
+	}
+	if _, err := backupChain(catalog, "v5"); err == nil || !strings.Contains(err.Error(), "needs v4") {
+		t.Fatalf("expected missing parent error, got %v", err)
+	}
+	if _, err := backupChain(catalog, "v7"); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Fatalf("expected loop error, got %v", err)
+	}
+	if _, err := backupChain(catalog, "v9"); err == nil {
+		t.Fatalf("expected error for missing snapshot")
+	}
+}
+
+func TestResolveBackupRef(t *testing.T) {
+	catalog := backupCatalog{App: "myapp", Entries: []backupEntry{
+		{Tag: "v2"},
+		{Tag: "v10", Parent: "v2", Meta: snapshotMeta{Name: "release"}},
+		{Tag: "v9", Parent: "v2"},
+	}}
+	for ref, want := range map[string]string{"": "v10", "latest": "v10", "v9": "v9", "Release": "v10"} {
+		got, err := resolveBackupRef(catalog, ref)
+		if err != nil || got != want {
+			t.Fatalf("resolveBackupRef(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+	if _, err := resolveBackupRef(catalog, "v3"); err == nil {
+		t.Fatalf("expected error for snapshot that is not backed up")
+	}
+	if _, err := resolveBackupRef(backupCatalog{App: "empty"}, "latest"); err == nil {
+		t.Fatalf("expected error for empty catalog")
+	}
+}
+
+func TestDirBackupStore(t *testing.T) {
+	store := dirBackupStore{root: t.TempDir()}
+	if _, err := store.Read("myapp/catalog.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+	n, err := store.Write("myapp/v1.btrfs", strings.NewReader("stream"))
+	if err != nil || n != 6 {
+		t.Fatalf("write: %d %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(store.root, "myapp", "v1.btrfs.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("expected temp file to be renamed, got %v", err)
+	}
+	reader, err := store.Open("myapp/v1.btrfs")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	if string(data) != "stream" {
+		t.Fatalf("unexpected stream data %q", data)
+	}
+	if _, err := store.Write("myapp/v2.btrfs", io.MultiReader(strings.NewReader("partial"), errReader{})); err == nil {
+		t.Fatalf("expected write error")
+	}
+	names, err := store.List("myapp")
+	if err != nil || !reflect.DeepEqual(names, []string{"v1.btrfs"}) {
+		t.Fatalf("expected failed write to leave nothing behind, got %v %v", names, err)
+	}
+	if names, err := store.List("missing"); err != nil || len(names) != 0 {
+		t.Fatalf("expected empty listing, got %v %v", names, err)
+	}
+}
+
+func TestReadBackupCatalogRejectsUnsafeEntries(t *testing.T) {
+	store := dirBackupStore{root: t.TempDir()}
+	good := backupEntry{Tag: "v2", Parent: "v1", File: "v2" + backupStreamExt}
+	for _, bad := range []backupEntry{
+		{Tag: "../v2", File: "../v2" + backupStreamExt},
+		{Tag: "v2/x", File: "v2/x" + backupStreamExt},
+		{Tag: "V2", File: "V2" + backupStreamExt},
+		{Tag: "v2", Parent: "../../v1", File: "v2" + backupStreamExt},
+		{Tag: "v2", File: "../../etc/shadow"},
+		{Tag: "v2", File: "v3" + backupStreamExt},
+	} {
+		catalog := backupCatalog{App: "myapp", Entries: []backupEntry{good, bad}}
+		if err := writeBackupCatalog(store, catalog); err != nil {
+			t.Fatalf("write catalog: %v", err)
+		}
+		if _, err := readBackupCatalog(store, "myapp"); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+	if err := writeBackupCatalog(store, backupCatalog{App: "myapp", Entries: []backupEntry{good}}); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+	if _, err := readBackupCatalog(store, "myapp"); err != nil {
+		t.Fatalf("expected a valid catalog to load: %v", err)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("send failed")
+}
+
+func TestSendAndReceiveBackups(t *testing.T) {
+	root := t.TempDir()
+	origBaseDir := homeVolumeBaseDir
+	homeVolumeBaseDir = filepath.Join(root, "apps")
+	t.Cleanup(func() { homeVolumeBaseDir = origBaseDir })
+	origSend, origReceive := btrfsSend, btrfsReceive
+	t.Cleanup(func() { btrfsSend, btrfsReceive = origSend, origReceive })
+
+	btrfsSend = func(snapshot string, parent string, out io.Writer) error {
+		_, err := fmt.Fprintf(out, "%s<%s", filepath.Base(snapshot), filepath.Base(parent))
+		return err
+	}
+	store := dirBackupStore{root: filepath.Join(root, "target")}
+	cfg := homeVolumeConfigForApp("myapp")
+	catalog := backupCatalog{App: "myapp"}
+	plans := planBackups([]string{"v1", "v2"}, catalog)
+	metas := map[string]snapshotMeta{"v2": {Name: "release", Message: "ship it"}}
+	sent, err := sendBackups(store, "myapp", cfg, plans, metas, &catalog)
+	if err != nil || len(sent) != 2 {
+		t.Fatalf("sendBackups: %+v %v", sent, err)
+	}
+	saved, err := readBackupCatalog(store, "myapp")
+	if err != nil {
+		t.Fatalf("read catalog: %v", err)
+	}
+	if len(saved.Entries) != 2 || saved.Entries[1].Parent != "v1" || saved.Entries[1].Meta.Name != "release" {
+		t.Fatalf("unexpected catalog: %+v", saved)
+	}
+	if data, _ := store.Read("myapp/v2.btrfs"); string(data) != "v2<v1" {
+		t.Fatalf("expected incremental stream, got %q", data)
+	}
+
+	btrfsSend = func(string, string, io.Writer) error { return errors.New("boom") }
+	catalog = saved
+	if _, err := sendBackups(store, "myapp", cfg, []backupPlan{{Tag: "v3", Parent: "v2"}}, nil, &catalog); err == nil {
+		t.Fatalf("expected send error")
+	}
+	if saved, _ := readBackupCatalog(store, "myapp"); len(saved.Entries) != 2 {
+		t.Fatalf("failed send must not be cataloged: %+v", saved.Entries)
+	}
+
+	received := []string{}
+	btrfsReceive = func(dir string, in io.Reader) error {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		tag, _, _ := strings.Cut(string(data), "<")
+		received = append(received, string(data))
+		return os.MkdirAll(filepath.Join(dir, tag), 0o755)
+	}
+	chain, err := receiveBackupChain(store, "myapp", cfg, saved, "v2")
+	if err != nil || len(chain) != 2 {
+		t.Fatalf("receiveBackupChain: %+v %v", chain, err)
+	}
+	if !reflect.DeepEqual(received, []string{"v1<.", "v2<v1"}) {
+		t.Fatalf("unexpected receive order: %v", received)
+	}
+	restored, err := readSnapshotMetas("myapp")
+	if err != nil || restored["v2"].Message != "ship it" {
+		t.Fatalf("expected snapshot metadata to be restored, got %+v %v", restored, err)
+	}
+}
+
+func TestAppBackupStatus(t *testing.T) {
+	infos := []SnapshotInfo{{Tag: "v3"}, {Tag: "v2", Name: "before-upgrade"}}
+	catalog := backupCatalog{App: "myapp", Entries: []backupEntry{
+		{Tag: "v1", Size: 100},
+		{Tag: "v2", Parent: "v1", Size: 10},
+	}}
+	status := appBackupStatus("myapp", infos, catalog)
+	got := []string{}
+	for _, item := range status.Snapshots {
+		got = append(got, fmt.Sprintf("%s local=%v backed_up=%v parent=%s", item.Tag, item.Local, item.BackedUp, item.Parent))
+	}
+	want := []string{
+		"v1 local=false backed_up=true parent=",
+		"v2 local=true backed_up=true parent=v1",
+		"v3 local=true backed_up=false parent=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("appBackupStatus() = %v, want %v", got, want)
+	}
+	var out strings.Builder
+	writeAppBackupStatus(&out, status)
+	if !strings.Contains(out.String(), "v2 (before-upgrade)  backed up, delta from v1") || !strings.Contains(out.String(), "backup only") {
+		t.Fatalf("unexpected status text: %q", out.String())
+	}
+}
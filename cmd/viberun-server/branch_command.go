@@ -11,8 +11,10 @@ import (
 	"strings"
 	"time"
 
+	branchpkg "github.com/shayne/viberun/internal/branch"
 	"github.com/shayne/viberun/internal/proxy"
 	"github.com/shayne/viberun/internal/server"
+	"github.com/shayne/viberun/internal/serverapi"
 )
 
 type branchCommand struct {
@@ -48,8 +50,7 @@ func handleBranchCommand(args []string) error {
 		if err != nil {
 			return err
 		}
-		fmt.Fprintf(os.Stdout, "Created branch %s for %s\n", meta.Branch, meta.BaseApp)
-		return nil
+		return printMessage("Created branch %s for %s", meta.Branch, meta.BaseApp)
 	case "delete", "rm":
 		if cmd.branch == "" {
 			return newUsageError("Usage: viberun-server branch delete <app> <branch>")
@@ -70,6 +71,23 @@ func runBranchList(base string) error {
 	if err != nil {
 		return err
 	}
+	if jsonOutput {
+		baseApp, err := proxy.NormalizeAppName(base)
+		if err != nil {
+			return err
+		}
+		list := serverapi.BranchList{App: baseApp, Branches: []serverapi.BranchInfo{}}
+		for _, meta := range metas {
+			derived, _ := branchpkg.DerivedAppName(baseApp, meta.Branch)
+			list.Branches = append(list.Branches, serverapi.BranchInfo{
+				Branch:          meta.Branch,
+				App:             derived,
+				CreatedAt:       meta.CreatedAt,
+				BaseSnapshotRef: meta.BaseSnapshotRef,
+			})
+		}
+		return writeJSONResult(serverapi.KindBranches, list)
+	}
 	if len(metas) == 0 {
 		baseApp, err := proxy.NormalizeAppName(base)
 		if err != nil {
@@ -132,6 +150,5 @@ func deleteBranchEnv(base string, branch string) error {
 	if err := persistState(statePath, &state, &stateDirty); err != nil {
 		return err
 	}
-	fmt.Fprintf(os.Stdout, "Deleted branch %s for %s\n", branchName, baseApp)
-	return nil
+	return printMessage("Deleted branch %s for %s", branchName, baseApp)
 }
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/shayne/viberun/internal/hostcmd"
@@ -73,3 +74,28 @@ func restoreHomeVolume(cfg homeVolumeConfig, tag string) error {
 	}
 	return ensureSubvolumeMounted(loop, "@home", cfg.MountDir)
 }
+
+// snapshotSize returns the total bytes referenced by a snapshot.
+func snapshotSize(cfg homeVolumeConfig, tag string) (int64, error) {
+	out, err := hostcmd.RunCapture("btrfs", "filesystem", "du", "-s", "--raw", snapshotPathForTag(cfg, tag))
+	if err != nil {
+		return 0, err
+	}
+	return parseBtrfsDuTotal(out)
+}
+
+func parseBtrfsDuTotal(output string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		fields := strings.Fields(lines[i])
+		if len(fields) == 0 {
+			continue
+		}
+		total, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			break
+		}
+		return total, nil
+	}
+	return 0, fmt.Errorf("unexpected btrfs du output: %q", strings.TrimSpace(output))
+}
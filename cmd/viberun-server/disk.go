@@ -0,0 +1,329 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shayne/viberun/internal/hostcmd"
+	"github.com/shayne/viberun/internal/muxrpc"
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+const (
+	// diskWarnPercent is the usage at which the apps table and the tmux
+	// status bar start warning about a home volume.
+	diskWarnPercent = muxrpc.DiskWarnPercent
+	// diskCheckInterval is how often an attached session refreshes
+	// disk.json.
+	diskCheckInterval = 5 * time.Minute
+
+	diskStatusFilename = "disk.json"
+)
+
+var diskSizePattern = regexp.MustCompile(`^(\+)?([0-9]+(?:\.[0-9]+)?)\s*([kmgt]?)(?:i?b)?$`)
+
+// diskStatus is written next to update.json so the tmux status bar inside
+// the container can warn about a full volume.
+type diskStatus struct {
+	UsedPercent int       `json:"used_percent"`
+	Warn        bool      `json:"warn"`
+	CheckedAt   time.Time `json:"checked_at,omitempty"`
+}
+
+type homeVolumeUsage struct {
+	Size int64
+	Used int64
+	Free int64
+}
+
+func usedPercent(used int64, size int64) int {
+	if size <= 0 || used <= 0 {
+		return 0
+	}
+	percent := int(used * 100 / size)
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+// parseBtrfsUsage reads the overall figures from `btrfs filesystem usage -b`.
+func parseBtrfsUsage(output string) (homeVolumeUsage, error) {
+	var usage homeVolumeUsage
+	found := 0
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		number, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "Device size":
+			usage.Size = number
+			found++
+		case "Used":
+			usage.Used = number
+			found++
+		case "Free (estimated)":
+			usage.Free = number
+			found++
+		}
+	}
+	if found < 3 {
+		return usage, fmt.Errorf("unexpected btrfs usage output: %q", strings.TrimSpace(output))
+	}
+	return usage, nil
+}
+
+// parseBtrfsDuExclusive reads the exclusive column from
+// `btrfs filesystem du -s --raw`.
+func parseBtrfsDuExclusive(output string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) >= 2 {
+		if exclusive, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			return exclusive, nil
+		}
+	}
+	return 0, fmt.Errorf("unexpected btrfs du output: %q", strings.TrimSpace(output))
+}
+
+// parseDiskSize parses an absolute size ("200g") or an increment ("+50g")
+// relative to current. Units are binary.
+func parseDiskSize(value string, current int64) (int64, error) {
+	match := diskSizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q (examples: 200g, +50g, 1.5t)", value)
+	}
+	number, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	multiplier := map[string]float64{"": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40}[match[3]]
+	size := int64(number * multiplier)
+	if match[1] == "+" {
+		size += current
+	}
+	return size, nil
+}
+
+// homeVolumeMountedPercent is a cheap usage check for the apps stream. It
+// returns false when the volume is not mounted.
+func homeVolumeMountedPercent(app string) (int, bool) {
+	cfg := homeVolumeConfigForApp(app)
+	if _, ok := mountInfoForTarget(cfg.MountDir); !ok {
+		return 0, false
+	}
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(cfg.MountDir, &stat); err != nil {
+		return 0, false
+	}
+	size := int64(stat.Blocks) * int64(stat.Bsize)
+	used := int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize)
+	return usedPercent(used, size), true
+}
+
+func hostFreeBytes(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+func diskUsageResult(app string) (serverapi.DiskUsage, error) {
+	result := serverapi.DiskUsage{App: app, Snapshots: []serverapi.SnapshotDiskUsage{}}
+	cfg, ok, err := ensureHomeVolume(app, false)
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, fmt.Errorf("app volume does not exist")
+	}
+	out, err := hostcmd.RunCapture("btrfs", "filesystem", "usage", "-b", cfg.RootMountDir)
+	if err != nil {
+		return result, err
+	}
+	usage, err := parseBtrfsUsage(out)
+	if err != nil {
+		return result, err
+	}
+	result.Size = usage.Size
+	result.Used = usage.Used
+	result.Free = usage.Free
+	result.UsedPercent = usedPercent(usage.Used, usage.Size)
+	result.Warn = result.UsedPercent >= diskWarnPercent
+	if free, err := hostFreeBytes(cfg.BaseDir); err == nil {
+		result.HostFree = free
+	}
+	infos, err := listSnapshotInfos(app)
+	if err != nil {
+		return result, err
+	}
+	sortSnapshotInfos(infos)
+	for _, info := range infos {
+		item := serverapi.SnapshotDiskUsage{Tag: info.Tag, Name: info.Name}
+		if out, err := hostcmd.RunCapture("btrfs", "filesystem", "du", "-s", "--raw", snapshotPathForTag(cfg, info.Tag)); err == nil {
+			item.Exclusive, _ = parseBtrfsDuExclusive(out)
+		}
+		result.Snapshots = append(result.Snapshots, item)
+	}
+	_ = writeDiskStatus(app, diskStatus{UsedPercent: result.UsedPercent, Warn: result.Warn, CheckedAt: time.Now().UTC()})
+	return result, nil
+}
+
+func writeDiskUsage(out io.Writer, usage serverapi.DiskUsage) {
+	fmt.Fprintf(out, "Used: %s of %s (%d%%)\n", formatByteSize(usage.Used), formatByteSize(usage.Size), usage.UsedPercent)
+	fmt.Fprintf(out, "Free: %s\n", formatByteSize(usage.Free))
+	if usage.HostFree > 0 {
+		fmt.Fprintf(out, "Host free: %s\n", formatByteSize(usage.HostFree))
+	}
+	if len(usage.Snapshots) > 0 {
+		fmt.Fprintln(out, "Snapshots (exclusive):")
+		for _, item := range usage.Snapshots {
+			label := item.Tag
+			if item.Name != "" {
+				label = fmt.Sprintf("%s (%s)", item.Tag, item.Name)
+			}
+			fmt.Fprintf(out, "  %s  %s\n", label, formatByteSize(item.Exclusive))
+		}
+	}
+	if usage.Warn {
+		fmt.Fprintf(out, "Warning: the volume is %d%% full. Delete snapshots or files, or run `disk grow <size>`.\n", usage.UsedPercent)
+	}
+}
+
+// growHomeVolume extends the sparse file, refreshes the loop device size,
+// and resizes the mounted filesystem online.
+func growHomeVolume(app string, value string) (int64, int64, error) {
+	cfg, ok, err := ensureHomeVolume(app, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !ok {
+		return 0, 0, fmt.Errorf("app volume does not exist")
+	}
+	info, err := os.Stat(cfg.FilePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	oldSize := info.Size()
+	newSize, err := parseDiskSize(value, oldSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if newSize <= oldSize {
+		return oldSize, 0, fmt.Errorf("volume is already %s; grow only increases the size", formatByteSize(oldSize))
+	}
+	loop, err := findLoopDevice(cfg.FilePath)
+	if err != nil {
+		return oldSize, 0, err
+	}
+	if loop == "" {
+		return oldSize, 0, fmt.Errorf("loop device not found for %s", cfg.FilePath)
+	}
+	if err := os.Truncate(cfg.FilePath, newSize); err != nil {
+		return oldSize, 0, err
+	}
+	if err := hostcmd.RunOutput("losetup", "-c", loop); err != nil {
+		return oldSize, 0, fmt.Errorf("failed to refresh loop device: %w", err)
+	}
+	if err := hostcmd.RunOutput("btrfs", "filesystem", "resize", "max", cfg.RootMountDir); err != nil {
+		return oldSize, 0, fmt.Errorf("failed to resize filesystem: %w", err)
+	}
+	return oldSize, newSize, nil
+}
+
+func diskStatusPath(app string) string {
+	return filepath.Join(hostRPCConfigForApp(app).HostDir, diskStatusFilename)
+}
+
+func writeDiskStatus(app string, status diskStatus) error {
+	path := diskStatusPath(app)
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return err
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func checkAndWriteDiskStatus(app string) {
+	percent, ok := homeVolumeMountedPercent(app)
+	if !ok {
+		return
+	}
+	_ = writeDiskStatus(app, diskStatus{UsedPercent: percent, Warn: percent >= diskWarnPercent, CheckedAt: time.Now().UTC()})
+}
+
+// startDiskWatcher keeps disk.json fresh while a session is attached.
+func startDiskWatcher(app string, interval time.Duration) func() {
+	stop := make(chan struct{})
+	go func() {
+		checkAndWriteDiskStatus(app)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				checkAndWriteDiskStatus(app)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+func handleAppDiskAction(app string, args []string) error {
+	sub := "show"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch {
+	case sub == "show" && len(args) <= 1:
+		usage, err := diskUsageResult(app)
+		if err != nil {
+			return fmt.Errorf("failed to read disk usage: %w", err)
+		}
+		return printResult(serverapi.KindDiskUsage, usage, func(out io.Writer) {
+			writeDiskUsage(out, usage)
+		})
+	case sub == "grow" && len(args) == 2:
+		oldSize, newSize, err := growHomeVolume(app, args[1])
+		if err != nil {
+			return fmt.Errorf("failed to grow volume: %w", err)
+		}
+		checkAndWriteDiskStatus(app)
+		return printResult(serverapi.KindDiskGrow, serverapi.DiskGrow{App: app, OldSize: oldSize, NewSize: newSize}, func(out io.Writer) {
+			fmt.Fprintf(out, "Grew %s volume from %s to %s\n", app, formatByteSize(oldSize), formatByteSize(newSize))
+		})
+	default:
+		return newUsageError("usage: viberun-server <app> disk [show|grow <size>]")
+	}
+}
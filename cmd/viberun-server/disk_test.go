@@ -0,0 +1,121 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+func TestParseBtrfsUsage(t *testing.T) {
+	output := `Overall:
+    Device size:                     1000000000000
+    Device allocated:                   2172649472
+    Device unallocated:               997827350528
+    Device missing:                              0
+    Device slack:                                0
+    Used:                                917504000
+    Free (estimated):                 999043457024	(min: 499129782272)
+    Free (statfs, df):                999042408448
+    Data ratio:                               1.00
+    Metadata ratio:                           2.00
+    Global reserve:                        5505024	(used: 0)
+    Multiple profiles:                          no
+
+Data,single: Size:8388608, Used:917504000 (10.94%)
+   /dev/loop3	   8388608
+`
+	usage, err := parseBtrfsUsage(output)
+	if err != nil {
+		t.Fatalf("parseBtrfsUsage: %v", err)
+	}
+	want := homeVolumeUsage{Size: 1000000000000, Used: 917504000, Free: 999043457024}
+	if usage != want {
+		t.Fatalf("parseBtrfsUsage() = %+v, want %+v", usage, want)
+	}
+	if _, err := parseBtrfsUsage("ERROR: not a btrfs filesystem"); err == nil {
+		t.Fatalf("expected error for unexpected output")
+	}
+}
+
+func TestParseBtrfsDuExclusive(t *testing.T) {
+	output := "     Total   Exclusive  Set shared  Filename\n 104857600    20971520    83886080  /var/lib/viberun/apps/myapp/snapshots/v3\n"
+	exclusive, err := parseBtrfsDuExclusive(output)
+	if err != nil || exclusive != 20971520 {
+		t.Fatalf("parseBtrfsDuExclusive() = %d, %v", exclusive, err)
+	}
+	if _, err := parseBtrfsDuExclusive("     Total   Exclusive  Set shared  Filename"); err == nil {
+		t.Fatalf("expected error without a data row")
+	}
+}
+
+func TestParseDiskSize(t *testing.T) {
+	const gib = int64(1) << 30
+	current := 1000 * gib
+	for value, want := range map[string]int64{
+		"2t":    2 << 40,
+		"1.5T":  3 << 39,
+		"+50g":  current + 50*gib,
+		"1200G": 1200 * gib,
+		"512gb": 512 * gib,
+		"4GiB":  4 * gib,
+	} {
+		got, err := parseDiskSize(value, current)
+		if err != nil || got != want {
+			t.Fatalf("parseDiskSize(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "big", "-5g", "10p", "+"} {
+		if _, err := parseDiskSize(value, current); err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+}
+
+func TestUsedPercent(t *testing.T) {
+	for _, tc := range []struct {
+		used, size int64
+		want       int
+	}{
+		{0, 100, 0},
+		{90, 100, 90},
+		{999, 1000, 99},
+		{150, 100, 100},
+		{10, 0, 0},
+	} {
+		if got := usedPercent(tc.used, tc.size); got != tc.want {
+			t.Fatalf("usedPercent(%d, %d) = %d, want %d", tc.used, tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestWriteDiskUsage(t *testing.T) {
+	usage := serverapi.DiskUsage{
+		App:         "myapp",
+		Size:        100 << 30,
+		Used:        95 << 30,
+		Free:        5 << 30,
+		UsedPercent: 95,
+		Warn:        true,
+		HostFree:    40 << 30,
+		Snapshots:   []serverapi.SnapshotDiskUsage{{Tag: "v1", Exclusive: 2 << 30}, {Tag: "v2", Name: "release", Exclusive: 1 << 20}},
+	}
+	var out strings.Builder
+	writeDiskUsage(&out, usage)
+	text := out.String()
+	for _, want := range []string{
+		"Used: 95.0 GiB of 100.0 GiB (95%)",
+		"Host free: 40.0 GiB",
+		"  v1  2.0 GiB",
+		"  v2 (release)  1.0 MiB",
+		"Warning: the volume is 95% full",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in output:\n%s", want, text)
+		}
+	}
+}
@@ -24,7 +24,7 @@ func hasPair(args []string, key string, value string) bool {
 func TestDockerRunArgsIncludesHostRPCMount(t *testing.T) {
 	t.Setenv("VIBERUN_XDG_OPEN_SOCKET", "")
 	cfg := hostRPCConfigForApp("myapp")
-	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test")
+	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test", builtinLimits(), nil)
 
 	homeCfg := homeVolumeConfigForApp("myapp")
 	if !hasPair(args, "-v", fmt.Sprintf("%s:%s", homeCfg.MountDir, "/home/viberun")) {
@@ -64,7 +64,7 @@ func TestDockerRunArgsIncludesForwardAgentSocket(t *testing.T) {
 	t.Setenv("SSH_AUTH_SOCK", socketPath)
 	t.Setenv("VIBERUN_XDG_OPEN_SOCKET", "")
 
-	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test")
+	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test", builtinLimits(), nil)
 	if !hasPair(args, "-v", fmt.Sprintf("%s:%s", socketDir, socketDir)) {
 		t.Fatalf("expected ssh agent socket mount in args: %v", args)
 	}
@@ -15,6 +15,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/shayne/viberun/internal/mux"
 	"github.com/shayne/viberun/internal/muxrpc"
 	"github.com/shayne/viberun/internal/server"
+	"github.com/shayne/viberun/internal/serverapi"
 )
 
 type gatewayServer struct {
@@ -203,7 +205,11 @@ func loadAppSnapshots() ([]muxrpc.AppSnapshot, error) {
 	sort.Strings(names)
 	snapshots := make([]muxrpc.AppSnapshot, 0, len(names))
 	for _, name := range names {
-		snapshots = append(snapshots, muxrpc.AppSnapshot{Name: name, Port: state.Ports[name]})
+		snapshot := muxrpc.AppSnapshot{Name: name, Port: state.Ports[name]}
+		if percent, ok := homeVolumeMountedPercent(name); ok {
+			snapshot.DiskUsedPercent = percent
+		}
+		snapshots = append(snapshots, snapshot)
 	}
 	return snapshots, nil
 }
@@ -304,17 +310,28 @@ func runGateway(agent string) error {
 	server := &gatewayServer{defaultAgent: strings.TrimSpace(agent)}
 	conn := &stdioConn{}
 	m := mux.New(conn, false)
+	m.SetSoftware(versionString())
 	m.Handle("control", server.handleControlStream)
 	m.Handle("open", server.handleOpenStream)
 	m.Handle("apps", server.handleAppsStream)
 	m.Handle("pty", server.handlePtyStream)
 	m.Handle("forward", server.handleForwardStream)
 	m.Handle("upload", server.handleUploadStream)
+	m.Handle("download", server.handleDownloadStream)
+	m.Handle("exec", server.handleExecStream)
+	m.Advertise(muxrpc.CapExecStream, muxrpc.CapCopyStream)
 	m.Run()
+	// A client that vanished without closing SSH (a sleeping laptop) would
+	// otherwise keep its PTYs attached to tmux until TCP gives up.
+	m.Keepalive(gatewayKeepaliveInterval)
 	<-m.Done()
 	return nil
 }
 
+// gatewayKeepaliveInterval is how often the gateway pings the client and
+// how long it waits for the answer.
+const gatewayKeepaliveInterval = 20 * time.Second
+
 type stdioConn struct{}
 
 func (c *stdioConn) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
@@ -341,17 +358,8 @@ func (s *gatewayServer) handleForwardStream(stream *mux.Stream, open mux.StreamO
 		return
 	}
 	defer func() { _ = conn.Close() }()
-	done := make(chan struct{}, 2)
-	go func() {
-		_, _ = io.Copy(conn, stream)
-		done <- struct{}{}
-	}()
-	go func() {
-		_, _ = io.Copy(stream, conn)
-		_ = stream.Close()
-		done <- struct{}{}
-	}()
-	<-done
+	defer func() { _ = stream.Close() }()
+	mux.Pipe(stream, conn)
 }
 
 func (s *gatewayServer) handleUploadStream(stream *mux.Stream, open mux.StreamOpen) {
@@ -470,12 +478,76 @@ func (s *gatewayServer) handleUploadStream(stream *mux.Stream, open mux.StreamOp
 		}
 		sendResult(nil)
 		_ = stream.Close()
+	case "app":
+		handleAppUpload(stream, meta, func(result muxrpc.UploadResult) {
+			if payload, err := json.Marshal(result); err == nil {
+				_ = stream.SendMsg(payload)
+			}
+		})
+		_ = stream.Close()
 	default:
 		sendResult(errors.New("unknown upload target"))
 		_ = stream.Close()
 	}
 }
 
+func (s *gatewayServer) handleDownloadStream(stream *mux.Stream, open mux.StreamOpen) {
+	defer func() { _ = stream.Close() }()
+	sendInfo := func(info muxrpc.DownloadInfo) bool {
+		payload, err := json.Marshal(info)
+		if err != nil {
+			return false
+		}
+		return stream.SendMsg(payload) == nil
+	}
+	var meta muxrpc.DownloadMeta
+	if err := json.Unmarshal(open.Meta, &meta); err != nil {
+		sendInfo(muxrpc.DownloadInfo{Error: err.Error()})
+		return
+	}
+	if strings.ToLower(strings.TrimSpace(meta.Target)) == "app" {
+		handleAppDownload(stream, meta, sendInfo)
+		return
+	}
+	if strings.ToLower(strings.TrimSpace(meta.Target)) != "host" {
+		sendInfo(muxrpc.DownloadInfo{Error: "unknown download target"})
+		return
+	}
+	path := strings.TrimSpace(meta.Path)
+	if path == "" {
+		sendInfo(muxrpc.DownloadInfo{Error: "missing path"})
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		sendInfo(muxrpc.DownloadInfo{Error: err.Error()})
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		sendInfo(muxrpc.DownloadInfo{Error: err.Error()})
+		return
+	}
+	if !info.Mode().IsRegular() {
+		sendInfo(muxrpc.DownloadInfo{Error: "not a regular file"})
+		return
+	}
+	if !sendInfo(muxrpc.DownloadInfo{Size: info.Size()}) {
+		return
+	}
+	if _, err := io.CopyN(stream, file, info.Size()); err != nil {
+		return
+	}
+	// Closing here could drop data still buffered on the client, so wait
+	// for the client to close once it has read Size bytes.
+	for {
+		if _, err := stream.ReceiveMsg(); err != nil {
+			return
+		}
+	}
+}
+
 func runGatewayCommand(params muxrpc.CommandParams) (string, error) {
 	if len(params.Args) == 0 {
 		return "", errors.New("missing command args")
@@ -507,6 +579,21 @@ func runGatewayCommand(params muxrpc.CommandParams) (string, error) {
 	if strings.TrimSpace(params.Input) != "" {
 		cmd.Stdin = strings.NewReader(params.Input)
 	}
+	if slices.Contains(params.Args, serverapi.FlagJSON) {
+		// Keep stderr (progress, warnings) out of the JSON result.
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err != nil && strings.TrimSpace(stdout.String()) == "" {
+			trimmed := strings.TrimSpace(stderr.String())
+			if trimmed == "" {
+				trimmed = err.Error()
+			}
+			return "", fmt.Errorf("%s", trimmed)
+		}
+		return stdout.String(), nil
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		trimmed := strings.TrimSpace(string(output))
@@ -0,0 +1,283 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/shayne/viberun/internal/filecopy"
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+// containerHomeDir anchors relative paths given to app copies.
+const containerHomeDir = "/home/viberun"
+
+// copyContainer returns the running container for an app copy.
+func copyContainer(app string) (string, error) {
+	normalized, err := proxy.NormalizeAppName(app)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("viberun-%s", normalized)
+	running, err := containerRunning(name)
+	if err != nil {
+		return "", fmt.Errorf("app %s does not exist", normalized)
+	}
+	if !running {
+		return "", fmt.Errorf("app %s is not running; start it with `vibe %s`", normalized, normalized)
+	}
+	return name, nil
+}
+
+// containerCopyPath makes p absolute inside the container and splits it
+// into the directory tar runs in and the tree's name within it.
+func containerCopyPath(p string) (string, string) {
+	p = strings.TrimSpace(p)
+	if !path.IsAbs(p) {
+		p = path.Join(containerHomeDir, p)
+	}
+	p = path.Clean(p)
+	if p == "/" {
+		return "/", filecopy.Root
+	}
+	return path.Dir(p), path.Base(p)
+}
+
+// containerTar starts `tar -c` of dir/base in the container as the app
+// user. The caller reads stdout to the end and then calls wait. Hard links
+// are archived as plain files, since the receiving side only writes files,
+// directories and symlinks.
+func containerTar(name string, dir string, base string) (io.Reader, func() error, error) {
+	args := []string{"tar", "--hard-dereference", "-cf", "-", "-C", dir, base}
+	cmd := exec.Command("docker", dockerExecArgs(name, args, false, nil)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	wait := func() error {
+		_, _ = io.Copy(io.Discard, stdout)
+		if err := cmd.Wait(); err != nil {
+			return commandError(err, stderr.String())
+		}
+		return nil
+	}
+	return stdout, wait, nil
+}
+
+func commandError(err error, output string) error {
+	if msg := strings.TrimSpace(output); msg != "" {
+		return errors.New(msg)
+	}
+	return err
+}
+
+// containerIsDir reports whether p is a directory in the container.
+func containerIsDir(name string, p string) bool {
+	return exec.Command("docker", dockerExecArgs(name, []string{"test", "-d", p}, false, nil)...).Run() == nil
+}
+
+// scanContainerTree builds the manifest of dir/base in the container.
+func scanContainerTree(name string, dir string, base string) ([]muxrpc.CopyEntry, error) {
+	stdout, wait, err := containerTar(name, dir, base)
+	if err != nil {
+		return nil, err
+	}
+	entries, scanErr := filecopy.ScanTar(stdout, base)
+	if err := wait(); err != nil {
+		return nil, err
+	}
+	return entries, scanErr
+}
+
+// handleAppDownload sends a container file or directory as a tar archive.
+// The tree is read twice: once to hash it against the client's manifest
+// and once to send what changed.
+func handleAppDownload(stream *mux.Stream, meta muxrpc.DownloadMeta, sendInfo func(muxrpc.DownloadInfo) bool) {
+	have, err := filecopy.ReceiveManifest(stream)
+	if err != nil {
+		sendInfo(muxrpc.DownloadInfo{Error: err.Error()})
+		return
+	}
+	name, err := copyContainer(meta.App)
+	if err != nil {
+		sendInfo(muxrpc.DownloadInfo{Error: err.Error()})
+		return
+	}
+	dir, base := containerCopyPath(meta.Path)
+	entries, err := scanContainerTree(name, dir, base)
+	if err != nil {
+		sendInfo(muxrpc.DownloadInfo{Error: err.Error()})
+		return
+	}
+	haveIndex := filecopy.Index(have)
+	send := map[string]bool{}
+	info := muxrpc.DownloadInfo{}
+	for _, entry := range entries {
+		if entry.Path == filecopy.Root {
+			info.Dir = fs.FileMode(entry.Mode).IsDir()
+		}
+		if entry.SHA256 == "" {
+			continue
+		}
+		if filecopy.Unchanged(haveIndex, entry) {
+			info.Skipped++
+			continue
+		}
+		send[entry.Path] = true
+		info.Files++
+		info.Size += entry.Size
+	}
+	if !sendInfo(info) {
+		return
+	}
+	stdout, wait, err := containerTar(name, dir, base)
+	if err != nil {
+		return
+	}
+	defer func() { _ = wait() }()
+	tr := tar.NewReader(stdout)
+	tw := tar.NewWriter(stream)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return
+		}
+		rel, ok := filecopy.TrimRoot(hdr.Name, base)
+		if !ok {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeReg && !send[rel] {
+			continue
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return
+	}
+	// As with host downloads, the client closes once it has read it all.
+	for {
+		if _, err := stream.ReceiveMsg(); err != nil {
+			return
+		}
+	}
+}
+
+// handleAppUpload extracts a tar archive from the client into a container.
+// Like cp, an existing directory at Path receives the tree under the
+// source's name; otherwise the tree becomes Path.
+func handleAppUpload(stream *mux.Stream, meta muxrpc.UploadMeta, sendResult func(muxrpc.UploadResult)) {
+	fail := func(err error) { sendResult(muxrpc.UploadResult{Error: err.Error()}) }
+	name, err := copyContainer(meta.App)
+	if err != nil {
+		fail(err)
+		return
+	}
+	dir, base := containerCopyPath(meta.Path)
+	if sourceName := path.Base(strings.TrimSpace(meta.Name)); sourceName != "." && sourceName != "/" {
+		if containerIsDir(name, path.Join(dir, base)) {
+			dir, base = path.Join(dir, base), sourceName
+		}
+	}
+	if base == filecopy.Root {
+		fail(errors.New("cannot replace /; copy into a directory under it"))
+		return
+	}
+	// A destination that is missing or unreadable has nothing to skip.
+	entries, err := scanContainerTree(name, dir, base)
+	if err != nil {
+		entries = nil
+	}
+	if err := filecopy.SendManifest(stream, entries); err != nil {
+		return
+	}
+
+	cmd := exec.Command("docker", dockerExecArgs(name, []string{"tar", "-xpf", "-", "-C", dir}, false, nil)...)
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		fail(err)
+		return
+	}
+	stats, copyErr := rewriteUploadArchive(tar.NewReader(stream), tar.NewWriter(stdin), base)
+	_ = stdin.Close()
+	waitErr := cmd.Wait()
+	switch {
+	case copyErr != nil:
+		fail(copyErr)
+	case waitErr != nil:
+		fail(commandError(waitErr, output.String()))
+	default:
+		sendResult(muxrpc.UploadResult{Files: stats.Files, Bytes: stats.Bytes})
+	}
+}
+
+// rewriteUploadArchive moves the client's entries under base, which also
+// keeps them inside the extraction directory.
+func rewriteUploadArchive(tr *tar.Reader, tw *tar.Writer, base string) (filecopy.Stats, error) {
+	var stats filecopy.Stats
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return stats, tw.Close()
+		}
+		if err != nil {
+			return stats, err
+		}
+		rel, err := filecopy.CleanName(hdr.Name)
+		if err != nil {
+			return stats, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+		default:
+			continue
+		}
+		hdr.Name = path.Join(base, rel)
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return stats, err
+		}
+		n, err := io.Copy(tw, tr)
+		if err != nil {
+			return stats, err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			stats.Files++
+			stats.Bytes += n
+		}
+	}
+}
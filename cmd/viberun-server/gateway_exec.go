@@ -0,0 +1,300 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/creack/pty"
+
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+const (
+	execNotStartedCode = 127
+	execSignalBase     = 128
+	// execStderrChunk caps the stderr carried by one ExecEvent.
+	execStderrChunk = 32 * 1024
+)
+
+var execSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// handleExecStream runs one command and streams its output. Stdin and
+// stdout are stream data, stderr and the exit status are ExecEvent
+// messages, and the client sends signals and resizes as ExecInput
+// messages.
+func (s *gatewayServer) handleExecStream(stream *mux.Stream, open mux.StreamOpen) {
+	defer func() { _ = stream.Close() }()
+	var meta muxrpc.ExecMeta
+	if err := json.Unmarshal(open.Meta, &meta); err != nil {
+		sendExecExit(stream, muxrpc.ExecExit{Code: execNotStartedCode, Error: err.Error()})
+		return
+	}
+	target, err := gatewayExecCommand(meta)
+	if err != nil {
+		sendExecExit(stream, muxrpc.ExecExit{Code: execNotStartedCode, Error: err.Error()})
+		return
+	}
+	defer target.cleanup()
+	run := runExecPipes
+	if meta.TTY {
+		run = runExecTTY
+	}
+	sendExecExit(stream, run(target, stream, meta))
+}
+
+// execCommand is a command plus how to signal it. Host commands get their
+// own process group; app commands are signalled inside the container, since
+// docker exec does not forward signals from the CLI.
+type execCommand struct {
+	cmd     *exec.Cmd
+	signal  func(syscall.Signal)
+	cleanup func()
+}
+
+// gatewayExecCommand builds the command for meta. App commands run as the
+// container user through viberun-env, like agent sessions.
+func gatewayExecCommand(meta muxrpc.ExecMeta) (*execCommand, error) {
+	if len(meta.Args) == 0 {
+		return nil, errors.New("missing exec args")
+	}
+	if strings.TrimSpace(meta.App) == "" {
+		target := &execCommand{cmd: exec.Command(meta.Args[0], meta.Args[1:]...), cleanup: func() {}}
+		target.cmd.Env = mergeExecEnv(os.Environ(), meta.Env)
+		target.signal = func(sig syscall.Signal) {
+			if target.cmd.Process != nil {
+				_ = syscall.Kill(-target.cmd.Process.Pid, sig)
+			}
+		}
+		return target, nil
+	}
+	app, err := proxy.NormalizeAppName(meta.App)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("viberun-%s", app)
+	running, err := containerRunning(name)
+	if err != nil {
+		return nil, fmt.Errorf("app %s does not exist", app)
+	}
+	if !running {
+		return nil, fmt.Errorf("app %s is not running; start it with `vibe %s`", app, app)
+	}
+	id, err := randomExecID()
+	if err != nil {
+		return nil, err
+	}
+	pidFile := "/tmp/viberun-exec-" + id + ".pid"
+	// The shell records its pid and then becomes the command, so the pid
+	// file names the process to signal.
+	command := append([]string{"sh", "-c", `echo $$ > "$0"; exec "$@"`, pidFile}, wrapWithEnv(meta.Args)...)
+	cmd := exec.Command("docker", dockerExecArgs(name, command, meta.TTY, meta.Env)...)
+	cmd.Env = os.Environ()
+	return &execCommand{
+		cmd: cmd,
+		signal: func(sig syscall.Signal) {
+			script := fmt.Sprintf(`kill -s %s "$(cat "$0")"`, execSignalName(sig))
+			_ = exec.Command("docker", dockerExecArgs(name, []string{"sh", "-c", script, pidFile}, false, nil)...).Run()
+		},
+		cleanup: func() {
+			_ = exec.Command("docker", dockerExecArgs(name, []string{"rm", "-f", pidFile}, false, nil)...).Run()
+		},
+	}, nil
+}
+
+func randomExecID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate exec id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func execSignalName(sig syscall.Signal) string {
+	for name, candidate := range execSignals {
+		if candidate == sig {
+			return name
+		}
+	}
+	return "TERM"
+}
+
+func mergeExecEnv(base []string, extra map[string]string) []string {
+	if len(extra) == 0 {
+		return base
+	}
+	env := map[string]string{}
+	for _, entry := range base {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+	for key, value := range extra {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		env[key] = value
+	}
+	merged := make([]string, 0, len(env))
+	for key, value := range env {
+		merged = append(merged, key+"="+value)
+	}
+	return merged
+}
+
+func runExecPipes(target *execCommand, stream *mux.Stream, _ muxrpc.ExecMeta) muxrpc.ExecExit {
+	cmd := target.cmd
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return muxrpc.ExecExit{Code: execNotStartedCode, Error: err.Error()}
+	}
+	cmd.Stdout = stream
+	cmd.Stderr = execStderr{stream: stream}
+	if err := cmd.Start(); err != nil {
+		return muxrpc.ExecExit{Code: execNotStartedCode, Error: err.Error()}
+	}
+	inputs := newExecInputs(stdin, target.signal, nil)
+	go inputs.run(stream)
+	err = cmd.Wait()
+	// Unblock a stdin write to a command that exited without reading.
+	_ = stdin.Close()
+	return execExitFor(err)
+}
+
+func runExecTTY(target *execCommand, stream *mux.Stream, meta muxrpc.ExecMeta) muxrpc.ExecExit {
+	cmd := target.cmd
+	var size *pty.Winsize
+	if meta.Rows > 0 && meta.Cols > 0 {
+		size = &pty.Winsize{Rows: uint16(meta.Rows), Cols: uint16(meta.Cols)}
+	}
+	ptmx, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return muxrpc.ExecExit{Code: execNotStartedCode, Error: err.Error()}
+	}
+	defer func() { _ = ptmx.Close() }()
+	inputs := newExecInputs(ptmx, target.signal, func(rows int, cols int) {
+		_ = pty.Setsize(ptmx, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+	})
+	// A TTY has no end of input short of closing it, so stdin stays open.
+	inputs.keepStdin = true
+	go inputs.run(stream)
+	copyDone := make(chan struct{})
+	go func() {
+		defer close(copyDone)
+		_, _ = io.Copy(stream, ptmx)
+	}()
+	err = cmd.Wait()
+	// The pty reports EIO once the command and its children are gone.
+	<-copyDone
+	return execExitFor(err)
+}
+
+// execInputs feeds a running command from the client. Stdin is copied
+// from the stream data on its own goroutine, so a command that does not
+// read only holds back the client's stdin, not its signals.
+type execInputs struct {
+	stdin     io.WriteCloser
+	signal    func(syscall.Signal)
+	resize    func(rows int, cols int)
+	keepStdin bool
+}
+
+func newExecInputs(stdin io.WriteCloser, signal func(syscall.Signal), resize func(int, int)) *execInputs {
+	return &execInputs{
+		stdin:  stdin,
+		signal: signal,
+		resize: resize,
+	}
+}
+
+func (in *execInputs) run(stream *mux.Stream) {
+	go func() {
+		// The client half-closes the stream when its stdin ends.
+		_, _ = io.Copy(in.stdin, stream)
+		if !in.keepStdin {
+			_ = in.stdin.Close()
+		}
+	}()
+	for {
+		msg, err := stream.ReceiveMsg()
+		if err != nil {
+			return
+		}
+		var input muxrpc.ExecInput
+		if err := json.Unmarshal(msg, &input); err != nil {
+			continue
+		}
+		if sig, ok := execSignals[strings.ToUpper(strings.TrimPrefix(input.Signal, "SIG"))]; ok && in.signal != nil {
+			in.signal(sig)
+		}
+		if input.Rows > 0 && input.Cols > 0 && in.resize != nil {
+			in.resize(input.Rows, input.Cols)
+		}
+	}
+}
+
+// execStderr forwards stderr writes as exec events of at most
+// execStderrChunk bytes each.
+type execStderr struct {
+	stream *mux.Stream
+}
+
+func (w execStderr) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), execStderrChunk)
+		payload, err := json.Marshal(muxrpc.ExecEvent{Stderr: p[:n]})
+		if err != nil {
+			return written, err
+		}
+		if err := w.stream.SendMsg(payload); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func execExitFor(err error) muxrpc.ExecExit {
+	if err == nil {
+		return muxrpc.ExecExit{}
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return muxrpc.ExecExit{Code: execNotStartedCode, Error: err.Error()}
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		sig := status.Signal()
+		return muxrpc.ExecExit{Code: execSignalBase + int(sig), Signal: sig.String()}
+	}
+	return muxrpc.ExecExit{Code: exitErr.ExitCode()}
+}
+
+func sendExecExit(stream *mux.Stream, exit muxrpc.ExecExit) {
+	payload, err := json.Marshal(muxrpc.ExecEvent{Exit: &exit})
+	if err != nil {
+		return
+	}
+	_ = stream.SendMsg(payload)
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+func openTestDownload(t *testing.T, meta muxrpc.DownloadMeta) (*mux.Stream, muxrpc.DownloadInfo) {
+	t.Helper()
+	left, right := net.Pipe()
+	t.Cleanup(func() {
+		_ = left.Close()
+		_ = right.Close()
+	})
+	server := &gatewayServer{}
+	serverMux := mux.New(right, false)
+	serverMux.Handle("download", server.handleDownloadStream)
+	serverMux.Run()
+	clientMux := mux.New(left, true)
+	clientMux.Run()
+	stream, err := clientMux.OpenStream("download", meta)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	msg, err := stream.ReceiveMsg()
+	if err != nil {
+		t.Fatalf("receive info: %v", err)
+	}
+	var info muxrpc.DownloadInfo
+	if err := json.Unmarshal(msg, &info); err != nil {
+		t.Fatalf("decode info: %v", err)
+	}
+	return stream, info
+}
+
+func TestGatewayDownloadStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.tar.gz")
+	payload := make([]byte, 200*1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(path, payload, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	stream, info := openTestDownload(t, muxrpc.DownloadMeta{Target: "host", Path: path})
+	defer func() { _ = stream.Close() }()
+	if info.Error != "" || info.Size != int64(len(payload)) {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	got := make([]byte, info.Size)
+	if _, err := io.ReadFull(stream, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	for i := range payload {
+		if got[i] != payload[i] {
+			t.Fatalf("payload mismatch at %d", i)
+		}
+	}
+}
+
+func TestGatewayDownloadStreamMissingFile(t *testing.T) {
+	stream, info := openTestDownload(t, muxrpc.DownloadMeta{Target: "host", Path: filepath.Join(t.TempDir(), "missing")})
+	defer func() { _ = stream.Close() }()
+	if info.Error == "" {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+// runTestExec runs meta over an exec stream, feeding stdin and then
+// closing it, and collects what the command wrote.
+func runTestExec(t *testing.T, meta muxrpc.ExecMeta, stdin string, inputs ...muxrpc.ExecInput) (string, string, muxrpc.ExecExit) {
+	t.Helper()
+	left, right := net.Pipe()
+	t.Cleanup(func() {
+		_ = left.Close()
+		_ = right.Close()
+	})
+	server := &gatewayServer{}
+	serverMux := mux.New(right, false)
+	serverMux.Handle("exec", server.handleExecStream)
+	serverMux.Run()
+	clientMux := mux.New(left, true)
+	clientMux.Run()
+	if err := clientMux.Handshake(2 * time.Second); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	stream, err := clientMux.OpenStream("exec", meta)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	send := func(input muxrpc.ExecInput) {
+		payload, _ := json.Marshal(input)
+		if err := stream.SendMsg(payload); err != nil {
+			t.Fatalf("send input: %v", err)
+		}
+	}
+	if stdin != "" {
+		if _, err := stream.Write([]byte(stdin)); err != nil {
+			t.Fatalf("write stdin: %v", err)
+		}
+	}
+	if err := stream.CloseWrite(); err != nil {
+		t.Fatalf("close stdin: %v", err)
+	}
+	// Inputs still reach the command after its stdin is closed.
+	for _, input := range inputs {
+		send(input)
+	}
+	stdout := make(chan string, 1)
+	go func() {
+		out, _ := io.ReadAll(stream)
+		stdout <- string(out)
+	}()
+	var stderr []byte
+	for {
+		msg, err := stream.ReceiveMsg()
+		if err != nil {
+			t.Fatalf("receive event: %v", err)
+		}
+		var event muxrpc.ExecEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		stderr = append(stderr, event.Stderr...)
+		if event.Exit != nil {
+			return <-stdout, string(stderr), *event.Exit
+		}
+	}
+}
+
+func TestGatewayExecStreamSeparatesOutput(t *testing.T) {
+	script := `read line; echo "out:$line"; echo "err:$line" >&2; exit 3`
+	stdout, stderr, exit := runTestExec(t, muxrpc.ExecMeta{Args: []string{"sh", "-c", script}}, "hello\n")
+	if stdout != "out:hello\n" {
+		t.Fatalf("unexpected stdout: %q", stdout)
+	}
+	if stderr != "err:hello\n" {
+		t.Fatalf("unexpected stderr: %q", stderr)
+	}
+	if exit.Code != 3 || exit.Signal != "" || exit.Error != "" {
+		t.Fatalf("unexpected exit: %+v", exit)
+	}
+}
+
+func TestGatewayExecStreamSignal(t *testing.T) {
+	_, _, exit := runTestExec(t, muxrpc.ExecMeta{Args: []string{"sleep", "30"}}, "", muxrpc.ExecInput{Signal: "TERM"})
+	if exit.Code != 128+15 || exit.Signal == "" {
+		t.Fatalf("unexpected exit: %+v", exit)
+	}
+}
+
+func TestGatewayExecStreamMissingCommand(t *testing.T) {
+	_, _, exit := runTestExec(t, muxrpc.ExecMeta{Args: []string{filepath.Join(t.TempDir(), "missing")}}, "")
+	if exit.Code != 127 || exit.Error == "" {
+		t.Fatalf("unexpected exit: %+v", exit)
+	}
+}
+
+func TestContainerCopyPath(t *testing.T) {
+	cases := []struct {
+		path string
+		dir  string
+		base string
+	}{
+		{"/srv/data/", "/srv", "data"},
+		{"src", "/home/viberun", "src"},
+		{"", "/home", "viberun"},
+		{"/", "/", "."},
+	}
+	for _, tc := range cases {
+		dir, base := containerCopyPath(tc.path)
+		if dir != tc.dir || base != tc.base {
+			t.Fatalf("containerCopyPath(%q) = %q, %q", tc.path, dir, base)
+		}
+	}
+}
+
+func TestRewriteUploadArchive(t *testing.T) {
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	for _, hdr := range []*tar.Header{
+		{Name: ".", Mode: 0o755, Typeflag: tar.TypeDir},
+		{Name: "a/b.txt", Mode: 0o644, Typeflag: tar.TypeReg, Size: 3},
+		{Name: "fifo", Mode: 0o644, Typeflag: tar.TypeFifo},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("header: %v", err)
+		}
+		if hdr.Size > 0 {
+			_, _ = tw.Write([]byte("abc"))
+		}
+	}
+	_ = tw.Close()
+
+	var out bytes.Buffer
+	stats, err := rewriteUploadArchive(tar.NewReader(&in), tar.NewWriter(&out), "site")
+	if err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if stats.Files != 1 || stats.Bytes != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	var names []string
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	if strings.Join(names, ",") != "site/,site/a/b.txt" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	in.Reset()
+	tw = tar.NewWriter(&in)
+	_ = tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0o644, Typeflag: tar.TypeReg})
+	_ = tw.Close()
+	if _, err := rewriteUploadArchive(tar.NewReader(&in), tar.NewWriter(io.Discard), "site"); err == nil {
+		t.Fatal("expected an error for a name outside the tree")
+	}
+}
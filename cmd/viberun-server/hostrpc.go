@@ -9,6 +9,7 @@ import (
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,6 +24,7 @@ import (
 
 	branchpkg "github.com/shayne/viberun/internal/branch"
 	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/serverapi"
 )
 
 const hostRPCContainerDir = "/var/run/viberun-hostrpc"
@@ -47,8 +49,9 @@ type hostRPCServer struct {
 	httpServer         *http.Server
 	hostSocket         string
 	hostTokenFile      string
-	snapshotFn         func(containerName string, app string) (string, error)
+	snapshotFn         func(containerName string, app string, opts snapshotOptions) (string, error)
 	listFn             func(app string) ([]string, error)
+	listInfoFn         func(app string) (serverapi.SnapshotList, error)
 	restoreFn          func(containerName string, app string, port int, snapshotRef string) error
 	branchListFn       func(base string) ([]branchMeta, error)
 	branchCreateFn     func(base string, branch string) (branchMeta, error)
@@ -119,7 +122,7 @@ func deleteHostRPCDir(app string) error {
 	return os.RemoveAll(cfg.HostDir)
 }
 
-func startHostRPC(app string, containerName string, port int, snapshotFn func(containerName string, app string) (string, error), listFn func(app string) ([]string, error), restoreFn func(containerName string, app string, port int, snapshotRef string) error) (*hostRPCServer, map[string]string, error) {
+func startHostRPC(app string, containerName string, port int, snapshotFn func(containerName string, app string, opts snapshotOptions) (string, error), listFn func(app string) ([]string, error), restoreFn func(containerName string, app string, port int, snapshotRef string) error) (*hostRPCServer, map[string]string, error) {
 	cfg := hostRPCConfigForApp(app)
 	if err := ensureHostRPCDir(app); err != nil {
 		return nil, nil, err
@@ -151,6 +154,7 @@ func startHostRPC(app string, containerName string, port int, snapshotFn func(co
 		hostTokenFile:      cfg.HostTokenFile,
 		snapshotFn:         snapshotFn,
 		listFn:             listFn,
+		listInfoFn:         snapshotListResult,
 		restoreFn:          restoreFn,
 		branchListFn:       listBranchMetas,
 		branchCreateFn:     createBranchEnv,
@@ -222,7 +226,22 @@ func (s *hostRPCServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	ref, err := s.snapshotFn(s.containerName, s.app)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	opts := snapshotOptions{
+		Name:    strings.TrimSpace(r.URL.Query().Get("name")),
+		Message: strings.TrimSpace(string(body)),
+		Origin:  snapshotOriginManual,
+		Creator: "container",
+	}
+	if _, err := normalizeSnapshotName(opts.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ref, err := s.snapshotFn(s.containerName, s.app, opts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -239,6 +258,16 @@ func (s *hostRPCServer) handleSnapshots(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if wantsJSON(r) {
+		list, err := s.listInfoFn(s.app)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
 	tags, err := s.listFn(s.app)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -249,6 +278,15 @@ func (s *hostRPCServer) handleSnapshots(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// wantsJSON reports whether the caller asked for JSON via ?format=json or
+// an Accept header; plain text stays the default for vrctl.
+func wantsJSON(r *http.Request) bool {
+	if strings.EqualFold(r.URL.Query().Get("format"), "json") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
 func (s *hostRPCServer) handleRestore(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -500,28 +538,22 @@ func (s *hostRPCServer) handleBranchApply(w http.ResponseWriter, r *http.Request
 	_, _ = w.Write([]byte("ok\n"))
 }
 
-func (s *hostRPCServer) handleProxyPublic(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+// editProxyConfig applies fn to proxy.toml under the config lock and
+// syncs the proxy. Errors from fn are reported as bad requests.
+func editProxyConfig(w http.ResponseWriter, fn func(*proxy.Config) error) {
+	var requestErr error
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		requestErr = fn(cfg)
+		return requestErr
+	})
+	if requestErr != nil {
+		http.Error(w, requestErr.Error(), http.StatusBadRequest)
 		return
 	}
-	if !s.authorized(r) {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-	cfg, path, err := proxy.LoadConfig()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if err := setProxyAccess(&cfg, s.app, proxy.AccessPublic); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 	state, err := loadState()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -534,7 +566,7 @@ func (s *hostRPCServer) handleProxyPublic(w http.ResponseWriter, r *http.Request
 	_, _ = w.Write([]byte("ok\n"))
 }
 
-func (s *hostRPCServer) handleProxyPrivate(w http.ResponseWriter, r *http.Request) {
+func (s *hostRPCServer) handleProxyPublic(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -543,29 +575,23 @@ func (s *hostRPCServer) handleProxyPrivate(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	cfg, path, err := proxy.LoadConfig()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := setProxyAccess(&cfg, s.app, proxy.AccessPrivate); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	state, err := loadState()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	editProxyConfig(w, func(cfg *proxy.Config) error {
+		return setProxyAccess(cfg, s.app, proxy.AccessPublic)
+	})
+}
+
+func (s *hostRPCServer) handleProxyPrivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	if err := syncProxyWithState(cfg, state); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	_, _ = w.Write([]byte("ok\n"))
+	editProxyConfig(w, func(cfg *proxy.Config) error {
+		return setProxyAccess(cfg, s.app, proxy.AccessPrivate)
+	})
 }
 
 func shouldAttach(r *http.Request) bool {
@@ -631,26 +657,10 @@ func (s *hostRPCServer) handleProxyDisable(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	cfg, path, err := proxy.LoadConfig()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	setProxyDisabled(&cfg, s.app, true)
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	state, err := loadState()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := syncProxyWithState(cfg, state); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	_, _ = w.Write([]byte("ok\n"))
+	editProxyConfig(w, func(cfg *proxy.Config) error {
+		setProxyDisabled(cfg, s.app, true)
+		return nil
+	})
 }
 
 func (s *hostRPCServer) handleProxyEnable(w http.ResponseWriter, r *http.Request) {
@@ -662,26 +672,10 @@ func (s *hostRPCServer) handleProxyEnable(w http.ResponseWriter, r *http.Request
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	cfg, path, err := proxy.LoadConfig()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	setProxyDisabled(&cfg, s.app, false)
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	state, err := loadState()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := syncProxyWithState(cfg, state); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	_, _ = w.Write([]byte("ok\n"))
+	editProxyConfig(w, func(cfg *proxy.Config) error {
+		setProxyDisabled(cfg, s.app, false)
+		return nil
+	})
 }
 
 func (s *hostRPCServer) authorized(r *http.Request) bool {
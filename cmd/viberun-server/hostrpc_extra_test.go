@@ -42,7 +42,7 @@ func TestHostRPCConfigForAppBase(t *testing.T) {
 
 func TestStartHostRPCSetsEnvAndCreatesFiles(t *testing.T) {
 	app := "test-env-" + time.Now().Format("20060102150405.000000000")
-	server, env, err := startHostRPC(app, "container", 1234, func(string, string) (string, error) {
+	server, env, err := startHostRPC(app, "container", 1234, func(string, string, snapshotOptions) (string, error) {
 		return "ref", nil
 	}, func(string) ([]string, error) {
 		return nil, nil
@@ -70,7 +70,7 @@ func TestStartHostRPCCorrectsDirPermissions(t *testing.T) {
 	defer syscall.Umask(original)
 
 	app := "test-umask-" + time.Now().Format("20060102150405.000000000")
-	server, _, err := startHostRPC(app, "container", 1234, func(string, string) (string, error) {
+	server, _, err := startHostRPC(app, "container", 1234, func(string, string, snapshotOptions) (string, error) {
 		return "ref", nil
 	}, func(string) ([]string, error) {
 		return nil, nil
@@ -6,6 +6,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -14,6 +15,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/shayne/viberun/internal/serverapi"
 )
 
 func unixHTTPClient(socketPath string) *http.Client {
@@ -27,7 +30,7 @@ func unixHTTPClient(socketPath string) *http.Client {
 
 func TestHostRPCSnapshotAuth(t *testing.T) {
 	app := "test-auth-" + time.Now().Format("20060102150405.000000000")
-	server, _, err := startHostRPC(app, "container", 1234, func(string, string) (string, error) {
+	server, _, err := startHostRPC(app, "container", 1234, func(string, string, snapshotOptions) (string, error) {
 		return "ref", nil
 	}, func(string) ([]string, error) {
 		return nil, nil
@@ -52,7 +55,7 @@ func TestHostRPCSnapshotAuth(t *testing.T) {
 
 func TestHostRPCSnapshotAndList(t *testing.T) {
 	app := "test-list-" + time.Now().Format("20060102150405.000000000")
-	server, _, err := startHostRPC(app, "container", 1234, func(string, string) (string, error) {
+	server, _, err := startHostRPC(app, "container", 1234, func(string, string, snapshotOptions) (string, error) {
 		return "tag", nil
 	}, func(string) ([]string, error) {
 		return []string{"tag1", "tag2"}, nil
@@ -106,7 +109,7 @@ func TestHostRPCSnapshotAndList(t *testing.T) {
 func TestHostRPCRestore(t *testing.T) {
 	app := "test-restore-" + time.Now().Format("20060102150405.000000000")
 	called := make(chan string, 1)
-	server, _, err := startHostRPC(app, "container", 4242, func(string, string) (string, error) {
+	server, _, err := startHostRPC(app, "container", 4242, func(string, string, snapshotOptions) (string, error) {
 		return "ref", nil
 	}, func(string) ([]string, error) {
 		return nil, nil
@@ -207,3 +210,73 @@ func TestHostRPCBranchList(t *testing.T) {
 		t.Fatalf("unexpected response: %q", got)
 	}
 }
+
+func TestHostRPCNamedSnapshotAndJSONList(t *testing.T) {
+	app := "test-named-" + time.Now().Format("20060102150405.000000000")
+	var got snapshotOptions
+	server, _, err := startHostRPC(app, "container", 1234, func(_ string, _ string, opts snapshotOptions) (string, error) {
+		got = opts
+		return "v1", nil
+	}, func(string) ([]string, error) {
+		return nil, nil
+	}, func(string, string, int, string) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("startHostRPC: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+	server.listInfoFn = func(app string) (serverapi.SnapshotList, error) {
+		return serverapi.SnapshotList{App: app, Snapshots: []serverapi.SnapshotInfo{{Tag: "v1", Name: "checkpoint", Message: "before refactor"}}}, nil
+	}
+
+	client := unixHTTPClient(server.hostSocket)
+	req, err := http.NewRequest(http.MethodPost, "http://unix/snapshot?name=checkpoint", strings.NewReader("before refactor\n"))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+server.token)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("post snapshot: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got.Name != "checkpoint" || got.Message != "before refactor" || got.Creator != "container" {
+		t.Fatalf("unexpected snapshot options: %+v", got)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, "http://unix/snapshot?name=v9", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+server.token)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("post snapshot: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for reserved name, got %d", resp.StatusCode)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "http://unix/snapshots?format=json", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+server.token)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("get snapshots: %v", err)
+	}
+	defer resp.Body.Close()
+	var list serverapi.SnapshotList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode snapshots: %v", err)
+	}
+	if list.App != app || len(list.Snapshots) != 1 || list.Snapshots[0].Name != "checkpoint" {
+		t.Fatalf("unexpected snapshot list: %+v", list)
+	}
+}
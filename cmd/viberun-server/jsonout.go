@@ -0,0 +1,74 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+// jsonOutput is set by the global --json flag. Actions then print a single
+// serverapi envelope on stdout instead of human text.
+var (
+	jsonOutput        bool
+	jsonResultWritten bool
+)
+
+func extractJSONFlag(args []string) ([]string, bool) {
+	found := false
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == serverapi.FlagJSON {
+			found = true
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out, found
+}
+
+// printResult prints result as JSON in --json mode, otherwise it renders the
+// human text.
+func printResult(kind string, result any, text func(out io.Writer)) error {
+	if jsonOutput {
+		return writeJSONResult(kind, result)
+	}
+	if text != nil {
+		text(os.Stdout)
+	}
+	return nil
+}
+
+func writeJSONResult(kind string, result any) error {
+	jsonResultWritten = true
+	return serverapi.Write(os.Stdout, kind, result)
+}
+
+// finishJSONOutput emits an empty message for actions that succeed without
+// printing a result, so --json callers always get an envelope.
+func finishJSONOutput() {
+	if jsonOutput && !jsonResultWritten {
+		_ = writeJSONResult(serverapi.KindMessage, serverapi.Message{})
+	}
+}
+
+func printMessage(format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	return printResult(serverapi.KindMessage, serverapi.Message{Message: message}, func(out io.Writer) {
+		fmt.Fprintln(out, message)
+	})
+}
+
+// humanOutput is where progress and other human-only text goes; in --json
+// mode it moves to stderr so stdout stays machine readable.
+func humanOutput() io.Writer {
+	if jsonOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestExtractJSONFlag(t *testing.T) {
+	args, found := extractJSONFlag([]string{"myapp", "--json", "snapshots"})
+	if !found {
+		t.Fatalf("expected --json to be found")
+	}
+	if len(args) != 2 || args[0] != "myapp" || args[1] != "snapshots" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+	if _, found := extractJSONFlag([]string{"apps"}); found {
+		t.Fatalf("did not expect --json")
+	}
+}
+
+func TestParseBtrfsDuTotal(t *testing.T) {
+	output := "     Total   Exclusive  Set shared  Filename\n  1048576       4096     1044480  /var/lib/viberun/apps/myapp/snapshots/v1\n"
+	total, err := parseBtrfsDuTotal(output)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if total != 1048576 {
+		t.Fatalf("unexpected total %d", total)
+	}
+	if _, err := parseBtrfsDuTotal("ERROR: not a btrfs filesystem"); err == nil {
+		t.Fatalf("expected error for unexpected output")
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+const (
+	limitsFilename = "limits.json"
+
+	limitsProfileHardened = "hardened"
+	limitsProfileRelaxed  = "relaxed"
+
+	defaultPidsLimit = 4096
+)
+
+// hardenedCapabilities are the capabilities kept after --cap-drop=ALL. The
+// container runs as an unprivileged user, but the host still execs as root to
+// fix ownership of copied auth files.
+var hardenedCapabilities = []string{
+	"CHOWN",
+	"DAC_OVERRIDE",
+	"FOWNER",
+	"FSETID",
+	"KILL",
+	"NET_BIND_SERVICE",
+	"SETGID",
+	"SETUID",
+}
+
+var (
+	memoryLimitPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[bkmgBKMG]?$`)
+	capabilityPattern  = regexp.MustCompile(`^[A-Z_]+$`)
+)
+
+// appLimits holds container resource limits and the hardening profile.
+// Empty fields inherit from the host default profile.
+type appLimits struct {
+	Profile   string   `json:"profile,omitempty"`
+	Memory    string   `json:"memory,omitempty"`
+	CPUs      string   `json:"cpus,omitempty"`
+	PidsLimit int      `json:"pids_limit,omitempty"`
+	CapAdd    []string `json:"cap_add,omitempty"`
+}
+
+func builtinLimits() appLimits {
+	return appLimits{
+		Profile:   limitsProfileHardened,
+		PidsLimit: defaultPidsLimit,
+	}
+}
+
+func hostLimitsPath() string {
+	return filepath.Join(filepath.Dir(homeVolumeBaseDir), limitsFilename)
+}
+
+func appLimitsPath(app string) string {
+	cfg := homeVolumeConfigForApp(app)
+	return filepath.Join(cfg.BaseDir, limitsFilename)
+}
+
+func readLimitsFile(path string) (appLimits, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return appLimits{}, false, nil
+		}
+		return appLimits{}, false, err
+	}
+	var limits appLimits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return appLimits{}, false, fmt.Errorf("invalid limits file %s: %w", path, err)
+	}
+	return limits, true, nil
+}
+
+func writeLimitsFile(path string, limits appLimits) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(limits, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return os.WriteFile(path, data, 0o644)
+}
+
+// mergeLimits overlays the non-empty fields of override onto base.
+func mergeLimits(base appLimits, override appLimits) appLimits {
+	out := base
+	if strings.TrimSpace(override.Profile) != "" {
+		out.Profile = override.Profile
+	}
+	if strings.TrimSpace(override.Memory) != "" {
+		out.Memory = override.Memory
+	}
+	if strings.TrimSpace(override.CPUs) != "" {
+		out.CPUs = override.CPUs
+	}
+	if override.PidsLimit != 0 {
+		out.PidsLimit = override.PidsLimit
+	}
+	if len(override.CapAdd) > 0 {
+		out.CapAdd = append([]string(nil), override.CapAdd...)
+	}
+	return out
+}
+
+func loadHostLimits() (appLimits, error) {
+	host, _, err := readLimitsFile(hostLimitsPath())
+	if err != nil {
+		return appLimits{}, err
+	}
+	return mergeLimits(builtinLimits(), host), nil
+}
+
+// resolveAppLimits returns the effective limits for an app: the built-in
+// defaults, then the host default profile, then the app's own overrides.
+func resolveAppLimits(app string) (appLimits, error) {
+	base, err := loadHostLimits()
+	if err != nil {
+		return appLimits{}, err
+	}
+	own, _, err := readLimitsFile(appLimitsPath(app))
+	if err != nil {
+		return appLimits{}, err
+	}
+	return mergeLimits(base, own), nil
+}
+
+func (l appLimits) dockerArgs() []string {
+	args := []string{}
+	if l.Profile != limitsProfileRelaxed {
+		args = append(args, "--cap-drop", "ALL")
+		for _, capability := range hardenedCapabilities {
+			args = append(args, "--cap-add", capability)
+		}
+		args = append(args, "--security-opt", "no-new-privileges")
+	}
+	for _, capability := range l.CapAdd {
+		args = append(args, "--cap-add", capability)
+	}
+	if memory := strings.TrimSpace(l.Memory); memory != "" {
+		args = append(args, "--memory", memory)
+	}
+	if cpus := strings.TrimSpace(l.CPUs); cpus != "" {
+		args = append(args, "--cpus", cpus)
+	}
+	if l.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(l.PidsLimit))
+	}
+	return args
+}
+
+// applyLimitSettings parses key=value pairs into limits. A value of "none"
+// or an empty value clears the key so it inherits again.
+func applyLimitSettings(limits *appLimits, settings []string) error {
+	if len(settings) == 0 {
+		return fmt.Errorf("at least one key=value setting is required")
+	}
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q (expected key=value)", setting)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		unset := value == "" || strings.EqualFold(value, "none")
+		switch key {
+		case "profile":
+			if unset {
+				limits.Profile = ""
+				continue
+			}
+			value = strings.ToLower(value)
+			if value != limitsProfileHardened && value != limitsProfileRelaxed {
+				return fmt.Errorf("invalid profile %q (use %s or %s)", value, limitsProfileHardened, limitsProfileRelaxed)
+			}
+			limits.Profile = value
+		case "memory":
+			if unset {
+				limits.Memory = ""
+				continue
+			}
+			if !memoryLimitPattern.MatchString(value) {
+				return fmt.Errorf("invalid memory limit %q (examples: 512m, 4g)", value)
+			}
+			limits.Memory = strings.ToLower(value)
+		case "cpus":
+			if unset {
+				limits.CPUs = ""
+				continue
+			}
+			cpus, err := strconv.ParseFloat(value, 64)
+			if err != nil || cpus <= 0 {
+				return fmt.Errorf("invalid cpus value %q", value)
+			}
+			limits.CPUs = value
+		case "pids":
+			if unset {
+				limits.PidsLimit = 0
+				continue
+			}
+			pids, err := strconv.Atoi(value)
+			if err != nil || pids <= 0 {
+				return fmt.Errorf("invalid pids limit %q", value)
+			}
+			limits.PidsLimit = pids
+		case "cap-add":
+			if unset {
+				limits.CapAdd = nil
+				continue
+			}
+			caps, err := parseCapabilities(value)
+			if err != nil {
+				return err
+			}
+			limits.CapAdd = caps
+		default:
+			return fmt.Errorf("unknown limit %q (use profile, memory, cpus, pids, or cap-add)", key)
+		}
+	}
+	return nil
+}
+
+func parseCapabilities(value string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, part := range strings.Split(value, ",") {
+		capability := strings.ToUpper(strings.TrimSpace(part))
+		capability = strings.TrimPrefix(capability, "CAP_")
+		if capability == "" || seen[capability] {
+			continue
+		}
+		if !capabilityPattern.MatchString(capability) {
+			return nil, fmt.Errorf("invalid capability %q", part)
+		}
+		seen[capability] = true
+		out = append(out, capability)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func writeLimitsSummary(out io.Writer, effective appLimits, own appLimits, hasOwn bool) {
+	source := func(set bool) string {
+		if hasOwn && set {
+			return ""
+		}
+		return " (default)"
+	}
+	valueOr := func(value string, fallback string) string {
+		if strings.TrimSpace(value) == "" {
+			return fallback
+		}
+		return value
+	}
+	pids := "unlimited"
+	if effective.PidsLimit > 0 {
+		pids = strconv.Itoa(effective.PidsLimit)
+	}
+	caps := "none"
+	if len(effective.CapAdd) > 0 {
+		caps = strings.Join(effective.CapAdd, ",")
+	}
+	fmt.Fprintf(out, "Profile: %s%s\n", effective.Profile, source(own.Profile != ""))
+	fmt.Fprintf(out, "Memory: %s%s\n", valueOr(effective.Memory, "unlimited"), source(own.Memory != ""))
+	fmt.Fprintf(out, "CPUs: %s%s\n", valueOr(effective.CPUs, "unlimited"), source(own.CPUs != ""))
+	fmt.Fprintf(out, "PIDs: %s%s\n", pids, source(own.PidsLimit != 0))
+	fmt.Fprintf(out, "Extra capabilities: %s%s\n", caps, source(len(own.CapAdd) > 0))
+}
+
+func printLimits(effective appLimits, own appLimits, hasOwn bool) error {
+	result := serverapi.Limits{
+		Profile:   effective.Profile,
+		Memory:    effective.Memory,
+		CPUs:      effective.CPUs,
+		PidsLimit: effective.PidsLimit,
+		CapAdd:    effective.CapAdd,
+	}
+	if hasOwn {
+		for _, override := range []struct {
+			key string
+			set bool
+		}{
+			{"profile", own.Profile != ""},
+			{"memory", own.Memory != ""},
+			{"cpus", own.CPUs != ""},
+			{"pids", own.PidsLimit != 0},
+			{"cap-add", len(own.CapAdd) > 0},
+		} {
+			if override.set {
+				result.Overrides = append(result.Overrides, override.key)
+			}
+		}
+	}
+	return printResult(serverapi.KindLimits, result, func(out io.Writer) {
+		writeLimitsSummary(out, effective, own, hasOwn)
+	})
+}
+
+func handleAppLimitsAction(app string, args []string) error {
+	path := appLimitsPath(app)
+	sub := "show"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch sub {
+	case "show":
+		own, hasOwn, err := readLimitsFile(path)
+		if err != nil {
+			return err
+		}
+		effective, err := resolveAppLimits(app)
+		if err != nil {
+			return err
+		}
+		return printLimits(effective, own, hasOwn)
+	case "set":
+		own, _, err := readLimitsFile(path)
+		if err != nil {
+			return err
+		}
+		if err := applyLimitSettings(&own, args[1:]); err != nil {
+			return err
+		}
+		if err := writeLimitsFile(path, own); err != nil {
+			return fmt.Errorf("failed to save limits: %w", err)
+		}
+		return printMessage("Limits updated for %s. Run `update` to apply them to the container.", app)
+	case "reset":
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset limits: %w", err)
+		}
+		return printMessage("Limits reset to host defaults for %s. Run `update` to apply them to the container.", app)
+	default:
+		return newUsageError("usage: viberun-server <app> limits [show|set key=value...|reset]")
+	}
+}
+
+func handleHostLimitsCommand(args []string) error {
+	path := hostLimitsPath()
+	sub := "show"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch sub {
+	case "show":
+		own, hasOwn, err := readLimitsFile(path)
+		if err != nil {
+			return err
+		}
+		effective := mergeLimits(builtinLimits(), own)
+		return printLimits(effective, own, hasOwn)
+	case "set":
+		own, _, err := readLimitsFile(path)
+		if err != nil {
+			return err
+		}
+		if err := applyLimitSettings(&own, args[1:]); err != nil {
+			return err
+		}
+		if err := writeLimitsFile(path, own); err != nil {
+			return fmt.Errorf("failed to save limits: %w", err)
+		}
+		return printMessage("Host default limits updated. Apps pick them up on their next create or update.")
+	case "reset":
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset limits: %w", err)
+		}
+		return printMessage("Host default limits reset.")
+	default:
+		return newUsageError("usage: viberun-server limits [show|set key=value...|reset]")
+	}
+}
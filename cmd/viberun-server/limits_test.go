@@ -0,0 +1,119 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func useTempLimitsDir(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	origBaseDir := homeVolumeBaseDir
+	homeVolumeBaseDir = filepath.Join(root, "apps")
+	t.Cleanup(func() { homeVolumeBaseDir = origBaseDir })
+}
+
+func TestDockerRunArgsIncludesHardenedLimits(t *testing.T) {
+	t.Setenv("VIBERUN_XDG_OPEN_SOCKET", "")
+	limits := builtinLimits()
+	limits.Memory = "4g"
+	limits.CPUs = "2"
+	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test", limits, nil)
+
+	for _, pair := range [][2]string{
+		{"--cap-drop", "ALL"},
+		{"--cap-add", "CHOWN"},
+		{"--security-opt", "no-new-privileges"},
+		{"--memory", "4g"},
+		{"--cpus", "2"},
+		{"--pids-limit", "4096"},
+	} {
+		if !hasPair(args, pair[0], pair[1]) {
+			t.Fatalf("expected %s %s in args: %v", pair[0], pair[1], args)
+		}
+	}
+}
+
+func TestDockerRunArgsRelaxedProfileKeepsCapabilities(t *testing.T) {
+	t.Setenv("VIBERUN_XDG_OPEN_SOCKET", "")
+	limits := appLimits{Profile: limitsProfileRelaxed, CapAdd: []string{"NET_ADMIN"}}
+	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test", limits, nil)
+
+	if hasPair(args, "--cap-drop", "ALL") {
+		t.Fatalf("did not expect cap-drop for relaxed profile: %v", args)
+	}
+	if hasPair(args, "--security-opt", "no-new-privileges") {
+		t.Fatalf("did not expect no-new-privileges for relaxed profile: %v", args)
+	}
+	if !hasPair(args, "--cap-add", "NET_ADMIN") {
+		t.Fatalf("expected extra capability in args: %v", args)
+	}
+}
+
+func TestApplyLimitSettings(t *testing.T) {
+	limits := appLimits{Memory: "1g"}
+	if err := applyLimitSettings(&limits, []string{"memory=2G", "cpus=1.5", "pids=512", "cap-add=cap_sys_ptrace,NET_ADMIN", "profile=Relaxed"}); err != nil {
+		t.Fatalf("apply settings: %v", err)
+	}
+	if limits.Memory != "2g" || limits.CPUs != "1.5" || limits.PidsLimit != 512 || limits.Profile != limitsProfileRelaxed {
+		t.Fatalf("unexpected limits: %+v", limits)
+	}
+	if len(limits.CapAdd) != 2 || limits.CapAdd[0] != "NET_ADMIN" || limits.CapAdd[1] != "SYS_PTRACE" {
+		t.Fatalf("unexpected capabilities: %v", limits.CapAdd)
+	}
+	if err := applyLimitSettings(&limits, []string{"memory=none", "cap-add="}); err != nil {
+		t.Fatalf("clear settings: %v", err)
+	}
+	if limits.Memory != "" || limits.CapAdd != nil {
+		t.Fatalf("expected cleared settings, got %+v", limits)
+	}
+}
+
+func TestApplyLimitSettingsRejectsInvalidValues(t *testing.T) {
+	for _, setting := range []string{"memory=lots", "cpus=0", "pids=-1", "profile=root", "swap=1g", "memory"} {
+		limits := appLimits{}
+		if err := applyLimitSettings(&limits, []string{setting}); err == nil {
+			t.Fatalf("expected error for %q", setting)
+		}
+	}
+}
+
+func TestResolveAppLimitsLayersHostAndAppOverrides(t *testing.T) {
+	useTempLimitsDir(t)
+	if err := writeLimitsFile(hostLimitsPath(), appLimits{Memory: "8g", CPUs: "4"}); err != nil {
+		t.Fatalf("write host limits: %v", err)
+	}
+	if err := writeLimitsFile(appLimitsPath("myapp"), appLimits{CPUs: "1", Profile: limitsProfileRelaxed}); err != nil {
+		t.Fatalf("write app limits: %v", err)
+	}
+
+	limits, err := resolveAppLimits("myapp")
+	if err != nil {
+		t.Fatalf("resolve limits: %v", err)
+	}
+	if limits.Memory != "8g" || limits.CPUs != "1" || limits.Profile != limitsProfileRelaxed || limits.PidsLimit != defaultPidsLimit {
+		t.Fatalf("unexpected effective limits: %+v", limits)
+	}
+
+	other, err := resolveAppLimits("other")
+	if err != nil {
+		t.Fatalf("resolve limits: %v", err)
+	}
+	if other.Memory != "8g" || other.CPUs != "4" || other.Profile != limitsProfileHardened {
+		t.Fatalf("unexpected host default limits: %+v", other)
+	}
+}
+
+func TestParseActionLimits(t *testing.T) {
+	action, args, err := parseAction([]string{"limits", "set", "memory=2g", "cpus=1"})
+	if err != nil {
+		t.Fatalf("parse action: %v", err)
+	}
+	if action != "limits" || len(args) != 3 || args[0] != "set" {
+		t.Fatalf("unexpected action %q args %v", action, args)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/shayne/viberun/internal/hostcmd"
 	"github.com/shayne/viberun/internal/proxy"
 	"github.com/shayne/viberun/internal/server"
+	"github.com/shayne/viberun/internal/serverapi"
 	"github.com/shayne/viberun/internal/tui"
 	"github.com/shayne/yargs"
 )
@@ -40,13 +41,22 @@ func defaultImageRef() string {
 }
 
 type serverFlags struct {
-	Agent string `flag:"agent" help:"agent provider to run (codex, claude, gemini, ampcode, opencode, or npx:<pkg>/uvx:<pkg>)"`
+	Agent   string `flag:"agent" help:"agent provider to run (codex, claude, gemini, ampcode, opencode, or npx:<pkg>/uvx:<pkg>)"`
+	Message string `flag:"message" short:"m" help:"snapshot message"`
+	Output  string `flag:"output" short:"o" help:"export archive path (default: stdout)"`
 }
 
 type SnapshotInfo struct {
 	Tag          string
 	CreatedAt    time.Time
 	CreatedAtRaw string
+	Origin       string
+	Name         string
+	Message      string
+	Creator      string
+	Agent        string
+	ImageID      string
+	Size         int64
 }
 
 type restoreQueue struct {
@@ -149,6 +159,10 @@ func (e silentError) Unwrap() error {
 }
 
 func reportServerError(err error) {
+	if jsonOutput {
+		_ = serverapi.WriteError(os.Stdout, err)
+		return
+	}
 	var usageErr usageError
 	if errors.As(err, &usageErr) {
 		fmt.Fprintln(os.Stderr, usageErr.message)
@@ -175,13 +189,16 @@ func newSilentError(err error) error {
 func main() {
 	if err := runServer(); err != nil {
 		reportServerError(err)
+		return
 	}
+	finishJSONOutput()
 }
 
 func runServer() error {
-	args := os.Args[1:]
+	args, jsonFlag := extractJSONFlag(os.Args[1:])
+	jsonOutput = jsonFlag
 	if len(args) == 0 || hasHelpFlag(args) {
-		return newUsageError("Usage: viberun-server [--agent provider] <app> [snapshot|snapshots|restore <snapshot>|update|shell|port|status|delete|exists] | viberun-server apps | viberun-server branch <list|create|delete|apply> <app> [branch] | viberun-server proxy setup --domain <domain> --public-ip <ip> | viberun-server proxy url <app> | viberun-server wipe")
+		return newUsageError("Usage: viberun-server [--agent provider] <app> [snapshot [name] [--message text]|snapshots|restore <snapshot>|export <snapshot> [--output file]|import <archive>|update|shell|port|status|delete|exists|limits [show|set|reset]|ports [list|add|remove]|schedule [show|set|off]|backup [status|run|restore [snapshot]]|disk [show|grow <size>]] | viberun-server apps | viberun-server branch <list|create|delete|apply> <app> [branch] | viberun-server limits [show|set|reset] | viberun-server backup [show|set|off|run|status] | viberun-server snapshots daemon [--once] | viberun-server proxy setup --domain <domain> --public-ip <ip> | viberun-server proxy url <app> | viberun-server wipe")
 	}
 	if args[0] == "proxy" {
 		if os.Geteuid() != 0 {
@@ -223,6 +240,24 @@ func runServer() error {
 		}
 		return nil
 	}
+	if args[0] == "snapshots" {
+		if os.Geteuid() != 0 {
+			return fmt.Errorf("viberun-server must run as root; run via sudo or rerun setup")
+		}
+		return handleSnapshotsCommand(args[1:])
+	}
+	if args[0] == "limits" {
+		if os.Geteuid() != 0 {
+			return fmt.Errorf("viberun-server must run as root; run via sudo or rerun setup")
+		}
+		return handleHostLimitsCommand(args[1:])
+	}
+	if args[0] == "backup" {
+		if os.Geteuid() != 0 {
+			return fmt.Errorf("viberun-server must run as root; run via sudo or rerun setup")
+		}
+		return handleBackupCommand(args[1:])
+	}
 	if args[0] == "branch" {
 		if os.Geteuid() != 0 {
 			return fmt.Errorf("viberun-server must run as root; run via sudo or rerun setup")
@@ -236,16 +271,18 @@ func runServer() error {
 		return handleBranchCommand(args[1:])
 	}
 	if args[0] == "version" || args[0] == "--version" || args[0] == "-v" {
-		fmt.Fprintln(os.Stdout, versionString())
-		return nil
+		version := versionString()
+		return printResult(serverapi.KindVersion, serverapi.ServerVersion{Version: version}, func(out io.Writer) {
+			fmt.Fprintln(out, version)
+		})
 	}
 	result, err := yargs.ParseFlags[serverFlags](args)
 	if err != nil {
 		return err
 	}
 
-	if len(result.Args) < 1 || len(result.Args) > 3 {
-		return newUsageError("Usage: viberun-server [--agent provider] <app> [snapshot|snapshots|restore <snapshot>|update|shell|port|status|delete|exists] | viberun-server apps | viberun-server branch <list|create|delete|apply> <app> [branch] | viberun-server proxy setup --domain <domain> --public-ip <ip> | viberun-server proxy url <app> | viberun-server wipe")
+	if len(result.Args) < 1 || (len(result.Args) > 3 && !isSettingsAction(result.Args[1:])) {
+		return newUsageError("Usage: viberun-server [--agent provider] <app> [snapshot [name] [--message text]|snapshots|restore <snapshot>|export <snapshot> [--output file]|import <archive>|update|shell|port|status|delete|exists|limits [show|set|reset]|ports [list|add|remove]|schedule [show|set|off]|backup [status|run|restore [snapshot]]|disk [show|grow <size>]] | viberun-server apps | viberun-server branch <list|create|delete|apply> <app> [branch] | viberun-server limits [show|set|reset] | viberun-server backup [show|set|off|run|status] | viberun-server snapshots daemon [--once] | viberun-server proxy setup --domain <domain> --public-ip <ip> | viberun-server proxy url <app> | viberun-server wipe")
 	}
 	args = result.Args
 	app, err := proxy.NormalizeAppName(args[0])
@@ -257,6 +294,12 @@ func runServer() error {
 	if err != nil {
 		return err
 	}
+	if strings.TrimSpace(result.Flags.Message) != "" && action != "snapshot" {
+		return newUsageError("--message is only valid with snapshot")
+	}
+	if strings.TrimSpace(result.Flags.Output) != "" && action != "export" {
+		return newUsageError("--output is only valid with export")
+	}
 
 	agentProvider := strings.TrimSpace(result.Flags.Agent)
 	agentSpec, err := agents.Resolve(agentProvider)
@@ -307,8 +350,9 @@ func runServer() error {
 	}
 
 	if action == "exists" {
-		fmt.Fprintln(os.Stdout, exists)
-		return nil
+		return printResult(serverapi.KindAppExists, serverapi.AppExists{App: app, Exists: exists}, func(out io.Writer) {
+			fmt.Fprintln(out, exists)
+		})
 	}
 
 	if action == "snapshot" {
@@ -321,14 +365,36 @@ func runServer() error {
 			}
 			return fmt.Errorf("failed to access app volume: %w", err)
 		}
-		ref, err := createSnapshot(containerName, app)
+		opts := snapshotOptions{
+			Message: strings.TrimSpace(result.Flags.Message),
+			Origin:  snapshotOriginManual,
+			Creator: snapshotCreator(),
+			Agent:   agentSpec.Provider,
+		}
+		if len(actionArgs) > 0 {
+			opts.Name = actionArgs[0]
+		}
+		ref, err := createSnapshotWithOptions(containerName, app, opts)
 		if err != nil {
 			return fmt.Errorf("failed to create snapshot: %w", err)
 		}
-		fmt.Fprintf(os.Stdout, "Snapshot created: %s\n", ref)
-		return nil
+		name, _ := normalizeSnapshotName(opts.Name)
+		return printResult(serverapi.KindSnapshot, serverapi.SnapshotCreated{App: app, Tag: ref, Name: name}, func(out io.Writer) {
+			if name != "" {
+				fmt.Fprintf(out, "Snapshot created: %s (%s)\n", ref, name)
+				return
+			}
+			fmt.Fprintf(out, "Snapshot created: %s\n", ref)
+		})
 	}
 	if action == "snapshots" {
+		if jsonOutput {
+			list, err := snapshotListResult(app)
+			if err != nil {
+				return fmt.Errorf("failed to list snapshots: %w", err)
+			}
+			return writeJSONResult(serverapi.KindSnapshots, list)
+		}
 		lines, err := listSnapshotLines(app)
 		if err != nil {
 			return fmt.Errorf("failed to list snapshots: %w", err)
@@ -343,6 +409,41 @@ func runServer() error {
 		}
 		return nil
 	}
+	if action == "limits" {
+		return handleAppLimitsAction(app, actionArgs)
+	}
+	if action == "ports" {
+		return handleAppPortsAction(app, actionArgs)
+	}
+	if action == "schedule" {
+		return handleAppScheduleAction(app, actionArgs)
+	}
+	if action == "disk" {
+		return handleAppDiskAction(app, actionArgs)
+	}
+	if action == "backup" && !isBackupRestore(actionArgs) {
+		return handleAppBackupAction(app, actionArgs)
+	}
+	if action == "export" {
+		output := strings.TrimSpace(result.Flags.Output)
+		if jsonOutput && (output == "" || output == "-") {
+			return newUsageError("export --json requires --output")
+		}
+		ref, err := resolveSnapshotRef(app, actionArgs[0])
+		if err != nil {
+			return fmt.Errorf("failed to resolve snapshot: %w", err)
+		}
+		size, err := exportApp(app, ref, output)
+		if err != nil {
+			return fmt.Errorf("failed to export snapshot: %w", err)
+		}
+		if output == "" || output == "-" {
+			return nil
+		}
+		return printResult(serverapi.KindExport, serverapi.ArchiveExport{App: app, Snapshot: ref, Path: output, Size: size}, func(out io.Writer) {
+			fmt.Fprintf(out, "Exported %s %s to %s (%d bytes)\n", app, ref, output, size)
+		})
+	}
 	if action == "delete" {
 		branches, err := listBranchMetas(app)
 		if err != nil {
@@ -356,7 +457,7 @@ func runServer() error {
 				}
 			}
 			if len(names) > 0 {
-				fmt.Fprintf(os.Stdout, "Deleting %s also deletes branches: %s\n", app, strings.Join(names, ", "))
+				fmt.Fprintf(humanOutput(), "Deleting %s also deletes branches: %s\n", app, strings.Join(names, ", "))
 			}
 		}
 		deletedState, err := deleteApp(containerName, app, &state, exists)
@@ -369,24 +470,23 @@ func runServer() error {
 		if err := persistState(statePath, &state, &stateDirty); err != nil {
 			return err
 		}
-		fmt.Fprintf(os.Stdout, "Deleted app %s\n", app)
-		return nil
+		return printMessage("Deleted app %s", app)
 	}
 	if action == "status" {
-		if !exists {
-			fmt.Fprintln(os.Stdout, "missing")
-			return nil
-		}
-		running, err := containerRunning(containerName)
-		if err != nil {
-			return fmt.Errorf("failed to inspect container: %w", err)
-		}
-		if running {
-			fmt.Fprintln(os.Stdout, "running")
-		} else {
-			fmt.Fprintln(os.Stdout, "stopped")
+		status := serverapi.StatusMissing
+		if exists {
+			running, err := containerRunning(containerName)
+			if err != nil {
+				return fmt.Errorf("failed to inspect container: %w", err)
+			}
+			status = serverapi.StatusStopped
+			if running {
+				status = serverapi.StatusRunning
+			}
 		}
-		return nil
+		return printResult(serverapi.KindAppStatus, serverapi.AppStatus{App: app, Status: status}, func(out io.Writer) {
+			fmt.Fprintln(out, status)
+		})
 	}
 
 	port, portDirty, err := resolvePort(&state, app, containerName, exists)
@@ -401,8 +501,70 @@ func runServer() error {
 	}
 
 	if action == "port" {
-		fmt.Fprintln(os.Stdout, port)
-		return nil
+		return printResult(serverapi.KindAppPort, serverapi.AppPort{App: app, Port: port}, func(out io.Writer) {
+			fmt.Fprintln(out, port)
+		})
+	}
+
+	if action == "import" {
+		if exists {
+			return fmt.Errorf("cannot import: app container already exists")
+		}
+		ui := newAppProgress(app)
+		ui.Start()
+		ui.Step("Import archive")
+		manifest, drops, err := importApp(app, containerName, port, actionArgs[0])
+		if err != nil {
+			ui.Fail("failed")
+			ui.Stop()
+			return fmt.Errorf("failed to import app: %w", err)
+		}
+		ui.Done("")
+		ui.Stop()
+		warnProxySync(state)
+		result := serverapi.ArchiveImport{App: app, Source: manifest.App, Snapshot: manifest.Tag, Port: port, DroppedUsers: drops.Users, DroppedDomain: drops.Domain}
+		return printResult(serverapi.KindImport, result, func(out io.Writer) {
+			fmt.Fprintf(out, "Imported %s from %s %s\n", app, manifest.App, manifest.Tag)
+			for _, line := range result.Warnings() {
+				fmt.Fprintln(out, line)
+			}
+		})
+	}
+
+	if action == "backup" {
+		if exists {
+			return fmt.Errorf("cannot restore: app container already exists")
+		}
+		if len(actionArgs) > 2 {
+			return newUsageError("usage: viberun-server <app> backup restore [snapshot]")
+		}
+		ref := "latest"
+		if len(actionArgs) == 2 {
+			ref = actionArgs[1]
+		}
+		store, err := configuredBackupStore()
+		if err != nil {
+			return err
+		}
+		ui := newAppProgress(app)
+		ui.Start()
+		ui.Step("Restore from backup")
+		tag, chain, err := restoreAppFromBackup(store, app, containerName, port, ref)
+		if err != nil {
+			ui.Fail("failed")
+			ui.Stop()
+			return fmt.Errorf("failed to restore from backup: %w", err)
+		}
+		ui.Done("")
+		ui.Stop()
+		warnProxySync(state)
+		tags := make([]string, 0, len(chain))
+		for _, entry := range chain {
+			tags = append(tags, entry.Tag)
+		}
+		return printResult(serverapi.KindBackupRestore, serverapi.BackupRestore{App: app, Snapshot: tag, Chain: tags, Port: port}, func(out io.Writer) {
+			fmt.Fprintf(out, "Restored %s %s from %s (%d streams)\n", app, tag, store.String(), len(chain))
+		})
 	}
 
 	if action == "update" {
@@ -435,6 +597,12 @@ func runServer() error {
 			ui.Done("")
 		}
 		ui.Step("Recreate container")
+		// Check limits before removing the container so a bad limits.json
+		// leaves the old one in place.
+		if _, err := resolveAppLimits(app); err != nil {
+			ui.Fail("failed")
+			return fmt.Errorf("invalid limits: %w", err)
+		}
 		if err := runDockerCommandOutput("rm", "-f", containerName); err != nil {
 			ui.Fail("failed")
 			return fmt.Errorf("failed to remove container: %w", err)
@@ -445,8 +613,7 @@ func runServer() error {
 		}
 		ui.Done("")
 		_ = clearUpdateStatus(app)
-		fmt.Fprintf(os.Stdout, "Updated app %s\n", app)
-		return nil
+		return printMessage("Updated app %s", app)
 	}
 
 	if action == "restore" {
@@ -457,8 +624,7 @@ func runServer() error {
 		if err := restoreSnapshot(containerName, app, port, ref); err != nil {
 			return fmt.Errorf("failed to restore snapshot: %w", err)
 		}
-		fmt.Fprintf(os.Stdout, "Restored app %s from %s\n", app, ref)
-		return nil
+		return printMessage("Restored app %s from %s", app, ref)
 	}
 
 	var ui *tui.Progress
@@ -550,7 +716,11 @@ func runServer() error {
 
 	if action == "" || action == "shell" {
 		restoreQueue := newRestoreQueue()
-		hostRPC, extraEnv, err := startHostRPC(app, containerName, port, createSnapshot, listSnapshotLines, func(_ string, _ string, _ int, snapshotRef string) error {
+		snapshotFn := func(containerName string, app string, opts snapshotOptions) (string, error) {
+			opts.Agent = agentSpec.Provider
+			return createSnapshotWithOptions(containerName, app, opts)
+		}
+		hostRPC, extraEnv, err := startHostRPC(app, containerName, port, snapshotFn, listSnapshotLines, func(_ string, _ string, _ int, snapshotRef string) error {
 			if err := ensureSnapshotExists(app, snapshotRef); err != nil {
 				return err
 			}
@@ -559,7 +729,12 @@ func runServer() error {
 		if err != nil {
 			return fmt.Errorf("failed to start host rpc: %w", err)
 		}
-		stopUpdates := startUpdateWatcher(app, containerName, time.Hour)
+		stopUpdateWatcher := startUpdateWatcher(app, containerName, time.Hour)
+		stopDiskWatcher := startDiskWatcher(app, diskCheckInterval)
+		stopUpdates := func() {
+			stopUpdateWatcher()
+			stopDiskWatcher()
+		}
 		for key, value := range bundleEnv {
 			extraEnv[key] = value
 		}
@@ -607,6 +782,9 @@ func parseAction(args []string) (string, []string, error) {
 	if len(args) == 1 && args[0] == "snapshot" {
 		return "snapshot", nil, nil
 	}
+	if len(args) == 2 && args[0] == "snapshot" && strings.TrimSpace(args[1]) != "" {
+		return "snapshot", []string{strings.TrimSpace(args[1])}, nil
+	}
 	if len(args) == 1 && args[0] == "snapshots" {
 		return "snapshots", nil, nil
 	}
@@ -631,7 +809,19 @@ func parseAction(args []string) (string, []string, error) {
 	if len(args) == 2 && args[0] == "restore" && strings.TrimSpace(args[1]) != "" {
 		return "restore", []string{strings.TrimSpace(args[1])}, nil
 	}
-	return "", nil, fmt.Errorf("usage: viberun-server [--agent provider] <app> [snapshot|snapshots|restore <snapshot>|update|shell|port|status|delete|exists]")
+	if len(args) == 2 && (args[0] == "export" || args[0] == "import") && strings.TrimSpace(args[1]) != "" {
+		return args[0], []string{strings.TrimSpace(args[1])}, nil
+	}
+	if isSettingsAction(args) {
+		return args[0], args[1:], nil
+	}
+	return "", nil, fmt.Errorf("usage: viberun-server [--agent provider] <app> [snapshot [name] [--message text]|snapshots|restore <snapshot>|export <snapshot> [--output file]|import <archive>|update|shell|port|status|delete|exists|limits [show|set key=value...|reset]|ports [list|add <name> <port> [key=value...]|remove <name>]|schedule [show|set key=value...|off]|backup [status|run|restore [snapshot]]|disk [show|grow <size>]]")
+}
+
+// isSettingsAction reports whether args is an app action that takes a
+// variable number of key=value settings or a subcommand.
+func isSettingsAction(args []string) bool {
+	return len(args) > 0 && (args[0] == "limits" || args[0] == "ports" || args[0] == "schedule" || args[0] == "backup" || args[0] == "disk")
 }
 
 func hasHelpFlag(args []string) bool {
@@ -861,7 +1051,15 @@ func dockerRun(name string, app string, port int) error {
 	if err := ensureContainerConfig(app, name, port); err != nil {
 		return err
 	}
-	args := dockerRunArgs(name, app, port, defaultImageRef())
+	limits, err := resolveAppLimits(app)
+	if err != nil {
+		return err
+	}
+	state, _, err := server.LoadState()
+	if err != nil {
+		return err
+	}
+	args := dockerRunArgs(name, app, port, defaultImageRef(), limits, state.ExtraPortsForApp(app))
 	return runDockerCommandOutput(args...)
 }
 
@@ -928,6 +1126,9 @@ func normalizeTermValue(termValue string) string {
 
 func newAppProgress(app string) *tui.Progress {
 	tty := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	if jsonOutput {
+		return tui.NewProgress(os.Stderr, false, app, "")
+	}
 	return tui.NewProgress(os.Stdout, tty, app, "")
 }
 
@@ -1075,6 +1276,25 @@ func deleteApp(containerName string, app string, state *server.State, exists boo
 }
 
 func createSnapshot(containerName string, app string) (string, error) {
+	return createSnapshotWithOptions(containerName, app, snapshotOptions{Origin: snapshotOriginManual, Creator: snapshotCreator()})
+}
+
+func createSnapshotWithOptions(containerName string, app string, opts snapshotOptions) (string, error) {
+	unlock, err := lockSnapshots(app)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return createSnapshotLocked(containerName, app, opts)
+}
+
+// createSnapshotLocked is createSnapshotWithOptions for callers that already
+// hold the app's snapshot lock.
+func createSnapshotLocked(containerName string, app string, opts snapshotOptions) (string, error) {
+	name, err := normalizeSnapshotName(opts.Name)
+	if err != nil {
+		return "", err
+	}
 	cfg, ok, err := ensureHomeVolume(app, false)
 	if err != nil {
 		return "", err
@@ -1082,6 +1302,15 @@ func createSnapshot(containerName string, app string) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("app volume does not exist")
 	}
+	metas, err := readSnapshotMetas(app)
+	if err != nil {
+		return "", err
+	}
+	if name != "" {
+		if tag, exists := findSnapshotByName(metas, name); exists {
+			return "", fmt.Errorf("snapshot name %s is already used by %s", name, tag)
+		}
+	}
 	infos, err := listSnapshotInfos(app)
 	if err != nil {
 		return "", err
@@ -1090,6 +1319,23 @@ func createSnapshot(containerName string, app string) (string, error) {
 	if err := snapshotContainer(containerName, cfg, tag); err != nil {
 		return "", err
 	}
+	meta := snapshotMeta{
+		Name:      name,
+		Message:   strings.TrimSpace(opts.Message),
+		Origin:    opts.Origin,
+		Creator:   opts.Creator,
+		Agent:     opts.Agent,
+		CreatedAt: time.Now().UTC(),
+	}
+	if imageID, err := containerImageID(containerName); err == nil {
+		meta.ImageID = imageID
+	}
+	if size, err := snapshotSize(cfg, tag); err == nil {
+		meta.Size = size
+	}
+	if err := recordSnapshotMeta(app, tag, meta); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record snapshot metadata: %v\n", err)
+	}
 	return tag, nil
 }
 
@@ -1101,6 +1347,14 @@ func resolveSnapshotRef(app string, name string) (string, error) {
 	if normalized == "latest" {
 		return latestSnapshotRef(app)
 	}
+	if _, ok := parseSnapshotTag(normalized); ok {
+		return normalized, nil
+	}
+	if metas, err := readSnapshotMetas(app); err == nil {
+		if tag, ok := findSnapshotByName(metas, normalized); ok {
+			return tag, nil
+		}
+	}
 	if tag, ok := snapshotTag(normalized); ok {
 		return tag, nil
 	}
@@ -1132,6 +1386,10 @@ func listSnapshotInfos(app string) ([]SnapshotInfo, error) {
 		}
 		return nil, err
 	}
+	metas, err := readSnapshotMetas(app)
+	if err != nil {
+		return nil, err
+	}
 	var infos []SnapshotInfo
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -1144,13 +1402,30 @@ func listSnapshotInfos(app string) ([]SnapshotInfo, error) {
 		if _, ok := snapshotTag(tag); !ok {
 			continue
 		}
-		info := SnapshotInfo{Tag: tag}
+		info := SnapshotInfo{Tag: tag, Origin: snapshotOriginManual}
 		if stat, err := entry.Info(); err == nil {
 			info.CreatedAt = stat.ModTime()
 			if !info.CreatedAt.IsZero() {
 				info.CreatedAtRaw = info.CreatedAt.Format(time.RFC3339)
 			}
 		}
+		if meta, ok := metas[tag]; ok {
+			if meta.Origin != "" {
+				info.Origin = meta.Origin
+			}
+			info.Name = meta.Name
+			info.Message = meta.Message
+			info.Creator = meta.Creator
+			info.Agent = meta.Agent
+			info.ImageID = meta.ImageID
+			info.Size = meta.Size
+			// The snapshot root keeps the source directory's mtime, so the
+			// recorded creation time is more accurate when present.
+			if !meta.CreatedAt.IsZero() {
+				info.CreatedAt = meta.CreatedAt
+				info.CreatedAtRaw = meta.CreatedAt.Format(time.RFC3339)
+			}
+		}
 		infos = append(infos, info)
 	}
 	return infos, nil
@@ -1172,6 +1447,38 @@ func listSnapshotLines(app string) ([]string, error) {
 	return lines, nil
 }
 
+// snapshotListResult builds the --json snapshots result, including the size
+// of each snapshot.
+func snapshotListResult(app string) (serverapi.SnapshotList, error) {
+	list := serverapi.SnapshotList{App: app, Snapshots: []serverapi.SnapshotInfo{}}
+	infos, err := listSnapshotInfos(app)
+	if err != nil || len(infos) == 0 {
+		return list, err
+	}
+	sortSnapshotInfos(infos)
+	cfg := homeVolumeConfigForApp(app)
+	for _, info := range infos {
+		item := serverapi.SnapshotInfo{
+			Tag:       info.Tag,
+			Name:      info.Name,
+			Message:   info.Message,
+			CreatedAt: info.CreatedAt,
+			Size:      info.Size,
+			Origin:    info.Origin,
+			Creator:   info.Creator,
+			Agent:     info.Agent,
+			ImageID:   info.ImageID,
+		}
+		if item.Size == 0 {
+			if size, err := snapshotSize(cfg, info.Tag); err == nil {
+				item.Size = size
+			}
+		}
+		list.Snapshots = append(list.Snapshots, item)
+	}
+	return list, nil
+}
+
 func sortSnapshotInfos(infos []SnapshotInfo) {
 	sort.Slice(infos, func(i, j int) bool {
 		left, right := infos[i], infos[j]
@@ -1194,13 +1501,55 @@ func sortSnapshotInfos(infos []SnapshotInfo) {
 }
 
 func formatSnapshotLine(info SnapshotInfo) string {
+	parts := []string{info.Tag}
 	if info.CreatedAtRaw != "" {
-		return fmt.Sprintf("%s %s", info.Tag, info.CreatedAtRaw)
+		parts = append(parts, info.CreatedAtRaw)
+	} else if !info.CreatedAt.IsZero() {
+		parts = append(parts, info.CreatedAt.Format(time.RFC3339))
 	}
-	if !info.CreatedAt.IsZero() {
-		return fmt.Sprintf("%s %s", info.Tag, info.CreatedAt.Format(time.RFC3339))
+	if info.Name != "" {
+		parts = append(parts, "["+info.Name+"]")
 	}
-	return info.Tag
+	if info.Origin == snapshotOriginAuto {
+		parts = append(parts, "(auto)")
+	}
+	if info.Message != "" {
+		parts = append(parts, strconv.Quote(info.Message))
+	}
+	if info.Creator != "" {
+		parts = append(parts, "by "+info.Creator)
+	}
+	if info.Agent != "" {
+		parts = append(parts, "agent="+info.Agent)
+	}
+	if info.Size > 0 {
+		parts = append(parts, "("+formatByteSize(info.Size)+")")
+	}
+	if info.ImageID != "" {
+		parts = append(parts, "image="+shortImageID(info.ImageID))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(strings.TrimSpace(id), "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
 }
 
 func latestSnapshotRefFromInfos(app string, infos []SnapshotInfo) (string, error) {
@@ -1279,6 +1628,13 @@ func restoreSnapshot(containerName string, app string, port int, snapshotRef str
 	if !ok {
 		return fmt.Errorf("app volume does not exist")
 	}
+	// Hold the snapshot lock so retention cannot prune the snapshot while
+	// it is being restored.
+	unlock, err := lockSnapshots(app)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if err := ensureSnapshotExists(app, snapshotRef); err != nil {
 		return err
 	}
@@ -1395,7 +1751,7 @@ func runInteractiveSession(containerName string, app string, port int, agentArgs
 	}
 }
 
-func dockerRunArgs(name string, app string, port int, image string) []string {
+func dockerRunArgs(name string, app string, port int, image string, limits appLimits, extraPorts map[string]server.ExtraPort) []string {
 	hostRPC := hostRPCConfigForApp(app)
 	homeCfg := homeVolumeConfigForApp(app)
 	args := []string{
@@ -1406,6 +1762,16 @@ func dockerRunArgs(name string, app string, port int, image string) []string {
 		"-p",
 		fmt.Sprintf("%d:8080", port),
 	}
+	extraNames := make([]string, 0, len(extraPorts))
+	for extraName := range extraPorts {
+		extraNames = append(extraNames, extraName)
+	}
+	sort.Strings(extraNames)
+	for _, extraName := range extraNames {
+		extra := extraPorts[extraName]
+		args = append(args, "-p", fmt.Sprintf("%d:%d", extra.HostPort, extra.ContainerPort))
+	}
+	args = append(args, limits.dockerArgs()...)
 	args = append(args,
 		"-v",
 		fmt.Sprintf("%s:%s", homeCfg.MountDir, "/home/viberun"),
@@ -1462,6 +1828,13 @@ func handleAppsCommand() error {
 		names = append(names, name)
 	}
 	sort.Strings(names)
+	if jsonOutput {
+		apps := make([]serverapi.App, 0, len(names))
+		for _, name := range names {
+			apps = append(apps, serverapi.App{Name: name, Port: state.Ports[name]})
+		}
+		return writeJSONResult(serverapi.KindApps, serverapi.AppList{Apps: apps})
+	}
 	for _, name := range names {
 		fmt.Fprintln(os.Stdout, name)
 	}
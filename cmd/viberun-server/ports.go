@@ -0,0 +1,232 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/server"
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+const portsUsage = "usage: viberun-server <app> ports [list|add <name> <container-port> [path=/prefix] [access=public|private]|remove <name>]"
+
+// appContainerPort is where the app itself listens inside the container.
+const appContainerPort = 8080
+
+// handleAppPortsAction manages an app's named extra ports. Host ports are
+// allocated in the server state and routes are stored in proxy.toml; the
+// proxy picks up routes right away while new host ports are published on
+// the next update.
+func handleAppPortsAction(app string, args []string) error {
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch sub {
+	case "list", "show":
+		if len(args) > 1 {
+			return newUsageError(portsUsage)
+		}
+		state, err := loadState()
+		if err != nil {
+			return err
+		}
+		cfg, _, err := proxy.LoadConfig()
+		if err != nil {
+			return err
+		}
+		return printAppPorts(appPortsResult(cfg, state, app))
+	case "add", "set":
+		if len(args) < 3 {
+			return newUsageError(portsUsage)
+		}
+		return addAppPort(app, args[1], args[2], args[3:])
+	case "remove", "rm":
+		if len(args) != 2 {
+			return newUsageError(portsUsage)
+		}
+		return removeAppPort(app, args[1])
+	default:
+		return newUsageError(portsUsage)
+	}
+}
+
+func addAppPort(app string, rawName string, rawPort string, settings []string) error {
+	name, err := proxy.NormalizePortName(rawName)
+	if err != nil {
+		return err
+	}
+	containerPort, err := strconv.Atoi(strings.TrimSpace(rawPort))
+	if err != nil || containerPort < 1 || containerPort > 65535 {
+		return fmt.Errorf("invalid container port %q", rawPort)
+	}
+	if containerPort == appContainerPort {
+		return fmt.Errorf("port %d is already published as the app port", appContainerPort)
+	}
+	route, err := parsePortRouteSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	state, statePath, err := server.LoadState()
+	if err != nil {
+		return err
+	}
+	if _, ok := state.PortForApp(app); !ok {
+		return fmt.Errorf("app %s does not exist", app)
+	}
+	for other, extra := range state.ExtraPortsForApp(app) {
+		if other != name && extra.ContainerPort == containerPort {
+			return fmt.Errorf("container port %d is already published as %s", containerPort, other)
+		}
+	}
+	configPath, err := proxy.ConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := proxy.UpdateConfig(configPath, func(cfg *proxy.Config) error {
+		_, err := proxy.SetAppPortRoute(cfg, app, name, route)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	extra := state.SetExtraPort(app, name, containerPort)
+	if err := server.SaveState(statePath, state); err != nil {
+		return err
+	}
+	if err := syncProxyWithState(cfg, state); err != nil {
+		return err
+	}
+	info := appPortInfo(cfg, app, name, extra)
+	return printResult(serverapi.KindAppPorts, serverapi.AppPorts{App: app, Ports: []serverapi.AppExtraPort{info}}, func(out io.Writer) {
+		fmt.Fprintf(out, "Port %s saved for %s.\n", name, app)
+		fmt.Fprintln(out, formatAppPort(info))
+		fmt.Fprintln(out, "Run `update` to publish it on the container.")
+	})
+}
+
+func removeAppPort(app string, rawName string) error {
+	name, err := proxy.NormalizePortName(rawName)
+	if err != nil {
+		return err
+	}
+	state, statePath, err := server.LoadState()
+	if err != nil {
+		return err
+	}
+	configPath, err := proxy.ConfigPath()
+	if err != nil {
+		return err
+	}
+	routed := false
+	cfg, err := proxy.UpdateConfig(configPath, func(cfg *proxy.Config) error {
+		routed = proxy.RemoveAppPortRoute(cfg, app, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !state.RemoveExtraPort(app, name) && !routed {
+		return fmt.Errorf("app %s has no port named %s", app, name)
+	}
+	if err := server.SaveState(statePath, state); err != nil {
+		return err
+	}
+	if err := syncProxyWithState(cfg, state); err != nil {
+		return err
+	}
+	return printMessage("Port %s removed from %s. Run `update` to unpublish it from the container.", name, app)
+}
+
+// parsePortRouteSettings reads path= and access= settings. An empty path
+// routes the port on its own sub-host and an empty access inherits the
+// app's mode.
+func parsePortRouteSettings(settings []string) (proxy.PortRoute, error) {
+	var route proxy.PortRoute
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return proxy.PortRoute{}, fmt.Errorf("invalid setting %q (expected key=value)", setting)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			if value == "" || strings.EqualFold(value, "none") {
+				route.Path = ""
+				continue
+			}
+			path, err := proxy.NormalizePortPath(value)
+			if err != nil {
+				return proxy.PortRoute{}, err
+			}
+			route.Path = path
+		case "access":
+			switch strings.ToLower(value) {
+			case "", "inherit", "none":
+				route.Access = ""
+			case proxy.AccessPublic, proxy.AccessPrivate:
+				route.Access = strings.ToLower(value)
+			default:
+				return proxy.PortRoute{}, fmt.Errorf("access must be public, private, or inherit")
+			}
+		default:
+			return proxy.PortRoute{}, fmt.Errorf("unknown setting %q (use path or access)", key)
+		}
+	}
+	return route, nil
+}
+
+func appPortsResult(cfg proxy.Config, state server.State, app string) serverapi.AppPorts {
+	result := serverapi.AppPorts{App: app, Ports: []serverapi.AppExtraPort{}}
+	ports := state.ExtraPortsForApp(app)
+	for _, name := range state.ExtraPortNames(app) {
+		result.Ports = append(result.Ports, appPortInfo(cfg, app, name, ports[name]))
+	}
+	return result
+}
+
+func appPortInfo(cfg proxy.Config, app string, name string, extra server.ExtraPort) serverapi.AppExtraPort {
+	info := serverapi.AppExtraPort{
+		Name:          name,
+		ContainerPort: extra.ContainerPort,
+		HostPort:      extra.HostPort,
+		Path:          proxy.PortRouteForApp(cfg, app, name).Path,
+		Access:        proxy.EffectivePortAccess(cfg, app, name),
+	}
+	if cfg.Enabled && strings.TrimSpace(cfg.BaseDomain) != "" {
+		info.URL = proxy.PublicURLForPort(cfg, app, name)
+	}
+	return info
+}
+
+func printAppPorts(result serverapi.AppPorts) error {
+	return printResult(serverapi.KindAppPorts, result, func(out io.Writer) {
+		if len(result.Ports) == 0 {
+			fmt.Fprintf(out, "No extra ports for %s\n", result.App)
+			return
+		}
+		for _, port := range result.Ports {
+			fmt.Fprintln(out, formatAppPort(port))
+		}
+	})
+}
+
+func formatAppPort(port serverapi.AppExtraPort) string {
+	route := "sub-host"
+	if port.Path != "" {
+		route = "path " + port.Path
+	}
+	line := fmt.Sprintf("%s  container:%d  host:%d  %s  %s", port.Name, port.ContainerPort, port.HostPort, route, port.Access)
+	if port.URL != "" {
+		line += "  " + port.URL
+	}
+	return line
+}
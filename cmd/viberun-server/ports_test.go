@@ -0,0 +1,55 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/server"
+)
+
+func TestParsePortRouteSettings(t *testing.T) {
+	route, err := parsePortRouteSettings([]string{"path=admin/", "access=Private"})
+	if err != nil {
+		t.Fatalf("parse settings: %v", err)
+	}
+	if route.Path != "/admin" || route.Access != proxy.AccessPrivate {
+		t.Fatalf("unexpected route: %+v", route)
+	}
+	route, err = parsePortRouteSettings([]string{"path=none", "access=inherit"})
+	if err != nil || route != (proxy.PortRoute{}) {
+		t.Fatalf("expected sub-host route with inherited access, got %+v (%v)", route, err)
+	}
+	for _, setting := range []string{"path=/__viberun", "access=secret", "host=api", "path"} {
+		if _, err := parsePortRouteSettings([]string{setting}); err == nil {
+			t.Fatalf("expected error for %q", setting)
+		}
+	}
+}
+
+func TestDockerRunArgsPublishesExtraPorts(t *testing.T) {
+	t.Setenv("VIBERUN_XDG_OPEN_SOCKET", "")
+	extra := map[string]server.ExtraPort{
+		"api":   {ContainerPort: 3000, HostPort: 8081},
+		"admin": {ContainerPort: 9000, HostPort: 8082},
+	}
+	args := dockerRunArgs("viberun-myapp", "myapp", 4242, "viberun:test", builtinLimits(), extra)
+	for _, mapping := range []string{"4242:8080", "8081:3000", "8082:9000"} {
+		if !hasPair(args, "-p", mapping) {
+			t.Fatalf("expected -p %s in args: %v", mapping, args)
+		}
+	}
+}
+
+func TestParseActionPorts(t *testing.T) {
+	action, args, err := parseAction([]string{"ports", "add", "api", "3000", "path=/api"})
+	if err != nil {
+		t.Fatalf("parse action: %v", err)
+	}
+	if action != "ports" || len(args) != 4 || args[0] != "add" {
+		t.Fatalf("unexpected action %q args %v", action, args)
+	}
+}
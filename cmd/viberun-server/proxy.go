@@ -11,20 +11,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/shayne/viberun/internal/auth"
 	"github.com/shayne/viberun/internal/hostcmd"
 	"github.com/shayne/viberun/internal/proxy"
 	"github.com/shayne/viberun/internal/server"
+	"github.com/shayne/viberun/internal/serverapi"
+	"github.com/shayne/viberun/internal/totp"
 	"github.com/shayne/yargs"
 )
 
 var errProxyUnavailable = errors.New("proxy is not configured")
 
+// proxyAuthStateDir holds state that viberun-auth writes, such as the
+// session store and the proxy.toml lock.
+const proxyAuthStateDir = "/var/lib/viberun/auth"
+
+// proxyConfigMount is where proxy.toml is mounted, read-only, in the proxy
+// container. viberun-auth writes only to proxyAuthStateDir.
+const proxyConfigMount = "/var/lib/viberun/proxy.toml"
+
 type proxySetupFlags struct {
 	Domain         string `flag:"domain" help:"base domain for app URLs"`
 	PublicIP       string `flag:"public-ip" help:"public IP address for DNS"`
@@ -38,7 +50,7 @@ type proxySetupFlags struct {
 
 func handleProxyCommand(args []string) error {
 	if len(args) == 0 || hasHelpFlag(args) {
-		return fmt.Errorf("usage: viberun-server proxy setup --domain <domain> --public-ip <ip> --username <u> --password-stdin | viberun-server proxy config | viberun-server proxy url <app> | viberun-server proxy info <app> | viberun-server proxy users <list|add|remove|set-password>")
+		return fmt.Errorf("usage: viberun-server proxy setup --domain <domain> --public-ip <ip> --username <u> --password-stdin | viberun-server proxy config | viberun-server proxy url <app> | viberun-server proxy info <app> | viberun-server proxy rotate-key | viberun-server proxy share <app> <create|list|revoke> | viberun-server proxy users <list|add|remove|set-password|add-group|remove-group|sessions|revoke|totp|token|audit>")
 	}
 	switch args[0] {
 	case "setup":
@@ -49,29 +61,30 @@ func handleProxyCommand(args []string) error {
 		return handleProxyURL(args[1:])
 	case "info":
 		return handleProxyInfo(args[1:])
+	case "rotate-key":
+		return handleProxyRotateKey(args[1:])
 	case "set-access":
 		return handleProxySetAccess(args[1:])
 	case "set-domain":
 		return handleProxySetDomain(args[1:])
 	case "set-users":
 		return handleProxySetUsers(args[1:])
+	case "set-groups":
+		return handleProxySetGroups(args[1:])
 	case "set-disabled":
 		return handleProxySetDisabled(args[1:])
+	case "set-ip":
+		return handleProxySetIP(args[1:])
 	case "users":
 		return handleProxyUsers(args[1:])
+	case "share":
+		return handleProxyShare(args[1:])
 	default:
-		return fmt.Errorf("usage: viberun-server proxy setup --domain <domain> --public-ip <ip> --username <u> --password-stdin | viberun-server proxy config | viberun-server proxy url <app> | viberun-server proxy info <app> | viberun-server proxy users <list|add|remove|set-password>")
+		return fmt.Errorf("usage: viberun-server proxy setup --domain <domain> --public-ip <ip> --username <u> --password-stdin | viberun-server proxy config | viberun-server proxy url <app> | viberun-server proxy info <app> | viberun-server proxy rotate-key | viberun-server proxy share <app> <create|list|revoke> | viberun-server proxy users <list|add|remove|set-password|add-group|remove-group|sessions|revoke|totp|token|audit>")
 	}
 }
 
-type proxyConfigSummary struct {
-	Enabled     bool     `json:"enabled"`
-	BaseDomain  string   `json:"base_domain"`
-	PublicIP    string   `json:"public_ip"`
-	PrimaryUser string   `json:"primary_user"`
-	ProxyImage  string   `json:"proxy_image"`
-	Users       []string `json:"users"`
-}
+type proxyConfigSummary = serverapi.ProxyConfig
 
 func handleProxyConfig() error {
 	cfg, _, err := proxy.LoadConfig()
@@ -94,6 +107,9 @@ func handleProxyConfig() error {
 		ProxyImage:  cfg.ProxyImage,
 		Users:       proxy.NormalizeUserList(users),
 	}
+	if jsonOutput {
+		return writeJSONResult(serverapi.KindProxyConfig, summary)
+	}
 	data, err := json.Marshal(summary)
 	if err != nil {
 		return err
@@ -117,51 +133,10 @@ func handleProxySetup(args []string) error {
 		return fmt.Errorf("public IP is required")
 	}
 
-	cfg, _, err := proxy.LoadConfig()
-	if err != nil {
-		return err
-	}
-	cfg.Enabled = true
-	cfg.BaseDomain = domain
-	cfg.PublicIP = publicIP
-	cfg.AdminAddr = strings.TrimSpace(flags.AdminAddr)
-	cfg.CaddyContainer = strings.TrimSpace(flags.CaddyContainer)
-	if strings.TrimSpace(flags.ProxyImage) != "" {
-		cfg.ProxyImage = strings.TrimSpace(flags.ProxyImage)
-	}
-	if strings.TrimSpace(flags.Username) != "" {
-		cfg.PrimaryUser = strings.TrimSpace(flags.Username)
-	}
-	if strings.TrimSpace(flags.AuthKey) != "" {
-		cfg.Auth.SigningKey = strings.TrimSpace(flags.AuthKey)
-	}
-	if strings.TrimSpace(cfg.Auth.SigningKey) == "" {
-		key, err := proxy.GenerateSigningKey()
-		if err != nil {
-			return err
-		}
-		cfg.Auth.SigningKey = key
-	}
 	password, err := readPasswordIfRequested(flags.PasswordStdin)
 	if err != nil {
 		return err
 	}
-	if password != "" {
-		if strings.TrimSpace(cfg.PrimaryUser) == "" {
-			return fmt.Errorf("username is required when setting a password")
-		}
-		if err := proxy.UpsertUser(&cfg, cfg.PrimaryUser, password); err != nil {
-			return err
-		}
-	}
-	if strings.TrimSpace(cfg.PrimaryUser) == "" {
-		return fmt.Errorf("primary username is required")
-	}
-	if len(cfg.Users) == 0 {
-		return fmt.Errorf("at least one user is required")
-	}
-
-	cfg = applyProxyDefaults(cfg)
 
 	if _, err := exec.LookPath("docker"); err != nil {
 		return fmt.Errorf("docker is required but was not found in PATH")
@@ -170,7 +145,8 @@ func handleProxySetup(args []string) error {
 		return err
 	}
 
-	running, err := caddyContainerRunning(cfg.CaddyContainer)
+	container := applyProxyDefaults(proxy.Config{CaddyContainer: strings.TrimSpace(flags.CaddyContainer)}).CaddyContainer
+	running, err := caddyContainerRunning(container)
 	if err != nil {
 		return err
 	}
@@ -180,13 +156,46 @@ func handleProxySetup(args []string) error {
 		}
 	}
 
-	path, err := proxy.ConfigPath()
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		cfg.Enabled = true
+		cfg.BaseDomain = domain
+		cfg.PublicIP = publicIP
+		cfg.AdminAddr = strings.TrimSpace(flags.AdminAddr)
+		cfg.CaddyContainer = container
+		if strings.TrimSpace(flags.ProxyImage) != "" {
+			cfg.ProxyImage = strings.TrimSpace(flags.ProxyImage)
+		}
+		if strings.TrimSpace(flags.Username) != "" {
+			cfg.PrimaryUser = strings.TrimSpace(flags.Username)
+		}
+		if strings.TrimSpace(flags.AuthKey) != "" {
+			proxy.AddSigningKey(cfg, flags.AuthKey, time.Now())
+		}
+		if !proxy.HasSigningKey(*cfg) {
+			if _, _, err := proxy.RotateSigningKey(cfg, time.Now()); err != nil {
+				return err
+			}
+		}
+		if password != "" {
+			if strings.TrimSpace(cfg.PrimaryUser) == "" {
+				return fmt.Errorf("username is required when setting a password")
+			}
+			if err := proxy.UpsertUser(cfg, cfg.PrimaryUser, password); err != nil {
+				return err
+			}
+		}
+		if strings.TrimSpace(cfg.PrimaryUser) == "" {
+			return fmt.Errorf("primary username is required")
+		}
+		if len(cfg.Users) == 0 {
+			return fmt.Errorf("at least one user is required")
+		}
+		*cfg = applyProxyDefaults(*cfg)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		return err
-	}
 	if err := ensureCaddyContainer(cfg.CaddyContainer, cfg.ProxyImage); err != nil {
 		return err
 	}
@@ -213,23 +222,12 @@ func handleProxyURL(args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(os.Stdout, url)
-	return nil
+	return printResult(serverapi.KindProxyURL, serverapi.ProxyURL{App: app, URL: url}, func(out io.Writer) {
+		fmt.Fprintln(out, url)
+	})
 }
 
-type proxyInfo struct {
-	App          string   `json:"app"`
-	URL          string   `json:"url"`
-	Access       string   `json:"access"`
-	Disabled     bool     `json:"disabled"`
-	CustomDomain string   `json:"custom_domain"`
-	AllowedUsers []string `json:"allowed_users"`
-	PrimaryUser  string   `json:"primary_user"`
-	Users        []string `json:"users"`
-	BaseDomain   string   `json:"base_domain"`
-	PublicIP     string   `json:"public_ip"`
-	Enabled      bool     `json:"enabled"`
-}
+type proxyInfo = serverapi.ProxyInfo
 
 func handleProxyInfo(args []string) error {
 	if len(args) != 1 {
@@ -248,22 +246,72 @@ func handleProxyInfo(args []string) error {
 	}
 	access := proxy.EffectiveAppAccess(cfg, app)
 	info := proxyInfo{
-		App:          app,
-		URL:          proxy.PublicURLForApp(cfg, app),
-		Access:       access.Access,
-		Disabled:     access.Disabled,
-		CustomDomain: access.CustomDomain,
-		AllowedUsers: proxy.EffectiveAllowedUsers(cfg, app),
-		PrimaryUser:  cfg.PrimaryUser,
-		Users:        proxy.Usernames(cfg),
-		BaseDomain:   cfg.BaseDomain,
-		PublicIP:     cfg.PublicIP,
-		Enabled:      cfg.Enabled,
+		App:           app,
+		URL:           proxy.PublicURLForApp(cfg, app),
+		Access:        access.Access,
+		Disabled:      access.Disabled,
+		CustomDomain:  access.CustomDomain,
+		AllowedUsers:  proxy.EffectiveAllowedUsers(cfg, app),
+		AllowedGroups: access.AllowedGroups,
+		AllowIPs:      access.AllowIPs,
+		DenyIPs:       access.DenyIPs,
+		PrimaryUser:   cfg.PrimaryUser,
+		Users:         proxy.Usernames(cfg),
+		Groups:        proxy.KnownGroups(cfg),
+		BaseDomain:    cfg.BaseDomain,
+		PublicIP:      cfg.PublicIP,
+		Enabled:       cfg.Enabled,
+	}
+	if jsonOutput {
+		return writeJSONResult(serverapi.KindProxyInfo, info)
 	}
 	encoder := json.NewEncoder(os.Stdout)
 	return encoder.Encode(info)
 }
 
+// handleProxyRotateKey makes a new signing key active. Older keys keep
+// verifying cookies until one cookie TTL after they were replaced, so
+// nobody is logged out by a rotation.
+func handleProxyRotateKey(args []string) error {
+	if len(args) != 0 {
+		return newUsageError("usage: viberun-server proxy rotate-key")
+	}
+	now := time.Now()
+	var active proxy.SigningKey
+	var retired []proxy.SigningKey
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		if !cfg.Enabled {
+			return errProxyUnavailable
+		}
+		var err error
+		active, retired, err = proxy.RotateSigningKey(cfg, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	result := serverapi.ProxyRotateKey{Active: active.ID, Verify: []string{}, Retired: []string{}}
+	for _, key := range proxy.VerifySigningKeys(cfg, now) {
+		result.Verify = append(result.Verify, key.ID)
+	}
+	for _, key := range retired {
+		result.Retired = append(result.Retired, key.ID)
+	}
+	return printResult(serverapi.KindProxyRotateKey, result, func(out io.Writer) {
+		writeProxyRotateKey(out, result, proxy.CookieTTL(cfg))
+	})
+}
+
+func writeProxyRotateKey(out io.Writer, result serverapi.ProxyRotateKey, ttl time.Duration) {
+	fmt.Fprintf(out, "Active signing key: %s\n", result.Active)
+	if len(result.Verify) > 1 {
+		fmt.Fprintf(out, "Still accepted: %s (each until %s after it was replaced)\n", strings.Join(result.Verify[:len(result.Verify)-1], ", "), ttl)
+	}
+	if len(result.Retired) > 0 {
+		fmt.Fprintf(out, "Retired: %s\n", strings.Join(result.Retired, ", "))
+	}
+}
+
 type proxyAccessFlags struct {
 	Access string `flag:"access" help:"public or private"`
 }
@@ -284,16 +332,12 @@ func handleProxySetAccess(args []string) error {
 	if access != proxy.AccessPublic && access != proxy.AccessPrivate {
 		return fmt.Errorf("access must be public or private")
 	}
-	cfg, path, err := proxy.LoadConfig()
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		return setProxyAccess(cfg, app, access)
+	})
 	if err != nil {
 		return err
 	}
-	if err := setProxyAccess(&cfg, app, access); err != nil {
-		return err
-	}
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		return err
-	}
 	state, err := loadState()
 	if err != nil {
 		return err
@@ -318,26 +362,24 @@ func handleProxySetDomain(args []string) error {
 	if err != nil {
 		return err
 	}
-	cfg, path, err := proxy.LoadConfig()
-	if err != nil {
-		return err
-	}
-	appCfg := cfg.Apps[app]
-	if result.Flags.Clear {
-		appCfg.CustomDomain = ""
-	} else {
+	customDomain := ""
+	if !result.Flags.Clear {
 		domain := strings.TrimSpace(result.Flags.Domain)
 		if domain == "" {
 			return fmt.Errorf("domain is required")
 		}
-		normalized, err := proxy.NormalizeDomainSuffix(domain)
+		customDomain, err = proxy.NormalizeDomainSuffix(domain)
 		if err != nil {
 			return err
 		}
-		appCfg.CustomDomain = normalized
 	}
-	cfg.Apps[app] = appCfg
-	if err := proxy.SaveConfig(path, cfg); err != nil {
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		appCfg := cfg.Apps[app]
+		appCfg.CustomDomain = customDomain
+		cfg.Apps[app] = appCfg
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 	state, err := loadState()
@@ -347,6 +389,130 @@ func handleProxySetDomain(args []string) error {
 	return syncProxyWithState(cfg, state)
 }
 
+type proxyShareFlags struct {
+	TTL  string `flag:"ttl" help:"link lifetime such as 24h"`
+	Path string `flag:"path" help:"path to open, such as /demo"`
+	ID   string `flag:"id" help:"share id to revoke"`
+}
+
+// handleProxyShare manages expiring links that open one app without an
+// account.
+func handleProxyShare(args []string) error {
+	const usage = "usage: viberun-server proxy share <app> create [--ttl <d>] [--path <p>] | list | revoke --id <id>"
+	if len(args) < 2 {
+		return newUsageError(usage)
+	}
+	// Shares are matched against the normalized app name from the host.
+	app, err := proxy.NormalizeAppName(args[0])
+	if err != nil {
+		return err
+	}
+	result, err := yargs.ParseFlags[proxyShareFlags](args[2:])
+	if err != nil {
+		return err
+	}
+	cfg, _, err := proxy.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled || strings.TrimSpace(cfg.BaseDomain) == "" {
+		return errProxyUnavailable
+	}
+	now := time.Now()
+	switch args[1] {
+	case "list":
+		shares := serverapi.ProxyShares{App: app, Shares: []serverapi.ProxyShare{}}
+		for _, link := range proxy.ActiveShareLinks(cfg, app, now) {
+			shares.Shares = append(shares.Shares, proxyShareInfo(app, link))
+		}
+		return printResult(serverapi.KindProxyShares, shares, func(out io.Writer) {
+			if len(shares.Shares) == 0 {
+				fmt.Fprintf(out, "No active share links for %s\n", app)
+				return
+			}
+			for _, share := range shares.Shares {
+				fmt.Fprintln(out, formatProxyShare(share))
+			}
+		})
+	case "revoke":
+		id := strings.TrimSpace(result.Flags.ID)
+		if id == "" {
+			return newUsageError("usage: viberun-server proxy share <app> revoke --id <id>")
+		}
+		_, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+			if !proxy.RemoveShareLink(cfg, app, id) {
+				return fmt.Errorf("share %s not found for %s", id, app)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		info := serverapi.ProxyShare{ID: id, App: app}
+		return printResult(serverapi.KindProxyShare, info, func(out io.Writer) {
+			fmt.Fprintf(out, "Revoked share %s for %s\n", id, app)
+		})
+	case "create":
+	default:
+		return newUsageError(usage)
+	}
+	state, _, err := server.LoadState()
+	if err != nil {
+		return err
+	}
+	if _, ok := state.PortForApp(app); !ok {
+		return fmt.Errorf("app %s does not exist", app)
+	}
+	ttl := proxy.DefaultShareTTL
+	if raw := strings.TrimSpace(result.Flags.TTL); raw != "" {
+		ttl, err = time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q (use a duration like 24h)", raw)
+		}
+	}
+	base := proxy.PublicURLForApp(cfg, app)
+	if base == "" {
+		return fmt.Errorf("app %s has no public URL", app)
+	}
+	var link proxy.ShareLink
+	var token string
+	_, _, err = proxy.EditConfig(func(cfg *proxy.Config) error {
+		signingKey, ok := proxy.ActiveSigningKey(*cfg)
+		if !ok {
+			return fmt.Errorf("proxy auth signing key is not configured")
+		}
+		var err error
+		link, err = proxy.AddShareLink(cfg, app, result.Flags.Path, ttl, now)
+		if err != nil {
+			return err
+		}
+		token, err = auth.SignShareGrant(auth.ShareGrant{ID: link.ID, App: app, ExpiresAt: link.ExpiresAt.Unix()}, auth.SigningKey{ID: signingKey.ID, Secret: []byte(signingKey.Key)})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	info := proxyShareInfo(app, link)
+	info.URL = base + "/__viberun/auth/share?t=" + url.QueryEscape(token)
+	return printResult(serverapi.KindProxyShare, info, func(out io.Writer) {
+		fmt.Fprintf(out, "Share link for %s (expires %s):\n%s\n", app, info.ExpiresAt.Local().Format("2006-01-02 15:04"), info.URL)
+	})
+}
+
+func proxyShareInfo(app string, link proxy.ShareLink) serverapi.ProxyShare {
+	return serverapi.ProxyShare{
+		ID:        link.ID,
+		App:       app,
+		Path:      link.Path,
+		CreatedAt: link.CreatedAt,
+		ExpiresAt: link.ExpiresAt,
+	}
+}
+
+func formatProxyShare(share serverapi.ProxyShare) string {
+	return fmt.Sprintf("%s  %s  created %s  expires %s", share.ID, share.Path, share.CreatedAt.Local().Format("2006-01-02 15:04"), share.ExpiresAt.Local().Format("2006-01-02 15:04"))
+}
+
 type proxyUsersFlags struct {
 	Users string `flag:"users" help:"comma-separated list of secondary users"`
 }
@@ -363,18 +529,53 @@ func handleProxySetUsers(args []string) error {
 	if err != nil {
 		return err
 	}
-	cfg, path, err := proxy.LoadConfig()
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		secondary := parseUsersList(result.Flags.Users, cfg.PrimaryUser)
+		if err := validateUsersExist(*cfg, secondary); err != nil {
+			return err
+		}
+		appCfg := cfg.Apps[app]
+		appCfg.AllowedUsers = secondary
+		cfg.Apps[app] = appCfg
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	secondary := parseUsersList(result.Flags.Users, cfg.PrimaryUser)
-	if err := validateUsersExist(cfg, secondary); err != nil {
+	state, err := loadState()
+	if err != nil {
 		return err
 	}
-	appCfg := cfg.Apps[app]
-	appCfg.AllowedUsers = secondary
-	cfg.Apps[app] = appCfg
-	if err := proxy.SaveConfig(path, cfg); err != nil {
+	return syncProxyWithState(cfg, state)
+}
+
+type proxyGroupsFlags struct {
+	Groups string `flag:"groups" help:"comma-separated list of groups, or none"`
+}
+
+func handleProxySetGroups(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: viberun-server proxy set-groups <app> --groups <group1,group2|none>")
+	}
+	app := strings.TrimSpace(args[0])
+	if app == "" {
+		return fmt.Errorf("app name is required")
+	}
+	result, err := yargs.ParseFlags[proxyGroupsFlags](args[1:])
+	if err != nil {
+		return err
+	}
+	groups, err := proxy.ParseGroupList(result.Flags.Groups)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		appCfg := cfg.Apps[app]
+		appCfg.AllowedGroups = groups
+		cfg.Apps[app] = appCfg
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 	state, err := loadState()
@@ -408,12 +609,67 @@ func handleProxySetDisabled(args []string) error {
 	if result.Flags.Enabled {
 		disabled = false
 	}
-	cfg, path, err := proxy.LoadConfig()
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		setProxyDisabled(cfg, app, disabled)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
 	if err != nil {
 		return err
 	}
-	setProxyDisabled(&cfg, app, disabled)
-	if err := proxy.SaveConfig(path, cfg); err != nil {
+	return syncProxyWithState(cfg, state)
+}
+
+type proxyIPFlags struct {
+	Allow string `flag:"allow" help:"allow clients in this CIDR"`
+	Deny  string `flag:"deny" help:"block clients in this CIDR"`
+	Clear string `flag:"clear" help:"remove a CIDR, or all to remove every rule"`
+}
+
+// handleProxySetIP edits an app's IP allow and deny lists.
+func handleProxySetIP(args []string) error {
+	const usage = "usage: viberun-server proxy set-ip <app> --allow <cidr> | --deny <cidr> | --clear <cidr|all>"
+	if len(args) < 1 {
+		return newUsageError(usage)
+	}
+	app := strings.TrimSpace(args[0])
+	if app == "" {
+		return fmt.Errorf("app name is required")
+	}
+	result, err := yargs.ParseFlags[proxyIPFlags](args[1:])
+	if err != nil {
+		return err
+	}
+	flags := result.Flags
+	set := 0
+	for _, value := range []string{flags.Allow, flags.Deny, flags.Clear} {
+		if strings.TrimSpace(value) != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return newUsageError(usage)
+	}
+	cfg, _, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		var err error
+		switch {
+		case strings.TrimSpace(flags.Allow) != "":
+			_, err = proxy.AddAppIPRule(cfg, app, proxy.IPRuleAllow, flags.Allow)
+		case strings.TrimSpace(flags.Deny) != "":
+			_, err = proxy.AddAppIPRule(cfg, app, proxy.IPRuleDeny, flags.Deny)
+		default:
+			cidr := strings.TrimSpace(flags.Clear)
+			if strings.EqualFold(cidr, "all") {
+				cidr = ""
+			}
+			_, err = proxy.ClearAppIPRules(cfg, app, cidr)
+		}
+		return err
+	})
+	if err != nil {
 		return err
 	}
 	state, err := loadState()
@@ -453,11 +709,12 @@ func setProxyDisabled(cfg *proxy.Config, app string, disabled bool) {
 type proxyUserFlags struct {
 	Username      string `flag:"username" help:"username"`
 	PasswordStdin bool   `flag:"password-stdin" help:"read password from stdin"`
+	Group         string `flag:"group" help:"group name"`
 }
 
 func handleProxyUsers(args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("usage: viberun-server proxy users <list|add|remove|set-password>")
+		return fmt.Errorf("usage: viberun-server proxy users <list|add|remove|set-password|add-group|remove-group|sessions|revoke|totp|token|audit>")
 	}
 	switch args[0] {
 	case "list":
@@ -468,8 +725,22 @@ func handleProxyUsers(args []string) error {
 		return handleProxyUsersRemove(args[1:])
 	case "set-password":
 		return handleProxyUsersSetPassword(args[1:])
+	case "add-group":
+		return handleProxyUsersGroup(args[1:], true)
+	case "remove-group":
+		return handleProxyUsersGroup(args[1:], false)
+	case "sessions":
+		return handleProxyUsersSessions(args[1:])
+	case "revoke":
+		return handleProxyUsersRevoke(args[1:])
+	case "totp":
+		return handleProxyUsersTOTP(args[1:])
+	case "token":
+		return handleProxyUsersToken(args[1:])
+	case "audit":
+		return handleProxyUsersAudit(args[1:])
 	default:
-		return fmt.Errorf("usage: viberun-server proxy users <list|add|remove|set-password>")
+		return fmt.Errorf("usage: viberun-server proxy users <list|add|remove|set-password|add-group|remove-group|sessions|revoke|totp|token|audit>")
 	}
 }
 
@@ -478,10 +749,38 @@ func handleProxyUsersList() error {
 	if err != nil {
 		return err
 	}
-	for _, user := range proxy.Usernames(cfg) {
-		fmt.Fprintln(os.Stdout, user)
+	users := proxy.Usernames(cfg)
+	groups := map[string][]string{}
+	for _, user := range cfg.Users {
+		if len(user.Groups) > 0 {
+			groups[user.Username] = user.Groups
+		}
 	}
-	return nil
+	return printResult(serverapi.KindProxyUsers, serverapi.ProxyUsers{Users: users, Groups: groups}, func(out io.Writer) {
+		for _, user := range users {
+			if len(groups[user]) == 0 {
+				fmt.Fprintln(out, user)
+				continue
+			}
+			fmt.Fprintf(out, "%s (%s)\n", user, strings.Join(groups[user], ", "))
+		}
+	})
+}
+
+func handleProxyUsersGroup(args []string, member bool) error {
+	result, err := yargs.ParseFlags[proxyUserFlags](args)
+	if err != nil {
+		return err
+	}
+	username := strings.TrimSpace(result.Flags.Username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	_, _, err = proxy.EditConfig(func(cfg *proxy.Config) error {
+		_, err := proxy.SetUserGroup(cfg, username, result.Flags.Group, member)
+		return err
+	})
+	return err
 }
 
 func handleProxyUsersAdd(args []string) error {
@@ -500,15 +799,16 @@ func handleProxyUsersAdd(args []string) error {
 	if password == "" {
 		return fmt.Errorf("password is required")
 	}
-	cfg, path, err := proxy.LoadConfig()
+	existed := false
+	cfg, path, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		existed = proxyUserExists(*cfg, username)
+		return proxy.UpsertUser(cfg, username, password)
+	})
 	if err != nil {
 		return err
 	}
-	if err := proxy.UpsertUser(&cfg, username, password); err != nil {
-		return err
-	}
-	if err := proxy.SaveConfig(path, cfg); err != nil {
-		return err
+	if existed {
+		revokeProxySessions(path, username)
 	}
 	state, err := loadState()
 	if err != nil {
@@ -526,24 +826,54 @@ func handleProxyUsersRemove(args []string) error {
 	if username == "" {
 		return fmt.Errorf("username is required")
 	}
-	cfg, path, err := proxy.LoadConfig()
+	cfg, path, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		if strings.EqualFold(cfg.PrimaryUser, username) {
+			return fmt.Errorf("cannot remove the primary user")
+		}
+		removed, err := proxy.RemoveUser(cfg, username)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("user not found")
+		}
+		removeUserFromApps(cfg, username)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	revokeProxySessions(path, username)
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	return syncProxyWithState(cfg, state)
+}
+
+func handleProxyUsersSetPassword(args []string) error {
+	result, err := yargs.ParseFlags[proxyUserFlags](args)
 	if err != nil {
 		return err
 	}
-	if strings.EqualFold(cfg.PrimaryUser, username) {
-		return fmt.Errorf("cannot remove the primary user")
+	username := strings.TrimSpace(result.Flags.Username)
+	if username == "" {
+		return fmt.Errorf("username is required")
 	}
-	removed, err := proxy.RemoveUser(&cfg, username)
+	password, err := readPasswordIfRequested(result.Flags.PasswordStdin)
 	if err != nil {
 		return err
 	}
-	if !removed {
-		return fmt.Errorf("user not found")
+	if password == "" {
+		return fmt.Errorf("password is required")
 	}
-	removeUserFromApps(&cfg, username)
-	if err := proxy.SaveConfig(path, cfg); err != nil {
+	cfg, path, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		return proxy.UpsertUser(cfg, username, password)
+	})
+	if err != nil {
 		return err
 	}
+	revokeProxySessions(path, username)
 	state, err := loadState()
 	if err != nil {
 		return err
@@ -551,8 +881,14 @@ func handleProxyUsersRemove(args []string) error {
 	return syncProxyWithState(cfg, state)
 }
 
-func handleProxyUsersSetPassword(args []string) error {
-	result, err := yargs.ParseFlags[proxyUserFlags](args)
+type proxySessionFlags struct {
+	Username string `flag:"username" help:"username"`
+	Session  string `flag:"session" help:"session id to revoke"`
+	All      bool   `flag:"all" help:"revoke every session for the user"`
+}
+
+func handleProxyUsersSessions(args []string) error {
+	result, err := yargs.ParseFlags[proxySessionFlags](args)
 	if err != nil {
 		return err
 	}
@@ -560,28 +896,368 @@ func handleProxyUsersSetPassword(args []string) error {
 	if username == "" {
 		return fmt.Errorf("username is required")
 	}
-	password, err := readPasswordIfRequested(result.Flags.PasswordStdin)
+	path, err := proxy.ConfigPath()
 	if err != nil {
 		return err
 	}
-	if password == "" {
-		return fmt.Errorf("password is required")
+	records, err := auth.NewSessionStore(proxy.SessionStorePath(path)).List(username)
+	if err != nil {
+		return err
 	}
-	cfg, path, err := proxy.LoadConfig()
+	sessions := serverapi.ProxySessions{Username: username, Sessions: []serverapi.ProxySession{}}
+	for _, record := range records {
+		sessions.Sessions = append(sessions.Sessions, serverapi.ProxySession{
+			ID:        record.ID(),
+			IssuedAt:  time.Unix(record.IssuedAt, 0).UTC(),
+			ExpiresAt: time.Unix(record.ExpiresAt, 0).UTC(),
+			ClientIP:  record.ClientIP,
+			UserAgent: record.UserAgent,
+		})
+	}
+	return printResult(serverapi.KindProxySessions, sessions, func(out io.Writer) {
+		writeProxySessions(out, sessions)
+	})
+}
+
+func writeProxySessions(out io.Writer, sessions serverapi.ProxySessions) {
+	if len(sessions.Sessions) == 0 {
+		fmt.Fprintf(out, "No active sessions for %s\n", sessions.Username)
+		return
+	}
+	for _, session := range sessions.Sessions {
+		line := fmt.Sprintf("%s  signed in %s  expires %s", session.ID, session.IssuedAt.Local().Format("2006-01-02 15:04"), session.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		if session.ClientIP != "" {
+			line += "  " + session.ClientIP
+		}
+		if session.UserAgent != "" {
+			line += "  " + session.UserAgent
+		}
+		fmt.Fprintln(out, line)
+	}
+}
+
+type proxyAuditFlags struct {
+	User  string `flag:"user" help:"only events for this user"`
+	App   string `flag:"app" help:"only events for this app"`
+	Since string `flag:"since" help:"only events newer than this duration, such as 1h"`
+	Limit int    `flag:"limit" help:"most recent events to show (default 100)"`
+}
+
+// handleProxyUsersAudit prints the auth audit log written by viberun-auth.
+func handleProxyUsersAudit(args []string) error {
+	result, err := yargs.ParseFlags[proxyAuditFlags](args)
 	if err != nil {
 		return err
 	}
-	if err := proxy.UpsertUser(&cfg, username, password); err != nil {
+	filter := auth.AuditFilter{User: strings.TrimSpace(result.Flags.User), App: strings.TrimSpace(result.Flags.App)}
+	if raw := strings.TrimSpace(result.Flags.Since); raw != "" {
+		since, err := time.ParseDuration(raw)
+		if err != nil || since <= 0 {
+			return fmt.Errorf("invalid since %q (use a duration like 1h)", raw)
+		}
+		filter.Since = time.Now().Add(-since)
+	}
+	limit := result.Flags.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	path, err := proxy.ConfigPath()
+	if err != nil {
 		return err
 	}
-	if err := proxy.SaveConfig(path, cfg); err != nil {
+	events, err := auth.ReadAuditLog(proxy.AuditLogPath(path), filter)
+	if err != nil {
 		return err
 	}
-	state, err := loadState()
+	audit := serverapi.ProxyAudit{Events: []serverapi.ProxyAuditEvent{}}
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+		audit.Truncated = true
+	}
+	for _, event := range events {
+		audit.Events = append(audit.Events, serverapi.ProxyAuditEvent(event))
+	}
+	return printResult(serverapi.KindProxyAudit, audit, func(out io.Writer) {
+		if len(audit.Events) == 0 {
+			fmt.Fprintln(out, "No matching audit events")
+			return
+		}
+		for _, event := range audit.Events {
+			fmt.Fprintln(out, formatProxyAuditEvent(event))
+		}
+	})
+}
+
+func formatProxyAuditEvent(event serverapi.ProxyAuditEvent) string {
+	line := fmt.Sprintf("%s  %s %s", event.Time.Local().Format("2006-01-02 15:04:05"), event.Event, event.Outcome)
+	if event.User != "" {
+		line += "  user=" + event.User
+	}
+	if event.App != "" {
+		line += "  app=" + event.App
+	} else if event.Host != "" {
+		line += "  host=" + event.Host
+	}
+	if event.IP != "" {
+		line += "  ip=" + event.IP
+	}
+	if event.Reason != "" {
+		line += "  (" + event.Reason + ")"
+	}
+	return line
+}
+
+func handleProxyUsersRevoke(args []string) error {
+	result, err := yargs.ParseFlags[proxySessionFlags](args)
 	if err != nil {
 		return err
 	}
-	return syncProxyWithState(cfg, state)
+	username := strings.TrimSpace(result.Flags.Username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	id := strings.TrimSpace(result.Flags.Session)
+	if result.Flags.All == (id != "") {
+		return newUsageError("usage: viberun-server proxy users revoke --username <u> --session <id> | --all")
+	}
+	path, err := proxy.ConfigPath()
+	if err != nil {
+		return err
+	}
+	revoked, err := auth.NewSessionStore(proxy.SessionStorePath(path)).Revoke(username, id)
+	if err != nil {
+		return err
+	}
+	if id != "" && revoked == 0 {
+		return fmt.Errorf("session %s not found for %s", id, username)
+	}
+	return printResult(serverapi.KindProxyRevoke, serverapi.ProxyRevoke{Username: username, Revoked: revoked}, func(out io.Writer) {
+		fmt.Fprintf(out, "Revoked %d session(s) for %s\n", revoked, username)
+	})
+}
+
+func handleProxyUsersTOTP(args []string) error {
+	if len(args) == 0 || (args[0] != "enroll" && args[0] != "disable") {
+		return newUsageError("usage: viberun-server proxy users totp <enroll|disable> --username <u>")
+	}
+	enroll := args[0] == "enroll"
+	result, err := yargs.ParseFlags[proxyUserFlags](args[1:])
+	if err != nil {
+		return err
+	}
+	username := strings.TrimSpace(result.Flags.Username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	status := serverapi.ProxyTOTP{Username: username, Enabled: enroll}
+	_, path, err := proxy.EditConfig(func(cfg *proxy.Config) error {
+		if !enroll {
+			changed, err := proxy.DisableTOTP(cfg, username)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				return fmt.Errorf("totp is not enabled for %s", username)
+			}
+			return nil
+		}
+		secret, codes, err := proxy.EnrollTOTP(cfg, username)
+		if err != nil {
+			return err
+		}
+		issuer := "viberun"
+		if domain := strings.TrimSpace(cfg.BaseDomain); domain != "" {
+			issuer += " " + domain
+		}
+		status.URI = totp.URI(issuer, username, secret)
+		status.Secret = secret
+		status.RecoveryCodes = codes
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := auth.NewMFAStore(proxy.MFAStorePath(path)).Reset(username); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to reset used codes for %s: %v\n", username, err)
+	}
+	return printResult(serverapi.KindProxyTOTP, status, func(out io.Writer) {
+		writeProxyTOTP(out, status)
+	})
+}
+
+func writeProxyTOTP(out io.Writer, status serverapi.ProxyTOTP) {
+	if !status.Enabled {
+		fmt.Fprintf(out, "Disabled TOTP for %s\n", status.Username)
+		return
+	}
+	fmt.Fprintf(out, "Scan this code with an authenticator app for %s:\n\n", status.Username)
+	if qr, err := totp.QR(status.URI); err == nil {
+		fmt.Fprint(out, qr)
+	}
+	fmt.Fprintf(out, "\n%s\n\nSecret: %s\n\nRecovery codes (each works once; store them safely):\n", status.URI, status.Secret)
+	for _, code := range status.RecoveryCodes {
+		fmt.Fprintf(out, "  %s\n", code)
+	}
+}
+
+type proxyTokenFlags struct {
+	Username string `flag:"username" help:"username"`
+	Name     string `flag:"name" help:"label for the token"`
+	Apps     string `flag:"apps" help:"comma-separated apps the token may access"`
+	TTL      string `flag:"ttl" help:"token lifetime such as 720h"`
+	Token    string `flag:"token" help:"token id to revoke"`
+}
+
+func handleProxyUsersToken(args []string) error {
+	const usage = "usage: viberun-server proxy users token <create|list|revoke> --username <u> [--name <n>] [--apps <a,b>] [--ttl <d>] [--token <id>]"
+	if len(args) == 0 || (args[0] != "create" && args[0] != "list" && args[0] != "revoke") {
+		return newUsageError(usage)
+	}
+	action := args[0]
+	result, err := yargs.ParseFlags[proxyTokenFlags](args[1:])
+	if err != nil {
+		return err
+	}
+	username := strings.TrimSpace(result.Flags.Username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	cfg, path, err := proxy.LoadConfig()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "list":
+		var user proxy.AuthUser
+		found := false
+		for _, candidate := range cfg.Users {
+			if candidate.Username == username {
+				user, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("user not found")
+		}
+		tokens := serverapi.ProxyTokens{Username: username, Tokens: []serverapi.ProxyToken{}}
+		for _, token := range user.Tokens {
+			tokens.Tokens = append(tokens.Tokens, proxyTokenInfo(token))
+		}
+		return printResult(serverapi.KindProxyTokens, tokens, func(out io.Writer) {
+			writeProxyTokens(out, tokens)
+		})
+	case "revoke":
+		id := strings.TrimSpace(result.Flags.Token)
+		if id == "" {
+			return newUsageError("usage: viberun-server proxy users token revoke --username <u> --token <id>")
+		}
+		_, err := proxy.UpdateConfig(path, func(cfg *proxy.Config) error {
+			removed, err := proxy.RevokeAPIToken(cfg, username, id)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				return fmt.Errorf("token %s not found for %s", id, username)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		info := serverapi.ProxyToken{ID: id}
+		return printResult(serverapi.KindProxyToken, info, func(out io.Writer) {
+			fmt.Fprintf(out, "Revoked token %s for %s\n", id, username)
+		})
+	}
+	var ttl time.Duration
+	if raw := strings.TrimSpace(result.Flags.TTL); raw != "" {
+		ttl, err = time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q (use a duration like 720h)", raw)
+		}
+	}
+	apps := strings.Split(result.Flags.Apps, ",")
+	for _, app := range apps {
+		app = strings.TrimSpace(app)
+		if app == "" {
+			continue
+		}
+		if _, ok := cfg.Apps[app]; !ok {
+			return fmt.Errorf("unknown app %q", app)
+		}
+	}
+	var token proxy.APIToken
+	var raw string
+	_, err = proxy.UpdateConfig(path, func(cfg *proxy.Config) error {
+		var err error
+		token, raw, err = proxy.CreateAPIToken(cfg, username, result.Flags.Name, apps, ttl, time.Now())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	info := proxyTokenInfo(token)
+	info.Token = raw
+	return printResult(serverapi.KindProxyToken, info, func(out io.Writer) {
+		fmt.Fprintf(out, "Created token %s for %s (shown once; store it safely):\n\n  %s\n\nUse it with: Authorization: Bearer <token>\n", info.ID, username, raw)
+	})
+}
+
+func proxyTokenInfo(token proxy.APIToken) serverapi.ProxyToken {
+	return serverapi.ProxyToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Apps:      token.Apps,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
+
+func writeProxyTokens(out io.Writer, tokens serverapi.ProxyTokens) {
+	if len(tokens.Tokens) == 0 {
+		fmt.Fprintf(out, "No API tokens for %s\n", tokens.Username)
+		return
+	}
+	for _, token := range tokens.Tokens {
+		fmt.Fprintln(out, formatProxyToken(token))
+	}
+}
+
+// formatProxyToken renders one token line for the text output.
+func formatProxyToken(token serverapi.ProxyToken) string {
+	line := token.ID
+	if token.Name != "" {
+		line += "  " + token.Name
+	}
+	line += "  created " + token.CreatedAt.Local().Format("2006-01-02 15:04")
+	if token.ExpiresAt != nil {
+		line += "  expires " + token.ExpiresAt.Local().Format("2006-01-02 15:04")
+	} else {
+		line += "  never expires"
+	}
+	if len(token.Apps) > 0 {
+		line += "  apps " + strings.Join(token.Apps, ",")
+	} else {
+		line += "  all apps"
+	}
+	return line
+}
+
+// revokeProxySessions signs username out everywhere after a password
+// change or removal.
+func revokeProxySessions(configPath string, username string) {
+	if _, err := auth.NewSessionStore(proxy.SessionStorePath(configPath)).Revoke(username, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to revoke sessions for %s: %v\n", username, err)
+	}
+}
+
+func proxyUserExists(cfg proxy.Config, username string) bool {
+	for _, user := range proxy.Usernames(cfg) {
+		if user == username {
+			return true
+		}
+	}
+	return false
 }
 
 func proxyURLForApp(app string) (string, error) {
@@ -620,7 +1296,7 @@ func syncProxyWithState(cfg proxy.Config, state server.State) error {
 	if err := ensureCaddyContainer(cfg.CaddyContainer, cfg.ProxyImage); err != nil {
 		return err
 	}
-	caddyCfg, err := proxy.BuildCaddyConfig(cfg, state.Ports)
+	caddyCfg, err := proxy.BuildCaddyConfig(cfg, state.Ports, state.ExtraHostPorts())
 	if err != nil {
 		return err
 	}
@@ -660,12 +1336,20 @@ func ensureCaddyContainer(name string, image string) error {
 				running = false
 			}
 		}
+		if exists && (!containerHasMount(name, proxyAuthStateDir) || !containerMountReadOnly(name, proxyConfigMount)) {
+			_ = runDockerCommandOutput("rm", "-f", name)
+			exists = false
+			running = false
+		}
 	}
 	if !exists {
 		if strings.TrimSpace(image) == "" {
 			image = proxy.DefaultProxyImage()
 		}
-		if err := runDockerCommandOutput("run", "-d", "--name", name, "--network", "host", "--restart", "unless-stopped", "-v", "/var/lib/viberun/caddy:/data", "-v", "/var/lib/viberun/caddy:/config", "-v", "/var/lib/viberun/proxy.toml:/var/lib/viberun/proxy.toml:ro", image); err != nil {
+		if err := os.MkdirAll(proxyAuthStateDir, 0o700); err != nil {
+			return err
+		}
+		if err := runDockerCommandOutput("run", "-d", "--name", name, "--network", "host", "--restart", "unless-stopped", "-v", "/var/lib/viberun/caddy:/data", "-v", "/var/lib/viberun/caddy:/config", "-v", proxyConfigMount+":"+proxyConfigMount+":ro", "-v", proxyAuthStateDir+":"+proxyAuthStateDir, image); err != nil {
 			message := err.Error()
 			if strings.Contains(message, "pull access denied") || strings.Contains(message, "not found") || strings.Contains(message, "manifest unknown") {
 				return fmt.Errorf("proxy image %s not available; rerun setup or pull it manually: %s", image, err)
@@ -694,6 +1378,35 @@ func dockerImageID(image string) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+func containerHasMount(name string, destination string) bool {
+	out, err := hostcmd.RunCapture("docker", "inspect", "-f", "{{range .Mounts}}{{.Destination}}\n{{end}}", name)
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// containerMountReadOnly reports whether the mount at destination is
+// read-only. Like containerHasMount it assumes yes when docker cannot say.
+func containerMountReadOnly(name string, destination string) bool {
+	out, err := hostcmd.RunCapture("docker", "inspect", "-f", "{{range .Mounts}}{{.Destination}} {{.RW}}\n{{end}}", name)
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(out, "\n") {
+		dest, rw, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok && dest == destination {
+			return rw != "true"
+		}
+	}
+	return true
+}
+
 func caddyContainerRunning(name string) (bool, error) {
 	_, running, err := caddyContainerStatus(name)
 	return running, err
@@ -883,8 +1596,10 @@ func validateUsersExist(cfg proxy.Config, users []string) error {
 	for _, user := range proxy.Usernames(cfg) {
 		known[user] = true
 	}
+	// Single sign-on users are allowed by email before they first log in.
+	sso := proxy.OIDCEnabled(cfg)
 	for _, user := range users {
-		if !known[user] {
+		if !known[user] && !(sso && strings.Contains(user, "@")) {
 			return fmt.Errorf("unknown user %q", user)
 		}
 	}
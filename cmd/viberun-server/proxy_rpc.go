@@ -0,0 +1,94 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shayne/viberun/internal/proxy"
+)
+
+// proxyRPCServer applies changes viberun-auth asks for, since proxy.toml
+// is read-only in the proxy container. It listens on a root-only socket in
+// the auth state directory, which only the proxy container mounts.
+type proxyRPCServer struct {
+	configPath string
+	socket     string
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+func startProxyRPC(configPath string) (*proxyRPCServer, error) {
+	socket := proxy.HostSocketPath(configPath)
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create auth state dir: %w", err)
+	}
+	_ = os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on proxy rpc socket: %w", err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set proxy rpc socket permissions: %w", err)
+	}
+	server := &proxyRPCServer{configPath: configPath, socket: socket, listener: listener}
+	server.httpServer = &http.Server{Handler: server.routes(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.httpServer.Serve(listener)
+	}()
+	return server, nil
+}
+
+func (s *proxyRPCServer) Close() error {
+	if s == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.httpServer.Shutdown(ctx)
+	_ = s.listener.Close()
+	_ = os.Remove(s.socket)
+	return nil
+}
+
+func (s *proxyRPCServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/password", s.handlePassword)
+	return mux
+}
+
+// handlePassword stores a password a user changed on the account page.
+func (s *proxyRPCServer) handlePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var change proxy.PasswordChange
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&change); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	_, err := proxy.UpdateConfig(s.configPath, func(cfg *proxy.Config) error {
+		return proxy.ApplyPasswordChange(cfg, change)
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, proxy.ErrUserChanged) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/server"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHandleProxyShareNormalizesApp(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "proxy.toml")
+	statePath := filepath.Join(dir, "server-state.json")
+	t.Setenv("VIBERUN_PROXY_CONFIG_PATH", configPath)
+	t.Setenv("VIBERUN_STATE_PATH", statePath)
+	cfg := proxy.Config{Enabled: true, BaseDomain: "example.com", Auth: proxy.AuthConfig{SigningKey: "signing-secret"}}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	if err := server.SaveState(statePath, server.State{Ports: map[string]int{"myapp": 8080}}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	if err := handleProxyShare([]string{"MyApp", "create"}); err != nil {
+		t.Fatalf("share create: %v", err)
+	}
+	saved, _, err := proxy.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(saved.Apps["myapp"].Shares) != 1 || len(saved.Apps["MyApp"].Shares) != 0 {
+		t.Fatalf("expected the share under the normalized app, got %+v", saved.Apps)
+	}
+	if err := handleProxyShare([]string{"ghost", "create"}); err == nil {
+		t.Fatal("expected share for a missing app to fail")
+	}
+	if err := handleProxyShare([]string{"bad_name", "list"}); err == nil {
+		t.Fatal("expected an invalid app name to fail")
+	}
+}
+
+func TestProxyRPCChangesPassword(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "proxy.toml")
+	hash, err := proxy.HashPassword("old password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	cfg := proxy.Config{BaseDomain: "example.com", Users: []proxy.AuthUser{{Username: "erin", Password: hash}}}
+	if err := proxy.SaveConfig(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	rpc, err := startProxyRPC(configPath)
+	if err != nil {
+		t.Fatalf("startProxyRPC: %v", err)
+	}
+	defer func() { _ = rpc.Close() }()
+	client := unixHTTPClient(proxy.HostSocketPath(configPath))
+	change := func(username string, current string) int {
+		t.Helper()
+		body, _ := json.Marshal(proxy.PasswordChange{Username: username, Current: current, Password: "new password"})
+		resp, err := client.Post("http://unix/password", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("post password: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := change("erin", hash); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	saved, err := proxy.LoadConfigFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFromPath: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(saved.Users[0].Password), []byte("new password")) != nil {
+		t.Fatalf("expected new password in proxy.toml")
+	}
+	// The old hash is stale now, as it would be after an admin reset.
+	if status := change("erin", hash); status != http.StatusConflict {
+		t.Fatalf("stale hash: expected 409, got %d", status)
+	}
+	if status := change("frank", hash); status != http.StatusConflict {
+		t.Fatalf("unknown user: expected 409, got %d", status)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+const (
+	snapshotMetaFilename = "snapshots.json"
+	snapshotLockFilename = "snapshots.lock"
+
+	snapshotOriginManual = serverapi.OriginManual
+	snapshotOriginAuto   = serverapi.OriginAuto
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// snapshotMeta is the sidecar record for a snapshot tag. Snapshots are
+// read-only subvolumes, so metadata lives next to them in the app directory.
+type snapshotMeta struct {
+	Name      string    `json:"name,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Origin    string    `json:"origin,omitempty"`
+	Creator   string    `json:"creator,omitempty"`
+	Agent     string    `json:"agent,omitempty"`
+	ImageID   string    `json:"image_id,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// snapshotOptions describe a snapshot request; they end up in the sidecar.
+type snapshotOptions struct {
+	Name    string
+	Message string
+	Origin  string
+	Creator string
+	Agent   string
+}
+
+// normalizeSnapshotName validates an optional snapshot name. Names cannot
+// look like version tags or "latest" so restore stays unambiguous.
+func normalizeSnapshotName(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		return "", nil
+	}
+	if !snapshotNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid snapshot name %q (use lowercase letters, digits, '.', '_' or '-')", name)
+	}
+	if _, ok := parseSnapshotTag(normalized); ok || normalized == "latest" {
+		return "", fmt.Errorf("snapshot name %q is reserved", name)
+	}
+	return normalized, nil
+}
+
+// snapshotCreator is the host user behind the current command.
+func snapshotCreator() string {
+	for _, key := range []string{"SUDO_USER", "USER"} {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return "root"
+}
+
+func findSnapshotByName(metas map[string]snapshotMeta, name string) (string, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		return "", false
+	}
+	for tag, meta := range metas {
+		if meta.Name == normalized {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+func snapshotMetaPath(app string) string {
+	cfg := homeVolumeConfigForApp(app)
+	return filepath.Join(cfg.BaseDir, snapshotMetaFilename)
+}
+
+func readSnapshotMetas(app string) (map[string]snapshotMeta, error) {
+	metas := map[string]snapshotMeta{}
+	data, err := os.ReadFile(snapshotMetaPath(app))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return metas, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &metas); err != nil {
+		return nil, fmt.Errorf("invalid snapshot metadata: %w", err)
+	}
+	return metas, nil
+}
+
+func writeSnapshotMetas(app string, metas map[string]snapshotMeta) error {
+	path := snapshotMetaPath(app)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(metas, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lockSnapshots takes the app's snapshot lock and returns its release.
+// The schedule daemon and manual commands run in separate processes, so
+// anything that picks a tag, creates or deletes a snapshot, or rewrites
+// snapshots.json holds this flock for the whole operation. It is not
+// reentrant; the meta helpers below expect the caller to hold it.
+func lockSnapshots(app string) (func(), error) {
+	cfg := homeVolumeConfigForApp(app)
+	if err := os.MkdirAll(cfg.BaseDir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(cfg.BaseDir, snapshotLockFilename), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		_ = lock.Close()
+	}, nil
+}
+
+func recordSnapshotMeta(app string, tag string, meta snapshotMeta) error {
+	metas, err := readSnapshotMetas(app)
+	if err != nil {
+		return err
+	}
+	metas[tag] = meta
+	return writeSnapshotMetas(app, metas)
+}
+
+func removeSnapshotMeta(app string, tag string) error {
+	metas, err := readSnapshotMetas(app)
+	if err != nil {
+		return err
+	}
+	if _, ok := metas[tag]; !ok {
+		return nil
+	}
+	delete(metas, tag)
+	return writeSnapshotMetas(app, metas)
+}
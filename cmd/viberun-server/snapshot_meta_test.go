@@ -0,0 +1,96 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSnapshotName(t *testing.T) {
+	for input, want := range map[string]string{
+		"":                   "",
+		" Before-Migration ": "before-migration",
+		"release_1.2":        "release_1.2",
+	} {
+		got, err := normalizeSnapshotName(input)
+		if err != nil {
+			t.Fatalf("normalize %q: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("normalize %q: got %q want %q", input, got, want)
+		}
+	}
+	for _, input := range []string{"v3", "latest", "-leading", "has space", "a/b", strings.Repeat("x", 64)} {
+		if _, err := normalizeSnapshotName(input); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestResolveSnapshotRefByName(t *testing.T) {
+	useTempLimitsDir(t)
+	if err := recordSnapshotMeta("myapp", "v4", snapshotMeta{Name: "before-migration", Message: "pre schema change"}); err != nil {
+		t.Fatalf("record meta: %v", err)
+	}
+	for input, want := range map[string]string{
+		"before-migration": "v4",
+		"Before-Migration": "v4",
+		"v2":               "v2",
+	} {
+		got, err := resolveSnapshotRef("myapp", input)
+		if err != nil {
+			t.Fatalf("resolve %q: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("resolve %q: got %q want %q", input, got, want)
+		}
+	}
+}
+
+func TestFormatSnapshotLineWithMetadata(t *testing.T) {
+	line := formatSnapshotLine(SnapshotInfo{
+		Tag:       "v4",
+		CreatedAt: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+		Name:      "before-migration",
+		Message:   "pre schema change",
+		Creator:   "alice",
+		Agent:     "codex",
+		ImageID:   "sha256:0123456789abcdef0123",
+		Size:      2 << 20,
+	})
+	want := `v4 2026-03-10T12:00:00Z [before-migration] "pre schema change" by alice agent=codex (2.0 MiB) image=0123456789ab`
+	if line != want {
+		t.Fatalf("unexpected line:\n%s\nwant:\n%s", line, want)
+	}
+}
+
+func TestLockSnapshotsIsExclusive(t *testing.T) {
+	useTempLimitsDir(t)
+	unlock, err := lockSnapshots("myapp")
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	acquired := make(chan struct{})
+	go func() {
+		second, err := lockSnapshots("myapp")
+		if err == nil {
+			second()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second lock not acquired after release")
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shayne/viberun/internal/hostcmd"
+	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/serverapi"
+	"github.com/shayne/yargs"
+)
+
+const (
+	snapshotScheduleFilename = "snapshot-schedule.json"
+
+	minScheduleInterval  = 5 * time.Minute
+	snapshotDaemonPeriod = time.Minute
+
+	// The bootstrap script installs this unit to run `snapshots daemon`.
+	snapshotDaemonUnit     = "viberun-snapshots.service"
+	snapshotDaemonUnitPath = "/etc/systemd/system/" + snapshotDaemonUnit
+)
+
+// snapshotSchedule configures automatic snapshots for an app. Retention only
+// prunes automatic snapshots; manual ones are kept until deleted.
+type snapshotSchedule struct {
+	Every      string `json:"every,omitempty"`
+	KeepLast   int    `json:"keep_last,omitempty"`
+	KeepDaily  int    `json:"keep_daily,omitempty"`
+	KeepWeekly int    `json:"keep_weekly,omitempty"`
+}
+
+type snapshotDaemonFlags struct {
+	Once bool `flag:"once" help:"run one pass and exit"`
+}
+
+func snapshotSchedulePath(app string) string {
+	cfg := homeVolumeConfigForApp(app)
+	return filepath.Join(cfg.BaseDir, snapshotScheduleFilename)
+}
+
+func readSnapshotSchedule(app string) (snapshotSchedule, bool, error) {
+	path := snapshotSchedulePath(app)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snapshotSchedule{}, false, nil
+		}
+		return snapshotSchedule{}, false, err
+	}
+	var schedule snapshotSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return snapshotSchedule{}, false, fmt.Errorf("invalid snapshot schedule %s: %w", path, err)
+	}
+	return schedule, true, nil
+}
+
+func writeSnapshotSchedule(app string, schedule snapshotSchedule) error {
+	path := snapshotSchedulePath(app)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return os.WriteFile(path, data, 0o644)
+}
+
+func parseScheduleInterval(value string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+	interval, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q (use hourly, daily, weekly, or a duration like 6h)", value)
+	}
+	if interval < minScheduleInterval {
+		return 0, fmt.Errorf("interval %s is too short (minimum %s)", interval, minScheduleInterval)
+	}
+	return interval, nil
+}
+
+// applyScheduleSettings parses key=value pairs into schedule. A value of
+// "none" or an empty value clears the key.
+func applyScheduleSettings(schedule *snapshotSchedule, settings []string) error {
+	if len(settings) == 0 {
+		return fmt.Errorf("at least one key=value setting is required")
+	}
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q (expected key=value)", setting)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		unset := value == "" || strings.EqualFold(value, "none")
+		switch key {
+		case "every":
+			if unset {
+				schedule.Every = ""
+				continue
+			}
+			if _, err := parseScheduleInterval(value); err != nil {
+				return err
+			}
+			schedule.Every = strings.ToLower(value)
+		case "keep-last", "keep-daily", "keep-weekly":
+			count := 0
+			if !unset {
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed < 0 {
+					return fmt.Errorf("invalid %s value %q", key, value)
+				}
+				count = parsed
+			}
+			switch key {
+			case "keep-last":
+				schedule.KeepLast = count
+			case "keep-daily":
+				schedule.KeepDaily = count
+			default:
+				schedule.KeepWeekly = count
+			}
+		default:
+			return fmt.Errorf("unknown schedule setting %q (use every, keep-last, keep-daily, or keep-weekly)", key)
+		}
+	}
+	return nil
+}
+
+func (s snapshotSchedule) hasRetention() bool {
+	return s.KeepLast > 0 || s.KeepDaily > 0 || s.KeepWeekly > 0
+}
+
+// snapshotDue reports whether a new automatic snapshot should be taken.
+func snapshotDue(schedule snapshotSchedule, infos []SnapshotInfo, now time.Time) (bool, error) {
+	if strings.TrimSpace(schedule.Every) == "" {
+		return false, nil
+	}
+	interval, err := parseScheduleInterval(schedule.Every)
+	if err != nil {
+		return false, err
+	}
+	var last time.Time
+	for _, info := range infos {
+		if info.Origin == snapshotOriginAuto && info.CreatedAt.After(last) {
+			last = info.CreatedAt
+		}
+	}
+	return last.IsZero() || now.Sub(last) >= interval, nil
+}
+
+// snapshotsToPrune returns the automatic snapshot tags that fall outside the
+// retention policy. A snapshot survives if any rule keeps it: the newest
+// keep-last snapshots, the newest snapshot of each of the last keep-daily
+// days, and the newest snapshot of each of the last keep-weekly ISO weeks.
+func snapshotsToPrune(infos []SnapshotInfo, schedule snapshotSchedule) []string {
+	if !schedule.hasRetention() {
+		return nil
+	}
+	auto := make([]SnapshotInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.Origin == snapshotOriginAuto && !info.CreatedAt.IsZero() {
+			auto = append(auto, info)
+		}
+	}
+	sort.SliceStable(auto, func(i, j int) bool {
+		return auto[i].CreatedAt.After(auto[j].CreatedAt)
+	})
+	keep := map[string]bool{}
+	for i := 0; i < len(auto) && i < schedule.KeepLast; i++ {
+		keep[auto[i].Tag] = true
+	}
+	keepNewestPerPeriod(auto, schedule.KeepDaily, keep, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPerPeriod(auto, schedule.KeepWeekly, keep, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	prune := []string{}
+	for _, info := range auto {
+		if !keep[info.Tag] {
+			prune = append(prune, info.Tag)
+		}
+	}
+	return prune
+}
+
+func keepNewestPerPeriod(newestFirst []SnapshotInfo, periods int, keep map[string]bool, period func(time.Time) string) {
+	seen := map[string]bool{}
+	for _, info := range newestFirst {
+		if len(seen) >= periods {
+			return
+		}
+		key := period(info.CreatedAt.Local())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keep[info.Tag] = true
+	}
+}
+
+// deleteSnapshot removes a snapshot and its metadata. The caller holds the
+// app's snapshot lock.
+func deleteSnapshot(app string, tag string) error {
+	if _, ok := snapshotTag(tag); !ok {
+		return fmt.Errorf("invalid snapshot tag: %s", tag)
+	}
+	cfg := homeVolumeConfigForApp(app)
+	if err := hostcmd.RunOutput("btrfs", "subvolume", "delete", snapshotPathForTag(cfg, tag)); err != nil {
+		return err
+	}
+	return removeSnapshotMeta(app, tag)
+}
+
+// scheduledApps lists apps that have a snapshot schedule file.
+func scheduledApps() ([]string, error) {
+	entries, err := os.ReadDir(homeVolumeBaseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	apps := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(homeVolumeBaseDir, entry.Name(), snapshotScheduleFilename)); err == nil {
+			apps = append(apps, entry.Name())
+		}
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+// runScheduledSnapshots takes due snapshots and applies retention for every
+// scheduled app. Failures are reported per app and do not stop the pass.
+func runScheduledSnapshots(out io.Writer, now time.Time) {
+	apps, err := scheduledApps()
+	if err != nil {
+		fmt.Fprintf(out, "snapshots: failed to list apps: %v\n", err)
+		return
+	}
+	for _, app := range apps {
+		if err := runAppSnapshotSchedule(out, app, now); err != nil {
+			fmt.Fprintf(out, "snapshots: %s: %v\n", app, err)
+		}
+	}
+}
+
+func runAppSnapshotSchedule(out io.Writer, app string, now time.Time) error {
+	schedule, ok, err := readSnapshotSchedule(app)
+	if err != nil || !ok {
+		return err
+	}
+	containerName := fmt.Sprintf("viberun-%s", app)
+	exists, err := containerExists(containerName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	unlock, err := lockSnapshots(app)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	infos, err := listSnapshotInfos(app)
+	if err != nil {
+		return err
+	}
+	due, err := snapshotDue(schedule, infos, now)
+	if err != nil {
+		return err
+	}
+	if due {
+		tag, err := createSnapshotLocked(containerName, app, snapshotOptions{Origin: snapshotOriginAuto, Creator: "scheduler"})
+		if err != nil {
+			return fmt.Errorf("snapshot failed: %w", err)
+		}
+		fmt.Fprintf(out, "snapshots: %s: created %s\n", app, tag)
+		if infos, err = listSnapshotInfos(app); err != nil {
+			return err
+		}
+	}
+	for _, tag := range snapshotsToPrune(infos, schedule) {
+		if err := deleteSnapshot(app, tag); err != nil {
+			return fmt.Errorf("failed to prune %s: %w", tag, err)
+		}
+		fmt.Fprintf(out, "snapshots: %s: pruned %s\n", app, tag)
+	}
+	return nil
+}
+
+func stopSnapshotDaemon() {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return
+	}
+	if _, err := os.Stat(snapshotDaemonUnitPath); err != nil {
+		return
+	}
+	_ = exec.Command("systemctl", "disable", "--now", snapshotDaemonUnit).Run()
+}
+
+func handleSnapshotsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "daemon" {
+		return newUsageError("usage: viberun-server snapshots daemon [--once]")
+	}
+	result, err := yargs.ParseFlags[snapshotDaemonFlags](args[1:])
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	runScheduledSnapshots(os.Stderr, now)
+	runScheduledBackups(os.Stderr)
+	if result.Flags.Once {
+		return nil
+	}
+	// The daemon is the one host process that is always running, so it
+	// also applies the proxy changes viberun-auth cannot write itself.
+	if configPath, err := proxy.ConfigPath(); err == nil {
+		if rpc, err := startProxyRPC(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "proxy rpc: %v\n", err)
+		} else {
+			defer func() { _ = rpc.Close() }()
+		}
+	}
+	nextBackup := now.Add(autoBackupInterval)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(snapshotDaemonPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-signals:
+			return nil
+		case now := <-ticker.C:
+			runScheduledSnapshots(os.Stderr, now)
+			if !now.Before(nextBackup) {
+				runScheduledBackups(os.Stderr)
+				nextBackup = now.Add(autoBackupInterval)
+			}
+		}
+	}
+}
+
+func scheduleResult(app string, schedule snapshotSchedule) serverapi.SnapshotSchedule {
+	return serverapi.SnapshotSchedule{
+		App:        app,
+		Every:      schedule.Every,
+		KeepLast:   schedule.KeepLast,
+		KeepDaily:  schedule.KeepDaily,
+		KeepWeekly: schedule.KeepWeekly,
+	}
+}
+
+func writeScheduleSummary(out io.Writer, schedule snapshotSchedule) {
+	every := schedule.Every
+	if strings.TrimSpace(every) == "" {
+		every = "off"
+	}
+	fmt.Fprintf(out, "Every: %s\n", every)
+	if !schedule.hasRetention() {
+		fmt.Fprintln(out, "Retention: keep all")
+		return
+	}
+	fmt.Fprintf(out, "Keep last: %d\n", schedule.KeepLast)
+	fmt.Fprintf(out, "Keep daily: %d\n", schedule.KeepDaily)
+	fmt.Fprintf(out, "Keep weekly: %d\n", schedule.KeepWeekly)
+}
+
+func handleAppScheduleAction(app string, args []string) error {
+	sub := "show"
+	if len(args) > 0 {
+		sub = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch sub {
+	case "show":
+		schedule, _, err := readSnapshotSchedule(app)
+		if err != nil {
+			return err
+		}
+		return printResult(serverapi.KindSnapshotSchedule, scheduleResult(app, schedule), func(out io.Writer) {
+			writeScheduleSummary(out, schedule)
+		})
+	case "set":
+		schedule, _, err := readSnapshotSchedule(app)
+		if err != nil {
+			return err
+		}
+		if err := applyScheduleSettings(&schedule, args[1:]); err != nil {
+			return err
+		}
+		if err := writeSnapshotSchedule(app, schedule); err != nil {
+			return fmt.Errorf("failed to save schedule: %w", err)
+		}
+		return printMessage("Snapshot schedule updated for %s.", app)
+	case "off":
+		if err := os.Remove(snapshotSchedulePath(app)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove schedule: %w", err)
+		}
+		return printMessage("Automatic snapshots disabled for %s.", app)
+	default:
+		return newUsageError("usage: viberun-server <app> schedule [show|set key=value...|off]")
+	}
+}
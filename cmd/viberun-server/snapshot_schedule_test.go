@@ -0,0 +1,119 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestApplyScheduleSettings(t *testing.T) {
+	schedule := snapshotSchedule{}
+	if err := applyScheduleSettings(&schedule, []string{"every=Hourly", "keep-last=24", "keep-daily=7", "keep-weekly=4"}); err != nil {
+		t.Fatalf("apply settings: %v", err)
+	}
+	want := snapshotSchedule{Every: "hourly", KeepLast: 24, KeepDaily: 7, KeepWeekly: 4}
+	if schedule != want {
+		t.Fatalf("unexpected schedule: %+v", schedule)
+	}
+	if err := applyScheduleSettings(&schedule, []string{"keep-weekly=none", "every="}); err != nil {
+		t.Fatalf("clear settings: %v", err)
+	}
+	if schedule.Every != "" || schedule.KeepWeekly != 0 || schedule.KeepLast != 24 {
+		t.Fatalf("unexpected cleared schedule: %+v", schedule)
+	}
+}
+
+func TestApplyScheduleSettingsRejectsInvalidValues(t *testing.T) {
+	for _, setting := range []string{"every=1m", "every=sometimes", "keep-last=-1", "keep-daily=many", "keep-monthly=3", "every"} {
+		schedule := snapshotSchedule{}
+		if err := applyScheduleSettings(&schedule, []string{setting}); err == nil {
+			t.Fatalf("expected error for %q", setting)
+		}
+	}
+}
+
+func TestSnapshotDue(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	schedule := snapshotSchedule{Every: "hourly"}
+	infos := []SnapshotInfo{
+		{Tag: "v1", Origin: snapshotOriginAuto, CreatedAt: now.Add(-30 * time.Minute)},
+		{Tag: "v2", Origin: snapshotOriginManual, CreatedAt: now.Add(-5 * time.Hour)},
+	}
+	if due, err := snapshotDue(schedule, infos, now); err != nil || due {
+		t.Fatalf("expected not due, got %v (%v)", due, err)
+	}
+	if due, err := snapshotDue(schedule, infos, now.Add(31*time.Minute)); err != nil || !due {
+		t.Fatalf("expected due, got %v (%v)", due, err)
+	}
+	if due, err := snapshotDue(schedule, infos[1:], now); err != nil || !due {
+		t.Fatalf("expected due without automatic snapshots, got %v (%v)", due, err)
+	}
+	if due, _ := snapshotDue(snapshotSchedule{KeepLast: 3}, nil, now); due {
+		t.Fatalf("expected no snapshot without an interval")
+	}
+}
+
+func TestSnapshotsToPrune(t *testing.T) {
+	base := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	infos := []SnapshotInfo{
+		{Tag: "v1", Origin: snapshotOriginAuto, CreatedAt: base.AddDate(0, 0, -14)},
+		{Tag: "v2", Origin: snapshotOriginAuto, CreatedAt: base.AddDate(0, 0, -2)},
+		{Tag: "v3", Origin: snapshotOriginAuto, CreatedAt: base.AddDate(0, 0, -1)},
+		{Tag: "v4", Origin: snapshotOriginAuto, CreatedAt: base.AddDate(0, 0, -1).Add(time.Hour)},
+		{Tag: "v5", Origin: snapshotOriginManual, CreatedAt: base.AddDate(0, 0, -30)},
+		{Tag: "v6", Origin: snapshotOriginAuto, CreatedAt: base},
+		{Tag: "v7", Origin: snapshotOriginAuto, CreatedAt: base.Add(time.Hour)},
+	}
+
+	prune := snapshotsToPrune(infos, snapshotSchedule{KeepLast: 1, KeepDaily: 3})
+	sort.Strings(prune)
+	if want := []string{"v1", "v3", "v6"}; !reflect.DeepEqual(prune, want) {
+		t.Fatalf("unexpected prune list %v (want %v)", prune, want)
+	}
+
+	prune = snapshotsToPrune(infos, snapshotSchedule{KeepWeekly: 2})
+	sort.Strings(prune)
+	if want := []string{"v1", "v3", "v4", "v6"}; !reflect.DeepEqual(prune, want) {
+		t.Fatalf("unexpected weekly prune list %v (want %v)", prune, want)
+	}
+
+	if prune := snapshotsToPrune(infos, snapshotSchedule{Every: "hourly"}); len(prune) != 0 {
+		t.Fatalf("expected no pruning without retention, got %v", prune)
+	}
+}
+
+func TestSnapshotMetaRoundTrip(t *testing.T) {
+	useTempLimitsDir(t)
+	created := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	if err := recordSnapshotMeta("myapp", "v1", snapshotMeta{Origin: snapshotOriginAuto, CreatedAt: created}); err != nil {
+		t.Fatalf("record meta: %v", err)
+	}
+	if err := recordSnapshotMeta("myapp", "v2", snapshotMeta{Origin: snapshotOriginManual}); err != nil {
+		t.Fatalf("record meta: %v", err)
+	}
+	if err := removeSnapshotMeta("myapp", "v2"); err != nil {
+		t.Fatalf("remove meta: %v", err)
+	}
+	metas, err := readSnapshotMetas("myapp")
+	if err != nil {
+		t.Fatalf("read metas: %v", err)
+	}
+	if len(metas) != 1 || metas["v1"].Origin != snapshotOriginAuto || !metas["v1"].CreatedAt.Equal(created) {
+		t.Fatalf("unexpected metas: %+v", metas)
+	}
+}
+
+func TestParseActionSchedule(t *testing.T) {
+	action, args, err := parseAction([]string{"schedule", "set", "every=daily", "keep-daily=7"})
+	if err != nil {
+		t.Fatalf("parse action: %v", err)
+	}
+	if action != "schedule" || len(args) != 3 || args[0] != "set" {
+		t.Fatalf("unexpected action %q args %v", action, args)
+	}
+}
@@ -25,6 +25,7 @@ func handleWipeCommand() error {
 			caddyName = value
 		}
 	}
+	stopSnapshotDaemon()
 	toRemove := map[string]bool{}
 	if _, err := exec.LookPath("docker"); err == nil {
 		containers, err := listContainers()
@@ -79,6 +80,7 @@ func handleWipeCommand() error {
 		"/etc/viberun",
 		"/etc/sudoers.d/viberun-server",
 		"/usr/local/bin/viberun-server",
+		snapshotDaemonUnitPath,
 	} {
 		if err := os.RemoveAll(path); err != nil {
 			return err
@@ -61,7 +61,7 @@ func startAppsStream(gateway *gatewayClient) (*appsStream, error) {
 			}
 			apps := make([]appSnapshot, 0, len(event.Apps))
 			for _, app := range event.Apps {
-				apps = append(apps, appSnapshot{Name: app.Name, Port: app.Port})
+				apps = append(apps, appSnapshot{Name: app.Name, Port: app.Port, DiskPercent: app.DiskUsedPercent})
 			}
 			send(appsEvent{apps: apps})
 		}
@@ -88,13 +88,13 @@ func startAppsStreamCmd(state *shellState) tea.Cmd {
 func listenAppsStreamCmd(stream *appsStream) tea.Cmd {
 	return func() tea.Msg {
 		if stream == nil {
-			return appsStreamUpdateMsg{err: errors.New("apps stream not connected")}
+			return appsStreamUpdateMsg{stream: stream, err: errors.New("apps stream not connected")}
 		}
 		event, ok := <-stream.updates
 		if !ok {
-			return appsStreamUpdateMsg{err: errors.New("apps stream closed")}
+			return appsStreamUpdateMsg{stream: stream, err: errors.New("apps stream closed")}
 		}
-		return appsStreamUpdateMsg(event)
+		return appsStreamUpdateMsg{stream: stream, apps: event.apps, err: event.err}
 	}
 }
 
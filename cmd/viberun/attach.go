@@ -27,6 +27,10 @@ type AttachSession struct {
 	startOpen func(func(muxrpc.OpenEvent)) error
 	openPTY   func(muxrpc.PtyMeta) (*mux.Stream, error)
 	runPTY    func(*mux.Stream) error
+	// linkDone and awaitLink let Run tell a dropped connection from a
+	// session that ended, and wait for the gateway to reconnect.
+	linkDone  func() <-chan struct{}
+	awaitLink func() error
 }
 
 type attachSwitchError struct {
@@ -86,31 +90,84 @@ func (s *AttachSession) Run() error {
 			if switchReq == nil {
 				switchReq = &attachSwitchError{App: app, Action: strings.TrimSpace(evt.AttachAction)}
 			}
+			current := ptyStream
 			switchMu.Unlock()
-			if ptyStream != nil {
-				_ = ptyStream.Close()
+			if current != nil {
+				_ = current.Close()
 			}
 		}); err != nil {
 			return err
 		}
 	}
 
-	ptyStream, err := openPTY(s.PtyMeta)
-	if err != nil {
-		return err
+	linkDone := s.linkDone
+	awaitLink := s.awaitLink
+	if linkDone == nil && s.Gateway != nil {
+		linkDone = s.Gateway.linkDone
 	}
-	switchMu.Lock()
-	pendingSwitch := switchReq
-	switchMu.Unlock()
-	if pendingSwitch != nil && ptyStream != nil {
-		_ = ptyStream.Close()
+	if awaitLink == nil && s.Gateway != nil {
+		awaitLink = s.awaitGateway
 	}
-	err = runPTY(ptyStream)
-	switchMu.Lock()
-	pendingSwitch = switchReq
-	switchMu.Unlock()
-	if pendingSwitch != nil {
-		return pendingSwitch
+
+	meta := s.PtyMeta
+	for {
+		var lost <-chan struct{}
+		if linkDone != nil {
+			lost = linkDone()
+		}
+		stream, err := openPTY(meta)
+		if err == nil {
+			switchMu.Lock()
+			ptyStream = stream
+			pendingSwitch := switchReq
+			switchMu.Unlock()
+			if pendingSwitch != nil && stream != nil {
+				_ = stream.Close()
+			}
+			err = runPTY(stream)
+		}
+		switchMu.Lock()
+		pendingSwitch := switchReq
+		switchMu.Unlock()
+		if pendingSwitch != nil {
+			return pendingSwitch
+		}
+		if lost == nil || awaitLink == nil || !isClosedChan(lost) {
+			return err
+		}
+		// The link dropped under the session. The agent keeps running in
+		// tmux on the host, so reattach once the gateway is back.
+		if err := awaitLink(); err != nil {
+			return err
+		}
+		meta = resumePtyMeta(meta)
+	}
+}
+
+// awaitGateway reports the lost connection on the terminal and waits for
+// the gateway to reconnect.
+func (s *AttachSession) awaitGateway() error {
+	fmt.Fprintf(os.Stderr, "\r\nConnection to %s lost. Reconnecting...\r\n", hostLabel(s.Resolved.Host))
+	if err := s.Gateway.awaitLink(); err != nil {
+		return fmt.Errorf("reconnect failed: %w", err)
+	}
+	return nil
+}
+
+// resumePtyMeta prepares meta for reattaching to a running session: the
+// container already exists, so creation-only settings are dropped.
+func resumePtyMeta(meta muxrpc.PtyMeta) muxrpc.PtyMeta {
+	if len(meta.Env) == 0 {
+		return meta
+	}
+	env := make(map[string]string, len(meta.Env))
+	for key, value := range meta.Env {
+		switch key {
+		case "VIBERUN_AUTO_CREATE", "VIBERUN_AUTH_BUNDLE":
+			continue
+		}
+		env[key] = value
 	}
-	return err
+	meta.Env = env
+	return meta
 }
@@ -92,3 +92,46 @@ func TestAttachSessionRunAttachSwitch(t *testing.T) {
 		t.Fatalf("expected attachSwitchError, got %T", err)
 	}
 }
+
+func TestAttachSessionResumesAfterLostLink(t *testing.T) {
+	calls := []string{}
+	metas := []muxrpc.PtyMeta{}
+	link := make(chan struct{})
+	session := &AttachSession{
+		PtyMeta: muxrpc.PtyMeta{App: "myapp", Env: map[string]string{"TERM": "xterm", "VIBERUN_AUTO_CREATE": "1"}},
+		startOpen: func(func(muxrpc.OpenEvent)) error {
+			return nil
+		},
+		openPTY: func(meta muxrpc.PtyMeta) (*mux.Stream, error) {
+			calls = append(calls, "pty")
+			metas = append(metas, meta)
+			return nil, nil
+		},
+		runPTY: func(*mux.Stream) error {
+			calls = append(calls, "run")
+			if len(metas) == 1 {
+				close(link)
+			}
+			return nil
+		},
+		linkDone: func() <-chan struct{} {
+			return link
+		},
+		awaitLink: func() error {
+			calls = append(calls, "await")
+			link = make(chan struct{})
+			return nil
+		},
+	}
+	if err := session.Run(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []string{"pty", "run", "await", "pty", "run"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected call order %v, got %v", want, calls)
+	}
+	resumed := metas[1].Env
+	if resumed["TERM"] != "xterm" || resumed["VIBERUN_AUTO_CREATE"] != "" {
+		t.Fatalf("unexpected resume env: %v", resumed)
+	}
+}
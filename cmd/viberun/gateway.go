@@ -20,19 +20,55 @@ import (
 
 	"github.com/shayne/viberun/internal/mux"
 	"github.com/shayne/viberun/internal/muxrpc"
+	"github.com/shayne/viberun/internal/serverapi"
 	"github.com/shayne/viberun/internal/sshcmd"
 )
 
+// gatewayClient talks to viberun-server gateway over an SSH link. With
+// reconnect enabled a dropped link is redialed in the background; requests
+// made while it is down fail with errGatewayReconnecting.
 type gatewayClient struct {
+	dial func(echo io.Writer) (*gatewayLink, error)
+
+	mu          sync.Mutex
+	link        *gatewayLink
+	nextID      int64
+	openStarted bool
+	openHandler func(muxrpc.OpenEvent)
+	reconnect   bool
+	closed      bool
+	status      gatewayStatus
+	// statusChanged is closed and replaced whenever status changes.
+	statusChanged chan struct{}
+	doneCh        chan struct{}
+	doneOnce      sync.Once
+}
+
+// gatewayTarget says where and how to start viberun-server gateway.
+type gatewayTarget struct {
+	host          string
+	agentProvider string
+	extraEnv      map[string]string
+	forwardAgent  bool
+}
+
+// gatewayLink is one SSH connection running viberun-server gateway.
+type gatewayLink struct {
 	mux     *mux.Mux
-	conn    io.ReadWriteCloser
 	cmd     *exec.Cmd
 	control *mux.Stream
 
-	mu       sync.Mutex
-	nextID   int64
-	pending  map[string]chan rpcResult
-	openOnce sync.Once
+	mu           sync.Mutex
+	pending      map[string]chan rpcResult
+	shutdownOnce sync.Once
+}
+
+// gatewayStatus describes the link of a reconnecting gateway.
+type gatewayStatus struct {
+	reconnecting bool
+	attempt      int
+	// err is set when the gateway gave up reconnecting.
+	err error
 }
 
 type rpcResult struct {
@@ -50,15 +86,54 @@ func (c *sshConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
 func (c *sshConn) Write(p []byte) (int, error) { return c.w.Write(p) }
 func (c *sshConn) Close() error                { return c.c.Close() }
 
+const (
+	// gatewayHandshakeTimeout bounds the wait for the server's hello, which
+	// includes the SSH connection setup.
+	gatewayHandshakeTimeout = 30 * time.Second
+	// gatewayKeepaliveInterval is how often the client pings the gateway
+	// and how long it waits for the answer before dropping the link.
+	gatewayKeepaliveInterval = 15 * time.Second
+	gatewayReconnectMinDelay = time.Second
+	gatewayReconnectMaxDelay = 30 * time.Second
+)
+
+var (
+	errGatewayReconnecting = errors.New("connection to host lost; reconnecting")
+	errGatewayClosed       = errors.New("gateway closed")
+)
+
 func startGateway(host string, agentProvider string, extraEnv map[string]string, forwardAgent bool) (*gatewayClient, error) {
+	target := gatewayTarget{host: host, agentProvider: agentProvider, extraEnv: extraEnv, forwardAgent: forwardAgent}
+	return connectGateway(target.dial)
+}
+
+// connectGateway dials the first link. Reconnects use the same dial.
+func connectGateway(dial func(echo io.Writer) (*gatewayLink, error)) (*gatewayClient, error) {
+	g := &gatewayClient{
+		dial:          dial,
+		statusChanged: make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+	link, err := dial(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	g.link = link
+	go g.watch(link)
+	return g, nil
+}
+
+// dial starts viberun-server gateway over SSH and completes the mux
+// handshake. SSH's own messages are copied to echo.
+func (t gatewayTarget) dial(echo io.Writer) (*gatewayLink, error) {
 	remoteArgs := []string{"viberun-server", "gateway"}
-	if strings.TrimSpace(agentProvider) != "" {
-		remoteArgs = append(remoteArgs, "--agent", agentProvider)
+	if strings.TrimSpace(t.agentProvider) != "" {
+		remoteArgs = append(remoteArgs, "--agent", t.agentProvider)
 	}
-	remoteArgs = prependEnv(remoteArgs, extraEnv)
-	sshArgs := sshcmd.BuildArgs(host, remoteArgs, false)
+	remoteArgs = prependEnv(remoteArgs, t.extraEnv)
+	sshArgs := sshcmd.BuildArgs(t.host, remoteArgs, false)
 	sshArgs = append([]string{"-o", "LogLevel=ERROR"}, sshArgs...)
-	if forwardAgent {
+	if t.forwardAgent {
 		sshArgs = append([]string{"-A"}, sshArgs...)
 	}
 	cmd := exec.Command("ssh", sshArgs...)
@@ -72,27 +147,64 @@ func startGateway(host string, agentProvider string, extraEnv map[string]string,
 	if err != nil {
 		return nil, err
 	}
-	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	cmd.Stderr = stderr
+	if echo != nil {
+		cmd.Stderr = io.MultiWriter(echo, stderr)
+	}
 	if err := cmd.Start(); err != nil {
 		return nil, withGatewayStderr(err, stderr)
 	}
 	conn := &sshConn{r: stdout, w: stdin, c: stdin}
 	m := mux.New(conn, true)
+	m.SetSoftware(version)
 	m.Run()
-	control, err := m.OpenStream("control", nil)
+	if err := m.Handshake(gatewayHandshakeTimeout); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		var versionErr *mux.VersionError
+		if errors.As(err, &versionErr) {
+			return nil, gatewayVersionError(versionErr)
+		}
+		return nil, withGatewayStderr(err, stderr)
+	}
+	link, err := newGatewayLink(m, cmd)
 	if err != nil {
 		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
 		return nil, withGatewayStderr(err, stderr)
 	}
-	client := &gatewayClient{
+	return link, nil
+}
+
+// newGatewayLink opens the control stream on a mux that finished its
+// handshake. cmd is the SSH process behind it, if any.
+func newGatewayLink(m *mux.Mux, cmd *exec.Cmd) (*gatewayLink, error) {
+	control, err := m.OpenStream("control", nil)
+	if err != nil {
+		return nil, err
+	}
+	link := &gatewayLink{
 		mux:     m,
-		conn:    conn,
 		cmd:     cmd,
 		control: control,
 		pending: map[string]chan rpcResult{},
 	}
-	go client.readResponses()
-	return client, nil
+	m.Keepalive(gatewayKeepaliveInterval)
+	go link.readResponses()
+	return link, nil
+}
+
+// gatewayVersionError says which side to update when the client and
+// viberun-server share no mux protocol version.
+func gatewayVersionError(err *mux.VersionError) error {
+	server := "viberun-server"
+	if err.Peer.Software != "" {
+		server += " " + err.Peer.Software
+	}
+	if err.PeerOlder() {
+		return fmt.Errorf("%s on the host is too old for this client (%w); rerun setup to update it", server, err)
+	}
+	return fmt.Errorf("this viberun is too old for %s on the host (%w); update viberun", server, err)
 }
 
 func withGatewayStderr(err error, stderr *bytes.Buffer) error {
@@ -109,42 +221,229 @@ func withGatewayStderr(err error, stderr *bytes.Buffer) error {
 	return fmt.Errorf("%s", msg)
 }
 
+// enableReconnect makes the gateway redial when its link drops instead of
+// closing. Use watchStatus to follow the link.
+func (g *gatewayClient) enableReconnect() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.reconnect = true
+}
+
 func (g *gatewayClient) Close() error {
 	if g == nil {
 		return nil
 	}
-	_ = g.control.Close()
-	_ = g.mux.Close()
-	if g.cmd != nil && g.cmd.Process != nil {
-		_ = g.cmd.Process.Signal(os.Interrupt)
+	g.mu.Lock()
+	g.closed = true
+	link := g.link
+	g.link = nil
+	g.mu.Unlock()
+	if link != nil {
+		link.shutdown()
+	}
+	g.finish(nil)
+	return nil
+}
+
+// done is closed once the gateway is gone for good: it was closed, its link
+// dropped without reconnect enabled, or reconnecting gave up.
+func (g *gatewayClient) done() <-chan struct{} {
+	return g.doneCh
+}
+
+// watchStatus returns the current link status and a channel that is closed
+// when it changes.
+func (g *gatewayClient) watchStatus() (gatewayStatus, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status, g.statusChanged
+}
+
+// linkDone returns a channel that is closed when the current link drops,
+// so callers can tell a lost link from a stream the server ended.
+func (g *gatewayClient) linkDone() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.link == nil {
+		closed := make(chan struct{})
+		close(closed)
+		return closed
+	}
+	return g.link.mux.Done()
+}
+
+// awaitLink waits until the gateway has a live link again. It fails once
+// the gateway is closed or gives up reconnecting.
+func (g *gatewayClient) awaitLink() error {
+	for {
+		g.mu.Lock()
+		link, status, changed := g.link, g.status, g.statusChanged
+		finished := isClosedChan(g.doneCh)
+		g.mu.Unlock()
+		if finished {
+			if status.err != nil {
+				return status.err
+			}
+			return errGatewayClosed
+		}
+		if link != nil {
+			return nil
+		}
+		<-changed
+	}
+}
+
+func (g *gatewayClient) setStatus(status gatewayStatus) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.status = status
+	g.notifyStatusLocked()
+}
+
+func (g *gatewayClient) notifyStatusLocked() {
+	close(g.statusChanged)
+	g.statusChanged = make(chan struct{})
+}
+
+// finish marks the gateway as gone for good, recording err as the reason.
+func (g *gatewayClient) finish(err error) {
+	g.doneOnce.Do(func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.status = gatewayStatus{err: err}
+		close(g.doneCh)
+		g.notifyStatusLocked()
+	})
+}
+
+func isClosedChan(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// watch waits for link to drop, then either redials or finishes the
+// gateway.
+func (g *gatewayClient) watch(link *gatewayLink) {
+	<-link.mux.Done()
+	link.shutdown()
+	g.mu.Lock()
+	if g.link == link {
+		g.link = nil
+	}
+	retry := g.reconnect && !g.closed
+	g.mu.Unlock()
+	if !retry {
+		g.finish(nil)
+		return
+	}
+	g.redial()
+}
+
+// redial reconnects with exponential backoff until it succeeds, the
+// gateway is closed, or the host answers with an error retrying cannot fix.
+func (g *gatewayClient) redial() {
+	delay := gatewayReconnectMinDelay
+	for attempt := 1; ; attempt++ {
+		g.setStatus(gatewayStatus{reconnecting: true, attempt: attempt})
+		// SSH errors would land in the middle of the shell or the session,
+		// so they are only kept for the returned error.
+		link, err := g.dial(nil)
+		if err == nil {
+			g.mu.Lock()
+			if g.closed {
+				g.mu.Unlock()
+				link.shutdown()
+				return
+			}
+			g.link = link
+			openStarted, openHandler := g.openStarted, g.openHandler
+			g.mu.Unlock()
+			if openStarted {
+				_ = link.startOpenStream(openHandler)
+			}
+			g.setStatus(gatewayStatus{})
+			go g.watch(link)
+			return
+		}
+		var versionErr *mux.VersionError
+		if errors.As(err, &versionErr) || isMissingServerError(err) {
+			g.finish(err)
+			return
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-g.doneCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = min(delay*2, gatewayReconnectMaxDelay)
+	}
+}
+
+// current returns the live link, or an error while reconnecting or after
+// the gateway is gone.
+func (g *gatewayClient) current() (*gatewayLink, error) {
+	if g == nil {
+		return nil, errors.New("gateway not initialized")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.link != nil {
+		return g.link, nil
+	}
+	if g.reconnect && !g.closed && !isClosedChan(g.doneCh) {
+		return nil, errGatewayReconnecting
+	}
+	if g.status.err != nil {
+		return nil, g.status.err
+	}
+	return nil, errGatewayClosed
+}
+
+// shutdown closes the link and stops its SSH process.
+func (l *gatewayLink) shutdown() {
+	l.shutdownOnce.Do(func() {
+		_ = l.control.Close()
+		_ = l.mux.Close()
+		if l.cmd == nil || l.cmd.Process == nil {
+			return
+		}
+		_ = l.cmd.Process.Signal(os.Interrupt)
 		done := make(chan error, 1)
-		go func() { done <- g.cmd.Wait() }()
+		go func() { done <- l.cmd.Wait() }()
 		select {
 		case <-done:
 		case <-time.After(2 * time.Second):
-			_ = g.cmd.Process.Kill()
+			_ = l.cmd.Process.Kill()
 		}
-	}
-	return nil
+	})
 }
 
-func (g *gatewayClient) readResponses() {
+func (l *gatewayLink) readResponses() {
 	for {
-		msg, err := g.control.ReceiveMsg()
+		msg, err := l.control.ReceiveMsg()
 		if err != nil {
-			g.failAll(err)
+			l.failAll(err)
 			return
 		}
 		var resp muxrpc.Response
 		if err := json.Unmarshal(msg, &resp); err != nil {
 			continue
 		}
-		g.mu.Lock()
-		ch := g.pending[resp.ID]
+		l.mu.Lock()
+		ch := l.pending[resp.ID]
 		if ch != nil {
-			delete(g.pending, resp.ID)
+			delete(l.pending, resp.ID)
 		}
-		g.mu.Unlock()
+		l.mu.Unlock()
 		if ch != nil {
 			ch <- rpcResult{resp: resp}
 			close(ch)
@@ -152,19 +451,20 @@ func (g *gatewayClient) readResponses() {
 	}
 }
 
-func (g *gatewayClient) failAll(err error) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	for id, ch := range g.pending {
+func (l *gatewayLink) failAll(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, ch := range l.pending {
 		ch <- rpcResult{err: err}
 		close(ch)
-		delete(g.pending, id)
+		delete(l.pending, id)
 	}
 }
 
 func (g *gatewayClient) rpc(method string, params any, result any) error {
-	if g == nil {
-		return errors.New("gateway not initialized")
+	link, err := g.current()
+	if err != nil {
+		return err
 	}
 	id := g.nextRequestID()
 	var raw json.RawMessage
@@ -181,10 +481,10 @@ func (g *gatewayClient) rpc(method string, params any, result any) error {
 		return err
 	}
 	ch := make(chan rpcResult, 1)
-	g.mu.Lock()
-	g.pending[id] = ch
-	g.mu.Unlock()
-	if err := g.control.SendMsg(payload); err != nil {
+	link.mu.Lock()
+	link.pending[id] = ch
+	link.mu.Unlock()
+	if err := link.control.SendMsg(payload); err != nil {
 		return err
 	}
 	res := <-ch
@@ -212,6 +512,16 @@ func (g *gatewayClient) command(args []string, input string, env map[string]stri
 	return result.Output, nil
 }
 
+// commandJSON runs a viberun-server command with --json and decodes the
+// typed result of the given kind.
+func (g *gatewayClient) commandJSON(args []string, input string, env map[string]string, kind string, result any) error {
+	output, err := g.command(append([]string{serverapi.FlagJSON}, args...), input, env)
+	if err != nil {
+		return err
+	}
+	return serverapi.Decode(output, kind, result)
+}
+
 func (g *gatewayClient) exec(args []string, input string, env map[string]string) (string, error) {
 	params := muxrpc.ExecParams{Args: args, Input: input, Env: env}
 	var result muxrpc.ExecResult
@@ -222,7 +532,11 @@ func (g *gatewayClient) exec(args []string, input string, env map[string]string)
 }
 
 func (g *gatewayClient) openStream(streamType string, meta any) (*mux.Stream, error) {
-	return g.mux.OpenStream(streamType, meta)
+	link, err := g.current()
+	if err != nil {
+		return nil, err
+	}
+	return link.mux.OpenStream(streamType, meta)
 }
 
 func (g *gatewayClient) nextRequestID() string {
@@ -232,31 +546,45 @@ func (g *gatewayClient) nextRequestID() string {
 	return strconv.FormatInt(g.nextID, 10)
 }
 
+// startOpenStream listens for open events from the host. A reconnecting
+// gateway reopens the stream with the same handler on each new link.
 func (g *gatewayClient) startOpenStream(handler func(muxrpc.OpenEvent)) error {
-	var err error
-	g.openOnce.Do(func() {
-		stream, openErr := g.openStream("open", nil)
-		if openErr != nil {
-			err = openErr
-			return
-		}
-		go func() {
-			for {
-				msg, recvErr := stream.ReceiveMsg()
-				if recvErr != nil {
-					return
-				}
-				var evt muxrpc.OpenEvent
-				if jsonErr := json.Unmarshal(msg, &evt); jsonErr != nil {
-					continue
-				}
-				if handler != nil {
-					handler(evt)
-				}
+	link, err := g.current()
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	if g.openStarted {
+		g.mu.Unlock()
+		return nil
+	}
+	g.openStarted = true
+	g.openHandler = handler
+	g.mu.Unlock()
+	return link.startOpenStream(handler)
+}
+
+func (l *gatewayLink) startOpenStream(handler func(muxrpc.OpenEvent)) error {
+	stream, err := l.mux.OpenStream("open", nil)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			msg, recvErr := stream.ReceiveMsg()
+			if recvErr != nil {
+				return
 			}
-		}()
-	})
-	return err
+			var evt muxrpc.OpenEvent
+			if jsonErr := json.Unmarshal(msg, &evt); jsonErr != nil {
+				continue
+			}
+			if handler != nil {
+				handler(evt)
+			}
+		}
+	}()
+	return nil
 }
 
 func prependEnv(args []string, extraEnv map[string]string) []string {
@@ -0,0 +1,152 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/shayne/viberun/internal/filecopy"
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+// copyResult describes a finished copy. Dest is where the tree ended up,
+// locally for downloads and as given for uploads.
+type copyResult struct {
+	Dest    string
+	Files   int
+	Bytes   int64
+	Skipped int
+}
+
+// copyProgress is told the total bytes to send once it is known and then
+// each chunk as it is copied.
+type copyProgress interface {
+	Start(total int64)
+	Add(n int64)
+}
+
+func openCopyStream(gateway *gatewayClient, streamType string, meta any) (*mux.Stream, error) {
+	link, err := gateway.current()
+	if err != nil {
+		return nil, err
+	}
+	if !link.mux.PeerHas(muxrpc.CapCopyStream) {
+		return nil, errors.New("remote viberun-server does not support cp; rerun setup to update it")
+	}
+	return link.mux.OpenStream(streamType, meta)
+}
+
+// copyFromApp copies remotePath out of app's container to localPath. As
+// with cp, an existing local directory receives the tree under its own
+// name, and files that already match are not sent.
+func copyFromApp(gateway *gatewayClient, app string, remotePath string, localPath string, progress copyProgress) (copyResult, error) {
+	dest := localPath
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		if name := path.Base(path.Clean(remotePath)); name != "." && name != "/" {
+			dest = filepath.Join(localPath, name)
+		}
+	}
+	result := copyResult{Dest: dest}
+	have, err := filecopy.ScanDir(dest)
+	if err != nil {
+		return result, err
+	}
+	stream, err := openCopyStream(gateway, "download", muxrpc.DownloadMeta{Target: "app", App: app, Path: remotePath})
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = stream.Close() }()
+	if err := filecopy.SendManifest(stream, have); err != nil {
+		return result, err
+	}
+	msg, err := stream.ReceiveMsg()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return result, errors.New("copy stream closed; the remote viberun-server may be out of date")
+		}
+		return result, err
+	}
+	var info muxrpc.DownloadInfo
+	if err := json.Unmarshal(msg, &info); err != nil {
+		return result, err
+	}
+	if strings.TrimSpace(info.Error) != "" {
+		return result, errors.New(info.Error)
+	}
+	if progress != nil {
+		progress.Start(info.Size)
+	}
+	stats, err := filecopy.Extract(tar.NewReader(stream), dest, progressFunc(progress))
+	result.Files, result.Bytes, result.Skipped = stats.Files, stats.Bytes, info.Skipped
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return result, errors.New("copy interrupted; run cp again to resume")
+	}
+	return result, err
+}
+
+// copyToApp copies localPath into app's container at remotePath, sending
+// only the files that differ from what is already there.
+func copyToApp(gateway *gatewayClient, localPath string, app string, remotePath string, progress copyProgress) (copyResult, error) {
+	result := copyResult{Dest: remotePath}
+	if _, err := os.Stat(localPath); err != nil {
+		return result, err
+	}
+	abs, err := filepath.Abs(localPath)
+	if err != nil {
+		return result, err
+	}
+	meta := muxrpc.UploadMeta{Target: "app", App: app, Path: remotePath, Name: filepath.Base(abs)}
+	stream, err := openCopyStream(gateway, "upload", meta)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = stream.Close() }()
+	have, err := filecopy.ReceiveManifest(stream)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return result, errors.New("copy stream closed; the remote viberun-server may be out of date")
+		}
+		return result, err
+	}
+	send, plan, err := filecopy.Plan(localPath, filecopy.Index(have))
+	if err != nil {
+		return result, err
+	}
+	if progress != nil {
+		progress.Start(plan.Bytes)
+	}
+	wanted := func(entry muxrpc.CopyEntry) bool { return send[entry.Path] }
+	if _, err := filecopy.WriteDir(tar.NewWriter(stream), localPath, wanted, progressFunc(progress)); err != nil {
+		return result, err
+	}
+	msg, err := stream.ReceiveMsg()
+	if err != nil {
+		return result, err
+	}
+	var uploaded muxrpc.UploadResult
+	if err := json.Unmarshal(msg, &uploaded); err != nil {
+		return result, err
+	}
+	if strings.TrimSpace(uploaded.Error) != "" {
+		return result, errors.New(uploaded.Error)
+	}
+	result.Files, result.Bytes, result.Skipped = uploaded.Files, uploaded.Bytes, plan.Skipped
+	return result, nil
+}
+
+func progressFunc(progress copyProgress) func(int64) {
+	if progress == nil {
+		return nil
+	}
+	return progress.Add
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shayne/viberun/internal/filecopy"
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+func TestParseCopyArgs(t *testing.T) {
+	src, dst, err := parseCopyArgs([]string{"myapp:/srv/data", "./data"}, "")
+	if err != nil || src.App != "myapp" || src.Path != "/srv/data" || dst.App != "" || dst.Path != "./data" {
+		t.Fatalf("unexpected parse: %+v %+v %v", src, dst, err)
+	}
+	src, dst, err = parseCopyArgs([]string{"./a:b", ":logs"}, "current")
+	if err != nil || src.App != "" || src.Path != "./a:b" || dst.App != "current" || dst.Path != "logs" {
+		t.Fatalf("unexpected parse with current app: %+v %+v %v", src, dst, err)
+	}
+	for _, args := range [][]string{{"a", "b"}, {"x:a", "y:b"}, {"x:a"}, {":a", "b"}} {
+		if _, _, err := parseCopyArgs(args, ""); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
+
+// newTestCopyGateway serves app copies from and into appDir, standing in
+// for the container.
+func newTestCopyGateway(t *testing.T, appDir string) *gatewayClient {
+	t.Helper()
+	link, _ := newTestGatewayLink(t, map[string]mux.Handler{
+		"download": func(stream *mux.Stream, _ mux.StreamOpen) {
+			defer func() { _ = stream.Close() }()
+			have, err := filecopy.ReceiveManifest(stream)
+			if err != nil {
+				return
+			}
+			send, plan, _ := filecopy.Plan(appDir, filecopy.Index(have))
+			payload, _ := json.Marshal(muxrpc.DownloadInfo{Size: plan.Bytes, Files: plan.Files, Skipped: plan.Skipped, Dir: true})
+			_ = stream.SendMsg(payload)
+			_, _ = filecopy.WriteDir(tar.NewWriter(stream), appDir, func(entry muxrpc.CopyEntry) bool { return send[entry.Path] }, nil)
+			for {
+				if _, err := stream.ReceiveMsg(); err != nil {
+					return
+				}
+			}
+		},
+		"upload": func(stream *mux.Stream, _ mux.StreamOpen) {
+			defer func() { _ = stream.Close() }()
+			entries, _ := filecopy.ScanDir(appDir)
+			if err := filecopy.SendManifest(stream, entries); err != nil {
+				return
+			}
+			stats, err := filecopy.Extract(tar.NewReader(stream), appDir, nil)
+			result := muxrpc.UploadResult{Files: stats.Files, Bytes: stats.Bytes}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			payload, _ := json.Marshal(result)
+			_ = stream.SendMsg(payload)
+		},
+	})
+	gateway, err := connectGateway(func(io.Writer) (*gatewayLink, error) { return link, nil })
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = gateway.Close() })
+	return gateway
+}
+
+type recordingProgress struct {
+	total int64
+	done  int64
+}
+
+func (p *recordingProgress) Start(total int64) { p.total = total }
+func (p *recordingProgress) Add(n int64)       { p.done += n }
+
+func TestCopyToAppSkipsUnchangedFiles(t *testing.T) {
+	appDir := filepath.Join(t.TempDir(), "site")
+	local := t.TempDir()
+	for name, data := range map[string]string{"index.html": "<h1>hi</h1>", "app.js": "run()"} {
+		if err := os.WriteFile(filepath.Join(local, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	gateway := newTestCopyGateway(t, appDir)
+	progress := &recordingProgress{}
+	result, err := copyToApp(gateway, local, "myapp", "site", progress)
+	if err != nil {
+		t.Fatalf("first copy: %v", err)
+	}
+	if result.Files != 2 || result.Skipped != 0 || progress.done != progress.total || progress.total != 16 {
+		t.Fatalf("unexpected first copy: %+v %+v", result, progress)
+	}
+
+	if err := os.WriteFile(filepath.Join(local, "app.js"), []byte("run(2)"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	result, err = copyToApp(gateway, local, "myapp", "site", nil)
+	if err != nil {
+		t.Fatalf("second copy: %v", err)
+	}
+	if result.Files != 1 || result.Skipped != 1 {
+		t.Fatalf("unexpected second copy: %+v", result)
+	}
+	if data, err := os.ReadFile(filepath.Join(appDir, "app.js")); err != nil || string(data) != "run(2)" {
+		t.Fatalf("unexpected app file: %q %v", data, err)
+	}
+}
+
+func TestCopyFromAppIntoExistingDirectory(t *testing.T) {
+	appDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(appDir, "log.txt"), []byte("line\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	gateway := newTestCopyGateway(t, appDir)
+	local := t.TempDir()
+	result, err := copyFromApp(gateway, "myapp", "/var/logs", local, nil)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	want := filepath.Join(local, "logs")
+	if result.Dest != want || result.Files != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	info, err := os.Stat(filepath.Join(want, "log.txt"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected local file: %v %v", info, err)
+	}
+	result, err = copyFromApp(gateway, "myapp", "/var/logs", local, nil)
+	if err != nil || result.Files != 0 || result.Skipped != 1 {
+		t.Fatalf("expected unchanged file to be skipped: %+v %v", result, err)
+	}
+}
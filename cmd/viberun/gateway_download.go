@@ -0,0 +1,70 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+// downloadFileOverGateway copies a host file to localPath over a download
+// stream. The local file only appears once the full size has arrived.
+func downloadFileOverGateway(gateway *gatewayClient, remotePath string, localPath string) (int64, error) {
+	if gateway == nil {
+		return 0, os.ErrInvalid
+	}
+	stream, err := gateway.openStream("download", muxrpc.DownloadMeta{Target: "host", Path: remotePath})
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = stream.Close() }()
+	msg, err := stream.ReceiveMsg()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, errors.New("download stream closed; the remote viberun-server may be out of date")
+		}
+		return 0, err
+	}
+	var info muxrpc.DownloadInfo
+	if err := json.Unmarshal(msg, &info); err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(info.Error) != "" {
+		return 0, errors.New(info.Error)
+	}
+	if dir := filepath.Dir(localPath); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	tmp := localPath + ".part"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.CopyN(file, stream, info.Size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		if errors.Is(err, io.EOF) {
+			return n, fmt.Errorf("download interrupted after %d of %d bytes", n, info.Size)
+		}
+		return n, err
+	}
+	if err := os.Rename(tmp, localPath); err != nil {
+		_ = os.Remove(tmp)
+		return n, err
+	}
+	return n, nil
+}
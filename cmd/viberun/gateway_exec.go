@@ -0,0 +1,122 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+// execStreamIO wires an exec stream to local files. A nil Stdin closes the
+// command's stdin right away; Signals forwards local signals until the
+// command exits.
+type execStreamIO struct {
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+	Signals <-chan os.Signal
+}
+
+// runExecOverGateway runs a command over an exec stream and returns how it
+// exited. The error is only set when the stream itself failed.
+func runExecOverGateway(gateway *gatewayClient, meta muxrpc.ExecMeta, streams execStreamIO) (muxrpc.ExecExit, error) {
+	link, err := gateway.current()
+	if err != nil {
+		return muxrpc.ExecExit{}, err
+	}
+	if !link.mux.PeerHas(muxrpc.CapExecStream) {
+		return muxrpc.ExecExit{}, errors.New("remote viberun-server does not support run; rerun setup to update it")
+	}
+	stream, err := link.mux.OpenStream("exec", meta)
+	if err != nil {
+		return muxrpc.ExecExit{}, err
+	}
+	defer func() { _ = stream.Close() }()
+	send := func(input muxrpc.ExecInput) error {
+		payload, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(payload)
+	}
+
+	stdout := streams.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	stdoutDone := make(chan struct{})
+	go func() {
+		defer close(stdoutDone)
+		_, _ = io.Copy(stdout, stream)
+	}()
+	if streams.Stdin == nil {
+		_ = stream.CloseWrite()
+	} else {
+		go pumpExecStdin(streams.Stdin, stream)
+	}
+	if streams.Signals != nil {
+		go func() {
+			for {
+				select {
+				case sig := <-streams.Signals:
+					if name := execSignalName(sig); name != "" {
+						_ = send(muxrpc.ExecInput{Signal: name})
+					}
+				case <-stream.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		msg, err := stream.ReceiveMsg()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return muxrpc.ExecExit{}, errors.New("exec stream closed before the command finished")
+			}
+			return muxrpc.ExecExit{}, err
+		}
+		var event muxrpc.ExecEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			continue
+		}
+		if len(event.Stderr) > 0 && streams.Stderr != nil {
+			_, _ = streams.Stderr.Write(event.Stderr)
+		}
+		if event.Exit != nil {
+			// The gateway closes the stream after the exit event, so stdout
+			// ends once its queued data is written.
+			<-stdoutDone
+			return *event.Exit, nil
+		}
+	}
+}
+
+// pumpExecStdin sends stdin as stream data, so the gateway's window holds
+// it back when the command is not reading, and half-closes the stream when
+// stdin ends.
+func pumpExecStdin(stdin io.Reader, stream *mux.Stream) {
+	_, _ = io.Copy(stream, stdin)
+	_ = stream.CloseWrite()
+}
+
+func execSignalName(sig os.Signal) string {
+	switch sig {
+	case os.Interrupt:
+		return "INT"
+	case syscall.SIGTERM:
+		return "TERM"
+	case syscall.SIGHUP:
+		return "HUP"
+	}
+	return ""
+}
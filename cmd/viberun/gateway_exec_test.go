@@ -0,0 +1,79 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	app, command, err := parseRunArgs([]string{"myapp", "--", "ls", "-la"})
+	if err != nil || app != "myapp" || !reflect.DeepEqual(command, []string{"ls", "-la"}) {
+		t.Fatalf("unexpected parse: %q %q %v", app, command, err)
+	}
+	app, command, err = parseRunArgs([]string{"myapp", "npm", "test"})
+	if err != nil || app != "myapp" || !reflect.DeepEqual(command, []string{"npm", "test"}) {
+		t.Fatalf("unexpected parse without separator: %q %q %v", app, command, err)
+	}
+	for _, args := range [][]string{nil, {"myapp"}, {"myapp", "--"}, {"a", "b", "--", "ls"}} {
+		if _, _, err := parseRunArgs(args); err == nil {
+			t.Fatalf("expected usage error for %q", args)
+		}
+	}
+}
+
+func TestRunExecOverGateway(t *testing.T) {
+	metas := make(chan muxrpc.ExecMeta, 1)
+	link, _ := newTestGatewayLink(t, map[string]mux.Handler{
+		"exec": func(stream *mux.Stream, open mux.StreamOpen) {
+			defer func() { _ = stream.Close() }()
+			var meta muxrpc.ExecMeta
+			_ = json.Unmarshal(open.Meta, &meta)
+			metas <- meta
+			// Stdin is stream data that ends with the client's half-close.
+			stdin, err := io.ReadAll(stream)
+			if err != nil {
+				return
+			}
+			_, _ = stream.Write(bytes.ToUpper(stdin))
+			payload, _ := json.Marshal(muxrpc.ExecEvent{Stderr: []byte("warn\n")})
+			_ = stream.SendMsg(payload)
+			payload, _ = json.Marshal(muxrpc.ExecEvent{Exit: &muxrpc.ExecExit{Code: 2}})
+			_ = stream.SendMsg(payload)
+		},
+	})
+	gateway, err := connectGateway(func(io.Writer) (*gatewayLink, error) { return link, nil })
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer gateway.Close()
+
+	var stdout, stderr bytes.Buffer
+	exit, err := runExecOverGateway(gateway, muxrpc.ExecMeta{App: "myapp", Args: []string{"cat"}}, execStreamIO{
+		Stdin:  strings.NewReader("hello"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if meta := <-metas; meta.App != "myapp" || !reflect.DeepEqual(meta.Args, []string{"cat"}) {
+		t.Fatalf("unexpected meta: %+v", meta)
+	}
+	if stdout.String() != "HELLO" || stderr.String() != "warn\n" {
+		t.Fatalf("unexpected output: stdout=%q stderr=%q", stdout.String(), stderr.String())
+	}
+	if exit.Code != 2 {
+		t.Fatalf("unexpected exit: %+v", exit)
+	}
+}
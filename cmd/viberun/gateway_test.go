@@ -0,0 +1,141 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/mux"
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+// newTestGatewayLink connects a gateway link to an in-process server that
+// answers every control request with an empty result and advertises exec
+// and copy streams.
+func newTestGatewayLink(t *testing.T, handlers map[string]mux.Handler) (*gatewayLink, *mux.Mux) {
+	t.Helper()
+	left, right := net.Pipe()
+	t.Cleanup(func() {
+		_ = left.Close()
+		_ = right.Close()
+	})
+	server := mux.New(right, false)
+	server.Handle("control", func(stream *mux.Stream, _ mux.StreamOpen) {
+		for {
+			msg, err := stream.ReceiveMsg()
+			if err != nil {
+				return
+			}
+			var req muxrpc.Request
+			if err := json.Unmarshal(msg, &req); err != nil {
+				continue
+			}
+			payload, _ := json.Marshal(muxrpc.Response{ID: req.ID})
+			_ = stream.SendMsg(payload)
+		}
+	})
+	for name, handler := range handlers {
+		server.Handle(name, handler)
+	}
+	server.Advertise(muxrpc.CapExecStream, muxrpc.CapCopyStream)
+	server.Run()
+	client := mux.New(left, true)
+	client.Run()
+	if err := client.Handshake(2 * time.Second); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	link, err := newGatewayLink(client, nil)
+	if err != nil {
+		t.Fatalf("new link: %v", err)
+	}
+	return link, server
+}
+
+func waitClosed(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestGatewayReconnectsAfterLinkDrops(t *testing.T) {
+	opens := make(chan int, 4)
+	servers := make(chan *mux.Mux, 4)
+	dials := 0
+	dial := func(io.Writer) (*gatewayLink, error) {
+		dials++
+		n := dials
+		link, server := newTestGatewayLink(t, map[string]mux.Handler{
+			"open": func(*mux.Stream, mux.StreamOpen) { opens <- n },
+		})
+		servers <- server
+		return link, nil
+	}
+	gateway, err := connectGateway(dial)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer gateway.Close()
+	gateway.enableReconnect()
+	if err := gateway.startOpenStream(nil); err != nil {
+		t.Fatalf("start open stream: %v", err)
+	}
+	if n := <-opens; n != 1 {
+		t.Fatalf("expected open stream on link 1, got %d", n)
+	}
+
+	lost := gateway.linkDone()
+	_ = (<-servers).Close()
+	waitClosed(t, lost, "the link to drop")
+	if err := gateway.awaitLink(); err != nil {
+		t.Fatalf("await link: %v", err)
+	}
+	select {
+	case n := <-opens:
+		if n != 2 {
+			t.Fatalf("expected open stream on link 2, got %d", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the open stream to be reopened")
+	}
+	if err := gateway.rpc("ping", nil, nil); err != nil {
+		t.Fatalf("rpc after reconnect: %v", err)
+	}
+	if status, _ := gateway.watchStatus(); status.reconnecting || status.err != nil {
+		t.Fatalf("unexpected status after reconnect: %+v", status)
+	}
+	select {
+	case <-gateway.done():
+		t.Fatal("expected gateway to stay open")
+	default:
+	}
+}
+
+func TestGatewayWithoutReconnectFinishes(t *testing.T) {
+	var server *mux.Mux
+	gateway, err := connectGateway(func(io.Writer) (*gatewayLink, error) {
+		link, s := newTestGatewayLink(t, nil)
+		server = s
+		return link, nil
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	_ = server.Close()
+	waitClosed(t, gateway.done(), "the gateway to finish")
+	if err := gateway.rpc("ping", nil, nil); !errors.Is(err, errGatewayClosed) {
+		t.Fatalf("expected closed gateway error, got %v", err)
+	}
+	if err := gateway.awaitLink(); !errors.Is(err, errGatewayClosed) {
+		t.Fatalf("expected await to fail, got %v", err)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -21,6 +20,7 @@ import (
 	"os/exec"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,7 +31,9 @@ import (
 	"golang.org/x/term"
 
 	"github.com/shayne/viberun/internal/config"
+	"github.com/shayne/viberun/internal/muxrpc"
 	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/serverapi"
 	"github.com/shayne/viberun/internal/sshcmd"
 	"github.com/shayne/viberun/internal/target"
 	"github.com/shayne/viberun/internal/tui"
@@ -43,6 +45,10 @@ import (
 func main() {
 	if err := runCLI(); err != nil {
 		reportCLIError(err)
+		var exitErr exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 	}
 }
 
@@ -66,6 +72,25 @@ func (e silentError) Unwrap() error {
 	return e.err
 }
 
+// exitCodeError makes the CLI exit with code. Most commands exit 0 even on
+// failure; run passes through the remote command's status. A nil err
+// exits without printing anything.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e exitCodeError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e exitCodeError) Unwrap() error {
+	return e.err
+}
+
 type missingHostError struct{}
 
 func (missingHostError) Error() string {
@@ -73,6 +98,10 @@ func (missingHostError) Error() string {
 }
 
 func reportCLIError(err error) {
+	var exitErr exitCodeError
+	if errors.As(err, &exitErr) && exitErr.err == nil {
+		return
+	}
 	var usageErr usageError
 	if errors.As(err, &usageErr) {
 		fmt.Fprintln(os.Stderr, usageErr.message)
@@ -123,12 +152,18 @@ func runCLI() error {
 	if len(args) > 0 && args[0] == "attach" {
 		return handleAttach(args[1:])
 	}
+	if len(args) > 0 && args[0] == "run" {
+		// The remote command's own flags must not reach the parser.
+		return handleRun(args[1:])
+	}
 	if hasVersionFlag(args) {
 		fmt.Fprintln(os.Stdout, versionString())
 		return nil
 	}
 	handlers := map[string]yargs.SubcommandHandler{
 		"attach": handleAttachCommand,
+		"cp":     handleCopyCommand,
+		"run":    handleRunCommand,
 		"setup":  handleSetupCommand,
 		"wipe":   handleWipeCommand,
 	}
@@ -159,6 +194,23 @@ type attachArgs struct {
 	App string `pos:"0" help:"app to attach"`
 }
 
+type copyFlags struct {
+	Host string `flag:"host" help:"host override"`
+}
+
+type copyArgs struct {
+	Src string `pos:"0" help:"source: <app>:<path> or a local path"`
+	Dst string `pos:"1" help:"destination: <app>:<path> or a local path"`
+}
+
+type runFlags struct {
+	Host string `flag:"host" help:"host override"`
+}
+
+type runArgs struct {
+	App string `pos:"0" help:"app to run the command in"`
+}
+
 var helpConfig = yargs.HelpConfig{
 	Command: yargs.CommandInfo{
 		Name:        "viberun",
@@ -167,6 +219,8 @@ var helpConfig = yargs.HelpConfig{
 			"viberun",
 			"viberun --help",
 			"viberun attach myapp",
+			"viberun run myapp -- npm test",
+			"viberun cp ./src myapp:src",
 			"viberun help setup",
 			"viberun --version",
 			"viberun setup",
@@ -179,6 +233,16 @@ var helpConfig = yargs.HelpConfig{
 			Description: "Attach to an app session (internal)",
 			Usage:       "[--host <host>] [--agent <provider>] [--shell] <app>",
 		},
+		"cp": {
+			Name:        "cp",
+			Description: "Copy files and directories to or from an app container",
+			Usage:       "[--host <host>] <app>:<path> <local-path> | <local-path> <app>:<path>",
+		},
+		"run": {
+			Name:        "run",
+			Description: "Run a command in an app container and exit with its status",
+			Usage:       "[--host <host>] <app> -- <command> [args...]",
+		},
 		"setup": {
 			Name:        "setup",
 			Description: "Connect a server and install viberun",
@@ -233,7 +297,7 @@ func rewriteHelpArgs(args []string) []string {
 
 func isKnownCommand(value string) bool {
 	switch value {
-	case "setup", "wipe":
+	case "cp", "run", "setup", "wipe":
 		return true
 	default:
 		return false
@@ -377,6 +441,121 @@ func handleAttach(args []string) error {
 	}
 }
 
+func handleCopyCommand(_ context.Context, args []string) error {
+	return handleCopy(args)
+}
+
+// handleCopy copies between the local machine and an app container. A
+// failed copy exits 1.
+func handleCopy(args []string) error {
+	parseArgs := append([]string{"cp"}, args...)
+	result, err := yargs.ParseAndHandleHelp[struct{}, copyFlags, copyArgs](parseArgs, helpConfig)
+	if errors.Is(err, yargs.ErrShown) {
+		return nil
+	}
+	if err != nil {
+		return exitCodeError{code: 1, err: err}
+	}
+	src, dst, err := parseCopyArgs([]string{result.Args.Src, result.Args.Dst}, "")
+	if err != nil {
+		return exitCodeError{code: 1, err: newUsageError(err.Error())}
+	}
+	remote := src
+	if remote.App == "" {
+		remote = dst
+	}
+	cfg, _, err := config.Load()
+	if err != nil {
+		return exitCodeError{code: 1, err: fmt.Errorf("failed to load config: %w", err)}
+	}
+	if host := strings.TrimSpace(result.SubCommandFlags.Host); host != "" {
+		cfg.DefaultHost = host
+	}
+	resolved, err := target.Resolve(remote.App, cfg)
+	if err != nil {
+		if errors.Is(err, target.ErrNoHostConfigured) {
+			return exitCodeError{code: 1, err: missingHostError{}}
+		}
+		return exitCodeError{code: 1, err: err}
+	}
+	gateway, err := startGateway(resolved.Host, strings.TrimSpace(cfg.AgentProvider), devChannelEnv(), false)
+	if err != nil {
+		return exitCodeError{code: 1, err: err}
+	}
+	defer func() { _ = gateway.Close() }()
+	copied, err := copyWithProgress(gateway, resolved.App, src, dst)
+	if err != nil {
+		return exitCodeError{code: 1, err: err}
+	}
+	fmt.Fprintln(os.Stdout, formatCopyResult(copied, dst.App != "", remote.App))
+	return nil
+}
+
+func handleRunCommand(_ context.Context, args []string) error {
+	return handleRun(args)
+}
+
+// handleRun runs a command in an app container. It exits with the
+// command's status, or 255 when the command could not be run.
+func handleRun(args []string) error {
+	head, command := args, []string(nil)
+	if idx := slices.Index(args, "--"); idx >= 0 {
+		head, command = args[:idx], args[idx+1:]
+	}
+	parseArgs := append([]string{"run"}, head...)
+	result, err := yargs.ParseAndHandleHelp[struct{}, runFlags, runArgs](parseArgs, helpConfig)
+	if errors.Is(err, yargs.ErrShown) {
+		return nil
+	}
+	if err != nil {
+		return exitCodeError{code: runLocalFailureCode, err: err}
+	}
+	app := strings.TrimSpace(result.Args.App)
+	if app == "" || len(command) == 0 {
+		return exitCodeError{code: runLocalFailureCode, err: newUsageError("usage: viberun run [--host <host>] <app> -- <command> [args...]")}
+	}
+	exit, err := runCLICommand(app, strings.TrimSpace(result.SubCommandFlags.Host), command)
+	if err != nil {
+		return exitCodeError{code: runLocalFailureCode, err: err}
+	}
+	if exit.Error != "" {
+		return exitCodeError{code: exit.Code, err: errors.New(exit.Error)}
+	}
+	if exit.Code != 0 {
+		return exitCodeError{code: exit.Code}
+	}
+	return nil
+}
+
+func runCLICommand(app string, host string, command []string) (muxrpc.ExecExit, error) {
+	cfg, _, err := config.Load()
+	if err != nil {
+		return muxrpc.ExecExit{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	if host != "" {
+		cfg.DefaultHost = host
+	}
+	resolved, err := target.Resolve(app, cfg)
+	if err != nil {
+		if errors.Is(err, target.ErrNoHostConfigured) {
+			return muxrpc.ExecExit{}, missingHostError{}
+		}
+		return muxrpc.ExecExit{}, err
+	}
+	gateway, err := startGateway(resolved.Host, strings.TrimSpace(cfg.AgentProvider), devChannelEnv(), false)
+	if err != nil {
+		return muxrpc.ExecExit{}, err
+	}
+	defer func() { _ = gateway.Close() }()
+	// A terminal on stdin means nothing is being piped in; leaving it
+	// unread keeps commands that read stdin from waiting on the keyboard.
+	var stdin io.Reader
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		stdin = os.Stdin
+	}
+	return runAppCommand(gateway, resolved.App, command, stdin)
+}
+
 func promptProxySetup() bool {
 	prompt := "Set up a public domain name? [y/N]: "
 	if useDialogPrompts() {
@@ -637,51 +816,24 @@ func fetchRemoteServerVersion(host string) (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
-type proxyInfo struct {
-	App          string   `json:"app"`
-	URL          string   `json:"url"`
-	Access       string   `json:"access"`
-	Disabled     bool     `json:"disabled"`
-	CustomDomain string   `json:"custom_domain"`
-	AllowedUsers []string `json:"allowed_users"`
-	PrimaryUser  string   `json:"primary_user"`
-	Users        []string `json:"users"`
-	BaseDomain   string   `json:"base_domain"`
-	PublicIP     string   `json:"public_ip"`
-	Enabled      bool     `json:"enabled"`
-}
+type proxyInfo = serverapi.ProxyInfo
 
-type proxyConfigSummary struct {
-	Enabled     bool     `json:"enabled"`
-	BaseDomain  string   `json:"base_domain"`
-	PublicIP    string   `json:"public_ip"`
-	PrimaryUser string   `json:"primary_user"`
-	ProxyImage  string   `json:"proxy_image"`
-	Users       []string `json:"users"`
-}
+type proxyConfigSummary = serverapi.ProxyConfig
 
 func fetchProxyInfo(gateway *gatewayClient, app string) (proxyInfo, error) {
 	remoteArgs := []string{"proxy", "info", app}
-	output, err := gateway.command(remoteArgs, "", nil)
-	if err != nil {
-		return proxyInfo{}, err
-	}
 	var info proxyInfo
-	if err := json.Unmarshal([]byte(output), &info); err != nil {
-		return proxyInfo{}, fmt.Errorf("failed to parse proxy info: %w", err)
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindProxyInfo, &info); err != nil {
+		return proxyInfo{}, err
 	}
 	return info, nil
 }
 
 func fetchRemoteProxyConfig(gateway *gatewayClient) (proxyConfigSummary, error) {
 	remoteArgs := []string{"proxy", "config"}
-	output, err := gateway.command(remoteArgs, "", nil)
-	if err != nil {
-		return proxyConfigSummary{}, err
-	}
 	var summary proxyConfigSummary
-	if err := json.Unmarshal([]byte(output), &summary); err != nil {
-		return proxyConfigSummary{}, fmt.Errorf("failed to parse proxy config: %w", err)
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindProxyConfig, &summary); err != nil {
+		return proxyConfigSummary{}, err
 	}
 	return summary, nil
 }
@@ -704,6 +856,16 @@ func runRemoteUsersSetPassword(gateway *gatewayClient, username string, password
 	return err
 }
 
+func runRemoteUsersGroup(gateway *gatewayClient, username string, group string, member bool) error {
+	action := "remove-group"
+	if member {
+		action = "add-group"
+	}
+	args := []string{"proxy", "users", action, "--username", username, "--group", group}
+	_, err := gateway.command(args, "", nil)
+	return err
+}
+
 func runRemoteWipe(gateway *gatewayClient) error {
 	args := []string{"wipe"}
 	_, err := gateway.command(args, "", nil)
@@ -715,14 +877,13 @@ func runRemoteAppsList(gateway *gatewayClient) ([]string, error) {
 }
 
 func runRemoteAppsListWithArgs(gateway *gatewayClient, args []string) ([]string, error) {
-	output, err := gateway.command(args, "", nil)
-	if err != nil {
+	var list serverapi.AppList
+	if err := gateway.commandJSON(args, "", nil, serverapi.KindApps, &list); err != nil {
 		return nil, err
 	}
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	apps := make([]string, 0, len(lines))
-	for _, line := range lines {
-		name := strings.TrimSpace(line)
+	apps := make([]string, 0, len(list.Apps))
+	for _, app := range list.Apps {
+		name := strings.TrimSpace(app.Name)
 		if name == "" {
 			continue
 		}
@@ -781,6 +942,17 @@ func runRemoteSetDisabled(gateway *gatewayClient, app string, disabled bool) err
 	return err
 }
 
+// runRemoteSetIP applies an app IP rule: action is allow, deny, or clear.
+// Clearing without a CIDR removes every rule.
+func runRemoteSetIP(gateway *gatewayClient, app string, action string, cidr string) error {
+	if action == "clear" && strings.TrimSpace(cidr) == "" {
+		cidr = "all"
+	}
+	args := []string{"proxy", "set-ip", app, "--" + action, cidr}
+	_, err := gateway.command(args, "", nil)
+	return err
+}
+
 func runRemoteSetUsers(gateway *gatewayClient, app string, users []string) error {
 	joined := strings.Join(users, ",")
 	args := []string{"proxy", "set-users", app, "--users", joined}
@@ -788,6 +960,16 @@ func runRemoteSetUsers(gateway *gatewayClient, app string, users []string) error
 	return err
 }
 
+func runRemoteSetGroups(gateway *gatewayClient, app string, groups []string) error {
+	joined := "none"
+	if len(groups) > 0 {
+		joined = strings.Join(groups, ",")
+	}
+	args := []string{"proxy", "set-groups", app, "--groups", joined}
+	_, err := gateway.command(args, "", nil)
+	return err
+}
+
 func promptRecreateApps(gateway *gatewayClient, host string) error {
 	apps, err := runRemoteAppsList(gateway)
 	if err != nil {
@@ -839,6 +1021,15 @@ func printURLSummary(out io.Writer, info proxyInfo) {
 	if len(info.AllowedUsers) > 0 {
 		fmt.Fprintf(out, "%s %s\n", styler.label("Users:"), styler.value(strings.Join(info.AllowedUsers, ", ")))
 	}
+	if len(info.AllowedGroups) > 0 {
+		fmt.Fprintf(out, "%s %s\n", styler.label("Groups:"), styler.value(strings.Join(info.AllowedGroups, ", ")))
+	}
+	if len(info.AllowIPs) > 0 {
+		fmt.Fprintf(out, "%s %s\n", styler.label("IP allow:"), styler.value(strings.Join(info.AllowIPs, ", ")))
+	}
+	if len(info.DenyIPs) > 0 {
+		fmt.Fprintf(out, "%s %s\n", styler.label("IP deny:"), styler.value(strings.Join(info.DenyIPs, ", ")))
+	}
 	fmt.Fprintln(out, "")
 	fmt.Fprintln(out, styler.header("Commands:"))
 	styler.commands(out, []commandLine{
@@ -847,6 +1038,7 @@ func printURLSummary(out io.Writer, info proxyInfo) {
 		{cmd: fmt.Sprintf("app %s users", info.App), desc: "manage who can access this app"},
 		{cmd: fmt.Sprintf("app %s url public", info.App), desc: "allow anyone to access"},
 		{cmd: fmt.Sprintf("app %s url private", info.App), desc: "require login to access"},
+		{cmd: fmt.Sprintf("app %s url ip allow <cidr>", info.App), desc: "only allow these addresses"},
 		{cmd: fmt.Sprintf("app %s url disable", info.App), desc: "turn off the URL"},
 		{cmd: fmt.Sprintf("app %s url enable", info.App), desc: "turn the URL back on"},
 	})
@@ -1544,6 +1736,26 @@ else
     $SUDO ln -sf "$VIBERUN_SERVER_PATH" "/usr/local/bin/viberun-server"
   fi
 fi
+
+if need_cmd systemctl && [ -d /run/systemd/system ]; then
+  $SUDO tee /etc/systemd/system/viberun-snapshots.service >/dev/null <<'UNIT'
+[Unit]
+Description=viberun scheduled snapshots
+After=docker.service
+Wants=docker.service
+
+[Service]
+ExecStart=/usr/local/bin/viberun-server snapshots daemon
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+  $SUDO systemctl daemon-reload
+  $SUDO systemctl enable viberun-snapshots.service >/dev/null 2>&1 || true
+  $SUDO systemctl restart viberun-snapshots.service >/dev/null 2>&1 || true
+fi
 `
 }
 
@@ -1561,26 +1773,23 @@ func shellQuote(value string) string {
 
 func resolveHostPort(gateway *gatewayClient, app string, agentProvider string) (int, error) {
 	remoteArgs := buildAppCommandArgs(agentProvider, app, []string{"port"})
-	output, err := gateway.command(remoteArgs, "", nil)
-	if err != nil {
+	var result serverapi.AppPort
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindAppPort, &result); err != nil {
 		return 0, fmt.Errorf("failed to resolve host port: %v", err)
 	}
-	portText := strings.TrimSpace(string(output))
-	port, err := strconv.Atoi(portText)
-	if err != nil || port <= 0 {
-		return 0, fmt.Errorf("unexpected host port response: %q", portText)
+	if result.Port <= 0 {
+		return 0, fmt.Errorf("unexpected host port response: %d", result.Port)
 	}
-	return port, nil
+	return result.Port, nil
 }
 
 func remoteContainerExists(gateway *gatewayClient, app string, agentProvider string) (bool, error) {
 	remoteArgs := buildAppCommandArgs(agentProvider, app, []string{"exists"})
-	output, err := gateway.command(remoteArgs, "", nil)
-	if err != nil {
+	var result serverapi.AppExists
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindAppExists, &result); err != nil {
 		return false, fmt.Errorf("failed to check container: %v", err)
 	}
-	text := strings.TrimSpace(strings.ToLower(string(output)))
-	return text == "true" || text == "yes" || text == "1", nil
+	return result.Exists, nil
 }
 
 func ensureLocalPortAvailable(port int) error {
@@ -6,10 +6,10 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"net"
 	"sync"
 
+	"github.com/shayne/viberun/internal/mux"
 	"github.com/shayne/viberun/internal/muxrpc"
 )
 
@@ -42,16 +42,7 @@ func startLocalForwardMux(gateway *gatewayClient, port int) (func(), error) {
 					return
 				}
 				defer func() { _ = stream.Close() }()
-				copyDone := make(chan struct{}, 2)
-				go func() {
-					_, _ = io.Copy(stream, c)
-					copyDone <- struct{}{}
-				}()
-				go func() {
-					_, _ = io.Copy(c, stream)
-					copyDone <- struct{}{}
-				}()
-				<-copyDone
+				mux.Pipe(stream, c)
 			}(conn)
 		}
 	}()
@@ -32,6 +32,8 @@ const (
 	connConnecting
 	connConnected
 	connFailed
+	// connReconnecting means the gateway lost its link and is redialing.
+	connReconnecting
 )
 
 type setupAction struct {
@@ -49,12 +51,15 @@ const (
 
 type appSummary struct {
 	Name       string
+	Host       string
 	Status     appStatus
 	LocalURL   string
 	PublicURL  string
 	Port       int
 	Forwarded  bool
 	ForwardErr string
+	// DiskPercent is how full the home volume is, when the host reports it.
+	DiskPercent int
 }
 
 type appForward struct {
@@ -68,6 +73,8 @@ type shellActionKind int
 const (
 	actionVibe shellActionKind = iota
 	actionShell
+	actionRun
+	actionCopy
 	actionDelete
 	actionProxySetup
 	actionUsersAdd
@@ -80,6 +87,7 @@ const (
 type shellAction struct {
 	kind         shellActionKind
 	app          string
+	args         []string
 	host         string
 	username     string
 	proxyPlan    *proxyPlan
@@ -132,6 +140,7 @@ type shellState struct {
 	appForwards        map[string]appForward
 	forwarder          *forwardManager
 	appsStream         *appsStream
+	hostGateways       *hostGatewayPool
 }
 
 func runShell() error {
@@ -141,6 +150,7 @@ func runShell() error {
 	}
 	defer func() {
 		closeShellGateway(state)
+		state.hostGateways.closeAll()
 	}()
 	for {
 		model := newShellModel(state)
@@ -223,7 +233,7 @@ func actionClearsTerminal(kind shellActionKind) bool {
 
 func actionResumesShell(kind shellActionKind) bool {
 	switch kind {
-	case actionVibe, actionShell, actionDelete:
+	case actionVibe, actionShell, actionRun, actionCopy, actionDelete:
 		return true
 	default:
 		return false
@@ -247,6 +257,7 @@ func newShellState() (*shellState, error) {
 		devMode:      isDevMode(),
 		bootstrapped: false,
 		appForwards:  map[string]appForward{},
+		hostGateways: newHostGatewayPool(),
 	}
 	state.host = strings.TrimSpace(cfg.DefaultHost)
 	state.agent = strings.TrimSpace(cfg.AgentProvider)
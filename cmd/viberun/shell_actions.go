@@ -21,6 +21,7 @@ import (
 )
 
 type preparedSession struct {
+	target     string
 	resolved   target.Resolved
 	gateway    *gatewayClient
 	ptyMeta    muxrpc.PtyMeta
@@ -34,6 +35,10 @@ func runShellAction(state *shellState, action shellAction) error {
 		return runShellAttachSubprocess(state, action.app, "")
 	case actionShell:
 		return runShellAttachSubprocess(state, action.app, "shell")
+	case actionRun:
+		return runShellRun(state, action.app, action.args)
+	case actionCopy:
+		return runShellCopy(state, action.app, action.args)
 	case actionDelete:
 		return runShellDelete(state, action.app)
 	case actionProxySetup:
@@ -102,7 +107,11 @@ func runPreparedInteractive(state *shellState, session *preparedSession) error {
 		defer session.cleanup()
 	}
 	action := strings.TrimSpace(session.ptyMeta.Action)
-	if err := runShellAttachSubprocess(state, session.resolved.App, action); err != nil {
+	app := session.resolved.App
+	if strings.Contains(session.target, "@") {
+		app = session.target
+	}
+	if err := runShellAttachSubprocess(state, app, action); err != nil {
 		return err
 	}
 	return nil
@@ -182,6 +191,7 @@ func prepareInteractiveSession(state *shellState, appArg string, action string)
 
 	outputTail := &tailBuffer{max: 32 * 1024}
 	return &preparedSession{
+		target:     strings.TrimSpace(appArg),
 		resolved:   resolved,
 		gateway:    gateway,
 		ptyMeta:    ptyMeta,
@@ -363,8 +373,10 @@ func runDeleteConfirmed(state *shellState, appArg string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	removeAppSummary(state, resolved.App)
-	markAppsStale(state)
+	if current, err := resolveShellHost(state, ""); err == nil && current.Host == resolved.Host {
+		removeAppSummary(state, resolved.App)
+		markAppsStale(state)
+	}
 	output = strings.TrimRight(output, "\n")
 	if output == "" {
 		output = fmt.Sprintf("Deleted app %s", resolved.App)
@@ -535,9 +547,7 @@ func ensureShellGatewayForHost(state *shellState, hostArg string) (*gatewayClien
 		return nil, func() {}, err
 	}
 	if sameHost {
-		closeShellGateway(state)
-		state.gateway = gateway
-		state.gatewayHost = resolved.Host
+		adoptShellGateway(state, gateway, resolved.Host)
 		return gateway, func() {}, nil
 	}
 	cleanup := func() { _ = gateway.Close() }
@@ -553,14 +563,16 @@ func gatewayForResolvedHost(state *shellState, host string) (*gatewayClient, fun
 	if sameHost && state.gateway != nil && state.gatewayHost == host {
 		return state.gateway, func() {}, nil
 	}
+	if !sameHost && state.hostGateways != nil {
+		gateway, err := state.hostGateways.get(host, strings.TrimSpace(state.agent))
+		return gateway, func() {}, err
+	}
 	gateway, err := startGateway(host, strings.TrimSpace(state.agent), devChannelEnv(), false)
 	if err != nil {
 		return nil, func() {}, err
 	}
 	if sameHost {
-		closeShellGateway(state)
-		state.gateway = gateway
-		state.gatewayHost = host
+		adoptShellGateway(state, gateway, host)
 		return gateway, func() {}, nil
 	}
 	cleanup := func() { _ = gateway.Close() }
@@ -575,6 +587,8 @@ func startAttachGateway(state *shellState, host string) (*gatewayClient, func(),
 	if err != nil {
 		return nil, func() {}, err
 	}
+	// The session reattaches to tmux when the link comes back.
+	gateway.enableReconnect()
 	cleanup := func() { _ = gateway.Close() }
 	return gateway, cleanup, nil
 }
@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/shayne/viberun/internal/serverapi"
 )
 
 func findAppSummary(state *shellState, app string) (appSummary, bool) {
@@ -69,14 +71,14 @@ func fetchAppStatus(gateway *gatewayClient, app string) (appStatus, error) {
 		return appStatusUnknown, errors.New("gateway not connected")
 	}
 	remoteArgs := buildAppCommandArgs("", app, []string{"status"})
-	output, err := gateway.command(remoteArgs, "", nil)
-	if err != nil {
+	var result serverapi.AppStatus
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindAppStatus, &result); err != nil {
 		return appStatusUnknown, err
 	}
-	switch strings.ToLower(strings.TrimSpace(output)) {
-	case "running":
+	switch result.Status {
+	case serverapi.StatusRunning:
 		return appStatusRunning, nil
-	case "stopped", "missing":
+	case serverapi.StatusStopped, serverapi.StatusMissing:
 		return appStatusStopped, nil
 	default:
 		return appStatusUnknown, nil
@@ -123,3 +125,30 @@ func openAppURL(state *shellState, app string) (string, error) {
 	}
 	return "", nil
 }
+
+// openRemoteAppURL opens an app on a host other than the current one. Only
+// the public URL is usable there since ports are forwarded for the current
+// host alone.
+func openRemoteAppURL(state *shellState, app shellAppTarget) (string, error) {
+	gateway, cleanup, err := gatewayForShellApp(state, app)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	url := ""
+	if info, err := fetchProxyInfo(gateway, app.App); err == nil && !info.Disabled {
+		url = strings.TrimSpace(info.URL)
+	}
+	if url == "" && isLocalHost(app.Host.Host) {
+		if port, err := resolveHostPort(gateway, app.App, ""); err == nil && port > 0 {
+			url = fmt.Sprintf("http://localhost:%d", port)
+		}
+	}
+	if url == "" {
+		return "", fmt.Errorf("URL is not available for %s on %s", app.App, shellHostLabel(app.Host))
+	}
+	if err := openURL(url); err != nil {
+		return "", err
+	}
+	return "", nil
+}
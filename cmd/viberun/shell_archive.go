@@ -0,0 +1,81 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/serverapi"
+)
+
+// exportAppArchive exports a snapshot of the current app to localPath.
+func exportAppArchive(state *shellState, snapshot string, localPath string) (string, error) {
+	resolved, err := resolveAppTarget(state)
+	if err != nil {
+		return "", err
+	}
+	if state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	result, size, err := downloadAppArchive(state.gateway, strings.TrimSpace(state.agent), resolved.App, snapshot, localPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Exported %s %s to %s (%s)", resolved.App, result.Snapshot, result.Path, formatByteSize(size)), nil
+}
+
+// importAppArchive rebuilds app on the current host from a local archive.
+func importAppArchive(state *shellState, app string, localPath string) (string, error) {
+	if state == nil || state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	result, err := uploadAppArchive(state.gateway, strings.TrimSpace(state.agent), strings.TrimSpace(app), localPath)
+	if err != nil {
+		return "", err
+	}
+	lines := append([]string{fmt.Sprintf("Imported %s from %s %s", result.App, result.Source, result.Snapshot)}, result.Warnings()...)
+	return strings.Join(lines, "\n"), nil
+}
+
+// downloadAppArchive has the server write the archive to a temp file on the
+// host, then pulls it over a download stream. The returned Path is the local
+// file; an empty localPath defaults to <app>-<vN>.tar.gz.
+func downloadAppArchive(gateway *gatewayClient, agent string, app string, snapshot string, localPath string) (serverapi.ArchiveExport, int64, error) {
+	remotePath := fmt.Sprintf("/tmp/viberun-export-%s-%d.tar.gz", app, time.Now().UnixNano())
+	remoteArgs := buildAppCommandArgs(agent, app, []string{"export", snapshot, "--output", remotePath})
+	var result serverapi.ArchiveExport
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindExport, &result); err != nil {
+		return result, 0, err
+	}
+	defer func() { _, _ = gateway.exec([]string{"rm", "-f", remotePath}, "", nil) }()
+	if strings.TrimSpace(localPath) == "" {
+		localPath = fmt.Sprintf("%s-%s.tar.gz", app, result.Snapshot)
+	}
+	size, err := downloadFileOverGateway(gateway, remotePath, localPath)
+	if err != nil {
+		return result, size, fmt.Errorf("download failed: %w", err)
+	}
+	result.Path = localPath
+	return result, size, nil
+}
+
+// uploadAppArchive uploads a local archive to a temp file on the host and
+// imports it as app.
+func uploadAppArchive(gateway *gatewayClient, agent string, app string, localPath string) (serverapi.ArchiveImport, error) {
+	var result serverapi.ArchiveImport
+	remotePath := fmt.Sprintf("/tmp/viberun-import-%s-%d.tar.gz", app, time.Now().UnixNano())
+	if err := uploadFileOverGateway(gateway, localPath, remotePath); err != nil {
+		return result, fmt.Errorf("upload failed: %w", err)
+	}
+	defer func() { _, _ = gateway.exec([]string{"rm", "-f", remotePath}, "", nil) }()
+	remoteArgs := buildAppCommandArgs(agent, app, []string{"import", remotePath})
+	if err := gateway.commandJSON(remoteArgs, "", nil, serverapi.KindImport, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
@@ -17,7 +18,10 @@ import (
 	"github.com/shayne/viberun/internal/agents"
 	branchpkg "github.com/shayne/viberun/internal/branch"
 	"github.com/shayne/viberun/internal/config"
+	"github.com/shayne/viberun/internal/proxy"
+	"github.com/shayne/viberun/internal/serverapi"
 	"github.com/shayne/viberun/internal/target"
+	"github.com/shayne/viberun/internal/totp"
 )
 
 type parsedCommand struct {
@@ -264,7 +268,7 @@ func localAppValidationError(state *shellState, cmd parsedCommand) (string, bool
 		if cmd.enforceExisting {
 			return renderShellError(fmt.Sprintf("error: app %q not found. Run `vibe %s` to create it.", app, app)), true
 		}
-	case "shell", "rm", "delete":
+	case "shell", "run", "rm", "delete":
 		return renderShellError(fmt.Sprintf("error: app %q not found", app)), true
 	}
 	return "", false
@@ -318,6 +322,14 @@ func dispatchGlobalCommand(state *shellState, cmd parsedCommand) (string, tea.Cm
 	case "ls":
 		fallthrough
 	case "apps":
+		if len(cmd.args) > 0 {
+			if len(cmd.args) != 1 || cmd.args[0] != "--all-hosts" {
+				return "error: usage: apps [--all-hosts]", nil
+			}
+			return "", runAsync(func() (string, error) {
+				return allHostsAppsOutput(state)
+			})
+		}
 		if !state.appsLoaded {
 			if state.appsSyncing {
 				state.appsRenderPending = true
@@ -345,12 +357,24 @@ func dispatchGlobalCommand(state *shellState, cmd parsedCommand) (string, tea.Cm
 		if err != nil {
 			return fmt.Sprintf("error: %v", err), nil
 		}
+		appTarget, err := resolveShellApp(state, parsed.app)
+		if err != nil {
+			return renderShellError(fmt.Sprintf("error: %v", err)), nil
+		}
+		if appTarget.Remote {
+			if parsed.branch != "" {
+				state.busyLabel = fmt.Sprintf("Creating branch %s...", parsed.branch)
+				return "", prepareBranchVibeCmd(state, appTarget, parsed.branch)
+			}
+			return "", prepareInteractiveCmd(state, shellAction{kind: actionVibe, app: appTarget.String()})
+		}
+		parsed.app = appTarget.App
 		if parsed.branch != "" {
 			if state.appsLoaded && !appExists(state, parsed.app) {
 				return renderShellError(fmt.Sprintf("error: app %q not found", parsed.app)), nil
 			}
 			state.busyLabel = fmt.Sprintf("Creating branch %s...", parsed.branch)
-			return "", prepareBranchVibeCmd(state, parsed.app, parsed.branch)
+			return "", prepareBranchVibeCmd(state, appTarget, parsed.branch)
 		}
 		if cmd.enforceExisting && !appExists(state, parsed.app) {
 			return renderShellError(fmt.Sprintf("error: app %q not found. Run `vibe %s` to create it.", parsed.app, parsed.app)), nil
@@ -363,29 +387,83 @@ func dispatchGlobalCommand(state *shellState, cmd parsedCommand) (string, tea.Cm
 		if len(cmd.args) < 1 {
 			return "error: shell requires an app name", nil
 		}
-		if !appExists(state, cmd.args[0]) {
-			return renderShellError(fmt.Sprintf("error: app %q not found", cmd.args[0])), nil
+		appTarget, err := resolveShellApp(state, cmd.args[0])
+		if err != nil {
+			return renderShellError(fmt.Sprintf("error: %v", err)), nil
+		}
+		if !appTarget.Remote && !appExists(state, appTarget.App) {
+			return renderShellError(fmt.Sprintf("error: app %q not found", appTarget.App)), nil
 		}
-		return "", prepareInteractiveCmd(state, shellAction{kind: actionShell, app: cmd.args[0]})
+		return "", prepareInteractiveCmd(state, shellAction{kind: actionShell, app: appTarget.String()})
+	case "run":
+		app, command, err := parseRunArgs(cmd.args)
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		appTarget, err := resolveShellApp(state, app)
+		if err != nil {
+			return renderShellError(fmt.Sprintf("error: %v", err)), nil
+		}
+		if !appTarget.Remote && !appExists(state, appTarget.App) {
+			return renderShellError(fmt.Sprintf("error: app %q not found", appTarget.App)), nil
+		}
+		return "", shellActionCmd(shellAction{kind: actionRun, app: appTarget.String(), args: command})
+	case "cp":
+		if _, _, err := parseCopyArgs(cmd.args, ""); err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		return "", shellActionCmd(shellAction{kind: actionCopy, args: cmd.args})
 	case "open":
 		if len(cmd.args) < 1 {
 			return "error: open requires an app name", nil
 		}
-		if state.appsLoaded && !appExists(state, cmd.args[0]) {
-			return renderShellError(fmt.Sprintf("error: app %q not found", cmd.args[0])), nil
+		appTarget, err := resolveShellApp(state, cmd.args[0])
+		if err != nil {
+			return renderShellError(fmt.Sprintf("error: %v", err)), nil
+		}
+		if appTarget.Remote {
+			return "", runAsync(func() (string, error) {
+				return openRemoteAppURL(state, appTarget)
+			})
+		}
+		if state.appsLoaded && !appExists(state, appTarget.App) {
+			return renderShellError(fmt.Sprintf("error: app %q not found", appTarget.App)), nil
 		}
 		return "", runAsync(func() (string, error) {
-			return openAppURL(state, cmd.args[0])
+			return openAppURL(state, appTarget.App)
 		})
 	case "rm", "delete":
 		if len(cmd.args) < 1 {
 			return "error: rm requires an app name", nil
 		}
-		if !appExists(state, cmd.args[0]) {
-			return renderShellError(fmt.Sprintf("error: app %q not found", cmd.args[0])), nil
+		appTarget, err := resolveShellApp(state, cmd.args[0])
+		if err != nil {
+			return renderShellError(fmt.Sprintf("error: %v", err)), nil
+		}
+		if !appTarget.Remote && !appExists(state, appTarget.App) {
+			return renderShellError(fmt.Sprintf("error: app %q not found", appTarget.App)), nil
 		}
-		beginDeletePrompt(state, cmd.args[0])
+		beginDeletePrompt(state, appTarget.String())
 		return "", nil
+	case "import":
+		if len(cmd.args) != 2 {
+			return "error: usage: import <app> <archive>", nil
+		}
+		if appExists(state, cmd.args[0]) {
+			return renderShellError(fmt.Sprintf("error: app %q already exists", cmd.args[0])), nil
+		}
+		app, archive := cmd.args[0], cmd.args[1]
+		return "", runAsync(func() (string, error) {
+			return importAppArchive(state, app, archive)
+		})
+	case "migrate":
+		opts, err := parseMigrateArgs(cmd.args)
+		if err != nil {
+			return "error: " + err.Error(), nil
+		}
+		return "", runAsync(func() (string, error) {
+			return migrateApp(state, opts)
+		})
 	case "branch":
 		return handleBranchShell(state, scopeGlobal, cmd.args)
 	case "config":
@@ -427,7 +505,16 @@ func dispatchGlobalCommand(state *shellState, cmd parsedCommand) (string, tea.Cm
 			cmd := startPromptFlow(state, newProxySetupFlow(input))
 			return "", cmd
 		}
-		return "error: usage: proxy setup [host]", nil
+		if len(cmd.args) > 0 && cmd.args[0] == "rotate-key" && len(cmd.args) <= 2 {
+			hostArg := ""
+			if len(cmd.args) > 1 {
+				hostArg = cmd.args[1]
+			}
+			return "", runAsync(func() (string, error) {
+				return runProxyRotateKeyShell(state, hostArg)
+			})
+		}
+		return "error: usage: proxy setup [host] | proxy rotate-key [host]", nil
 	case "users":
 		return handleUsersShell(state, cmd.args)
 	case "wipe":
@@ -477,9 +564,20 @@ func dispatchAppCommand(state *shellState, cmd parsedCommand) (string, tea.Cmd)
 			return "error: usage: vibe [--branch <branch>]", nil
 		}
 		state.busyLabel = fmt.Sprintf("Creating branch %s...", parsed.branch)
-		return "", prepareBranchVibeCmd(state, parsed.app, parsed.branch)
+		return "", prepareBranchVibeCmd(state, shellAppTarget{App: parsed.app}, parsed.branch)
 	case "shell":
 		return "", prepareInteractiveCmd(state, shellAction{kind: actionShell, app: state.app})
+	case "run":
+		_, command, err := parseRunArgs(append([]string{state.app}, cmd.args...))
+		if err != nil {
+			return "error: usage: run -- <command> [args...]", nil
+		}
+		return "", shellActionCmd(shellAction{kind: actionRun, app: state.app, args: command})
+	case "cp":
+		if _, _, err := parseCopyArgs(cmd.args, state.app); err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		return "", shellActionCmd(shellAction{kind: actionCopy, app: state.app, args: cmd.args})
 	case "open":
 		return "", runAsync(func() (string, error) {
 			return openAppURL(state, state.app)
@@ -491,21 +589,37 @@ func dispatchAppCommand(state *shellState, cmd parsedCommand) (string, tea.Cmd)
 	case "branch":
 		return handleBranchShell(state, scopeAppConfig, cmd.args)
 	case "snapshot":
+		args, err := snapshotServerArgs(cmd.args)
+		if err != nil {
+			return "error: " + err.Error(), nil
+		}
 		return "", runAsync(func() (string, error) {
-			return runAppServerCommand(state, []string{"snapshot"})
+			return runAppServerCommand(state, args)
 		})
 	case "snapshots":
 		return "", runAsync(func() (string, error) {
-			return runAppServerCommand(state, []string{"snapshots"})
+			return listSnapshotsOutput(state)
 		})
 	case "restore":
 		if len(cmd.args) < 1 {
-			return "error: restore requires a snapshot (vN or latest)", nil
+			return "error: restore requires a snapshot (vN, name, or latest)", nil
 		}
 		args := []string{"restore", cmd.args[0]}
 		return "", runAsync(func() (string, error) {
 			return runAppServerCommand(state, args)
 		})
+	case "export":
+		if len(cmd.args) < 1 || len(cmd.args) > 2 {
+			return "error: usage: export <vN|name|latest> [file]", nil
+		}
+		snapshot := cmd.args[0]
+		localPath := ""
+		if len(cmd.args) > 1 {
+			localPath = cmd.args[1]
+		}
+		return "", runAsync(func() (string, error) {
+			return exportAppArchive(state, snapshot, localPath)
+		})
 	case "update":
 		return "", runAsync(func() (string, error) {
 			return runAppServerCommand(state, []string{"update"})
@@ -524,13 +638,47 @@ func dispatchAppCommand(state *shellState, cmd parsedCommand) (string, tea.Cmd)
 		return "", shellActionCmd(shellAction{kind: actionDelete, app: targetApp})
 	case "url":
 		return handleURLShell(state, cmd.args)
+	case "schedule":
+		return handleScheduleShell(state, cmd.args)
+	case "limits":
+		return handleLimitsShell(state, cmd.args)
+	case "ports":
+		return handlePortsShell(state, cmd.args)
+	case "disk":
+		return handleDiskShell(state, cmd.args)
 	case "users":
+		if len(cmd.args) > 0 {
+			return handleAppGroupsShell(state, cmd.args)
+		}
 		return "", shellActionCmd(shellAction{kind: actionUsersEditor, app: state.app})
 	default:
 		return fmt.Sprintf("error: unknown command %q", cmd.name), nil
 	}
 }
 
+func handleAppGroupsShell(state *shellState, args []string) (string, tea.Cmd) {
+	if len(args) != 2 || strings.ToLower(args[0]) != "groups" {
+		return "error: usage: users | users groups <group,...|none>", nil
+	}
+	groups, err := proxy.ParseGroupList(args[1])
+	if err != nil {
+		return fmt.Sprintf("error: %v", err), nil
+	}
+	return "", runAsync(func() (string, error) {
+		resolved, err := resolveAppTarget(state)
+		if err != nil {
+			return "", err
+		}
+		if state.gateway == nil {
+			return "", errors.New("gateway not connected")
+		}
+		if err := runRemoteSetGroups(state.gateway, resolved.App, groups); err != nil {
+			return "", err
+		}
+		return "OK", nil
+	})
+}
+
 func handleConfigShell(state *shellState, args []string) (string, tea.Cmd) {
 	if len(args) == 0 || args[0] == "show" {
 		return renderConfig(state.cfg, state.cfgPath), nil
@@ -575,7 +723,7 @@ func handleConfigShell(state *shellState, args []string) (string, tea.Cmd) {
 
 func handleUsersShell(state *shellState, args []string) (string, tea.Cmd) {
 	if len(args) == 0 {
-		return "error: usage: users list|add|remove|set-password [host]", nil
+		return "error: usage: users list|add|remove|set-password|add-group|remove-group|sessions|revoke|totp|token|audit [host]", nil
 	}
 	switch args[0] {
 	case "list":
@@ -586,15 +734,82 @@ func handleUsersShell(state *shellState, args []string) (string, tea.Cmd) {
 			}
 			return listUsersOutput(state, hostArg)
 		})
-	case "add", "remove", "set-password":
-		username, hostArg, err := parseUsersArgs(args[1:])
+	case "sessions", "revoke":
+		if len(args) < 2 || strings.HasPrefix(args[1], "--") {
+			return "error: usage: users sessions <u> [host] | users revoke <u> --all|--session <id> [host]", nil
+		}
+		username := strings.TrimSpace(args[1])
+		parsed, err := parseUsersArgs(args[2:])
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		if args[0] == "sessions" {
+			return "", runAsync(func() (string, error) {
+				return listUserSessionsOutput(state, username, parsed.host)
+			})
+		}
+		if parsed.all == (parsed.session != "") {
+			return "error: usage: users revoke <u> --all|--session <id> [host]", nil
+		}
+		return "", runAsync(func() (string, error) {
+			return runUsersRevokeShell(state, username, parsed.session, parsed.host)
+		})
+	case "totp":
+		if len(args) < 2 || (args[1] != "enroll" && args[1] != "disable") {
+			return "error: usage: users totp enroll|disable --username <u> [host]", nil
+		}
+		parsed, err := parseUsersArgs(args[2:])
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		if parsed.username == "" {
+			return "error: username is required", nil
+		}
+		return "", runAsync(func() (string, error) {
+			return runUsersTOTPShell(state, args[1], parsed.username, parsed.host)
+		})
+	case "token":
+		if len(args) < 2 || (args[1] != "create" && args[1] != "list" && args[1] != "revoke") {
+			return "error: usage: users token create|list|revoke --username <u> [--name <n>] [--apps <a,b>] [--ttl <d>] [--token <id>] [host]", nil
+		}
+		parsed, err := parseUsersArgs(args[2:])
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		if parsed.username == "" {
+			return "error: username is required", nil
+		}
+		if args[1] == "revoke" && parsed.token == "" {
+			return "error: usage: users token revoke --username <u> --token <id> [host]", nil
+		}
+		return "", runAsync(func() (string, error) {
+			return runUsersTokenShell(state, args[1], parsed)
+		})
+	case "audit":
+		parsed, err := parseUsersArgs(args[1:])
 		if err != nil {
 			return fmt.Sprintf("error: %v", err), nil
 		}
+		return "", runAsync(func() (string, error) {
+			return listUsersAuditOutput(state, parsed)
+		})
+	case "add", "remove", "set-password", "add-group", "remove-group":
+		parsed, err := parseUsersArgs(args[1:])
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), nil
+		}
+		username, hostArg := parsed.username, parsed.host
 		if username == "" {
 			return "error: username is required", nil
 		}
 		switch args[0] {
+		case "add-group", "remove-group":
+			if parsed.group == "" {
+				return "error: group is required", nil
+			}
+			return "", runAsync(func() (string, error) {
+				return runUsersGroupShell(state, username, parsed.group, args[0] == "add-group", hostArg)
+			})
 		case "remove":
 			return "", runAsync(func() (string, error) {
 				return runUsersRemoveShell(state, username, hostArg)
@@ -613,7 +828,7 @@ func handleUsersShell(state *shellState, args []string) (string, tea.Cmd) {
 			return "", cmd
 		}
 	default:
-		return "error: usage: users list|add|remove|set-password [host]", nil
+		return "error: usage: users list|add|remove|set-password|add-group|remove-group|sessions|revoke|totp|token|audit [host]", nil
 	}
 }
 
@@ -642,6 +857,11 @@ func handleBranchShell(state *shellState, scope shellScope, args []string) (stri
 	if base == "" {
 		return "error: app name required", nil
 	}
+	appTarget, err := resolveShellApp(state, base)
+	if err != nil {
+		return renderShellError(fmt.Sprintf("error: %v", err)), nil
+	}
+	base = appTarget.App
 	switch action {
 	case "list":
 		if len(args) > argOffset {
@@ -669,11 +889,11 @@ func handleBranchShell(state *shellState, scope shellScope, args []string) (stri
 		serverArgs = append(serverArgs, branch)
 	}
 	return "", runAsync(func() (string, error) {
-		output, err := runHostServerCommand(state, serverArgs)
+		output, err := runServerCommandForApp(state, appTarget, serverArgs)
 		if err != nil {
 			return output, err
 		}
-		if action == "delete" && branch != "" {
+		if action == "delete" && branch != "" && !appTarget.Remote {
 			if derived, derr := branchpkg.DerivedAppName(base, branch); derr == nil {
 				removeAppSummary(state, derived)
 			}
@@ -682,6 +902,99 @@ func handleBranchShell(state *shellState, scope shellScope, args []string) (stri
 	})
 }
 
+func handleLimitsShell(state *shellState, args []string) (string, tea.Cmd) {
+	if state.app == "" {
+		return "error: no app selected", nil
+	}
+	if len(args) == 0 || args[0] == "show" {
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"limits", "show"})
+		})
+	}
+	switch args[0] {
+	case "set":
+		if len(args) < 2 {
+			return "error: limits set requires key=value (memory, cpus, pids, profile, cap-add)", nil
+		}
+		serverArgs := append([]string{"limits", "set"}, args[1:]...)
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, serverArgs)
+		})
+	case "reset":
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"limits", "reset"})
+		})
+	default:
+		return "error: usage: limits [show|set key=value...|reset]", nil
+	}
+}
+
+func handlePortsShell(state *shellState, args []string) (string, tea.Cmd) {
+	if len(args) == 0 || (args[0] == "list" && len(args) == 1) {
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"ports", "list"})
+		})
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return "error: usage: ports add <name> <container-port> [path=/prefix] [access=public|private]", nil
+		}
+		serverArgs := append([]string{"ports", "add"}, args[1:]...)
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, serverArgs)
+		})
+	case "remove", "rm":
+		if len(args) != 2 {
+			return "error: usage: ports remove <name>", nil
+		}
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"ports", "remove", args[1]})
+		})
+	default:
+		return "error: usage: ports [list|add <name> <container-port> [key=value...]|remove <name>]", nil
+	}
+}
+
+func handleDiskShell(state *shellState, args []string) (string, tea.Cmd) {
+	if len(args) == 0 || (args[0] == "show" && len(args) == 1) {
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"disk", "show"})
+		})
+	}
+	if args[0] == "grow" && len(args) == 2 {
+		serverArgs := []string{"disk", "grow", args[1]}
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, serverArgs)
+		})
+	}
+	return "error: usage: disk [show|grow <size>]", nil
+}
+
+func handleScheduleShell(state *shellState, args []string) (string, tea.Cmd) {
+	if len(args) == 0 || args[0] == "show" {
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"schedule", "show"})
+		})
+	}
+	switch args[0] {
+	case "set":
+		if len(args) < 2 {
+			return "error: schedule set requires key=value (every, keep-last, keep-daily, keep-weekly)", nil
+		}
+		serverArgs := append([]string{"schedule", "set"}, args[1:]...)
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, serverArgs)
+		})
+	case "off":
+		return "", runAsync(func() (string, error) {
+			return runAppServerCommand(state, []string{"schedule", "off"})
+		})
+	default:
+		return "error: usage: schedule [show|set key=value...|off]", nil
+	}
+}
+
 func handleURLShell(state *shellState, args []string) (string, tea.Cmd) {
 	if state.app == "" {
 		return "error: no app selected", nil
@@ -724,11 +1037,70 @@ func handleURLShell(state *shellState, args []string) (string, tea.Cmd) {
 		return "", runAsync(func() (string, error) {
 			return runURLDomainChange(state, "", true)
 		})
+	case "ip":
+		if len(args) < 2 || len(args) > 3 || (args[1] != "clear" && len(args) != 3) {
+			return "error: usage: url ip allow|deny <cidr> | url ip clear [<cidr>]", nil
+		}
+		action := args[1]
+		if action != "allow" && action != "deny" && action != "clear" {
+			return "error: usage: url ip allow|deny <cidr> | url ip clear [<cidr>]", nil
+		}
+		cidr := ""
+		if len(args) == 3 {
+			cidr = args[2]
+		}
+		return "", runAsync(func() (string, error) {
+			return runURLIPChange(state, action, cidr)
+		})
+	case "share":
+		ttl, path, err := parseURLShareArgs(args[1:])
+		if err != nil {
+			return "error: " + err.Error(), nil
+		}
+		return "", runAsync(func() (string, error) {
+			return runURLShareCreate(state, ttl, path)
+		})
+	case "shares":
+		return "", runAsync(func() (string, error) {
+			return runURLShareList(state)
+		})
+	case "unshare":
+		if len(args) != 2 {
+			return "error: usage: url unshare <id>", nil
+		}
+		return "", runAsync(func() (string, error) {
+			return runURLShareRevoke(state, args[1])
+		})
 	default:
-		return "error: usage: url [show|open|public|private|disable|enable|set-domain <domain>|reset-domain]", nil
+		return "error: usage: url [show|open|public|private|disable|enable|set-domain <domain>|reset-domain|ip allow|deny|clear <cidr>|share [--ttl <d>] [--path <p>]|shares|unshare <id>]", nil
 	}
 }
 
+func parseURLShareArgs(args []string) (string, string, error) {
+	ttl := ""
+	path := ""
+	for i := 0; i < len(args); i++ {
+		part := strings.TrimSpace(args[i])
+		switch part {
+		case "":
+			continue
+		case "--ttl", "--path":
+			if i+1 >= len(args) {
+				return "", "", fmt.Errorf("missing value for %s", part)
+			}
+			if part == "--ttl" {
+				ttl = strings.TrimSpace(args[i+1])
+			} else {
+				path = strings.TrimSpace(args[i+1])
+			}
+			i++
+		default:
+			return "", "", fmt.Errorf("usage: url share [--ttl <d>] [--path <p>]")
+		}
+	}
+	return ttl, path, nil
+}
+
 func handleSetupShell(state *shellState, args []string) (string, tea.Cmd) {
 	if len(args) != 0 {
 		return "error: usage: setup", nil
@@ -798,11 +1170,18 @@ func listUsersOutput(state *shellState, hostArg string) (string, error) {
 		return "", err
 	}
 	defer cleanup()
-	output, err := gateway.command([]string{"proxy", "users", "list"}, "", nil)
-	if err != nil {
+	var result serverapi.ProxyUsers
+	if err := gateway.commandJSON([]string{"proxy", "users", "list"}, "", nil, serverapi.KindProxyUsers, &result); err != nil {
 		return "", err
 	}
-	return strings.TrimRight(output, "\n"), nil
+	lines := make([]string, 0, len(result.Users))
+	for _, user := range result.Users {
+		if groups := result.Groups[user]; len(groups) > 0 {
+			user = fmt.Sprintf("%s (%s)", user, strings.Join(groups, ", "))
+		}
+		lines = append(lines, user)
+	}
+	return strings.Join(lines, "\n"), nil
 }
 
 func renderConfig(cfg config.Config, path string) string {
@@ -1042,6 +1421,13 @@ func renderAppSummary(state *shellState) (string, error) {
 	if info.Access != "" {
 		fmt.Fprintf(buf, "Access: %s\n", info.Access)
 	}
+	remoteArgs := buildAppCommandArgs(strings.TrimSpace(state.agent), resolved.App, []string{"limits", "show"})
+	if limits, err := state.gateway.command(remoteArgs, "", nil); err == nil && strings.TrimSpace(limits) != "" {
+		fmt.Fprintln(buf, "Limits:")
+		for _, line := range strings.Split(strings.TrimRight(limits, "\n"), "\n") {
+			fmt.Fprintf(buf, "  %s\n", line)
+		}
+	}
 	return buf.String(), nil
 }
 
@@ -1122,6 +1508,85 @@ func runURLDomainChange(state *shellState, domain string, reset bool) (string, e
 	return buf.String(), nil
 }
 
+func runURLIPChange(state *shellState, action string, cidr string) (string, error) {
+	resolved, err := resolveAppTarget(state)
+	if err != nil {
+		return "", err
+	}
+	if state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	if err := runRemoteSetIP(state.gateway, resolved.App, action, cidr); err != nil {
+		return "", err
+	}
+	info, err := fetchProxyInfo(state.gateway, resolved.App)
+	if err != nil {
+		return "", err
+	}
+	buf := &ttyBuffer{tty: true}
+	printShellURLActionResult(buf, info)
+	return buf.String(), nil
+}
+
+func runURLShareCreate(state *shellState, ttl string, path string) (string, error) {
+	resolved, err := resolveAppTarget(state)
+	if err != nil {
+		return "", err
+	}
+	if state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	args := []string{"proxy", "share", resolved.App, "create"}
+	if ttl != "" {
+		args = append(args, "--ttl", ttl)
+	}
+	if path != "" {
+		args = append(args, "--path", path)
+	}
+	var result serverapi.ProxyShare
+	if err := state.gateway.commandJSON(args, "", nil, serverapi.KindProxyShare, &result); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Share link %s for %s (expires %s; shown once):\n\n  %s\n\nRevoke it with: url unshare %s", result.ID, resolved.App, result.ExpiresAt.Local().Format("2006-01-02 15:04"), result.URL, result.ID), nil
+}
+
+func runURLShareList(state *shellState) (string, error) {
+	resolved, err := resolveAppTarget(state)
+	if err != nil {
+		return "", err
+	}
+	if state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	var result serverapi.ProxyShares
+	if err := state.gateway.commandJSON([]string{"proxy", "share", resolved.App, "list"}, "", nil, serverapi.KindProxyShares, &result); err != nil {
+		return "", err
+	}
+	if len(result.Shares) == 0 {
+		return fmt.Sprintf("No active share links for %s", resolved.App), nil
+	}
+	lines := make([]string, 0, len(result.Shares))
+	for _, share := range result.Shares {
+		lines = append(lines, fmt.Sprintf("%s  %s  expires %s", share.ID, share.Path, share.ExpiresAt.Local().Format("2006-01-02 15:04")))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func runURLShareRevoke(state *shellState, id string) (string, error) {
+	resolved, err := resolveAppTarget(state)
+	if err != nil {
+		return "", err
+	}
+	if state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	var result serverapi.ProxyShare
+	if err := state.gateway.commandJSON([]string{"proxy", "share", resolved.App, "revoke", "--id", id}, "", nil, serverapi.KindProxyShare, &result); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Revoked share %s for %s", result.ID, resolved.App), nil
+}
+
 func runHostServerCommand(state *shellState, args []string) (string, error) {
 	if state == nil {
 		return "", errors.New("gateway not connected")
@@ -1129,7 +1594,24 @@ func runHostServerCommand(state *shellState, args []string) (string, error) {
 	if state.gateway == nil {
 		return "", errors.New("gateway not connected")
 	}
-	output, err := state.gateway.command(args, "", nil)
+	return runGatewayServerCommand(state.gateway, args)
+}
+
+// runServerCommandForApp runs a host command on whichever host app lives on.
+func runServerCommandForApp(state *shellState, app shellAppTarget, args []string) (string, error) {
+	if !app.Remote {
+		return runHostServerCommand(state, args)
+	}
+	gateway, cleanup, err := gatewayForShellApp(state, app)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return runGatewayServerCommand(gateway, args)
+}
+
+func runGatewayServerCommand(gateway *gatewayClient, args []string) (string, error) {
+	output, err := gateway.command(args, "", nil)
 	if err != nil {
 		return "", err
 	}
@@ -1140,8 +1622,8 @@ func runHostServerCommand(state *shellState, args []string) (string, error) {
 	return output, nil
 }
 
-func runBranchCreate(state *shellState, app string, branch string) error {
-	app = strings.TrimSpace(app)
+func runBranchCreate(state *shellState, appTarget shellAppTarget, branch string) error {
+	app := strings.TrimSpace(appTarget.App)
 	branch = strings.TrimSpace(branch)
 	if app == "" {
 		return fmt.Errorf("app name required")
@@ -1149,7 +1631,7 @@ func runBranchCreate(state *shellState, app string, branch string) error {
 	if branch == "" {
 		return fmt.Errorf("branch name required")
 	}
-	_, err := runHostServerCommand(state, []string{"branch", "create", app, branch})
+	_, err := runServerCommandForApp(state, appTarget, []string{"branch", "create", app, branch})
 	return err
 }
 
@@ -1180,6 +1662,101 @@ func runAppServerCommand(state *shellState, args []string) (string, error) {
 	return output, nil
 }
 
+func listSnapshotsOutput(state *shellState) (string, error) {
+	resolved, err := resolveAppTarget(state)
+	if err != nil {
+		return "", err
+	}
+	if state.gateway == nil {
+		return "", errors.New("gateway not connected")
+	}
+	remoteArgs := buildAppCommandArgs(strings.TrimSpace(state.agent), resolved.App, []string{"snapshots"})
+	var list serverapi.SnapshotList
+	if err := state.gateway.commandJSON(remoteArgs, "", nil, serverapi.KindSnapshots, &list); err != nil {
+		return "", err
+	}
+	return renderSnapshotList(resolved.App, list.Snapshots), nil
+}
+
+// snapshotServerArgs turns `snapshot [name] [-m message]` into server args.
+func snapshotServerArgs(args []string) ([]string, error) {
+	out := []string{"snapshot"}
+	name := ""
+	message := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-m" || arg == "--message":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			message = args[i]
+		case strings.HasPrefix(arg, "--message="):
+			message = strings.TrimPrefix(arg, "--message=")
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unknown snapshot flag: %s", arg)
+		case name == "":
+			name = arg
+		default:
+			return nil, errors.New("usage: snapshot [name] [-m message]")
+		}
+	}
+	if name != "" {
+		out = append(out, name)
+	}
+	if strings.TrimSpace(message) != "" {
+		out = append(out, "--message", message)
+	}
+	return out, nil
+}
+
+func renderSnapshotList(app string, snapshots []serverapi.SnapshotInfo) string {
+	if len(snapshots) == 0 {
+		return fmt.Sprintf("No snapshots found for %s", app)
+	}
+	lines := []string{fmt.Sprintf("Snapshots for %s:", app)}
+	for _, snapshot := range snapshots {
+		line := snapshot.Tag
+		if snapshot.Name != "" {
+			line = fmt.Sprintf("%s %s", line, snapshot.Name)
+		}
+		if !snapshot.CreatedAt.IsZero() {
+			line = fmt.Sprintf("%s %s", line, snapshot.CreatedAt.Format(time.RFC3339))
+		}
+		if snapshot.Size > 0 {
+			line = fmt.Sprintf("%s (%s)", line, formatByteSize(snapshot.Size))
+		}
+		if snapshot.Origin == serverapi.OriginAuto {
+			line += " [auto]"
+		}
+		if snapshot.Creator != "" {
+			line += " by " + snapshot.Creator
+		}
+		if snapshot.Agent != "" {
+			line += " via " + snapshot.Agent
+		}
+		lines = append(lines, "  "+line)
+		if snapshot.Message != "" {
+			lines = append(lines, "    "+snapshot.Message)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func resolveAppTarget(state *shellState) (target.Resolved, error) {
 	app := strings.TrimSpace(state.app)
 	if app == "" {
@@ -1222,12 +1799,13 @@ func prepareInteractiveCmd(state *shellState, action shellAction) tea.Cmd {
 	}
 }
 
-func prepareBranchVibeCmd(state *shellState, app string, branch string) tea.Cmd {
+func prepareBranchVibeCmd(state *shellState, app shellAppTarget, branch string) tea.Cmd {
 	return func() tea.Msg {
-		derived, err := branchpkg.DerivedAppName(app, branch)
+		derived, err := branchpkg.DerivedAppName(app.App, branch)
 		if err != nil {
-			return interactivePreparedMsg{action: shellAction{kind: actionVibe, app: app}, err: err}
+			return interactivePreparedMsg{action: shellAction{kind: actionVibe, app: app.String()}, err: err}
 		}
+		derived = app.withApp(derived)
 		if err := runBranchCreate(state, app, branch); err != nil && !isBranchAlreadyExistsError(err) {
 			return interactivePreparedMsg{action: shellAction{kind: actionVibe, app: derived}, err: err}
 		}
@@ -1342,32 +1920,70 @@ func parseVibeArgs(args []string) (vibeArgs, error) {
 	return out, nil
 }
 
-func parseUsersArgs(args []string) (string, string, error) {
-	username := ""
-	host := ""
+type usersArgs struct {
+	username string
+	group    string
+	session  string
+	name     string
+	apps     string
+	ttl      string
+	token    string
+	app      string
+	since    string
+	all      bool
+	host     string
+}
+
+func parseUsersArgs(args []string) (usersArgs, error) {
+	out := usersArgs{}
 	for i := 0; i < len(args); i++ {
 		part := strings.TrimSpace(args[i])
 		if part == "" {
 			continue
 		}
-		if part == "--username" {
+		if part == "--all" {
+			out.all = true
+			continue
+		}
+		switch part {
+		case "--username", "--user", "--group", "--session", "--name", "--apps", "--ttl", "--token", "--app", "--since":
 			if i+1 >= len(args) {
-				return "", "", errors.New("missing value for --username")
+				return usersArgs{}, fmt.Errorf("missing value for %s", part)
+			}
+			value := strings.TrimSpace(args[i+1])
+			switch part {
+			case "--username", "--user":
+				out.username = value
+			case "--group":
+				out.group = value
+			case "--session":
+				out.session = value
+			case "--name":
+				out.name = value
+			case "--apps":
+				out.apps = value
+			case "--ttl":
+				out.ttl = value
+			case "--app":
+				out.app = value
+			case "--since":
+				out.since = value
+			default:
+				out.token = value
 			}
-			username = strings.TrimSpace(args[i+1])
 			i++
 			continue
 		}
 		if strings.HasPrefix(part, "--") {
-			return "", "", fmt.Errorf("unknown flag: %s", part)
+			return usersArgs{}, fmt.Errorf("unknown flag: %s", part)
 		}
-		if host == "" {
-			host = part
+		if out.host == "" {
+			out.host = part
 			continue
 		}
-		return "", "", fmt.Errorf("unexpected argument: %s", part)
+		return usersArgs{}, fmt.Errorf("unexpected argument: %s", part)
 	}
-	return username, host, nil
+	return out, nil
 }
 
 func runUsersRemoveShell(state *shellState, username string, hostArg string) (string, error) {
@@ -1382,6 +1998,211 @@ func runUsersRemoveShell(state *shellState, username string, hostArg string) (st
 	return "OK", nil
 }
 
+func listUserSessionsOutput(state *shellState, username string, hostArg string) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, hostArg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	var result serverapi.ProxySessions
+	if err := gateway.commandJSON([]string{"proxy", "users", "sessions", "--username", username}, "", nil, serverapi.KindProxySessions, &result); err != nil {
+		return "", err
+	}
+	if len(result.Sessions) == 0 {
+		return fmt.Sprintf("No active sessions for %s", username), nil
+	}
+	lines := make([]string, 0, len(result.Sessions))
+	for _, session := range result.Sessions {
+		line := fmt.Sprintf("%s  signed in %s  expires %s", session.ID, session.IssuedAt.Local().Format("2006-01-02 15:04"), session.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		if session.ClientIP != "" {
+			line += "  " + session.ClientIP
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func listUsersAuditOutput(state *shellState, parsed usersArgs) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, parsed.host)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	args := []string{"proxy", "users", "audit"}
+	if parsed.username != "" {
+		args = append(args, "--user", parsed.username)
+	}
+	if parsed.app != "" {
+		args = append(args, "--app", parsed.app)
+	}
+	if parsed.since != "" {
+		args = append(args, "--since", parsed.since)
+	}
+	var result serverapi.ProxyAudit
+	if err := gateway.commandJSON(args, "", nil, serverapi.KindProxyAudit, &result); err != nil {
+		return "", err
+	}
+	if len(result.Events) == 0 {
+		return "No matching audit events", nil
+	}
+	lines := make([]string, 0, len(result.Events)+1)
+	if result.Truncated {
+		lines = append(lines, fmt.Sprintf("Showing the last %d events; narrow with --user, --app or --since.", len(result.Events)))
+	}
+	for _, event := range result.Events {
+		line := fmt.Sprintf("%s  %s %s", event.Time.Local().Format("2006-01-02 15:04:05"), event.Event, event.Outcome)
+		if event.User != "" {
+			line += "  " + event.User
+		}
+		if event.App != "" {
+			line += "  " + event.App
+		}
+		if event.IP != "" {
+			line += "  " + event.IP
+		}
+		if event.Reason != "" {
+			line += "  (" + event.Reason + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func runUsersRevokeShell(state *shellState, username string, session string, hostArg string) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, hostArg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	args := []string{"proxy", "users", "revoke", "--username", username}
+	if session != "" {
+		args = append(args, "--session", session)
+	} else {
+		args = append(args, "--all")
+	}
+	var result serverapi.ProxyRevoke
+	if err := gateway.commandJSON(args, "", nil, serverapi.KindProxyRevoke, &result); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Revoked %d session(s) for %s", result.Revoked, username), nil
+}
+
+func runUsersTOTPShell(state *shellState, action string, username string, hostArg string) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, hostArg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	var result serverapi.ProxyTOTP
+	if err := gateway.commandJSON([]string{"proxy", "users", "totp", action, "--username", username}, "", nil, serverapi.KindProxyTOTP, &result); err != nil {
+		return "", err
+	}
+	if !result.Enabled {
+		return fmt.Sprintf("Disabled TOTP for %s", username), nil
+	}
+	lines := []string{fmt.Sprintf("Scan this code with an authenticator app for %s:", username), ""}
+	if qr, err := totp.QR(result.URI); err == nil {
+		lines = append(lines, strings.TrimRight(qr, "\n"))
+	}
+	lines = append(lines, "", result.URI, "", "Secret: "+result.Secret, "", "Recovery codes (each works once; store them safely):")
+	for _, code := range result.RecoveryCodes {
+		lines = append(lines, "  "+code)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func runUsersTokenShell(state *shellState, action string, parsed usersArgs) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, parsed.host)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	args := []string{"proxy", "users", "token", action, "--username", parsed.username}
+	if action == "list" {
+		var result serverapi.ProxyTokens
+		if err := gateway.commandJSON(args, "", nil, serverapi.KindProxyTokens, &result); err != nil {
+			return "", err
+		}
+		if len(result.Tokens) == 0 {
+			return fmt.Sprintf("No API tokens for %s", parsed.username), nil
+		}
+		lines := make([]string, 0, len(result.Tokens))
+		for _, token := range result.Tokens {
+			lines = append(lines, formatProxyToken(token))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	if action == "revoke" {
+		args = append(args, "--token", parsed.token)
+	} else {
+		if parsed.name != "" {
+			args = append(args, "--name", parsed.name)
+		}
+		if parsed.apps != "" {
+			args = append(args, "--apps", parsed.apps)
+		}
+		if parsed.ttl != "" {
+			args = append(args, "--ttl", parsed.ttl)
+		}
+	}
+	var result serverapi.ProxyToken
+	if err := gateway.commandJSON(args, "", nil, serverapi.KindProxyToken, &result); err != nil {
+		return "", err
+	}
+	if action == "revoke" {
+		return fmt.Sprintf("Revoked token %s for %s", result.ID, parsed.username), nil
+	}
+	return fmt.Sprintf("Created token %s for %s (shown once; store it safely):\n\n  %s\n\nUse it with: Authorization: Bearer <token>", result.ID, parsed.username, result.Token), nil
+}
+
+func formatProxyToken(token serverapi.ProxyToken) string {
+	line := token.ID
+	if token.Name != "" {
+		line += "  " + token.Name
+	}
+	line += "  created " + token.CreatedAt.Local().Format("2006-01-02 15:04")
+	if token.ExpiresAt != nil {
+		line += "  expires " + token.ExpiresAt.Local().Format("2006-01-02 15:04")
+	} else {
+		line += "  never expires"
+	}
+	if len(token.Apps) > 0 {
+		line += "  apps " + strings.Join(token.Apps, ",")
+	} else {
+		line += "  all apps"
+	}
+	return line
+}
+
+func runProxyRotateKeyShell(state *shellState, hostArg string) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, hostArg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	var result serverapi.ProxyRotateKey
+	if err := gateway.commandJSON([]string{"proxy", "rotate-key"}, "", nil, serverapi.KindProxyRotateKey, &result); err != nil {
+		return "", err
+	}
+	lines := []string{fmt.Sprintf("Active signing key: %s", result.Active)}
+	if len(result.Retired) > 0 {
+		lines = append(lines, fmt.Sprintf("Retired: %s", strings.Join(result.Retired, ", ")))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func runUsersGroupShell(state *shellState, username string, group string, member bool, hostArg string) (string, error) {
+	gateway, cleanup, err := gatewayForCommand(state, hostArg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	if err := runRemoteUsersGroup(gateway, username, group, member); err != nil {
+		return "", err
+	}
+	return "OK", nil
+}
+
 func runURLOpen(state *shellState) (string, error) {
 	resolved, err := resolveAppTarget(state)
 	if err != nil {
@@ -1526,6 +2347,9 @@ func printShellURLSummary(out *ttyBuffer, info proxyInfo) {
 	if len(info.AllowedUsers) > 0 {
 		fmt.Fprintf(out, "%s %s\n", styler.label("Users:"), styler.value(strings.Join(info.AllowedUsers, ", ")))
 	}
+	if len(info.AllowedGroups) > 0 {
+		fmt.Fprintf(out, "%s %s\n", styler.label("Groups:"), styler.value(strings.Join(info.AllowedGroups, ", ")))
+	}
 	fmt.Fprintln(out, "")
 	fmt.Fprintln(out, styler.header("Commands:"))
 	styler.commands(out, []commandLine{
@@ -1551,6 +2375,12 @@ func printShellURLActionResult(out *ttyBuffer, info proxyInfo) {
 	if info.URL != "" {
 		fmt.Fprintf(out, "%s %s\n", styler.label("URL:"), styler.link(info.URL))
 	}
+	if len(info.AllowIPs) > 0 {
+		fmt.Fprintf(out, "%s %s\n", styler.label("IP allow:"), styler.value(strings.Join(info.AllowIPs, ", ")))
+	}
+	if len(info.DenyIPs) > 0 {
+		fmt.Fprintf(out, "%s %s\n", styler.label("IP deny:"), styler.value(strings.Join(info.DenyIPs, ", ")))
+	}
 }
 
 // ttyBuffer allows lipgloss rendering while capturing output.
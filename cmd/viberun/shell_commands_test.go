@@ -7,6 +7,9 @@ package main
 import (
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/serverapi"
 )
 
 func TestSplitShellArgs(t *testing.T) {
@@ -267,3 +270,92 @@ func TestRunDotSlashRequiresExisting(t *testing.T) {
 		t.Fatalf("unexpected error output: %q", result)
 	}
 }
+
+func TestHandleLimitsShellUsage(t *testing.T) {
+	state := &shellState{app: "myapp", scope: scopeAppConfig}
+	if out, cmd := handleLimitsShell(state, []string{"set"}); cmd != nil || !strings.Contains(out, "requires key=value") {
+		t.Fatalf("expected set usage error, got %q", out)
+	}
+	if out, cmd := handleLimitsShell(state, []string{"bogus"}); cmd != nil || !strings.Contains(out, "usage: limits") {
+		t.Fatalf("expected usage error, got %q", out)
+	}
+	if _, cmd := handleLimitsShell(state, []string{"set", "memory=4g"}); cmd == nil {
+		t.Fatalf("expected async command for limits set")
+	}
+	if out, cmd := handleLimitsShell(&shellState{}, nil); cmd != nil || out != "error: no app selected" {
+		t.Fatalf("expected no app error, got %q", out)
+	}
+}
+
+func TestRenderSnapshotList(t *testing.T) {
+	if out := renderSnapshotList("myapp", nil); out != "No snapshots found for myapp" {
+		t.Fatalf("unexpected empty output: %q", out)
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	out := renderSnapshotList("myapp", []serverapi.SnapshotInfo{
+		{Tag: "v1", CreatedAt: created, Size: 3 << 20},
+		{Tag: "v2", Origin: serverapi.OriginAuto},
+	})
+	want := "Snapshots for myapp:\n  v1 2026-01-02T03:04:05Z (3.0 MiB)\n  v2 [auto]"
+	if out != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestRenderSnapshotListNamed(t *testing.T) {
+	out := renderSnapshotList("myapp", []serverapi.SnapshotInfo{
+		{Tag: "v3", Name: "before-migration", Message: "pre schema change", Creator: "alice", Agent: "codex"},
+	})
+	want := "Snapshots for myapp:\n  v3 before-migration by alice via codex\n    pre schema change"
+	if out != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestSnapshotServerArgs(t *testing.T) {
+	args, err := snapshotServerArgs([]string{"pre-deploy", "-m", "before the deploy"})
+	if err != nil {
+		t.Fatalf("snapshotServerArgs: %v", err)
+	}
+	if got := strings.Join(args, "|"); got != "snapshot|pre-deploy|--message|before the deploy" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+	args, err = snapshotServerArgs(nil)
+	if err != nil || len(args) != 1 || args[0] != "snapshot" {
+		t.Fatalf("unexpected bare args: %v %v", args, err)
+	}
+	if _, err := snapshotServerArgs([]string{"-m"}); err == nil {
+		t.Fatalf("expected error for missing message")
+	}
+	if _, err := snapshotServerArgs([]string{"a", "b"}); err == nil {
+		t.Fatalf("expected error for two names")
+	}
+}
+
+func TestParseMigrateArgs(t *testing.T) {
+	opts, err := parseMigrateArgs([]string{"myapp@staging", "--to", "prod", "--disable-source"})
+	if err != nil {
+		t.Fatalf("parseMigrateArgs: %v", err)
+	}
+	if opts.App != "myapp" || opts.From != "staging" || opts.To != "prod" || !opts.DisableSource {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	opts, err = parseMigrateArgs([]string{"--to=prod", "myapp"})
+	if err != nil {
+		t.Fatalf("parseMigrateArgs: %v", err)
+	}
+	if opts.App != "myapp" || opts.From != "" || opts.To != "prod" || opts.DisableSource {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"myapp"},
+		{"myapp", "--to"},
+		{"myapp", "other", "--to", "prod"},
+		{"myapp", "--force", "--to", "prod"},
+		{"@staging", "--to", "prod"},
+	} {
+		if _, err := parseMigrateArgs(args); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
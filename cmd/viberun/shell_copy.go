@@ -0,0 +1,179 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const copyUsage = "usage: cp <app>:<path> <local-path> | cp <local-path> <app>:<path>"
+
+// copyEndpoint is one side of a copy. App is empty for local paths.
+type copyEndpoint struct {
+	App  string
+	Path string
+}
+
+// parseCopyEndpoint splits `<app>:<path>`. Anything with a path separator
+// before the colon is local, as is a Windows drive letter. An empty app
+// means currentApp.
+func parseCopyEndpoint(raw string, currentApp string) (copyEndpoint, error) {
+	prefix, rest, found := strings.Cut(raw, ":")
+	if !found || strings.ContainsAny(prefix, `/\`) || (runtime.GOOS == "windows" && len(prefix) == 1) {
+		return copyEndpoint{Path: raw}, nil
+	}
+	if prefix == "" {
+		if strings.TrimSpace(currentApp) == "" {
+			return copyEndpoint{}, fmt.Errorf("missing app in %q", raw)
+		}
+		prefix = currentApp
+	}
+	return copyEndpoint{App: prefix, Path: rest}, nil
+}
+
+// parseCopyArgs parses `<src> <dst>`, exactly one of which is in an app.
+func parseCopyArgs(args []string, currentApp string) (copyEndpoint, copyEndpoint, error) {
+	if len(args) != 2 {
+		return copyEndpoint{}, copyEndpoint{}, errors.New(copyUsage)
+	}
+	src, err := parseCopyEndpoint(args[0], currentApp)
+	if err != nil {
+		return copyEndpoint{}, copyEndpoint{}, err
+	}
+	dst, err := parseCopyEndpoint(args[1], currentApp)
+	if err != nil {
+		return copyEndpoint{}, copyEndpoint{}, err
+	}
+	if (src.App == "") == (dst.App == "") {
+		return copyEndpoint{}, copyEndpoint{}, errors.New(copyUsage)
+	}
+	local := src
+	if local.App != "" {
+		local = dst
+	}
+	if strings.TrimSpace(local.Path) == "" {
+		return copyEndpoint{}, copyEndpoint{}, errors.New(copyUsage)
+	}
+	return src, dst, nil
+}
+
+// runCopy copies between the local machine and app, the remote endpoint's
+// app without any @host suffix.
+func runCopy(gateway *gatewayClient, app string, src copyEndpoint, dst copyEndpoint, progress copyProgress) (copyResult, error) {
+	if src.App != "" {
+		return copyFromApp(gateway, app, src.Path, dst.Path, progress)
+	}
+	return copyToApp(gateway, src.Path, app, dst.Path, progress)
+}
+
+func formatCopyResult(result copyResult, remote bool, app string) string {
+	dest := result.Dest
+	if remote {
+		dest = app + ":" + dest
+	}
+	unchanged := ""
+	if result.Skipped > 0 {
+		unchanged = fmt.Sprintf("; %d unchanged %s skipped", result.Skipped, pluralize(result.Skipped, "file", "files"))
+	}
+	if result.Files == 0 {
+		return fmt.Sprintf("Nothing to copy to %s%s", dest, unchanged)
+	}
+	return fmt.Sprintf("Copied %d %s (%s) to %s%s", result.Files, pluralize(result.Files, "file", "files"), formatByteSize(result.Bytes), dest, unchanged)
+}
+
+func pluralize(n int, one string, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// terminalCopyProgress redraws a single status line, at most ten times a
+// second.
+type terminalCopyProgress struct {
+	w     io.Writer
+	total int64
+	done  int64
+	drawn time.Time
+}
+
+// newCopyProgress returns a progress line on stderr, or nil when stderr is
+// not a terminal.
+func newCopyProgress() *terminalCopyProgress {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return &terminalCopyProgress{w: os.Stderr}
+}
+
+func (p *terminalCopyProgress) Start(total int64) {
+	p.total = total
+	p.draw()
+}
+
+func (p *terminalCopyProgress) Add(n int64) {
+	p.done += n
+	if time.Since(p.drawn) >= 100*time.Millisecond {
+		p.draw()
+	}
+}
+
+func (p *terminalCopyProgress) draw() {
+	p.drawn = time.Now()
+	percent := int64(100)
+	if p.total > 0 {
+		percent = min(p.done*100/p.total, 100)
+	}
+	fmt.Fprintf(p.w, "\r\033[KCopying %s / %s (%d%%)", formatByteSize(p.done), formatByteSize(p.total), percent)
+}
+
+// Finish clears the progress line.
+func (p *terminalCopyProgress) Finish() {
+	fmt.Fprint(p.w, "\r\033[K")
+}
+
+// copyWithProgress runs a copy with a progress line on the terminal.
+func copyWithProgress(gateway *gatewayClient, app string, src copyEndpoint, dst copyEndpoint) (copyResult, error) {
+	progress := newCopyProgress()
+	if progress == nil {
+		return runCopy(gateway, app, src, dst, nil)
+	}
+	defer progress.Finish()
+	return runCopy(gateway, app, src, dst, progress)
+}
+
+func runShellCopy(state *shellState, currentApp string, args []string) error {
+	src, dst, err := parseCopyArgs(args, currentApp)
+	if err != nil {
+		return err
+	}
+	remote := src
+	if remote.App == "" {
+		remote = dst
+	}
+	appTarget, err := resolveShellApp(state, remote.App)
+	if err != nil {
+		return err
+	}
+	gateway, cleanup, err := gatewayForShellApp(state, appTarget)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	result, err := copyWithProgress(gateway, appTarget.App, src, dst)
+	if err != nil {
+		return err
+	}
+	state.appendOutput(formatCopyResult(result, dst.App != "", remote.App))
+	return nil
+}
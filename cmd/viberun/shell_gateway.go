@@ -8,6 +8,8 @@ import (
 	"errors"
 	"strings"
 
+	tea "charm.land/bubbletea/v2"
+
 	"github.com/shayne/viberun/internal/target"
 )
 
@@ -41,6 +43,28 @@ func closeShellGateway(state *shellState) {
 	state.gatewayHost = ""
 }
 
+// adoptShellGateway makes gateway the shell's connection to host. The
+// shell's gateway redials on its own when the link drops.
+func adoptShellGateway(state *shellState, gateway *gatewayClient, host string) {
+	closeShellGateway(state)
+	gateway.enableReconnect()
+	state.gateway = gateway
+	state.gatewayHost = host
+}
+
+// listenGatewayStatusCmd waits for the next change in gateway's link.
+func listenGatewayStatusCmd(gateway *gatewayClient) tea.Cmd {
+	if gateway == nil {
+		return nil
+	}
+	_, changed := gateway.watchStatus()
+	return func() tea.Msg {
+		<-changed
+		status, _ := gateway.watchStatus()
+		return gatewayStatusMsg{gateway: gateway, status: status, finished: isClosedChan(gateway.done())}
+	}
+}
+
 func resolveShellHost(state *shellState, hostArg string) (target.ResolvedHost, error) {
 	if state == nil {
 		return target.ResolvedHost{}, errors.New("missing shell state")
@@ -67,6 +91,10 @@ func gatewayForCommand(state *shellState, hostArg string) (*gatewayClient, func(
 	if strings.TrimSpace(hostArg) == "" {
 		return nil, func() {}, errors.New("gateway not connected")
 	}
+	if state.hostGateways != nil {
+		gateway, err := state.hostGateways.get(resolved.Host, strings.TrimSpace(state.agent))
+		return gateway, func() {}, err
+	}
 	gateway, err := startGateway(resolved.Host, strings.TrimSpace(state.agent), devChannelEnv(), false)
 	if err != nil {
 		return nil, func() {}, err
@@ -0,0 +1,342 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shayne/viberun/internal/target"
+)
+
+const allHostsAppsTimeout = 15 * time.Second
+
+var errHostPoolClosed = errors.New("host gateways closed")
+
+// hostGatewayPool keeps gateways to hosts other than the shell's current host.
+// A gateway is dialed on first use and then left open while idle; if it drops,
+// it is redialed in the background so the next command finds it ready.
+type hostGatewayPool struct {
+	mu      sync.Mutex
+	entries map[string]*pooledGateway
+	closed  bool
+	dial    func(host string, agent string) (*gatewayClient, error)
+}
+
+type pooledGateway struct {
+	ready   chan struct{}
+	gateway *gatewayClient
+	err     error
+}
+
+func newHostGatewayPool() *hostGatewayPool {
+	return &hostGatewayPool{
+		entries: map[string]*pooledGateway{},
+		dial: func(host string, agent string) (*gatewayClient, error) {
+			return startGateway(host, agent, devChannelEnv(), false)
+		},
+	}
+}
+
+func (p *hostGatewayPool) get(host string, agent string) (*gatewayClient, error) {
+	if p == nil {
+		return nil, errHostPoolClosed
+	}
+	key := host + "\x00" + agent
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errHostPoolClosed
+	}
+	entry := p.entries[key]
+	if entry != nil && !entry.usable() {
+		entry = nil
+	}
+	if entry == nil {
+		entry = p.connectLocked(key, host, agent)
+	}
+	p.mu.Unlock()
+	<-entry.ready
+	if entry.err != nil {
+		p.mu.Lock()
+		if p.entries[key] == entry {
+			delete(p.entries, key)
+		}
+		p.mu.Unlock()
+		return nil, entry.err
+	}
+	return entry.gateway, nil
+}
+
+// usable reports whether the entry is still dialing or holds a live gateway.
+func (e *pooledGateway) usable() bool {
+	select {
+	case <-e.ready:
+	default:
+		return true
+	}
+	if e.err != nil || e.gateway == nil {
+		return false
+	}
+	select {
+	case <-e.gateway.done():
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *hostGatewayPool) connectLocked(key string, host string, agent string) *pooledGateway {
+	entry := &pooledGateway{ready: make(chan struct{})}
+	p.entries[key] = entry
+	go func() {
+		gateway, err := p.dial(host, agent)
+		p.mu.Lock()
+		if err == nil && p.closed {
+			_ = gateway.Close()
+			gateway, err = nil, errHostPoolClosed
+		}
+		p.mu.Unlock()
+		entry.gateway, entry.err = gateway, err
+		close(entry.ready)
+		if err == nil {
+			go p.watch(key, host, agent, entry)
+		}
+	}()
+	return entry
+}
+
+func (p *hostGatewayPool) watch(key string, host string, agent string, entry *pooledGateway) {
+	<-entry.gateway.done()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.entries[key] != entry {
+		return
+	}
+	p.connectLocked(key, host, agent)
+}
+
+func (p *hostGatewayPool) closeAll() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	entries := p.entries
+	p.entries = map[string]*pooledGateway{}
+	p.mu.Unlock()
+	for _, entry := range entries {
+		go func(entry *pooledGateway) {
+			<-entry.ready
+			if entry.gateway != nil {
+				_ = entry.gateway.Close()
+			}
+		}(entry)
+	}
+}
+
+// configuredHosts lists the current host first, then the default host and
+// every alias from the config, without duplicates.
+func configuredHosts(state *shellState) []target.ResolvedHost {
+	if state == nil {
+		return nil
+	}
+	raw := []string{state.host, state.cfg.DefaultHost}
+	aliases := make([]string, 0, len(state.cfg.Hosts))
+	for alias := range state.cfg.Hosts {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	raw = append(raw, aliases...)
+	seen := map[string]int{}
+	hosts := []target.ResolvedHost{}
+	for _, value := range raw {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		resolved, err := target.ResolveHost(value, state.cfg)
+		if err != nil {
+			continue
+		}
+		if idx, ok := seen[resolved.Host]; ok {
+			if hosts[idx].HostAlias == "" {
+				hosts[idx].HostAlias = resolved.HostAlias
+			}
+			continue
+		}
+		seen[resolved.Host] = len(hosts)
+		hosts = append(hosts, resolved)
+	}
+	return hosts
+}
+
+func isMultiHost(state *shellState) bool {
+	return len(configuredHosts(state)) > 1
+}
+
+// shellHostLabel names a host the way the user configured it.
+func shellHostLabel(host target.ResolvedHost) string {
+	if strings.TrimSpace(host.HostAlias) != "" {
+		return host.HostAlias
+	}
+	return host.Host
+}
+
+// currentHostLabel names the shell's current host, preferring its alias.
+func currentHostLabel(state *shellState) string {
+	hosts := configuredHosts(state)
+	if len(hosts) == 0 {
+		return ""
+	}
+	return shellHostLabel(hosts[0])
+}
+
+// shellAppTarget is an app argument, optionally written as app@host.
+type shellAppTarget struct {
+	App     string
+	Host    target.ResolvedHost
+	hostArg string
+	Remote  bool
+}
+
+// String renders the target so that it resolves to the same app again.
+func (t shellAppTarget) String() string {
+	return t.withApp(t.App)
+}
+
+func (t shellAppTarget) withApp(app string) string {
+	if !t.Remote {
+		return app
+	}
+	return app + "@" + t.hostArg
+}
+
+// resolveShellApp splits app@host. Targets on the current host come back as a
+// bare app name so the existing single-host paths handle them unchanged.
+func resolveShellApp(state *shellState, raw string) (shellAppTarget, error) {
+	raw = strings.TrimSpace(raw)
+	current, currentErr := resolveShellHost(state, "")
+	if !strings.Contains(raw, "@") {
+		return shellAppTarget{App: raw, Host: current}, nil
+	}
+	cfg := state.cfg
+	if state.host != "" {
+		cfg.DefaultHost = state.host
+	}
+	resolved, err := target.Resolve(raw, cfg)
+	if err != nil {
+		return shellAppTarget{}, err
+	}
+	out := shellAppTarget{
+		App:     resolved.App,
+		Host:    target.ResolvedHost{Host: resolved.Host, HostAlias: resolved.HostAlias},
+		hostArg: strings.TrimSpace(raw[strings.Index(raw, "@")+1:]),
+	}
+	out.Remote = currentErr != nil || current.Host != resolved.Host
+	return out, nil
+}
+
+// gatewayForShellApp returns the gateway for the host an app target lives on.
+func gatewayForShellApp(state *shellState, app shellAppTarget) (*gatewayClient, func(), error) {
+	if !app.Remote {
+		return gatewayForCommand(state, "")
+	}
+	return gatewayForCommand(state, app.Host.Host)
+}
+
+type hostApps struct {
+	host target.ResolvedHost
+	apps []appSummary
+	err  error
+}
+
+// loadAllHostsApps reads the first apps event from every configured host in
+// parallel and builds summaries tagged with the host label.
+func loadAllHostsApps(state *shellState) []hostApps {
+	hosts := configuredHosts(state)
+	results := make([]hostApps, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		results[i].host = host
+		wg.Add(1)
+		go func(i int, host target.ResolvedHost) {
+			defer wg.Done()
+			apps, err := loadHostApps(state, host)
+			results[i].apps = apps
+			results[i].err = err
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+func loadHostApps(state *shellState, host target.ResolvedHost) ([]appSummary, error) {
+	gateway, cleanup, err := gatewayForCommand(state, host.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	stream, err := startAppsStream(gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.close()
+	var event appsEvent
+	select {
+	case ev, ok := <-stream.updates:
+		if !ok {
+			return nil, errors.New("apps stream closed")
+		}
+		event = ev
+	case <-time.After(allHostsAppsTimeout):
+		return nil, errors.New("timed out waiting for apps")
+	}
+	if event.err != nil {
+		return nil, event.err
+	}
+	proxyEnabled := false
+	if summary, err := fetchRemoteProxyConfig(gateway); err == nil {
+		proxyEnabled = summary.Enabled
+	}
+	summaries, err := buildAppSummaries(gateway, event.apps, proxyEnabled)
+	if err != nil {
+		return nil, err
+	}
+	label := shellHostLabel(host)
+	local := state.gatewayHost == host.Host || isLocalHost(host.Host)
+	for i := range summaries {
+		summaries[i].Host = label
+		if !local {
+			summaries[i].LocalURL = ""
+		}
+	}
+	return summaries, nil
+}
+
+func allHostsAppsOutput(state *shellState) (string, error) {
+	results := loadAllHostsApps(state)
+	if len(results) == 0 {
+		return "", errors.New("no hosts configured")
+	}
+	apps := []appSummary{}
+	failures := []string{}
+	for i, result := range results {
+		if result.err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", shellHostLabel(result.host), result.err))
+			continue
+		}
+		for _, app := range result.apps {
+			if i == 0 {
+				app = applyForwardStatus(state, app)
+			}
+			apps = append(apps, app)
+		}
+	}
+	return renderAppsTableFor("Apps on all hosts", apps, true, failures), nil
+}
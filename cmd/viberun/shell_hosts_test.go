@@ -0,0 +1,144 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shayne/viberun/internal/config"
+)
+
+func newMultiHostState(t *testing.T) *shellState {
+	t.Helper()
+	state := newTestState(t)
+	state.host = "prod"
+	state.cfg = config.Config{
+		DefaultHost: "prod",
+		Hosts: map[string]string{
+			"prod":    "root@10.0.0.1",
+			"staging": "root@10.0.0.2",
+		},
+	}
+	return state
+}
+
+func TestConfiguredHostsCurrentFirst(t *testing.T) {
+	state := newMultiHostState(t)
+	state.host = "staging"
+	hosts := configuredHosts(state)
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %+v", hosts)
+	}
+	if hosts[0].HostAlias != "staging" || hosts[1].HostAlias != "prod" {
+		t.Fatalf("unexpected host order: %+v", hosts)
+	}
+	if !isMultiHost(state) {
+		t.Fatalf("expected multi-host state")
+	}
+	if isMultiHost(newTestState(t)) {
+		t.Fatalf("expected single-host state")
+	}
+}
+
+func TestResolveShellApp(t *testing.T) {
+	state := newMultiHostState(t)
+	local, err := resolveShellApp(state, "myapp")
+	if err != nil || local.Remote || local.App != "myapp" || local.String() != "myapp" {
+		t.Fatalf("unexpected local target: %+v %v", local, err)
+	}
+	same, err := resolveShellApp(state, "myapp@prod")
+	if err != nil || same.Remote || same.String() != "myapp" {
+		t.Fatalf("expected current host target to be local: %+v %v", same, err)
+	}
+	remote, err := resolveShellApp(state, "myapp@staging")
+	if err != nil {
+		t.Fatalf("resolveShellApp: %v", err)
+	}
+	if !remote.Remote || remote.App != "myapp" || remote.Host.Host != "root@10.0.0.2" {
+		t.Fatalf("unexpected remote target: %+v", remote)
+	}
+	if got := remote.withApp("myapp-feature"); got != "myapp-feature@staging" {
+		t.Fatalf("unexpected derived target: %q", got)
+	}
+	if _, err := resolveShellApp(state, "a@b@c"); err == nil {
+		t.Fatalf("expected error for invalid target")
+	}
+}
+
+func TestRenderAppsTableWithHosts(t *testing.T) {
+	newTestState(t)
+	apps := []appSummary{
+		{Name: "alpha", Host: "prod", Status: appStatusRunning, LocalURL: "http://localhost:8080"},
+		{Name: "beta", Host: "staging", Status: appStatusStopped},
+	}
+	out := renderAppsTableFor("Apps on all hosts", apps, true, []string{"lab: connection refused"})
+	lines := strings.Split(out, "\n")
+	if !strings.HasPrefix(lines[3], "app    host     status") {
+		t.Fatalf("unexpected header row: %q", lines[3])
+	}
+	if !strings.HasPrefix(lines[5], "beta   staging  stopped") {
+		t.Fatalf("unexpected row: %q", lines[5])
+	}
+	if !strings.Contains(out, "error: lab: connection refused") {
+		t.Fatalf("expected host failure in output: %q", out)
+	}
+	if single := renderAppsTableFor("Apps on prod", apps, false, nil); strings.Contains(single, "staging") {
+		t.Fatalf("expected no host column: %q", single)
+	}
+}
+
+func TestRenderPromptPrefixMultiHost(t *testing.T) {
+	state := newMultiHostState(t)
+	if got, want := renderPromptPrefix(state), "viberun @prod > "; got != want {
+		t.Fatalf("renderPromptPrefix() = %q, want %q", got, want)
+	}
+	state.scope = scopeAppConfig
+	state.app = "myapp"
+	if got, want := renderPromptPrefix(state), "viberun myapp@prod > "; got != want {
+		t.Fatalf("renderPromptPrefix() = %q, want %q", got, want)
+	}
+}
+
+func TestHostGatewayPoolDropsFailedDial(t *testing.T) {
+	pool := newHostGatewayPool()
+	dials := 0
+	pool.dial = func(string, string) (*gatewayClient, error) {
+		dials++
+		return nil, errors.New("unreachable")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := pool.get("root@10.0.0.2", "codex"); err == nil {
+			t.Fatalf("expected dial error")
+		}
+	}
+	if dials != 2 {
+		t.Fatalf("expected a fresh dial after failure, got %d", dials)
+	}
+	pool.closeAll()
+	if _, err := pool.get("root@10.0.0.2", "codex"); !errors.Is(err, errHostPoolClosed) {
+		t.Fatalf("expected closed pool error, got %v", err)
+	}
+}
+
+func TestRenderAppsTableDiskWarning(t *testing.T) {
+	newTestState(t)
+	apps := []appSummary{
+		{Name: "alpha", Status: appStatusRunning, DiskPercent: 95},
+		{Name: "beta", Status: appStatusRunning, DiskPercent: 40},
+	}
+	out := renderAppsTableFor("Apps on prod", apps, false, nil)
+	if !strings.Contains(out, "warning: alpha home volume is 95% full") {
+		t.Fatalf("expected disk warning: %q", out)
+	}
+	if strings.Contains(out, "beta home volume") {
+		t.Fatalf("unexpected warning for beta: %q", out)
+	}
+	apps[0].Host = "staging"
+	if out := renderAppsTableFor("Apps on all hosts", apps, true, nil); !strings.Contains(out, "run `app alpha@staging` then `disk`") {
+		t.Fatalf("expected host-qualified hint: %q", out)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/serverapi"
+	"github.com/shayne/viberun/internal/target"
+)
+
+const (
+	migrateStartTimeout  = 60 * time.Second
+	migrateStartInterval = 2 * time.Second
+	migrateStartChecks   = 2
+)
+
+type migrateOptions struct {
+	App           string
+	From          string
+	To            string
+	DisableSource bool
+}
+
+// parseMigrateArgs parses `<app>[@host] --to <host> [--disable-source]`.
+func parseMigrateArgs(args []string) (migrateOptions, error) {
+	var opts migrateOptions
+	usage := errors.New("usage: migrate <app> --to <host> [--disable-source]")
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		switch {
+		case arg == "--to":
+			if i+1 >= len(args) {
+				return opts, usage
+			}
+			i++
+			opts.To = strings.TrimSpace(args[i])
+		case strings.HasPrefix(arg, "--to="):
+			opts.To = strings.TrimSpace(strings.TrimPrefix(arg, "--to="))
+		case arg == "--disable-source":
+			opts.DisableSource = true
+		case strings.HasPrefix(arg, "-"):
+			return opts, fmt.Errorf("unknown flag: %s", arg)
+		case opts.App == "":
+			opts.App = arg
+		default:
+			return opts, usage
+		}
+	}
+	if opts.App == "" || opts.To == "" {
+		return opts, usage
+	}
+	if app, host, ok := strings.Cut(opts.App, "@"); ok {
+		opts.App = strings.TrimSpace(app)
+		opts.From = strings.TrimSpace(host)
+		if opts.App == "" || opts.From == "" {
+			return opts, errors.New("invalid target: expected app@host")
+		}
+	}
+	return opts, nil
+}
+
+// migrateApp moves an app between hosts by exporting a fresh snapshot from
+// the source, importing it on the destination, and waiting for the new
+// container to stay running. The source is left untouched unless
+// DisableSource is set, and only after the destination is verified.
+func migrateApp(state *shellState, opts migrateOptions) (string, error) {
+	if state == nil {
+		return "", errors.New("missing shell state")
+	}
+	source, err := resolveShellHost(state, opts.From)
+	if err != nil {
+		return "", err
+	}
+	dest, err := target.ResolveHost(opts.To, state.cfg)
+	if err != nil {
+		return "", err
+	}
+	if source.Host == dest.Host {
+		return "", fmt.Errorf("%s is already on %s", opts.App, shellHostLabel(dest))
+	}
+	agent := strings.TrimSpace(state.agent)
+
+	srcGateway, srcCleanup, err := gatewayForCommand(state, source.Host)
+	if err != nil {
+		return "", fmt.Errorf("connect to %s: %w", shellHostLabel(source), err)
+	}
+	defer srcCleanup()
+	dstGateway, dstCleanup, err := gatewayForCommand(state, dest.Host)
+	if err != nil {
+		return "", fmt.Errorf("connect to %s: %w", shellHostLabel(dest), err)
+	}
+	defer dstCleanup()
+
+	var status serverapi.AppStatus
+	if err := dstGateway.commandJSON(buildAppCommandArgs("", opts.App, []string{"status"}), "", nil, serverapi.KindAppStatus, &status); err != nil {
+		return "", err
+	}
+	if status.Status != serverapi.StatusMissing {
+		return "", fmt.Errorf("app %q already exists on %s", opts.App, shellHostLabel(dest))
+	}
+
+	var created serverapi.SnapshotCreated
+	snapshotArgs := []string{"snapshot", "--message", "migrate to " + shellHostLabel(dest)}
+	if err := srcGateway.commandJSON(buildAppCommandArgs(agent, opts.App, snapshotArgs), "", nil, serverapi.KindSnapshot, &created); err != nil {
+		return "", fmt.Errorf("snapshot on %s: %w", shellHostLabel(source), err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "viberun-migrate-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	archivePath := filepath.Join(tmpDir, fmt.Sprintf("%s-%s.tar.gz", opts.App, created.Tag))
+	exported, size, err := downloadAppArchive(srcGateway, agent, opts.App, created.Tag, archivePath)
+	if err != nil {
+		return "", fmt.Errorf("export from %s: %w", shellHostLabel(source), err)
+	}
+	imported, err := uploadAppArchive(dstGateway, agent, opts.App, exported.Path)
+	if err != nil {
+		return "", fmt.Errorf("import on %s: %w", shellHostLabel(dest), err)
+	}
+	if err := waitForAppRunning(dstGateway, opts.App); err != nil {
+		return "", fmt.Errorf("%s was imported on %s but did not start: %w; the source on %s is unchanged", opts.App, shellHostLabel(dest), err, shellHostLabel(source))
+	}
+
+	lines := []string{fmt.Sprintf("Migrated %s %s from %s to %s (%s)", opts.App, created.Tag, shellHostLabel(source), shellHostLabel(dest), formatByteSize(size))}
+	// The destination may lack some users or already route the custom
+	// domain; say so rather than let the access list narrow silently.
+	lines = append(lines, imported.Warnings()...)
+	if opts.DisableSource {
+		if err := runRemoteSetDisabled(srcGateway, opts.App, true); err != nil {
+			return "", fmt.Errorf("migrated %s, but failed to disable the source URL on %s: %w", opts.App, shellHostLabel(source), err)
+		}
+		lines = append(lines, fmt.Sprintf("Disabled the %s URL on %s.", opts.App, shellHostLabel(source)))
+	} else {
+		lines = append(lines, fmt.Sprintf("%s is still running on %s.", opts.App, shellHostLabel(source)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// waitForAppRunning polls until app reports running on consecutive checks.
+func waitForAppRunning(gateway *gatewayClient, app string) error {
+	deadline := time.Now().Add(migrateStartTimeout)
+	streak := 0
+	var lastErr error
+	for {
+		status, err := fetchAppStatus(gateway, app)
+		lastErr = err
+		if err == nil && status == appStatusRunning {
+			streak++
+			if streak >= migrateStartChecks {
+				return nil
+			}
+		} else {
+			streak = 0
+		}
+		if time.Now().After(deadline) {
+			if lastErr != nil {
+				return lastErr
+			}
+			return fmt.Errorf("not running after %s", migrateStartTimeout)
+		}
+		time.Sleep(migrateStartInterval)
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -102,8 +103,15 @@ type appsStreamStartedMsg struct {
 }
 
 type appsStreamUpdateMsg struct {
-	apps []appSnapshot
-	err  error
+	stream *appsStream
+	apps   []appSnapshot
+	err    error
+}
+
+type gatewayStatusMsg struct {
+	gateway  *gatewayClient
+	status   gatewayStatus
+	finished bool
 }
 
 type themePollMsg struct{}
@@ -161,6 +169,10 @@ func (m shellModel) Init() tea.Cmd {
 	}
 	if m.state.resumeAfterAction && gatewayReady(m.state) {
 		m.state.resumeAfterAction = false
+		if status, _ := m.state.gateway.watchStatus(); status.reconnecting {
+			m.state.connState = connReconnecting
+		}
+		cmds = append(cmds, listenGatewayStatusCmd(m.state.gateway))
 		startForwardManager(m.state)
 		m.state.appsSyncing = true
 		if cmd := startAppsStreamCmd(m.state); cmd != nil {
@@ -357,9 +369,7 @@ func (m shellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.state.busyLabel = ""
 		}
 		if msg.gateway != nil {
-			closeShellGateway(m.state)
-			m.state.gateway = msg.gateway
-			m.state.gatewayHost = msg.gatewayHost
+			adoptShellGateway(m.state, msg.gateway, msg.gatewayHost)
 		} else if msg.err != nil || !msg.bootstrapped {
 			closeShellGateway(m.state)
 		}
@@ -407,7 +417,7 @@ func (m shellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.state.setupNeeded = false
 		startForwardManager(m.state)
 		m.state.startupStage = "Syncing apps..."
-		return m, startupAppsCmd(m.state)
+		return m, tea.Batch(startupAppsCmd(m.state), listenGatewayStatusCmd(m.state.gateway))
 	case startupAppsMsg:
 		if msg.err != nil {
 			m.state.startupActive = false
@@ -470,9 +480,7 @@ func (m shellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.state.busyLabel = ""
 		}
 		if msg.gateway != nil {
-			closeShellGateway(m.state)
-			m.state.gateway = msg.gateway
-			m.state.gatewayHost = msg.gatewayHost
+			adoptShellGateway(m.state, msg.gateway, msg.gatewayHost)
 		} else if msg.err != nil || !msg.bootstrapped {
 			closeShellGateway(m.state)
 		}
@@ -519,19 +527,20 @@ func (m shellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.state.apps = applyAppSummaries(m.state, msg.apps)
 		m.state.appsLoaded = true
 		m.state.appsSyncing = false
+		listenStatus := listenGatewayStatusCmd(msg.gateway)
 		if m.state.pendingCmd != nil {
 			pending := m.state.pendingCmd
 			m.state.pendingCmd = nil
 			result, cmd := dispatchCommandWithScope(m.state, pending)
 			if cmd != nil {
 				m.busy = true
-				return m, m.startSpinner(cmd)
+				return m, tea.Batch(m.startSpinner(cmd), listenStatus)
 			}
 			if result != "" {
 				m.state.appendOutput(result)
 			}
 		}
-		return m, startAppsStreamCmd(m.state)
+		return m, tea.Batch(startAppsStreamCmd(m.state), listenStatus)
 	case shellActionMsg:
 		m.state.shellAction = &msg.action
 		m.busy = false
@@ -560,12 +569,47 @@ func (m shellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	case appsStreamStartedMsg:
 		if msg.err != nil {
-			m.state.appendOutput(fmt.Sprintf("error: %v", msg.err))
+			// The stream is reopened once the gateway is back.
+			if !errors.Is(msg.err, errGatewayReconnecting) {
+				m.state.appendOutput(fmt.Sprintf("error: %v", msg.err))
+			}
 			return m, nil
 		}
 		m.state.appsStream = msg.stream
 		return m, listenAppsStreamCmd(msg.stream)
+	case gatewayStatusMsg:
+		if msg.gateway != m.state.gateway {
+			return m, nil
+		}
+		if msg.finished {
+			closeShellGateway(m.state)
+			m.state.connState = connFailed
+			m.state.connError = "disconnected"
+			if msg.status.err != nil {
+				m.state.connError = msg.status.err.Error()
+			}
+			m.state.appendOutput(fmt.Sprintf("Connection to %s lost: %s", hostLabel(m.state.host), m.state.connError))
+			return m, nil
+		}
+		if msg.status.reconnecting {
+			m.state.connState = connReconnecting
+			return m, listenGatewayStatusCmd(msg.gateway)
+		}
+		// Streams from the old link are gone; the forward manager reopens
+		// its own, so only the shell's apps stream needs a restart.
+		m.state.connState = connConnected
+		m.state.connError = ""
+		if m.state.appsStream != nil {
+			if m.state.appsStream.close != nil {
+				m.state.appsStream.close()
+			}
+			m.state.appsStream = nil
+		}
+		return m, tea.Batch(listenGatewayStatusCmd(msg.gateway), startAppsStreamCmd(m.state))
 	case appsStreamUpdateMsg:
+		if msg.stream != m.state.appsStream {
+			return m, nil
+		}
 		if msg.err != nil {
 			if m.state.appsStream != nil {
 				if m.state.appsStream.close != nil {
@@ -918,10 +962,14 @@ func renderHeader(state *shellState) string {
 			status = "●"
 		case connFailed:
 			status = "○"
-		case connConnecting:
+		case connConnecting, connReconnecting:
 			status = "○"
 		}
-		return fmt.Sprintf("%s viberun host=%s agent=%s", status, host, agent)
+		line := fmt.Sprintf("%s viberun host=%s agent=%s", status, host, agent)
+		if state.connState == connReconnecting {
+			line += " reconnecting..."
+		}
+		return line
 	}
 	status := "○"
 	statusStyle := theme.StatusConnecting
@@ -932,7 +980,7 @@ func renderHeader(state *shellState) string {
 	case connFailed:
 		status = "○"
 		statusStyle = theme.StatusFailed
-	case connConnecting:
+	case connConnecting, connReconnecting:
 		status = "○"
 		statusStyle = theme.StatusConnecting
 	}
@@ -944,7 +992,7 @@ func renderHeader(state *shellState) string {
 	if agent == "" {
 		agent = "default"
 	}
-	return fmt.Sprintf("%s %s  %s %s  %s %s",
+	line := fmt.Sprintf("%s %s  %s %s  %s %s",
 		statusStyle.Render(status),
 		theme.Brand.Render("viberun"),
 		theme.Label.Render("host="),
@@ -952,6 +1000,10 @@ func renderHeader(state *shellState) string {
 		theme.Label.Render("agent="),
 		theme.Value.Render(agent),
 	)
+	if state.connState == connReconnecting {
+		line += "  " + theme.Muted.Render("reconnecting...")
+	}
+	return line
 }
 
 func renderPrompt(m shellModel) string {
@@ -1001,17 +1053,32 @@ func hostLabel(host string) string {
 
 func renderPromptPrefix(state *shellState) string {
 	theme := shellTheme()
+	// With several hosts configured, name the current one so it is clear
+	// where unqualified app names resolve.
+	host := ""
+	if isMultiHost(state) {
+		host = "@" + currentHostLabel(state)
+	}
 	if !theme.Enabled {
 		if state.scope == scopeAppConfig && state.app != "" {
-			return fmt.Sprintf("viberun %s > ", state.app)
+			return fmt.Sprintf("viberun %s%s > ", state.app, host)
+		}
+		if host != "" {
+			return fmt.Sprintf("viberun %s > ", host)
 		}
 		return "viberun > "
 	}
 	brand := theme.PromptBrand.Render("viberun")
 	arrow := theme.PromptArrow.Render(">")
+	if host != "" {
+		host = theme.Muted.Render(host)
+	}
 	if state.scope == scopeAppConfig && state.app != "" {
 		app := theme.Value.Render(state.app)
-		return fmt.Sprintf("%s %s %s ", brand, app, arrow)
+		return fmt.Sprintf("%s %s%s %s ", brand, app, host, arrow)
+	}
+	if host != "" {
+		return fmt.Sprintf("%s %s %s ", brand, host, arrow)
 	}
 	return fmt.Sprintf("%s %s ", brand, arrow)
 }
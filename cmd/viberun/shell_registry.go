@@ -31,13 +31,17 @@ type CommandSpec struct {
 
 func shellCommandSpecs() []CommandSpec {
 	return []CommandSpec{
-		{Key: "apps", Display: "apps", Scope: scopeGlobal, Aliases: []string{"ls"}, Summary: "list apps on the host", Description: "List apps on the host.", Usage: "apps", Examples: []string{"apps"}, RequiresSync: true},
+		{Key: "apps", Display: "apps [--all-hosts]", Scope: scopeGlobal, Aliases: []string{"ls"}, Summary: "list apps on the host", Description: "List apps on the host. Use --all-hosts to list apps on every configured host.", Usage: "apps [--all-hosts]", Examples: []string{"apps", "apps --all-hosts"}, RequiresSync: true},
 		{Key: "app", Display: "app <name>", Scope: scopeGlobal, Summary: "enter app config mode", Description: "Enter app config mode.", Usage: "app <name>", Examples: []string{"app myapp"}, RequiresSync: true},
-		{Key: "vibe", Display: "vibe <app> [--branch <branch>]", Scope: scopeGlobal, Summary: "attach to the app session", Description: "Attach to the app tmux session (creates the app if it doesn't exist). Use --branch to work in a branch environment.", Usage: "vibe <app> [--branch <branch>]", Examples: []string{"vibe myapp", "vibe myapp --branch contact-form"}, RequiresSync: true},
-		{Key: "shell", Display: "shell <app>", Scope: scopeGlobal, Summary: "open an app shell", Description: "Open a shell in the app container.", Usage: "shell <app>", Examples: []string{"shell myapp"}, RequiresSync: true},
-		{Key: "open", Display: "open <app>", Scope: scopeGlobal, Summary: "open app URL", Description: "Open the app URL in your browser.", Usage: "open <app>", Examples: []string{"open myapp"}, RequiresSync: true},
-		{Key: "rm", Display: "rm <app>", Scope: scopeGlobal, Aliases: []string{"delete"}, Summary: "delete an app", Description: "Delete an app and its snapshots.", Usage: "rm <app>", Examples: []string{"rm myapp"}, RequiresSync: true},
-		{Key: "branch", Display: "branch <list|create|delete|apply> <app> [branch]", Scope: scopeGlobal, Summary: "manage branch environments", Description: "Create, list, delete, and apply branch environments for an app.", Usage: "branch list <app> | branch create <app> <branch> | branch delete <app> <branch> | branch apply <app> <branch>", Examples: []string{"branch list myapp", "branch create myapp contact-form", "branch apply myapp contact-form"}, RequiresSync: true, Children: []HelpChild{
+		{Key: "vibe", Display: "vibe <app> [--branch <branch>]", Scope: scopeGlobal, Summary: "attach to the app session", Description: "Attach to the app tmux session (creates the app if it doesn't exist). Use --branch to work in a branch environment.", Usage: "vibe <app>[@host] [--branch <branch>]", Examples: []string{"vibe myapp", "vibe myapp --branch contact-form", "vibe myapp@prod"}, RequiresSync: true},
+		{Key: "shell", Display: "shell <app>", Scope: scopeGlobal, Summary: "open an app shell", Description: "Open a shell in the app container.", Usage: "shell <app>[@host]", Examples: []string{"shell myapp", "shell myapp@prod"}, RequiresSync: true},
+		{Key: "run", Display: "run <app> -- <command>", Scope: scopeGlobal, Summary: "run a command in an app", Description: "Run a command in the app container without a TTY. Stdout and stderr stream to your terminal and a non-zero exit status is reported.", Usage: "run <app>[@host] -- <command> [args...]", Examples: []string{"run myapp -- npm test", "run myapp@prod -- ls -la /home/viberun"}, RequiresSync: true},
+		{Key: "cp", Display: "cp <src> <dst>", Scope: scopeGlobal, Summary: "copy files to or from an app", Description: "Copy files and directories between this machine and an app container. One side is <app>:<path>; relative paths start in the app's home directory. Modes and modification times are kept, and files that already match are skipped.", Usage: "cp <app>[@host]:<path> <local-path> | cp <local-path> <app>[@host]:<path>", Examples: []string{"cp ./src myapp:src", "cp myapp:/home/viberun/logs ./logs", "cp myapp@prod:data.db ."}, RequiresSync: true},
+		{Key: "open", Display: "open <app>", Scope: scopeGlobal, Summary: "open app URL", Description: "Open the app URL in your browser.", Usage: "open <app>[@host]", Examples: []string{"open myapp", "open myapp@prod"}, RequiresSync: true},
+		{Key: "rm", Display: "rm <app>", Scope: scopeGlobal, Aliases: []string{"delete"}, Summary: "delete an app", Description: "Delete an app and its snapshots.", Usage: "rm <app>[@host]", Examples: []string{"rm myapp", "rm myapp@staging"}, RequiresSync: true},
+		{Key: "import", Display: "import <app> <archive>", Scope: scopeGlobal, Summary: "import an app archive", Description: "Create an app from an archive made by `export`. The app must not exist yet.", Usage: "import <app> <archive>", Examples: []string{"import myapp ./myapp-v3.tar.gz"}, RequiresSync: true},
+		{Key: "migrate", Display: "migrate <app> --to <host>", Scope: scopeGlobal, Summary: "move an app to another host", Description: "Snapshot an app, copy its home volume and proxy settings to another host, and verify it starts there. Add --disable-source to turn off the old URL afterwards.", Usage: "migrate <app>[@host] --to <host> [--disable-source]", Examples: []string{"migrate myapp --to prod", "migrate myapp@staging --to prod --disable-source"}, RequiresSync: true},
+		{Key: "branch", Display: "branch <list|create|delete|apply> <app> [branch]", Scope: scopeGlobal, Summary: "manage branch environments", Description: "Create, list, delete, and apply branch environments for an app.", Usage: "branch list <app>[@host] | branch create <app>[@host] <branch> | branch delete <app>[@host] <branch> | branch apply <app>[@host] <branch>", Examples: []string{"branch list myapp", "branch create myapp contact-form", "branch apply myapp contact-form", "branch list myapp@prod"}, RequiresSync: true, Children: []HelpChild{
 			{Cmd: "branch list <app>", Desc: "list branches for an app"},
 			{Cmd: "branch create <app> <branch>", Desc: "create a new branch env"},
 			{Cmd: "branch delete <app> <branch>", Desc: "delete a branch env"},
@@ -49,14 +53,25 @@ func shellCommandSpecs() []CommandSpec {
 			{Cmd: "config set agent <provider>", Desc: "set default agent"},
 		}},
 		{Key: "setup", Display: "setup", Scope: scopeGlobal, Summary: "connect a server and get started", Description: "Guide you through connecting a server and installing viberun. You'll need an SSH login like user@1.2.3.4.", Usage: "setup", Examples: []string{"setup"}, Advanced: true, RequiresSync: false},
-		{Key: "proxy", Display: "proxy", Scope: scopeGlobal, Summary: "configure host proxy", Description: "Configure host proxy for app URLs.", Usage: "proxy setup [host]", Examples: []string{"proxy setup"}, RequiresSync: true, Children: []HelpChild{
+		{Key: "proxy", Display: "proxy", Scope: scopeGlobal, Summary: "configure host proxy", Description: "Configure host proxy for app URLs.", Usage: "proxy setup [host] | proxy rotate-key [host]", Examples: []string{"proxy setup", "proxy rotate-key"}, RequiresSync: true, Children: []HelpChild{
 			{Cmd: "proxy setup [host]", Desc: "configure host proxy"},
+			{Cmd: "proxy rotate-key [host]", Desc: "rotate the login signing key"},
 		}},
-		{Key: "users", Display: "users", Scope: scopeGlobal, Summary: "manage proxy users", Description: "Manage proxy login users.", Usage: "users list | users add --username <u> | users remove --username <u> | users set-password --username <u>", Examples: []string{"users list", "users add --username alice", "users remove --username alice", "users set-password --username alice"}, RequiresSync: true, Children: []HelpChild{
+		{Key: "users", Display: "users", Scope: scopeGlobal, Summary: "manage proxy users", Description: "Manage proxy login users.", Usage: "users list | users add --username <u> | users remove --username <u> | users set-password --username <u> | users add-group --username <u> --group <g> | users remove-group --username <u> --group <g> | users sessions <u> | users revoke <u> --all|--session <id> | users totp enroll|disable --username <u> | users token create|list|revoke --username <u> | users audit [--user <u>] [--app <a>] [--since <d>]", Examples: []string{"users list", "users add --username alice", "users remove --username alice", "users set-password --username alice", "users add-group --username alice --group team-a", "users sessions alice", "users revoke alice --all", "users totp enroll --username alice", "users token create --username alice --apps myapp --ttl 720h", "users audit --user alice --since 24h"}, RequiresSync: true, Children: []HelpChild{
 			{Cmd: "users list", Desc: "list proxy users"},
 			{Cmd: "users add --username <u>", Desc: "add a user"},
 			{Cmd: "users remove --username <u>", Desc: "remove a user"},
 			{Cmd: "users set-password --username <u>", Desc: "set a password"},
+			{Cmd: "users add-group --username <u> --group <g>", Desc: "add a user to a group"},
+			{Cmd: "users remove-group --username <u> --group <g>", Desc: "remove a user from a group"},
+			{Cmd: "users sessions <u>", Desc: "list active logins"},
+			{Cmd: "users revoke <u> --all", Desc: "log a user out everywhere"},
+			{Cmd: "users totp enroll --username <u>", Desc: "require an authenticator code"},
+			{Cmd: "users totp disable --username <u>", Desc: "remove a user's authenticator"},
+			{Cmd: "users token create --username <u>", Desc: "create an API token"},
+			{Cmd: "users token list --username <u>", Desc: "list API tokens"},
+			{Cmd: "users token revoke --username <u> --token <id>", Desc: "revoke an API token"},
+			{Cmd: "users audit", Desc: "show logins and access denials"},
 		}},
 		{Key: "wipe", Display: "wipe", Scope: scopeGlobal, Summary: "wipe server data", Description: "Remove viberun data from a server.", Usage: "wipe [host]", Examples: []string{"wipe"}, Advanced: true, RequiresSync: true},
 		{Key: "help", Display: "help", Scope: scopeGlobal, Aliases: []string{"?"}, Summary: "show this help", Description: "Show help, or help for a specific command.", Usage: "help [command]", Examples: []string{"help", "help vibe"}, RequiresSync: false},
@@ -65,9 +80,12 @@ func shellCommandSpecs() []CommandSpec {
 		{Key: "show", Display: "show", Scope: scopeAppConfig, Summary: "show app summary", Description: "Show app summary.", Usage: "show", RequiresSync: true},
 		{Key: "vibe", Display: "vibe [--branch <branch>]", Scope: scopeAppConfig, Summary: "attach to the app session", Description: "Attach to the current app session (creates the app if it doesn't exist). Use --branch to open a branch environment.", Usage: "vibe [--branch <branch>]", RequiresSync: true},
 		{Key: "shell", Display: "shell", Scope: scopeAppConfig, Summary: "open an app shell", Description: "Open a shell in the app container.", Usage: "shell", RequiresSync: true},
-		{Key: "snapshot", Display: "snapshot", Scope: scopeAppConfig, Summary: "create a snapshot", Description: "Create a snapshot of the app volume.", Usage: "snapshot", RequiresSync: true},
+		{Key: "run", Display: "run -- <command>", Scope: scopeAppConfig, Summary: "run a command in the app", Description: "Run a command in the app container without a TTY. Stdout and stderr stream to your terminal and a non-zero exit status is reported.", Usage: "run -- <command> [args...]", Examples: []string{"run -- npm test"}, RequiresSync: true},
+		{Key: "cp", Display: "cp <src> <dst>", Scope: scopeAppConfig, Summary: "copy files to or from the app", Description: "Copy files and directories between this machine and the app container, written as :<path>. Modes and modification times are kept, and files that already match are skipped.", Usage: "cp :<path> <local-path> | cp <local-path> :<path>", Examples: []string{"cp ./src :src", "cp :logs ./logs"}, RequiresSync: true},
+		{Key: "snapshot", Display: "snapshot [name] [-m msg]", Scope: scopeAppConfig, Summary: "create a snapshot", Description: "Create a snapshot of the app volume, optionally named and annotated with a message.", Usage: "snapshot [name] [-m message]", RequiresSync: true},
 		{Key: "snapshots", Display: "snapshots", Scope: scopeAppConfig, Summary: "list snapshots", Description: "List snapshots for the app.", Usage: "snapshots", RequiresSync: true},
-		{Key: "restore", Display: "restore <vN|latest>", Scope: scopeAppConfig, Summary: "restore snapshot", Description: "Restore the app volume from a snapshot.", Usage: "restore <vN|latest>", Examples: []string{"restore latest", "restore v3"}, RequiresSync: true},
+		{Key: "restore", Display: "restore <vN|name|latest>", Scope: scopeAppConfig, Summary: "restore snapshot", Description: "Restore the app volume from a snapshot tag or name.", Usage: "restore <vN|name|latest>", Examples: []string{"restore latest", "restore v3", "restore before-migration"}, RequiresSync: true},
+		{Key: "export", Display: "export <snapshot> [file]", Scope: scopeAppConfig, Summary: "export snapshot archive", Description: "Download a snapshot and the app's container and proxy config as a .tar.gz archive.", Usage: "export <vN|name|latest> [file]", Examples: []string{"export latest", "export v3 ./backups/myapp-v3.tar.gz"}, RequiresSync: true},
 		{Key: "update", Display: "update", Scope: scopeAppConfig, Summary: "recreate container", Description: "Recreate the app container.", Usage: "update", RequiresSync: true},
 		{Key: "delete", Display: "delete", Scope: scopeAppConfig, Aliases: []string{"rm"}, Summary: "delete app", Description: "Delete the app, branches, and snapshots.", Usage: "delete", RequiresSync: true},
 		{Key: "open", Display: "open", Scope: scopeAppConfig, Summary: "open app URL", Description: "Open the app URL in your browser.", Usage: "open", Examples: []string{"open"}, RequiresSync: true},
@@ -77,7 +95,7 @@ func shellCommandSpecs() []CommandSpec {
 			{Cmd: "branch delete <branch>", Desc: "delete a branch env"},
 			{Cmd: "branch apply <branch>", Desc: "apply a branch to this app"},
 		}},
-		{Key: "url", Display: "url", Scope: scopeAppConfig, Summary: "manage app URL", Description: "Show or manage the app URL.", Usage: "url [show|open|public|private|disable|enable|set-domain <domain>|reset-domain]", Options: []string{"show", "open", "public", "private", "disable", "enable", "set-domain <domain>", "reset-domain"}, Examples: []string{"url", "url public", "url set-domain myapp.com"}, RequiresSync: true, Children: []HelpChild{
+		{Key: "url", Display: "url", Scope: scopeAppConfig, Summary: "manage app URL", Description: "Show or manage the app URL.", Usage: "url [show|open|public|private|disable|enable|set-domain <domain>|reset-domain|ip allow|deny|clear <cidr>|share|shares|unshare <id>]", Options: []string{"show", "open", "public", "private", "disable", "enable", "set-domain <domain>", "reset-domain", "ip allow <cidr>", "ip deny <cidr>", "ip clear [<cidr>]", "share [--ttl <d>] [--path <p>]", "shares", "unshare <id>"}, Examples: []string{"url", "url public", "url set-domain myapp.com", "url ip allow 203.0.113.0/24", "url share --ttl 2h"}, RequiresSync: true, Children: []HelpChild{
 			{Cmd: "url show", Desc: "show URL info"},
 			{Cmd: "url open", Desc: "open URL in browser"},
 			{Cmd: "url public", Desc: "allow public access"},
@@ -86,8 +104,32 @@ func shellCommandSpecs() []CommandSpec {
 			{Cmd: "url enable", Desc: "enable the URL"},
 			{Cmd: "url set-domain <domain>", Desc: "set a custom domain"},
 			{Cmd: "url reset-domain", Desc: "reset to default domain"},
+			{Cmd: "url ip allow <cidr>", Desc: "only allow these addresses"},
+			{Cmd: "url ip deny <cidr>", Desc: "block these addresses"},
+			{Cmd: "url ip clear [<cidr>]", Desc: "remove one or all IP rules"},
+			{Cmd: "url share", Desc: "create an expiring share link"},
+			{Cmd: "url shares", Desc: "list active share links"},
+			{Cmd: "url unshare <id>", Desc: "revoke a share link"},
 		}},
-		{Key: "users", Display: "users", Scope: scopeAppConfig, Summary: "manage app access", Description: "Manage app access list.", Usage: "users", RequiresSync: true},
+		{Key: "schedule", Display: "schedule", Scope: scopeAppConfig, Summary: "manage automatic snapshots", Description: "Show or change the automatic snapshot schedule and retention. Retention only prunes automatic snapshots.", Usage: "schedule [show|set key=value...|off]", Options: []string{"every=<hourly|daily|weekly|duration>", "keep-last=<n>", "keep-daily=<n>", "keep-weekly=<n>"}, Examples: []string{"schedule", "schedule set every=hourly keep-last=24 keep-daily=7", "schedule off"}, RequiresSync: true, Children: []HelpChild{
+			{Cmd: "schedule show", Desc: "show the snapshot schedule"},
+			{Cmd: "schedule set <key=value>", Desc: "set interval or retention"},
+			{Cmd: "schedule off", Desc: "stop automatic snapshots"},
+		}},
+		{Key: "limits", Display: "limits", Scope: scopeAppConfig, Summary: "manage resource limits", Description: "Show or change container resource limits and the hardening profile. Changes apply on the next `update`.", Usage: "limits [show|set key=value...|reset]", Options: []string{"memory=<size>", "cpus=<n>", "pids=<n>", "profile=<hardened|relaxed>", "cap-add=<CAP,...>"}, Examples: []string{"limits", "limits set memory=4g cpus=2", "limits set profile=relaxed cap-add=NET_ADMIN", "limits reset"}, RequiresSync: true, Children: []HelpChild{
+			{Cmd: "limits show", Desc: "show effective limits"},
+			{Cmd: "limits set <key=value>", Desc: "override a limit for this app"},
+			{Cmd: "limits reset", Desc: "use host defaults"},
+		}},
+		{Key: "ports", Display: "ports", Scope: scopeAppConfig, Summary: "manage extra ports", Description: "Publish more container ports, each on its own sub-host (<name>.<app host>) or under a path prefix on the app host. Access defaults to the app's mode. New ports are published on the next `update`.", Usage: "ports [list|add <name> <container-port> [path=/prefix] [access=public|private]|remove <name>]", Options: []string{"path=<prefix>", "access=<public|private|inherit>"}, Examples: []string{"ports", "ports add api 3000", "ports add admin 9000 path=/admin access=private", "ports remove api"}, RequiresSync: true, Children: []HelpChild{
+			{Cmd: "ports add <name> <port>", Desc: "publish a container port"},
+			{Cmd: "ports remove <name>", Desc: "stop publishing a port"},
+		}},
+		{Key: "disk", Display: "disk", Scope: scopeAppConfig, Summary: "show or grow the home volume", Description: "Show home volume usage, free space, and the space each snapshot holds on its own. `disk grow` enlarges the volume online.", Usage: "disk [show|grow <size>]", Examples: []string{"disk", "disk grow +50g", "disk grow 2t"}, RequiresSync: true, Children: []HelpChild{
+			{Cmd: "disk show", Desc: "show volume and snapshot usage"},
+			{Cmd: "disk grow <size>", Desc: "grow to a size or by +size"},
+		}},
+		{Key: "users", Display: "users", Scope: scopeAppConfig, Summary: "manage app access", Description: "Manage app access list. Members of any listed group can also open the app.", Usage: "users | users groups <group,...|none>", Examples: []string{"users", "users groups team-a,admin", "users groups none"}, RequiresSync: true},
 		{Key: "help", Display: "help", Scope: scopeAppConfig, Aliases: []string{"?"}, Summary: "show this help", Description: "Show help for app commands.", Usage: "help [command]", Examples: []string{"help", "help open"}, RequiresSync: false},
 		{Key: "exit", Display: "exit", Scope: scopeAppConfig, Aliases: []string{"back"}, Summary: "back to global", Description: "Return to the global shell.", Usage: "exit", Hidden: true, RequiresSync: false},
 	}
@@ -0,0 +1,79 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+// runLocalFailureCode is the exit status of `viberun run` when the command
+// never ran because of a local or connection problem, as with ssh.
+const runLocalFailureCode = 255
+
+const runUsage = "usage: run <app> -- <command> [args...]"
+
+// parseRunArgs splits `<app> -- <command...>`. The separator may be left
+// out when the command has no flags of its own.
+func parseRunArgs(args []string) (string, []string, error) {
+	head, command := args, []string(nil)
+	if idx := slices.Index(args, "--"); idx >= 0 {
+		head, command = args[:idx], args[idx+1:]
+	} else if len(args) > 1 {
+		head, command = args[:1], args[1:]
+	}
+	if len(head) != 1 || strings.TrimSpace(head[0]) == "" || len(command) == 0 {
+		return "", nil, errors.New(runUsage)
+	}
+	return strings.TrimSpace(head[0]), command, nil
+}
+
+// runAppCommand runs command in the app container with stdout and stderr
+// on the local terminal, forwarding interrupts until it exits.
+func runAppCommand(gateway *gatewayClient, app string, command []string, stdin io.Reader) (muxrpc.ExecExit, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	return runExecOverGateway(gateway, muxrpc.ExecMeta{App: app, Args: command}, execStreamIO{
+		Stdin:   stdin,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Signals: signals,
+	})
+}
+
+func runShellRun(state *shellState, appArg string, command []string) error {
+	appTarget, err := resolveShellApp(state, appArg)
+	if err != nil {
+		return err
+	}
+	gateway, cleanup, err := gatewayForShellApp(state, appTarget)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	exit, err := runAppCommand(gateway, appTarget.App, command, nil)
+	if err != nil {
+		return err
+	}
+	if exit.Error != "" {
+		return errors.New(exit.Error)
+	}
+	switch {
+	case exit.Signal != "":
+		state.appendOutput(fmt.Sprintf("exit status %d (%s)", exit.Code, exit.Signal))
+	case exit.Code != 0:
+		state.appendOutput(fmt.Sprintf("exit status %d", exit.Code))
+	}
+	return nil
+}
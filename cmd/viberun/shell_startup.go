@@ -12,12 +12,14 @@ import (
 
 	tea "charm.land/bubbletea/v2"
 
+	"github.com/shayne/viberun/internal/muxrpc"
 	"github.com/shayne/viberun/internal/target"
 )
 
 type appSnapshot struct {
-	Name string
-	Port int
+	Name        string
+	Port        int
+	DiskPercent int
 }
 
 func startupConnectCmd(state *shellState) tea.Cmd {
@@ -124,11 +126,12 @@ func buildAppSummaries(gateway *gatewayClient, apps []appSnapshot, proxyEnabled
 			}
 		}
 		results = append(results, appSummary{
-			Name:      name,
-			Status:    status,
-			LocalURL:  localURL,
-			PublicURL: publicURL,
-			Port:      port,
+			Name:        name,
+			Status:      status,
+			LocalURL:    localURL,
+			PublicURL:   publicURL,
+			Port:        port,
+			DiskPercent: app.DiskPercent,
 		})
 	}
 	sort.Slice(results, func(i, j int) bool {
@@ -242,20 +245,28 @@ func renderAppsTable(state *shellState, includeTip bool) string {
 	if len(state.apps) == 0 {
 		return "No apps found."
 	}
-	rows := make([]appRow, 0, len(state.apps))
-	for _, app := range state.apps {
-		rows = append(rows, formatAppRow(app))
+	header := fmt.Sprintf("Apps on %s", hostLabel(state.host))
+	if isMultiHost(state) {
+		header = fmt.Sprintf("Apps on %s", currentHostLabel(state))
 	}
-	nameWidth, statusWidth, localWidth := columnWidths(rows)
-	publicWidth := 0
-	for _, row := range rows {
-		if len(row.public) > publicWidth {
-			publicWidth = len(row.public)
-		}
+	table := renderAppsTableFor(header, state.apps, false, nil)
+	if includeTip {
+		table = strings.TrimSuffix(table, "\n") + "\n\n" + renderStartupTip(shellTheme()) + "\n"
 	}
-	header := fmt.Sprintf("Apps on %s", hostLabel(state.host))
+	return table
+}
+
+// renderAppsTableFor renders apps under header. The host column is only shown
+// for listings that span hosts; failures are per-host errors listed after the
+// table.
+func renderAppsTableFor(header string, apps []appSummary, showHost bool, failures []string) string {
+	rows := make([]appRow, 0, len(apps))
+	for _, app := range apps {
+		rows = append(rows, formatAppRow(app))
+	}
+	widths := columnWidths(rows, showHost)
 	divider := strings.Repeat("-", len(header))
-	headerRow := renderAppRowHeader(nameWidth, statusWidth, localWidth, publicWidth)
+	headerRow := renderAppRowHeader(widths)
 	theme := shellTheme()
 	if theme.Enabled {
 		header = theme.HelpHeader.Render(header)
@@ -264,12 +275,34 @@ func renderAppsTable(state *shellState, includeTip bool) string {
 	}
 	lines := []string{"", header}
 	lines = append(lines, divider)
-	lines = append(lines, headerRow)
-	for _, row := range rows {
-		lines = append(lines, renderAppRow(row, nameWidth, statusWidth, localWidth, publicWidth))
+	if len(rows) == 0 {
+		lines = append(lines, "No apps found.")
+	} else {
+		lines = append(lines, headerRow)
+		for _, row := range rows {
+			lines = append(lines, renderAppRow(row, widths))
+		}
 	}
-	if includeTip {
-		lines = append(lines, "", renderStartupTip(shellTheme()))
+	for _, app := range apps {
+		if app.DiskPercent < muxrpc.DiskWarnPercent {
+			continue
+		}
+		name := app.Name
+		if showHost && app.Host != "" {
+			name += "@" + app.Host
+		}
+		text := fmt.Sprintf("warning: %s home volume is %d%% full (run `app %s` then `disk`)", name, app.DiskPercent, name)
+		if theme.Enabled {
+			text = theme.Error.Render(text)
+		}
+		lines = append(lines, text)
+	}
+	for _, failure := range failures {
+		text := "error: " + failure
+		if theme.Enabled {
+			text = theme.Error.Render(text)
+		}
+		lines = append(lines, text)
 	}
 	lines = append(lines, "")
 	return strings.Join(lines, "\n")
@@ -277,6 +310,7 @@ func renderAppsTable(state *shellState, includeTip bool) string {
 
 type appRow struct {
 	name       string
+	host       string
 	status     string
 	statusKind appStatus
 	local      string
@@ -299,6 +333,7 @@ func formatAppRow(app appSummary) appRow {
 	}
 	return appRow{
 		name:       app.Name,
+		host:       app.Host,
 		status:     string(app.Status),
 		statusKind: app.Status,
 		local:      local,
@@ -307,42 +342,60 @@ func formatAppRow(app appSummary) appRow {
 	}
 }
 
-func columnWidths(rows []appRow) (int, int, int) {
-	nameWidth := len("app")
-	statusWidth := len("status")
-	localWidth := len("local")
+// appColumnWidths holds the padded width of each column; host is zero when
+// the host column is hidden.
+type appColumnWidths struct {
+	name   int
+	host   int
+	status int
+	local  int
+	public int
+}
+
+func columnWidths(rows []appRow, showHost bool) appColumnWidths {
+	widths := appColumnWidths{
+		name:   len("app"),
+		status: len("status"),
+		local:  len("local"),
+	}
+	if showHost {
+		widths.host = len("host")
+	}
 	for _, row := range rows {
-		if len(row.name) > nameWidth {
-			nameWidth = len(row.name)
-		}
-		if len(row.status) > statusWidth {
-			statusWidth = len(row.status)
-		}
-		if len(row.local) > localWidth {
-			localWidth = len(row.local)
+		widths.name = max(widths.name, len(row.name))
+		if showHost {
+			widths.host = max(widths.host, len(row.host))
 		}
+		widths.status = max(widths.status, len(row.status))
+		widths.local = max(widths.local, len(row.local))
+		widths.public = max(widths.public, len(row.public))
 	}
-	return nameWidth, statusWidth, localWidth
+	return widths
 }
 
-func renderAppRowHeader(nameWidth, statusWidth, localWidth, publicWidth int) string {
-	parts := []string{
-		padColumn("app", nameWidth),
-		padColumn("status", statusWidth),
-		padColumn("local", localWidth),
-		padColumn("public", publicWidth),
-	}
+func renderAppRowHeader(widths appColumnWidths) string {
+	parts := []string{padColumn("app", widths.name)}
+	if widths.host > 0 {
+		parts = append(parts, padColumn("host", widths.host))
+	}
+	parts = append(parts,
+		padColumn("status", widths.status),
+		padColumn("local", widths.local),
+		padColumn("public", widths.public),
+	)
 	return strings.Join(parts, "  ")
 }
 
-func renderAppRow(row appRow, nameWidth, statusWidth, localWidth, publicWidth int) string {
+func renderAppRow(row appRow, widths appColumnWidths) string {
 	theme := shellTheme()
-	name := padColumn(row.name, nameWidth)
-	status := padColumn(row.status, statusWidth)
-	local := padColumn(row.local, localWidth)
-	public := padColumn(row.public, publicWidth)
+	name := padColumn(row.name, widths.name)
+	host := padColumn(row.host, widths.host)
+	status := padColumn(row.status, widths.status)
+	local := padColumn(row.local, widths.local)
+	public := padColumn(row.public, widths.public)
 	if theme.Enabled {
 		name = theme.Value.Render(name)
+		host = theme.Muted.Render(host)
 		statusStyle := theme.StatusUnknown
 		switch row.statusKind {
 		case appStatusRunning:
@@ -366,7 +419,11 @@ func renderAppRow(row appRow, nameWidth, statusWidth, localWidth, publicWidth in
 			public = theme.Muted.Render(public)
 		}
 	}
-	parts := []string{name, status, local, public}
+	parts := []string{name}
+	if widths.host > 0 {
+		parts = append(parts, host)
+	}
+	parts = append(parts, status, local, public)
 	return strings.Join(parts, "  ")
 }
 
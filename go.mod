@@ -13,6 +13,7 @@ require (
 	github.com/muesli/cancelreader v0.2.2
 	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/shayne/yargs v1.0.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/tailscale/depaware v0.0.0-20251001183927-9c2ad255ef3f
 	golang.design/x/clipboard v0.7.1
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
@@ -0,0 +1,195 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Audit event names.
+const (
+	AuditLogin          = "login"
+	AuditLogout         = "logout"
+	AuditVerify         = "verify"
+	AuditRateLimit      = "rate_limit"
+	AuditPasswordChange = "password_change"
+)
+
+// Audit outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+	AuditDenied  = "denied"
+)
+
+const (
+	// DefaultAuditMaxBytes is the size at which the audit log is rotated.
+	DefaultAuditMaxBytes = 10 << 20
+	// DefaultAuditKeep is how many rotated files are kept.
+	DefaultAuditKeep = 5
+)
+
+// AuditEvent is one line of the audit log.
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	User    string    `json:"user,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	App     string    `json:"app,omitempty"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// AuditFilter selects events in ReadAuditLog. Empty fields match anything.
+type AuditFilter struct {
+	User  string
+	App   string
+	Since time.Time
+}
+
+func (f AuditFilter) match(event AuditEvent) bool {
+	if f.User != "" && event.User != f.User {
+		return false
+	}
+	if f.App != "" && event.App != f.App {
+		return false
+	}
+	return f.Since.IsZero() || !event.Time.Before(f.Since)
+}
+
+// AuditLog appends events as JSON lines. When the file would grow past
+// MaxBytes it is renamed to path.1, path.1 to path.2 and so on, keeping
+// Keep old files.
+type AuditLog struct {
+	path     string
+	MaxBytes int64
+	Keep     int
+	mu       sync.Mutex
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path, MaxBytes: DefaultAuditMaxBytes, Keep: DefaultAuditKeep}
+}
+
+// Record appends event, stamping the time if it is unset. A nil log
+// discards events.
+func (l *AuditLog) Record(event AuditEvent) error {
+	if l == nil {
+		return nil
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(l.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	if info, err := os.Stat(l.path); err == nil && l.MaxBytes > 0 && info.Size()+int64(len(line)) > l.MaxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (l *AuditLog) rotate() error {
+	keep := l.Keep
+	if keep < 1 {
+		keep = 1
+	}
+	if err := os.Remove(fmt.Sprintf("%s.%d", l.path, keep)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := keep - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(l.path, l.path+".1")
+}
+
+// ReadAuditLog returns the events in path and its rotated files that match
+// filter, oldest first. Lines that do not parse are skipped.
+func ReadAuditLog(path string, filter AuditFilter) ([]AuditEvent, error) {
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var generations []string
+	for i := len(rotated); i >= 1; i-- {
+		name := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(name); err == nil {
+			generations = append(generations, name)
+		}
+	}
+	generations = append(generations, path)
+
+	var events []AuditEvent
+	for _, name := range generations {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var event AuditEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				continue
+			}
+			if filter.match(event) {
+				events = append(events, event)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLogRotatesAndFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth", "audit.jsonl")
+	log := NewAuditLog(path)
+	log.MaxBytes = 400
+	log.Keep = 2
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		user := "alice"
+		if i%2 == 1 {
+			user = "bob"
+		}
+		event := AuditEvent{Time: start.Add(time.Duration(i) * time.Minute), Event: AuditLogin, User: user, IP: "203.0.113.7", App: "web", Outcome: AuditSuccess}
+		if err := log.Record(event); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Fatalf("expected two rotated files: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatalf("expected only Keep rotated files")
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > log.MaxBytes {
+		t.Fatalf("active log should stay under MaxBytes: %v %v", info, err)
+	}
+
+	all, err := ReadAuditLog(path, AuditFilter{})
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+	if len(all) == 0 || len(all) >= 12 {
+		t.Fatalf("expected rotation to drop the oldest events, got %d", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Time.Before(all[i-1].Time) {
+			t.Fatalf("events out of order: %v before %v", all[i].Time, all[i-1].Time)
+		}
+	}
+	if last := all[len(all)-1]; !last.Time.Equal(start.Add(11 * time.Minute)) {
+		t.Fatalf("expected newest event last, got %v", last.Time)
+	}
+
+	bob, err := ReadAuditLog(path, AuditFilter{User: "bob", Since: start.Add(10 * time.Minute)})
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+	if len(bob) != 1 || bob[0].User != "bob" || !bob[0].Time.Equal(start.Add(11*time.Minute)) {
+		t.Fatalf("unexpected filtered events: %+v", bob)
+	}
+	if none, _ := ReadAuditLog(path, AuditFilter{App: "other"}); len(none) != 0 {
+		t.Fatalf("expected no events for other app, got %d", len(none))
+	}
+}
+
+func TestAuditLogNilAndMissing(t *testing.T) {
+	var log *AuditLog
+	if err := log.Record(AuditEvent{Event: AuditLogin}); err != nil {
+		t.Fatalf("nil log Record: %v", err)
+	}
+	events, err := ReadAuditLog(filepath.Join(t.TempDir(), "missing.jsonl"), AuditFilter{})
+	if err != nil || len(events) != 0 {
+		t.Fatalf("missing log: %v %v", events, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import "errors"
+
+// ErrCodeReused is returned when a TOTP step or recovery code has already
+// been used to sign in.
+var ErrCodeReused = errors.New("code already used")
+
+type mfaFile struct {
+	Users map[string]mfaUserState `json:"users"`
+}
+
+type mfaUserState struct {
+	LastStep     int64    `json:"last_step,omitempty"`
+	UsedRecovery []string `json:"used_recovery,omitempty"`
+}
+
+// MFAStore records consumed second-factor codes. It changes on every
+// second-factor login, so it lives next to the session store rather than
+// in proxy.toml.
+type MFAStore struct {
+	path string
+}
+
+func NewMFAStore(path string) *MFAStore {
+	return &MFAStore{path: path}
+}
+
+// UseStep marks a TOTP step as used. Steps at or before the last one used
+// are rejected so a code cannot be replayed.
+func (s *MFAStore) UseStep(username string, step int64) error {
+	return s.update(username, func(state *mfaUserState) error {
+		if step <= state.LastStep {
+			return ErrCodeReused
+		}
+		state.LastStep = step
+		return nil
+	})
+}
+
+// UseRecoveryCode marks the recovery code with the given hash as used.
+func (s *MFAStore) UseRecoveryCode(username string, hash string) error {
+	return s.update(username, func(state *mfaUserState) error {
+		for _, used := range state.UsedRecovery {
+			if used == hash {
+				return ErrCodeReused
+			}
+		}
+		state.UsedRecovery = append(state.UsedRecovery, hash)
+		return nil
+	})
+}
+
+// UsedRecoveryCodes returns the hashes of username's consumed recovery codes.
+func (s *MFAStore) UsedRecoveryCodes(username string) ([]string, error) {
+	var file mfaFile
+	if err := readJSONFile(s.path, &file); err != nil {
+		return nil, err
+	}
+	return file.Users[username].UsedRecovery, nil
+}
+
+// Reset forgets username's consumed codes after a new enrollment.
+func (s *MFAStore) Reset(username string) error {
+	var file mfaFile
+	return updateJSONFile(s.path, &file, func() error {
+		delete(file.Users, username)
+		return nil
+	})
+}
+
+func (s *MFAStore) update(username string, fn func(*mfaUserState) error) error {
+	var file mfaFile
+	return updateJSONFile(s.path, &file, func() error {
+		if file.Users == nil {
+			file.Users = map[string]mfaUserState{}
+		}
+		state := file.Users[username]
+		if err := fn(&state); err != nil {
+			return err
+		}
+		file.Users[username] = state
+		return nil
+	})
+}
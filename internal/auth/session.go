@@ -27,8 +27,21 @@ type Session struct {
 	IssuedAt  int64  `json:"iat"`
 	ExpiresAt int64  `json:"exp"`
 	Nonce     string `json:"n"`
+	// Provider is "oidc" for single sign-on sessions, which carry the
+	// provider's groups because the user may not exist in proxy.toml.
+	Provider string   `json:"p,omitempty"`
+	Groups   []string `json:"g,omitempty"`
+	// App limits a share link session to one app.
+	App string `json:"a,omitempty"`
 }
 
+const (
+	// ProviderOIDC marks a session created by an OpenID Connect login.
+	ProviderOIDC = "oidc"
+	// ProviderShare marks a session created from a share link.
+	ProviderShare = "share"
+)
+
 func NewSession(username string, ttl time.Duration) (Session, error) {
 	if strings.TrimSpace(username) == "" {
 		return Session{}, fmt.Errorf("username is required")
@@ -50,47 +63,220 @@ func NewSession(username string, ttl time.Duration) (Session, error) {
 	}, nil
 }
 
-func SignSession(session Session, key []byte) (string, error) {
-	if len(key) == 0 {
+// SigningKey is one HMAC key in a Keyring.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring holds the keys that may verify a session, oldest first. The last
+// key is the active one used for signing.
+type Keyring []SigningKey
+
+// Active returns the key new sessions are signed with.
+func (k Keyring) Active() (SigningKey, bool) {
+	if len(k) == 0 {
+		return SigningKey{}, false
+	}
+	return k[len(k)-1], true
+}
+
+// SignSession signs session as "<key id>.<payload>.<signature>".
+func SignSession(session Session, key SigningKey) (string, error) {
+	return signToken("", session, key)
+}
+
+// VerifySession accepts a token signed by any key in ring. Tokens from
+// before key IDs existed ("<payload>.<signature>") are checked against
+// every key.
+func VerifySession(token string, ring Keyring) (Session, error) {
+	var session Session
+	if err := verifyToken("", token, ring, &session); err != nil {
+		return Session{}, err
+	}
+	if session.ExpiresAt <= time.Now().UTC().Unix() {
+		return Session{}, ErrExpiredToken
+	}
+	if strings.TrimSpace(session.Username) == "" {
+		return Session{}, ErrInvalidToken
+	}
+	return session, nil
+}
+
+// Challenge carries a user from the password step to the TOTP step of a
+// login. It is never accepted as a session.
+type Challenge struct {
+	Username  string `json:"u"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"n"`
+}
+
+// ChallengeTTL is how long a user has to enter their code.
+const ChallengeTTL = 5 * time.Minute
+
+func NewChallenge(username string) (Challenge, error) {
+	if strings.TrimSpace(username) == "" {
+		return Challenge{}, fmt.Errorf("username is required")
+	}
+	nonce, err := randomNonce(16)
+	if err != nil {
+		return Challenge{}, err
+	}
+	return Challenge{
+		Username:  username,
+		ExpiresAt: time.Now().UTC().Add(ChallengeTTL).Unix(),
+		Nonce:     nonce,
+	}, nil
+}
+
+func SignChallenge(challenge Challenge, key SigningKey) (string, error) {
+	return signToken(challengeContext, challenge, key)
+}
+
+func VerifyChallenge(token string, ring Keyring) (Challenge, error) {
+	var challenge Challenge
+	if err := verifyToken(challengeContext, token, ring, &challenge); err != nil {
+		return Challenge{}, err
+	}
+	if challenge.ExpiresAt <= time.Now().UTC().Unix() {
+		return Challenge{}, ErrExpiredToken
+	}
+	if strings.TrimSpace(challenge.Username) == "" {
+		return Challenge{}, ErrInvalidToken
+	}
+	return challenge, nil
+}
+
+// OIDCFlow is the state of an OpenID Connect login between the redirect to
+// the provider and the callback. State is also sent to the provider, and the
+// signed flow rides in a cookie so the callback can check both match.
+type OIDCFlow struct {
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	Redirect  string `json:"r"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// OIDCFlowTTL is how long a user has to finish signing in at the provider.
+const OIDCFlowTTL = 10 * time.Minute
+
+func SignOIDCFlow(flow OIDCFlow, key SigningKey) (string, error) {
+	return signToken(oidcFlowContext, flow, key)
+}
+
+func VerifyOIDCFlow(token string, ring Keyring) (OIDCFlow, error) {
+	var flow OIDCFlow
+	if err := verifyToken(oidcFlowContext, token, ring, &flow); err != nil {
+		return OIDCFlow{}, err
+	}
+	if flow.ExpiresAt <= time.Now().UTC().Unix() {
+		return OIDCFlow{}, ErrExpiredToken
+	}
+	if flow.State == "" || flow.Nonce == "" || flow.Verifier == "" {
+		return OIDCFlow{}, ErrInvalidToken
+	}
+	return flow, nil
+}
+
+// ShareGrant is the signed part of a share link. The link only works while
+// a share with the same ID is still configured for App.
+type ShareGrant struct {
+	ID        string `json:"id"`
+	App       string `json:"a"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func SignShareGrant(grant ShareGrant, key SigningKey) (string, error) {
+	return signToken(shareContext, grant, key)
+}
+
+func VerifyShareGrant(token string, ring Keyring) (ShareGrant, error) {
+	var grant ShareGrant
+	if err := verifyToken(shareContext, token, ring, &grant); err != nil {
+		return ShareGrant{}, err
+	}
+	if grant.ExpiresAt <= time.Now().UTC().Unix() {
+		return ShareGrant{}, ErrExpiredToken
+	}
+	if grant.ID == "" || grant.App == "" {
+		return ShareGrant{}, ErrInvalidToken
+	}
+	return grant, nil
+}
+
+// Token contexts are mixed into the MAC so a challenge, login flow or share
+// grant can never verify as a session or the other way round.
+const (
+	challengeContext = "challenge:"
+	oidcFlowContext  = "oidc:"
+	shareContext     = "share:"
+)
+
+func signToken(context string, v any, key SigningKey) (string, error) {
+	if len(key.Secret) == 0 {
 		return "", fmt.Errorf("signing key is required")
 	}
-	payload, err := json.Marshal(session)
+	if key.ID == "" || strings.Contains(key.ID, ".") {
+		return "", fmt.Errorf("invalid signing key id %q", key.ID)
+	}
+	payload, err := json.Marshal(v)
 	if err != nil {
 		return "", err
 	}
-	encoded := base64.RawURLEncoding.EncodeToString(payload)
-	sig := sign(encoded, key)
+	encoded := key.ID + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := sign(context+encoded, key.Secret)
 	return encoded + "." + sig, nil
 }
 
-func VerifySession(token string, key []byte) (Session, error) {
+func verifyToken(context string, token string, ring Keyring, v any) error {
 	parts := strings.Split(token, ".")
-	if len(parts) != 2 {
-		return Session{}, ErrInvalidToken
-	}
-	payload := strings.TrimSpace(parts[0])
-	sig := strings.TrimSpace(parts[1])
-	if payload == "" || sig == "" {
-		return Session{}, ErrInvalidToken
-	}
-	if !verify(payload, sig, key) {
-		return Session{}, ErrInvalidToken
+	var payload string
+	switch {
+	case len(parts) == 2 && context == "":
+		payload = strings.TrimSpace(parts[0])
+		sig := strings.TrimSpace(parts[1])
+		if payload == "" || sig == "" {
+			return ErrInvalidToken
+		}
+		ok := false
+		for _, key := range ring {
+			if verify(payload, sig, key.Secret) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return ErrInvalidToken
+		}
+	case len(parts) == 3:
+		kid := strings.TrimSpace(parts[0])
+		payload = strings.TrimSpace(parts[1])
+		sig := strings.TrimSpace(parts[2])
+		if kid == "" || payload == "" || sig == "" {
+			return ErrInvalidToken
+		}
+		ok := false
+		for _, key := range ring {
+			if key.ID == kid {
+				ok = verify(context+kid+"."+payload, sig, key.Secret)
+				break
+			}
+		}
+		if !ok {
+			return ErrInvalidToken
+		}
+	default:
+		return ErrInvalidToken
 	}
 	raw, err := base64.RawURLEncoding.DecodeString(payload)
 	if err != nil {
-		return Session{}, ErrInvalidToken
-	}
-	var session Session
-	if err := json.Unmarshal(raw, &session); err != nil {
-		return Session{}, ErrInvalidToken
-	}
-	if session.ExpiresAt <= time.Now().UTC().Unix() {
-		return Session{}, ErrExpiredToken
+		return ErrInvalidToken
 	}
-	if strings.TrimSpace(session.Username) == "" {
-		return Session{}, ErrInvalidToken
+	if err := json.Unmarshal(raw, v); err != nil {
+		return ErrInvalidToken
 	}
-	return session, nil
+	return nil
 }
 
 func sign(payload string, key []byte) string {
@@ -5,13 +5,15 @@
 package auth
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
 )
 
 func TestSessionSignVerify(t *testing.T) {
-	key := []byte("secret")
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
 	session, err := NewSession("alice", time.Minute)
 	if err != nil {
 		t.Fatalf("NewSession: %v", err)
@@ -23,7 +25,7 @@ func TestSessionSignVerify(t *testing.T) {
 	if !strings.Contains(token, ".") {
 		t.Fatalf("expected signed token")
 	}
-	verified, err := VerifySession(token, key)
+	verified, err := VerifySession(token, Keyring{key})
 	if err != nil {
 		t.Fatalf("VerifySession: %v", err)
 	}
@@ -33,7 +35,7 @@ func TestSessionSignVerify(t *testing.T) {
 }
 
 func TestSessionExpired(t *testing.T) {
-	key := []byte("secret")
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
 	session := Session{
 		Version:   1,
 		Username:  "bob",
@@ -45,7 +47,88 @@ func TestSessionExpired(t *testing.T) {
 	if err != nil {
 		t.Fatalf("SignSession: %v", err)
 	}
-	if _, err := VerifySession(token, key); err != ErrExpiredToken {
+	if _, err := VerifySession(token, Keyring{key}); err != ErrExpiredToken {
 		t.Fatalf("expected expired token error, got %v", err)
 	}
 }
+
+func TestVerifySessionKeyring(t *testing.T) {
+	oldKey := SigningKey{ID: "old", Secret: []byte("old-secret")}
+	newKey := SigningKey{ID: "new", Secret: []byte("new-secret")}
+	session, err := NewSession("alice", time.Minute)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	token, err := SignSession(session, oldKey)
+	if err != nil {
+		t.Fatalf("SignSession: %v", err)
+	}
+	if !strings.HasPrefix(token, "old.") {
+		t.Fatalf("expected key id prefix, got %q", token)
+	}
+	if _, err := VerifySession(token, Keyring{oldKey, newKey}); err != nil {
+		t.Fatalf("expected verify-only key to be accepted: %v", err)
+	}
+	if _, err := VerifySession(token, Keyring{newKey}); err != ErrInvalidToken {
+		t.Fatalf("expected retired key to be rejected, got %v", err)
+	}
+	forged := SigningKey{ID: "new", Secret: oldKey.Secret}
+	token, _ = SignSession(session, forged)
+	if _, err := VerifySession(token, Keyring{oldKey, newKey}); err != ErrInvalidToken {
+		t.Fatalf("expected key id mismatch to be rejected, got %v", err)
+	}
+}
+
+func TestVerifySessionLegacyToken(t *testing.T) {
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+	session, _ := NewSession("alice", time.Minute)
+	payload, _ := json.Marshal(session)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := encoded + "." + sign(encoded, key.Secret)
+	if _, err := VerifySession(token, Keyring{{ID: "k2", Secret: []byte("other")}, key}); err != nil {
+		t.Fatalf("expected legacy token to verify: %v", err)
+	}
+}
+
+func TestChallengeIsNotASession(t *testing.T) {
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+	challenge, err := NewChallenge("alice")
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token, err := SignChallenge(challenge, key)
+	if err != nil {
+		t.Fatalf("SignChallenge: %v", err)
+	}
+	if got, err := VerifyChallenge(token, Keyring{key}); err != nil || got.Username != "alice" {
+		t.Fatalf("VerifyChallenge: %+v %v", got, err)
+	}
+	if _, err := VerifySession(token, Keyring{key}); err != ErrInvalidToken {
+		t.Fatalf("expected challenge to be rejected as a session, got %v", err)
+	}
+	session, _ := NewSession("alice", time.Minute)
+	sessionToken, _ := SignSession(session, key)
+	if _, err := VerifyChallenge(sessionToken, Keyring{key}); err != ErrInvalidToken {
+		t.Fatalf("expected session to be rejected as a challenge, got %v", err)
+	}
+}
+
+func TestShareGrantRoundTrip(t *testing.T) {
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+	grant := ShareGrant{ID: "abc123", App: "web", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	token, err := SignShareGrant(grant, key)
+	if err != nil {
+		t.Fatalf("SignShareGrant: %v", err)
+	}
+	if got, err := VerifyShareGrant(token, Keyring{key}); err != nil || got != grant {
+		t.Fatalf("VerifyShareGrant: %+v %v", got, err)
+	}
+	if _, err := VerifySession(token, Keyring{key}); err != ErrInvalidToken {
+		t.Fatalf("expected share grant to be rejected as a session, got %v", err)
+	}
+	grant.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	expired, _ := SignShareGrant(grant, key)
+	if _, err := VerifyShareGrant(expired, Keyring{key}); err != ErrExpiredToken {
+		t.Fatalf("expected expired grant, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRevokedSession is returned for a signed token whose nonce is no longer
+// in the session store.
+var ErrRevokedSession = errors.New("session revoked")
+
+// SessionRecord is a live session as stored on disk, keyed by nonce.
+type SessionRecord struct {
+	Nonce     string `json:"nonce"`
+	Username  string `json:"username"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ClientIP  string `json:"ip,omitempty"`
+	UserAgent string `json:"ua,omitempty"`
+}
+
+// ID is the short form of the nonce shown to users.
+func (r SessionRecord) ID() string {
+	if len(r.Nonce) > 8 {
+		return r.Nonce[:8]
+	}
+	return r.Nonce
+}
+
+// sessionFile is the on-disk store. Sessions issued before TrackedSince
+// predate the store and are accepted until they expire unless their user's
+// sessions were all revoked after they were issued.
+type sessionFile struct {
+	Sessions      []SessionRecord  `json:"sessions"`
+	TrackedSince  int64            `json:"tracked_since,omitempty"`
+	RevokedBefore map[string]int64 `json:"revoked_before,omitempty"`
+}
+
+// SessionStore is a JSON file shared by viberun-auth and viberun-server.
+// Check runs on every forward_auth request, so the parsed file is cached
+// until the file is replaced or its modification time changes.
+type SessionStore struct {
+	path string
+
+	mu     sync.Mutex
+	cached sessionFile
+	nonces map[string]string
+	info   os.FileInfo
+}
+
+func NewSessionStore(path string) *SessionStore {
+	return &SessionStore{path: path}
+}
+
+// Add records a newly issued session.
+func (s *SessionStore) Add(session Session, clientIP string, userAgent string) error {
+	return s.update(func(file *sessionFile, records []SessionRecord) ([]SessionRecord, error) {
+		// The first session may have been issued a moment before the
+		// store was created; it is tracked, not legacy.
+		file.TrackedSince = min(file.TrackedSince, session.IssuedAt)
+		return append(records, SessionRecord{
+			Nonce:     session.Nonce,
+			Username:  session.Username,
+			IssuedAt:  session.IssuedAt,
+			ExpiresAt: session.ExpiresAt,
+			ClientIP:  clientIP,
+			UserAgent: userAgent,
+		}), nil
+	})
+}
+
+// Check returns ErrRevokedSession unless session is still in the store or
+// was issued before the store existed.
+func (s *SessionStore) Check(session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if username, ok := s.nonces[session.Nonce]; ok && username == session.Username {
+		return nil
+	}
+	if s.cached.TrackedSince == 0 || session.IssuedAt < s.cached.TrackedSince {
+		if session.IssuedAt >= s.cached.RevokedBefore[session.Username] {
+			return nil
+		}
+	}
+	return ErrRevokedSession
+}
+
+// List returns the unexpired sessions for username, newest first. An empty
+// username lists every user.
+func (s *SessionStore) List(username string) ([]SessionRecord, error) {
+	records, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Unix()
+	out := []SessionRecord{}
+	for _, record := range records {
+		if record.ExpiresAt <= now {
+			continue
+		}
+		if username != "" && record.Username != username {
+			continue
+		}
+		out = append(out, record)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].IssuedAt > out[j].IssuedAt })
+	return out, nil
+}
+
+// Revoke removes sessions for username. An empty id removes all of them;
+// otherwise id must match the start of exactly one nonce.
+func (s *SessionStore) Revoke(username string, id string) (int, error) {
+	revoked := 0
+	err := s.update(func(file *sessionFile, records []SessionRecord) ([]SessionRecord, error) {
+		matches := 0
+		for _, record := range records {
+			if record.Username == username && (id == "" || strings.HasPrefix(record.Nonce, id)) {
+				matches++
+			}
+		}
+		if id != "" && matches > 1 {
+			return nil, fmt.Errorf("session id %q is ambiguous", id)
+		}
+		kept := make([]SessionRecord, 0, len(records))
+		for _, record := range records {
+			if record.Username == username && (id == "" || strings.HasPrefix(record.Nonce, id)) {
+				continue
+			}
+			kept = append(kept, record)
+		}
+		if id == "" {
+			file.revokeBefore(username)
+		}
+		revoked = matches
+		return kept, nil
+	})
+	return revoked, err
+}
+
+// RevokeOthers removes every session for username except the one with
+// nonce keep, as when a user logs out their other devices.
+func (s *SessionStore) RevokeOthers(username string, keep string) (int, error) {
+	revoked := 0
+	err := s.update(func(file *sessionFile, records []SessionRecord) ([]SessionRecord, error) {
+		kept := make([]SessionRecord, 0, len(records))
+		for _, record := range records {
+			if record.Username == username && record.Nonce != keep {
+				revoked++
+				continue
+			}
+			kept = append(kept, record)
+		}
+		file.revokeBefore(username)
+		return kept, nil
+	})
+	return revoked, err
+}
+
+// RevokeNonce removes a single session, as on logout.
+func (s *SessionStore) RevokeNonce(nonce string) error {
+	return s.update(func(_ *sessionFile, records []SessionRecord) ([]SessionRecord, error) {
+		kept := make([]SessionRecord, 0, len(records))
+		for _, record := range records {
+			if record.Nonce != nonce {
+				kept = append(kept, record)
+			}
+		}
+		return kept, nil
+	})
+}
+
+func (s *SessionStore) read() ([]SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.cached.Sessions, nil
+}
+
+// load refreshes the cache when the file has changed. Writers replace the
+// file by rename, so a write shows up as a different file. The caller holds
+// s.mu.
+func (s *SessionStore) load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		s.cached, s.nonces, s.info = sessionFile{}, nil, nil
+		return nil
+	}
+	if s.info != nil && os.SameFile(info, s.info) && info.ModTime().Equal(s.info.ModTime()) && info.Size() == s.info.Size() {
+		return nil
+	}
+	var file sessionFile
+	if err := readJSONFile(s.path, &file); err != nil {
+		return err
+	}
+	nonces := make(map[string]string, len(file.Sessions))
+	for _, record := range file.Sessions {
+		nonces[record.Nonce] = record.Username
+	}
+	s.cached, s.nonces, s.info = file, nonces, info
+	return nil
+}
+
+// revokeBefore rejects untracked sessions for username issued up to now.
+func (f *sessionFile) revokeBefore(username string) {
+	if f.RevokedBefore == nil {
+		f.RevokedBefore = map[string]int64{}
+	}
+	f.RevokedBefore[username] = time.Now().UTC().Unix() + 1
+}
+
+// update applies fn under the store lock and prunes expired sessions.
+func (s *SessionStore) update(fn func(*sessionFile, []SessionRecord) ([]SessionRecord, error)) error {
+	var file sessionFile
+	return updateJSONFile(s.path, &file, func() error {
+		now := time.Now().UTC().Unix()
+		if file.TrackedSince == 0 {
+			file.TrackedSince = now
+		}
+		records, err := fn(&file, file.Sessions)
+		if err != nil {
+			return err
+		}
+		live := make([]SessionRecord, 0, len(records))
+		for _, record := range records {
+			if record.ExpiresAt > now {
+				live = append(live, record)
+			}
+		}
+		file.Sessions = live
+		return nil
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessionStoreRevoke(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), "auth", "sessions.json"))
+	alice1, _ := NewSession("alice", time.Hour)
+	alice2, _ := NewSession("alice", time.Hour)
+	bob, _ := NewSession("bob", time.Hour)
+	for _, session := range []Session{alice1, alice2, bob} {
+		if err := store.Add(session, "203.0.113.7", "curl/8"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := store.Check(alice1); err != nil {
+		t.Fatalf("expected stored session to be valid: %v", err)
+	}
+	sessions, err := store.List("alice")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("List: %+v %v", sessions, err)
+	}
+
+	revoked, err := store.Revoke("alice", alice1.Nonce[:10])
+	if err != nil || revoked != 1 {
+		t.Fatalf("Revoke one: %d %v", revoked, err)
+	}
+	if err := store.Check(alice1); !errors.Is(err, ErrRevokedSession) {
+		t.Fatalf("expected revoked session, got %v", err)
+	}
+	if err := store.Check(alice2); err != nil {
+		t.Fatalf("expected other session to survive: %v", err)
+	}
+
+	revoked, err = store.Revoke("alice", "")
+	if err != nil || revoked != 1 {
+		t.Fatalf("Revoke all: %d %v", revoked, err)
+	}
+	if err := store.Check(bob); err != nil {
+		t.Fatalf("revoking alice must not touch bob: %v", err)
+	}
+	if err := store.RevokeNonce(bob.Nonce); err != nil {
+		t.Fatalf("RevokeNonce: %v", err)
+	}
+	if sessions, _ := store.List(""); len(sessions) != 0 {
+		t.Fatalf("expected empty store, got %+v", sessions)
+	}
+}
+
+func TestSessionStoreRevokeOthers(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	current, _ := NewSession("alice", time.Hour)
+	other, _ := NewSession("alice", time.Hour)
+	bob, _ := NewSession("bob", time.Hour)
+	for _, session := range []Session{current, other, bob} {
+		if err := store.Add(session, "", ""); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	revoked, err := store.RevokeOthers("alice", current.Nonce)
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeOthers: %d %v", revoked, err)
+	}
+	if err := store.Check(current); err != nil {
+		t.Fatalf("expected current session to survive: %v", err)
+	}
+	if err := store.Check(other); !errors.Is(err, ErrRevokedSession) {
+		t.Fatalf("expected other session revoked, got %v", err)
+	}
+	if err := store.Check(bob); err != nil {
+		t.Fatalf("revoking alice must not touch bob: %v", err)
+	}
+}
+
+func TestSessionStorePrunesExpired(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	expired, _ := NewSession("alice", time.Hour)
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	live, _ := NewSession("alice", time.Hour)
+	if err := store.Add(expired, "", ""); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := store.Add(live, "", ""); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	records, err := store.read()
+	if err != nil || len(records) != 1 || records[0].Nonce != live.Nonce {
+		t.Fatalf("expected expired session to be pruned, got %+v %v", records, err)
+	}
+}
+
+func TestMFAStoreRejectsReuse(t *testing.T) {
+	store := NewMFAStore(filepath.Join(t.TempDir(), "mfa.json"))
+	if err := store.UseStep("alice", 100); err != nil {
+		t.Fatalf("UseStep: %v", err)
+	}
+	if err := store.UseStep("alice", 100); !errors.Is(err, ErrCodeReused) {
+		t.Fatalf("expected replayed step to be rejected, got %v", err)
+	}
+	if err := store.UseStep("alice", 99); !errors.Is(err, ErrCodeReused) {
+		t.Fatalf("expected older step to be rejected, got %v", err)
+	}
+	if err := store.UseStep("bob", 100); err != nil {
+		t.Fatalf("steps are per user: %v", err)
+	}
+	if err := store.UseRecoveryCode("alice", "sha256:aa"); err != nil {
+		t.Fatalf("UseRecoveryCode: %v", err)
+	}
+	if err := store.UseRecoveryCode("alice", "sha256:aa"); !errors.Is(err, ErrCodeReused) {
+		t.Fatalf("expected used recovery code to be rejected, got %v", err)
+	}
+	if err := store.Reset("alice"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if used, _ := store.UsedRecoveryCodes("alice"); len(used) != 0 {
+		t.Fatalf("expected reset to clear used codes, got %v", used)
+	}
+}
+
+func TestSessionStoreAcceptsLegacySessions(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	legacy, _ := NewSession("alice", time.Hour)
+	legacy.IssuedAt -= 60
+	if err := store.Check(legacy); err != nil {
+		t.Fatalf("expected session from before the store to be valid: %v", err)
+	}
+	current, _ := NewSession("alice", time.Hour)
+	if err := store.Add(current, "", ""); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := store.Check(legacy); err != nil {
+		t.Fatalf("expected legacy session to survive the first write: %v", err)
+	}
+	untracked, _ := NewSession("alice", time.Hour)
+	untracked.IssuedAt += 60
+	if err := store.Check(untracked); !errors.Is(err, ErrRevokedSession) {
+		t.Fatalf("expected untracked new session to be rejected, got %v", err)
+	}
+	if _, err := store.RevokeOthers("alice", current.Nonce); err != nil {
+		t.Fatalf("RevokeOthers: %v", err)
+	}
+	if err := store.Check(legacy); !errors.Is(err, ErrRevokedSession) {
+		t.Fatalf("expected legacy session revoked, got %v", err)
+	}
+	if err := store.Check(current); err != nil {
+		t.Fatalf("expected current session to survive: %v", err)
+	}
+}
+
+func TestSessionStoreSeesOtherWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	reader := NewSessionStore(path)
+	writer := NewSessionStore(path)
+	session, _ := NewSession("alice", time.Hour)
+	if err := writer.Add(session, "", ""); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := reader.Check(session); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if err := writer.RevokeNonce(session.Nonce); err != nil {
+		t.Fatalf("RevokeNonce: %v", err)
+	}
+	if err := reader.Check(session); !errors.Is(err, ErrRevokedSession) {
+		t.Fatalf("expected cached reader to see the revoke, got %v", err)
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package filecopy moves file trees as tar archives for `viberun cp`. A
+// tree's entries are named relative to its root, which is ".", so either
+// side can put the tree wherever the destination resolves to. Manifests
+// of sha256 digests let the sender skip files the destination already has.
+package filecopy
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+// Root is the name of a tree's root entry.
+const Root = "."
+
+// manifestBatch caps the entries per CopyManifest message, well below the
+// mux frame limit.
+const manifestBatch = 2000
+
+// CleanName turns a tar entry name into a path relative to the root. It
+// rejects names that would land outside the tree.
+func CleanName(name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("absolute path in archive: %s", name)
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("path escapes the copy: %s", name)
+	}
+	return cleaned, nil
+}
+
+// TrimRoot maps an entry of an archive made with `tar -C dir base` to a
+// path relative to base. It reports false for entries outside base.
+func TrimRoot(name string, base string) (string, bool) {
+	rel, err := CleanName(name)
+	if err != nil {
+		return "", false
+	}
+	if base == Root {
+		return rel, true
+	}
+	if rel == base {
+		return Root, true
+	}
+	if rest, ok := strings.CutPrefix(rel, base+"/"); ok {
+		return rest, true
+	}
+	return "", false
+}
+
+// Index maps entries by path.
+func Index(entries []muxrpc.CopyEntry) map[string]muxrpc.CopyEntry {
+	index := make(map[string]muxrpc.CopyEntry, len(entries))
+	for _, entry := range entries {
+		index[entry.Path] = entry
+	}
+	return index
+}
+
+// Unchanged reports whether have already holds regular file entry with the
+// same content and permissions.
+func Unchanged(have map[string]muxrpc.CopyEntry, entry muxrpc.CopyEntry) bool {
+	existing, ok := have[entry.Path]
+	if !ok || !fs.FileMode(entry.Mode).IsRegular() {
+		return false
+	}
+	return existing.Mode == entry.Mode && existing.Size == entry.Size && existing.SHA256 == entry.SHA256
+}
+
+// MsgStream is the message half of a mux stream.
+type MsgStream interface {
+	SendMsg([]byte) error
+	ReceiveMsg() ([]byte, error)
+}
+
+// SendManifest sends entries as one or more CopyManifest messages.
+func SendManifest(stream MsgStream, entries []muxrpc.CopyEntry) error {
+	for {
+		batch := muxrpc.CopyManifest{Entries: entries}
+		if len(entries) > manifestBatch {
+			batch = muxrpc.CopyManifest{Entries: entries[:manifestBatch], More: true}
+		}
+		payload, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(payload); err != nil {
+			return err
+		}
+		if !batch.More {
+			return nil
+		}
+		entries = entries[manifestBatch:]
+	}
+}
+
+// ReceiveManifest reads the CopyManifest messages sent by SendManifest.
+func ReceiveManifest(stream MsgStream) ([]muxrpc.CopyEntry, error) {
+	var entries []muxrpc.CopyEntry
+	for {
+		msg, err := stream.ReceiveMsg()
+		if err != nil {
+			return nil, err
+		}
+		var manifest muxrpc.CopyManifest
+		if err := json.Unmarshal(msg, &manifest); err != nil {
+			return nil, err
+		}
+		if manifest.Error != "" {
+			return nil, errors.New(manifest.Error)
+		}
+		entries = append(entries, manifest.Entries...)
+		if !manifest.More {
+			return entries, nil
+		}
+	}
+}
+
+// entryFromInfo describes a file without its digest.
+func entryFromInfo(rel string, info fs.FileInfo, link string) muxrpc.CopyEntry {
+	entry := muxrpc.CopyEntry{Path: rel, Mode: uint32(info.Mode() & (fs.ModeType | fs.ModePerm)), Link: link}
+	if info.Mode().IsRegular() {
+		entry.Size = info.Size()
+	}
+	return entry
+}
+
+func digest(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ScanTar builds the manifest of a tree archived with `tar -C dir base`.
+func ScanTar(r io.Reader, base string) ([]muxrpc.CopyEntry, error) {
+	tr := tar.NewReader(r)
+	var entries []muxrpc.CopyEntry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rel, ok := TrimRoot(hdr.Name, base)
+		if !ok {
+			continue
+		}
+		entry := entryFromInfo(rel, hdr.FileInfo(), hdr.Linkname)
+		if hdr.Typeflag == tar.TypeReg {
+			if entry.SHA256, err = digest(tr); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// ScanDir builds the manifest of a local file or directory. A missing
+// path has an empty manifest.
+func ScanDir(root string) ([]muxrpc.CopyEntry, error) {
+	if _, err := os.Lstat(root); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	var entries []muxrpc.CopyEntry
+	err := walk(root, func(rel string, full string, info fs.FileInfo, link string) error {
+		entry := entryFromInfo(rel, info, link)
+		if info.Mode().IsRegular() {
+			file, err := os.Open(full)
+			if err != nil {
+				return err
+			}
+			entry.SHA256, err = digest(file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// walk visits root and everything below it in lexical order, without
+// following symlinks.
+func walk(root string, fn func(rel string, full string, info fs.FileInfo, link string) error) error {
+	return filepath.WalkDir(root, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, full)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(full); err != nil {
+				return err
+			}
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() && link == "" {
+			// Sockets, devices and pipes are not copied.
+			return nil
+		}
+		return fn(filepath.ToSlash(rel), full, info, link)
+	})
+}
+
+// Stats counts what a copy sent or wrote.
+type Stats struct {
+	Files   int
+	Bytes   int64
+	Skipped int
+}
+
+// Plan decides which regular files under a local root differ from have.
+// Files whose size and mode match are hashed to be sure. The returned
+// stats total what will be sent and skipped.
+func Plan(root string, have map[string]muxrpc.CopyEntry) (map[string]bool, Stats, error) {
+	send := map[string]bool{}
+	var stats Stats
+	err := walk(root, func(rel string, full string, info fs.FileInfo, link string) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		entry := entryFromInfo(rel, info, link)
+		if existing, ok := have[rel]; ok && existing.Mode == entry.Mode && existing.Size == entry.Size {
+			file, err := os.Open(full)
+			if err != nil {
+				return err
+			}
+			entry.SHA256, err = digest(file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+			if Unchanged(have, entry) {
+				stats.Skipped++
+				return nil
+			}
+		}
+		send[rel] = true
+		stats.Files++
+		stats.Bytes += entry.Size
+		return nil
+	})
+	return send, stats, err
+}
+
+// WriteDir archives a local file or directory. Directories and symlinks
+// are always sent; regular files only when send returns true. Progress, if
+// set, is called as file data is written.
+func WriteDir(tw *tar.Writer, root string, send func(muxrpc.CopyEntry) bool, progress func(int64)) (Stats, error) {
+	var stats Stats
+	err := walk(root, func(rel string, full string, info fs.FileInfo, link string) error {
+		entry := entryFromInfo(rel, info, link)
+		if info.Mode().IsRegular() && !send(entry) {
+			stats.Skipped++
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() && rel != Root {
+			hdr.Name += "/"
+		}
+		// Ownership belongs to the receiving side.
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		hdr.Format = tar.FormatPAX
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(full)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		n, err := io.Copy(tw, progressReader{r: file, progress: progress})
+		stats.Files++
+		stats.Bytes += n
+		return err
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, tw.Close()
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress func(int64)
+}
+
+func (p progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 && p.progress != nil {
+		p.progress(int64(n))
+	}
+	return n, err
+}
+
+// Extract writes an archived tree to dest, which becomes the tree's root.
+// Files are written through a temporary name and renamed into place.
+// Symlinks are created last so no entry is written through one, and
+// directory times are set after their contents. Everything below dest is
+// opened through an os.Root, so a symlink left by an earlier copy cannot
+// point a write outside the tree.
+func Extract(tr *tar.Reader, dest string, progress func(int64)) (Stats, error) {
+	var stats Stats
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return stats, err
+	}
+	parent, err := os.OpenRoot(filepath.Dir(dest))
+	if err != nil {
+		return stats, err
+	}
+	defer parent.Close()
+	t := &tree{parent: parent, name: filepath.Base(dest)}
+	defer t.close()
+
+	type pending struct {
+		rel     string
+		link    string
+		mode    fs.FileMode
+		modTime time.Time
+	}
+	var links, dirs []pending
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		rel, err := CleanName(hdr.Name)
+		if err != nil {
+			return stats, err
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			root, name, err := t.dir(rel)
+			if err != nil {
+				return stats, err
+			}
+			// Stay writable until the contents are in.
+			if err := root.Chmod(name, mode|0o700); err != nil {
+				return stats, err
+			}
+			dirs = append(dirs, pending{rel: rel, mode: mode, modTime: hdr.ModTime})
+		case tar.TypeReg:
+			root, name, err := t.at(rel)
+			if err != nil {
+				return stats, err
+			}
+			n, err := extractFile(tr, root, name, mode, progress)
+			if err != nil {
+				return stats, err
+			}
+			if err := root.Chtimes(name, hdr.ModTime, hdr.ModTime); err != nil {
+				return stats, err
+			}
+			stats.Files++
+			stats.Bytes += n
+		case tar.TypeSymlink:
+			links = append(links, pending{rel: rel, link: hdr.Linkname})
+		}
+	}
+	for _, link := range links {
+		root, name, err := t.at(link.rel)
+		if err != nil {
+			return stats, err
+		}
+		if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return stats, err
+		}
+		if info, err := root.Lstat(name); err == nil && !info.IsDir() {
+			_ = root.Remove(name)
+		}
+		if err := root.Symlink(link.link, name); err != nil {
+			return stats, err
+		}
+	}
+	// Deepest first, so a parent's time is set after its children change.
+	depth := func(rel string) int {
+		if rel == Root {
+			return 0
+		}
+		return strings.Count(rel, "/") + 1
+	}
+	sort.SliceStable(dirs, func(i, j int) bool { return depth(dirs[i].rel) > depth(dirs[j].rel) })
+	for _, dir := range dirs {
+		root, name, err := t.at(dir.rel)
+		if err != nil {
+			return stats, err
+		}
+		if err := root.Chmod(name, dir.mode); err != nil {
+			return stats, err
+		}
+		if err := root.Chtimes(name, dir.modTime, dir.modTime); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// tree resolves archive paths for Extract. The root entry is name in
+// parent; everything else is confined to the directory it names.
+type tree struct {
+	parent *os.Root
+	name   string
+	root   *os.Root
+}
+
+// at returns the root and name to use for rel.
+func (t *tree) at(rel string) (*os.Root, string, error) {
+	if rel == Root {
+		return t.parent, t.name, nil
+	}
+	if t.root == nil {
+		if err := t.parent.MkdirAll(t.name, 0o755); err != nil {
+			return nil, "", err
+		}
+		root, err := t.parent.OpenRoot(t.name)
+		if err != nil {
+			return nil, "", err
+		}
+		t.root = root
+	}
+	return t.root, filepath.FromSlash(rel), nil
+}
+
+// dir creates the directory rel and returns where it is.
+func (t *tree) dir(rel string) (*os.Root, string, error) {
+	root, name, err := t.at(rel)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, name, root.MkdirAll(name, 0o755)
+}
+
+func (t *tree) close() {
+	if t.root != nil {
+		_ = t.root.Close()
+	}
+}
+
+func extractFile(r io.Reader, root *os.Root, name string, mode fs.FileMode, progress func(int64)) (int64, error) {
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return 0, err
+	}
+	if info, err := root.Lstat(name); err == nil && info.IsDir() {
+		return 0, fmt.Errorf("cannot overwrite directory %s with a file", name)
+	}
+	tmp := name + ".viberun-part"
+	file, err := root.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(file, progressReader{r: r, progress: progress})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = root.Chmod(tmp, mode)
+	}
+	if err == nil {
+		err = root.Rename(tmp, name)
+	}
+	if err != nil {
+		_ = root.Remove(tmp)
+		return n, err
+	}
+	return n, nil
+}
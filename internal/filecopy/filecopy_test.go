@@ -0,0 +1,200 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filecopy
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/muxrpc"
+)
+
+func writeTestFile(t *testing.T, path string, data string, mode os.FileMode, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func copyTree(t *testing.T, src string, dest string) Stats {
+	t.Helper()
+	have, err := ScanDir(dest)
+	if err != nil {
+		t.Fatalf("scan dest: %v", err)
+	}
+	send, _, err := Plan(src, Index(have))
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	var archive bytes.Buffer
+	if _, err := WriteDir(tar.NewWriter(&archive), src, func(entry muxrpc.CopyEntry) bool { return send[entry.Path] }, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	stats, err := Extract(tar.NewReader(&archive), dest, nil)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	return stats
+}
+
+func TestCopyTreeKeepsModesTimesAndLinks(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	mtime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	writeTestFile(t, filepath.Join(src, "run.sh"), "#!/bin/sh\n", 0o755, mtime)
+	writeTestFile(t, filepath.Join(src, "nested", "data.txt"), "data", 0o600, mtime)
+	if err := os.Symlink("nested/data.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(src, "nested"), mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "dest")
+	stats := copyTree(t, src, dest)
+	if stats.Files != 2 {
+		t.Fatalf("expected 2 files, got %+v", stats)
+	}
+	info, err := os.Stat(filepath.Join(dest, "run.sh"))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o755 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("unexpected mode or mtime: %v %v", info.Mode(), info.ModTime())
+	}
+	if info, err := os.Stat(filepath.Join(dest, "nested")); err != nil || !info.ModTime().Equal(mtime) {
+		t.Fatalf("unexpected dir mtime: %v %v", info, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dest, "link")); err != nil || link != "nested/data.txt" {
+		t.Fatalf("unexpected link: %q %v", link, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "nested", "data.txt")); err != nil || string(data) != "data" {
+		t.Fatalf("unexpected data: %q %v", data, err)
+	}
+}
+
+func TestCopyTreeSkipsUnchangedFiles(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	mtime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	writeTestFile(t, filepath.Join(src, "a.txt"), "one", 0o644, mtime)
+	writeTestFile(t, filepath.Join(src, "b.txt"), "two", 0o644, mtime)
+	dest := filepath.Join(t.TempDir(), "dest")
+	copyTree(t, src, dest)
+
+	writeTestFile(t, filepath.Join(src, "b.txt"), "TWO", 0o644, mtime)
+	stats := copyTree(t, src, dest)
+	if stats.Files != 1 {
+		t.Fatalf("expected only the changed file, got %+v", stats)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "b.txt")); err != nil || string(data) != "TWO" {
+		t.Fatalf("unexpected data: %q %v", data, err)
+	}
+}
+
+func TestCopySingleFile(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	writeTestFile(t, src, "hello", 0o640, time.Now())
+	dest := filepath.Join(t.TempDir(), "copy.txt")
+	copyTree(t, src, dest)
+	info, err := os.Stat(dest)
+	if err != nil || info.IsDir() || info.Mode().Perm() != 0o640 {
+		t.Fatalf("unexpected dest: %v %v", info, err)
+	}
+}
+
+func TestExtractRejectsEscapingNames(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0o644, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	_ = tw.Close()
+	if _, err := Extract(tar.NewReader(&archive), t.TempDir(), nil); err == nil {
+		t.Fatal("expected an error for a name outside the tree")
+	}
+}
+
+func TestExtractDoesNotFollowPlantedLinks(t *testing.T) {
+	extract := func(dest string, headers ...*tar.Header) error {
+		t.Helper()
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for _, hdr := range headers {
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatalf("header: %v", err)
+			}
+			if hdr.Size > 0 {
+				_, _ = tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+			}
+		}
+		_ = tw.Close()
+		_, err := Extract(tar.NewReader(&archive), dest, nil)
+		return err
+	}
+	base := t.TempDir()
+	outside := filepath.Join(base, "outside")
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	dest := filepath.Join(base, "dest")
+	root := &tar.Header{Name: ".", Mode: 0o755, Typeflag: tar.TypeDir}
+
+	// The first copy plants links that point out of the tree; they are
+	// just links, so that copy succeeds.
+	if err := extract(dest, root,
+		&tar.Header{Name: "abs", Linkname: outside, Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "rel", Linkname: "../outside", Typeflag: tar.TypeSymlink},
+	); err != nil {
+		t.Fatalf("first copy: %v", err)
+	}
+	// The second copy tries to write through them.
+	for _, hdr := range []*tar.Header{
+		{Name: "abs/pwned", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg},
+		{Name: "rel/pwned", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg},
+		{Name: "abs/dir/", Mode: 0o755, Typeflag: tar.TypeDir},
+		{Name: "rel/link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink},
+	} {
+		if err := extract(dest, root, hdr); err == nil {
+			t.Fatalf("expected %s to be refused", hdr.Name)
+		}
+	}
+	entries, err := os.ReadDir(outside)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing written outside the tree, got %v %v", entries, err)
+	}
+}
+
+func TestTrimRoot(t *testing.T) {
+	cases := []struct {
+		name string
+		base string
+		want string
+		ok   bool
+	}{
+		{"src/", "src", ".", true},
+		{"src/a/b.txt", "src", "a/b.txt", true},
+		{"other/a", "src", "", false},
+		{"./a", ".", "a", true},
+		{"../a", "src", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := TrimRoot(tc.name, tc.base)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("TrimRoot(%q, %q) = %q, %v", tc.name, tc.base, got, ok)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// ProtocolVersion is the newest mux protocol this build speaks.
+	ProtocolVersion = 2
+	// MinProtocolVersion is the oldest mux protocol this build speaks.
+	// Version 1 is the original protocol, which has no hello frame.
+	MinProtocolVersion = 1
+	legacyVersion      = 1
+)
+
+// Capabilities the mux itself negotiates. A feature is only used when
+// both sides advertise it. Applications may advertise their own
+// capabilities with Advertise and check them with PeerHas.
+const (
+	CapFlowControl = "flow-control"
+	CapHalfClose   = "half-close"
+)
+
+// probeStreamType is opened by Handshake right after the hello. No peer
+// handles it, so an older peer that ignores the hello still answers with a
+// close frame.
+const probeStreamType = "mux.probe"
+
+// Hello is exchanged when a connection starts. The client sends its hello
+// first and the server answers with its own.
+type Hello struct {
+	Version      int      `json:"version"`
+	MinVersion   int      `json:"min_version"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Software is the peer's build version, for error messages.
+	Software string `json:"software,omitempty"`
+}
+
+func (h Hello) has(capability string) bool {
+	return slices.Contains(h.Capabilities, capability)
+}
+
+// legacyHello describes a peer that predates the handshake.
+func legacyHello() Hello {
+	return Hello{Version: legacyVersion, MinVersion: legacyVersion}
+}
+
+// VersionError reports that the two sides share no protocol version.
+type VersionError struct {
+	Local Hello
+	Peer  Hello
+}
+
+func (e *VersionError) Error() string {
+	peer := fmt.Sprintf("%d-%d", e.Peer.MinVersion, e.Peer.Version)
+	if e.Peer.Software != "" {
+		peer += " (" + e.Peer.Software + ")"
+	}
+	return fmt.Sprintf("no common mux protocol version: this side speaks %d-%d, peer speaks %s", e.Local.MinVersion, e.Local.Version, peer)
+}
+
+// PeerOlder reports whether the peer is the side that needs an update.
+func (e *VersionError) PeerOlder() bool {
+	return e.Peer.Version < e.Local.MinVersion
+}
+
+// negotiate picks the newest version both sides speak.
+func negotiate(local Hello, peer Hello) (int, error) {
+	version := min(local.Version, peer.Version)
+	if version < max(local.MinVersion, peer.MinVersion) {
+		return 0, &VersionError{Local: local, Peer: peer}
+	}
+	return version, nil
+}
+
+// Advertise adds capabilities to the hello this side sends. Call it before
+// Run.
+func (m *Mux) Advertise(capabilities ...string) {
+	m.helloMu.Lock()
+	defer m.helloMu.Unlock()
+	for _, capability := range capabilities {
+		if !m.local.has(capability) {
+			m.local.Capabilities = append(m.local.Capabilities, capability)
+		}
+	}
+}
+
+// SetSoftware sets the build version sent in this side's hello.
+func (m *Mux) SetSoftware(version string) {
+	m.helloMu.Lock()
+	defer m.helloMu.Unlock()
+	m.local.Software = version
+}
+
+// Peer returns the peer's hello. Until a hello arrives the peer is taken
+// to speak the original protocol with no capabilities.
+func (m *Mux) Peer() Hello {
+	m.helloMu.Lock()
+	defer m.helloMu.Unlock()
+	return m.peer
+}
+
+// Version returns the negotiated protocol version.
+func (m *Mux) Version() int {
+	m.helloMu.Lock()
+	defer m.helloMu.Unlock()
+	return m.version
+}
+
+// PeerHas reports whether the peer advertised capability.
+func (m *Mux) PeerHas(capability string) bool {
+	return m.Peer().has(capability)
+}
+
+// uses reports whether a mux feature is on for this connection.
+func (m *Mux) uses(capability string) bool {
+	m.helloMu.Lock()
+	defer m.helloMu.Unlock()
+	return m.local.has(capability) && m.peer.has(capability)
+}
+
+// Handshake sends this side's hello and waits for the peer's. An older
+// peer that does not answer the hello is treated as speaking protocol 1
+// without capabilities. It fails with a *VersionError when the two sides
+// share no version. Only the client calls Handshake; the server answers
+// from its read loop.
+func (m *Mux) Handshake(timeout time.Duration) error {
+	m.helloMu.Lock()
+	payload, err := json.Marshal(m.local)
+	m.helloMu.Unlock()
+	if err != nil {
+		return err
+	}
+	probe, err := json.Marshal(StreamOpen{Type: probeStreamType})
+	if err != nil {
+		return err
+	}
+	probeID := m.nextStreamID()
+	m.helloMu.Lock()
+	m.probeID = probeID
+	m.helloMu.Unlock()
+	// A peer that rejects the hello hangs up right after its reply, so a
+	// failed write only matters when no hello arrived.
+	writeErr := m.writeFrame(frameHello, 0, payload)
+	if writeErr == nil {
+		writeErr = m.writeFrame(frameOpen, probeID, probe)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-m.helloDone:
+		m.helloMu.Lock()
+		defer m.helloMu.Unlock()
+		return m.helloErr
+	case <-m.closed:
+		m.helloMu.Lock()
+		defer m.helloMu.Unlock()
+		if m.helloErr != nil {
+			return m.helloErr
+		}
+		if writeErr != nil {
+			return writeErr
+		}
+		return errClosed
+	case <-timer.C:
+		return errors.New("timed out waiting for mux handshake")
+	}
+}
+
+// handleHello records the peer's hello. The server answers with its own
+// and hangs up when there is no common version.
+func (m *Mux) handleHello(payload []byte) {
+	var peer Hello
+	if err := json.Unmarshal(payload, &peer); err != nil {
+		return
+	}
+	m.helloMu.Lock()
+	local := m.local
+	version, err := negotiate(local, peer)
+	m.peer = peer
+	m.version = version
+	m.helloErr = err
+	m.helloMu.Unlock()
+	if !m.client {
+		if reply, marshalErr := json.Marshal(local); marshalErr == nil {
+			_ = m.writeFrame(frameHello, 0, reply)
+		}
+		if err != nil {
+			_ = m.Close()
+		}
+		return
+	}
+	m.helloOnce.Do(func() { close(m.helloDone) })
+}
+
+// handleProbeClose finishes the handshake with an older peer, which closes
+// the probe stream without sending a hello.
+func (m *Mux) handleProbeClose(streamID uint32) bool {
+	m.helloMu.Lock()
+	probe := m.probeID != 0 && streamID == m.probeID
+	if probe {
+		m.probeID = 0
+		select {
+		case <-m.helloDone:
+		default:
+			m.version, m.helloErr = negotiate(m.local, m.peer)
+		}
+	}
+	m.helloMu.Unlock()
+	if probe {
+		m.helloOnce.Do(func() { close(m.helloDone) })
+	}
+	return probe
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// CapKeepalive lets either side send ping frames, which the other side
+// answers from its read loop.
+const CapKeepalive = "keepalive"
+
+// ErrPingUnsupported is returned by Ping when the peer did not negotiate
+// keepalive.
+var ErrPingUnsupported = errors.New("peer does not support mux pings")
+
+var errPingTimeout = errors.New("timed out waiting for mux pong")
+
+// Ping sends a ping and waits up to timeout for the peer's pong.
+func (m *Mux) Ping(timeout time.Duration) error {
+	if !m.uses(CapKeepalive) {
+		return ErrPingUnsupported
+	}
+	pong := make(chan struct{})
+	m.pingMu.Lock()
+	m.pingSeq++
+	seq := m.pingSeq
+	m.pings[seq] = pong
+	m.pingMu.Unlock()
+	defer func() {
+		m.pingMu.Lock()
+		delete(m.pings, seq)
+		m.pingMu.Unlock()
+	}()
+
+	var payload [8]byte
+	binary.BigEndian.PutUint64(payload[:], seq)
+	// The write may block behind a stalled connection, which is exactly
+	// what the timeout has to catch.
+	writeErr := make(chan error, 1)
+	go func() { writeErr <- m.writeFrame(framePing, 0, payload[:]) }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-pong:
+			return nil
+		case err := <-writeErr:
+			if err != nil {
+				return err
+			}
+			writeErr = nil
+		case <-m.closed:
+			return errClosed
+		case <-timer.C:
+			return errPingTimeout
+		}
+	}
+}
+
+// Keepalive pings the peer every interval and closes the mux when a pong
+// does not arrive within the next interval. It does nothing while the peer
+// lacks keepalive support, so it is safe to start before the handshake.
+func (m *Mux) Keepalive(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.closed:
+				return
+			case <-ticker.C:
+			}
+			err := m.Ping(interval)
+			if err == nil || errors.Is(err, ErrPingUnsupported) {
+				continue
+			}
+			_ = m.Close()
+			return
+		}
+	}()
+}
+
+// handlePing answers a ping. The reply is written off the read loop so a
+// stalled writer cannot hold up incoming frames.
+func (m *Mux) handlePing(payload []byte) {
+	if len(payload) != 8 {
+		return
+	}
+	reply := make([]byte, len(payload))
+	copy(reply, payload)
+	go func() { _ = m.writeFrame(framePong, 0, reply) }()
+}
+
+func (m *Mux) handlePong(payload []byte) {
+	if len(payload) != 8 {
+		return
+	}
+	seq := binary.BigEndian.Uint64(payload)
+	m.pingMu.Lock()
+	pong := m.pings[seq]
+	delete(m.pings, seq)
+	m.pingMu.Unlock()
+	if pong != nil {
+		close(pong)
+	}
+}
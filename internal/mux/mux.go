@@ -19,12 +19,27 @@ const (
 	frameData  = 2
 	frameMsg   = 3
 	frameClose = 4
+	// frameWindow grants the sender more data credit; the payload is a
+	// big-endian uint32 byte count.
+	frameWindow = 5
+	// frameCloseWrite half-closes a stream: the sender will write no more
+	// data but keeps reading, and messages still flow both ways.
+	frameCloseWrite = 6
+	// frameHello carries a Hello on stream 0 when a connection starts.
+	frameHello = 7
+	// framePing and framePong carry an 8-byte sequence number on stream 0.
+	framePing = 8
+	framePong = 9
 )
 
 const maxFrameSize = 8 * 1024 * 1024
 
 var errClosed = errors.New("mux closed")
 
+// errOverrun is returned by Read on a stream that was reset because the
+// peer sent more data than it was allowed to queue.
+var errOverrun = errors.New("mux stream overrun by peer")
+
 type StreamOpen struct {
 	Type string          `json:"type"`
 	Meta json.RawMessage `json:"meta,omitempty"`
@@ -43,6 +58,19 @@ type Mux struct {
 	handlers map[string]Handler
 	nextID   uint32
 	closed   chan struct{}
+
+	helloMu   sync.Mutex
+	local     Hello
+	peer      Hello
+	version   int
+	helloErr  error
+	probeID   uint32
+	helloDone chan struct{}
+	helloOnce sync.Once
+
+	pingMu  sync.Mutex
+	pingSeq uint64
+	pings   map[uint64]chan struct{}
 }
 
 func New(conn io.ReadWriteCloser, client bool) *Mux {
@@ -59,6 +87,15 @@ func New(conn io.ReadWriteCloser, client bool) *Mux {
 		handlers: map[string]Handler{},
 		nextID:   start,
 		closed:   make(chan struct{}),
+		local: Hello{
+			Version:      ProtocolVersion,
+			MinVersion:   MinProtocolVersion,
+			Capabilities: []string{CapFlowControl, CapHalfClose, CapKeepalive},
+		},
+		peer:      legacyHello(),
+		version:   legacyVersion,
+		helloDone: make(chan struct{}),
+		pings:     map[uint64]chan struct{}{},
 	}
 }
 
@@ -150,6 +187,16 @@ func (m *Mux) readLoop() {
 			m.handleMsg(streamID, payload)
 		case frameClose:
 			m.handleClose(streamID)
+		case frameWindow:
+			m.handleWindow(streamID, payload)
+		case frameCloseWrite:
+			m.handleCloseWrite(streamID)
+		case frameHello:
+			m.handleHello(payload)
+		case framePing:
+			m.handlePing(payload)
+		case framePong:
+			m.handlePong(payload)
 		}
 	}
 }
@@ -188,6 +235,9 @@ func (m *Mux) handleMsg(streamID uint32, payload []byte) {
 }
 
 func (m *Mux) handleClose(streamID uint32) {
+	if m.client && m.handleProbeClose(streamID) {
+		return
+	}
 	stream := m.lookup(streamID)
 	if stream == nil {
 		return
@@ -198,6 +248,22 @@ func (m *Mux) handleClose(streamID uint32) {
 	m.streamMu.Unlock()
 }
 
+func (m *Mux) handleWindow(streamID uint32, payload []byte) {
+	stream := m.lookup(streamID)
+	if stream == nil || len(payload) != 4 {
+		return
+	}
+	stream.addWindow(int(binary.BigEndian.Uint32(payload)))
+}
+
+func (m *Mux) handleCloseWrite(streamID uint32) {
+	stream := m.lookup(streamID)
+	if stream == nil {
+		return
+	}
+	stream.remoteCloseWrite()
+}
+
 func (m *Mux) lookup(streamID uint32) *Stream {
 	m.streamMu.Lock()
 	defer m.streamMu.Unlock()
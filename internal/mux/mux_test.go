@@ -5,6 +5,8 @@
 package mux
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
 	"net"
 	"testing"
@@ -91,3 +93,398 @@ func TestMuxStreamMessage(t *testing.T) {
 		t.Fatal("timed out waiting for msg")
 	}
 }
+
+func newTestPair(t *testing.T, handlers map[string]Handler) *Mux {
+	t.Helper()
+	left, right := net.Pipe()
+	t.Cleanup(func() {
+		_ = left.Close()
+		_ = right.Close()
+	})
+	server := New(right, false)
+	for name, handler := range handlers {
+		server.Handle(name, handler)
+	}
+	server.Run()
+	client := New(left, true)
+	client.Run()
+	if err := client.Handshake(2 * time.Second); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	return client
+}
+
+func TestMuxSlowStreamDoesNotStallOthers(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan int, 1)
+	client := newTestPair(t, map[string]Handler{
+		"sink": func(stream *Stream, _ StreamOpen) {
+			<-release
+			n, _ := io.Copy(io.Discard, stream)
+			received <- int(n)
+		},
+		"echo": func(stream *Stream, _ StreamOpen) {
+			defer stream.Close()
+			_, _ = io.Copy(stream, stream)
+		},
+	})
+
+	sink, err := client.OpenStream("sink", nil)
+	if err != nil {
+		t.Fatalf("open sink: %v", err)
+	}
+	const total = 4 * initialWindow
+	wrote := make(chan error, 1)
+	go func() {
+		_, err := sink.Write(make([]byte, total))
+		wrote <- err
+	}()
+
+	echo, err := client.OpenStream("echo", nil)
+	if err != nil {
+		t.Fatalf("open echo: %v", err)
+	}
+	defer echo.Close()
+	readDone := make(chan error, 1)
+	go func() {
+		if _, err := echo.Write([]byte("ping")); err != nil {
+			readDone <- err
+			return
+		}
+		buf := make([]byte, 4)
+		_, err := io.ReadFull(echo, buf)
+		readDone <- err
+	}()
+	select {
+	case err := <-readDone:
+		if err != nil {
+			t.Fatalf("echo: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("echo stream stalled behind the unread stream")
+	}
+	select {
+	case <-wrote:
+		t.Fatal("expected writer to wait for window updates")
+	default:
+	}
+
+	close(release)
+	select {
+	case err := <-wrote:
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer never got more window")
+	}
+	_ = sink.Close()
+	if n := <-received; n != total {
+		t.Fatalf("expected %d bytes, got %d", total, n)
+	}
+}
+
+func TestStreamResetWhenPeerOverruns(t *testing.T) {
+	for _, flow := range []bool{true, false} {
+		left, right := net.Pipe()
+		go func() { _, _ = io.Copy(io.Discard, right) }()
+		stream := newStream(New(left, true), 1)
+		stream.flow = flow
+		limit := maxUnflowedBuffer
+		if flow {
+			limit = initialWindow
+		}
+		chunk := make([]byte, maxDataFrame)
+		for queued := 0; queued < limit; queued += len(chunk) {
+			stream.pushData(chunk)
+		}
+		select {
+		case <-stream.Done():
+			t.Fatalf("flow=%v: stream reset within the limit", flow)
+		default:
+		}
+		stream.pushData([]byte{0})
+		select {
+		case <-stream.Done():
+		default:
+			t.Fatalf("flow=%v: expected the stream to be reset", flow)
+		}
+		if _, err := stream.Read(make([]byte, 1)); !errors.Is(err, errOverrun) {
+			t.Fatalf("flow=%v: expected overrun error, got %v", flow, err)
+		}
+		left.Close()
+		right.Close()
+	}
+}
+
+func TestStreamResetWhenPeerQueuesTooManyMessages(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+	go func() { _, _ = io.Copy(io.Discard, right) }()
+	stream := newStream(New(left, true), 1)
+	msg := make([]byte, maxFrameSize)
+	stream.pushMsg(msg)
+	stream.pushMsg(msg)
+	if got, err := stream.ReceiveMsg(); err != nil || len(got) != maxFrameSize {
+		t.Fatalf("receive: %d %v", len(got), err)
+	}
+	// Reading made room again.
+	stream.pushMsg(msg)
+	select {
+	case <-stream.Done():
+		t.Fatal("stream reset within the limit")
+	default:
+	}
+	stream.pushMsg([]byte{0})
+	select {
+	case <-stream.Done():
+	default:
+		t.Fatal("expected the stream to be reset")
+	}
+	if _, err := stream.ReceiveMsg(); !errors.Is(err, errOverrun) {
+		t.Fatalf("expected overrun error, got %v", err)
+	}
+}
+
+func TestMuxStreamCloseWrite(t *testing.T) {
+	client := newTestPair(t, map[string]Handler{
+		"upper": func(stream *Stream, _ StreamOpen) {
+			defer stream.Close()
+			data, err := io.ReadAll(stream)
+			if err != nil {
+				return
+			}
+			// Messages still arrive after the half-close.
+			msg, err := stream.ReceiveMsg()
+			if err != nil {
+				return
+			}
+			_, _ = stream.Write(append([]byte("got:"), append(data, msg...)...))
+		},
+	})
+	stream, err := client.OpenStream("upper", nil)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Close()
+	if _, err := stream.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		t.Fatalf("close write: %v", err)
+	}
+	if _, err := stream.Write([]byte("late")); err == nil {
+		t.Fatalf("expected write after CloseWrite to fail")
+	}
+	if err := stream.SendMsg([]byte("+msg")); err != nil {
+		t.Fatalf("send message after CloseWrite: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		data, err := io.ReadAll(stream)
+		if err != nil {
+			t.Errorf("read: %v", err)
+			return
+		}
+		if string(data) != "got:ping+msg" {
+			t.Errorf("unexpected reply: %q", data)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for reply after half-close")
+	}
+}
+
+func TestPipePassesHalfClose(t *testing.T) {
+	client := newTestPair(t, map[string]Handler{
+		"forward": func(stream *Stream, _ StreamOpen) {
+			defer stream.Close()
+			data, err := io.ReadAll(stream)
+			if err != nil {
+				return
+			}
+			_, _ = stream.Write(data)
+		},
+	})
+	stream, err := client.OpenStream("forward", nil)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		Pipe(stream, conn)
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatalf("close write: %v", err)
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(reply) != "hello" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestHandshakeNegotiatesCapabilities(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	server := New(right, false)
+	server.SetSoftware("v1.2.3")
+	server.Advertise("exec")
+	server.Run()
+	client := New(left, true)
+	client.Run()
+	if err := client.Handshake(2 * time.Second); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if client.Version() != ProtocolVersion {
+		t.Fatalf("expected version %d, got %d", ProtocolVersion, client.Version())
+	}
+	if peer := client.Peer(); peer.Software != "v1.2.3" || !client.PeerHas("exec") {
+		t.Fatalf("unexpected peer hello: %+v", peer)
+	}
+	if !client.uses(CapFlowControl) || !client.uses(CapHalfClose) {
+		t.Fatalf("expected flow control and half-close to be on")
+	}
+	if !server.uses(CapFlowControl) || server.Version() != ProtocolVersion {
+		t.Fatalf("expected server to record the client hello")
+	}
+}
+
+func TestHandshakeWithLegacyPeer(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	// A peer from before the handshake ignores the hello and closes
+	// streams it has no handler for.
+	legacy := New(right, false)
+	go func() {
+		for {
+			frameType, streamID, _, err := legacy.readFrame()
+			if err != nil {
+				return
+			}
+			if frameType == frameOpen {
+				_ = legacy.writeFrame(frameClose, streamID, nil)
+			}
+		}
+	}()
+	client := New(left, true)
+	client.Run()
+	if err := client.Handshake(2 * time.Second); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if client.Version() != legacyVersion || client.PeerHas(CapFlowControl) {
+		t.Fatalf("expected legacy peer, got version %d hello %+v", client.Version(), client.Peer())
+	}
+	stream, err := client.OpenStream("echo", nil)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	if stream.flow {
+		t.Fatalf("expected flow control off with a legacy peer")
+	}
+	if err := client.Ping(time.Second); !errors.Is(err, ErrPingUnsupported) {
+		t.Fatalf("expected pings to be unsupported, got %v", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		t.Fatalf("close write: %v", err)
+	}
+	select {
+	case <-stream.Done():
+	default:
+		t.Fatalf("expected CloseWrite to fall back to Close")
+	}
+}
+
+func TestHandshakeNoCommonVersion(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	server := New(right, false)
+	server.local.Version = ProtocolVersion + 2
+	server.local.MinVersion = ProtocolVersion + 1
+	server.Run()
+	client := New(left, true)
+	client.Run()
+	err := client.Handshake(2 * time.Second)
+	var versionErr *VersionError
+	if !errors.As(err, &versionErr) {
+		t.Fatalf("expected version error, got %v", err)
+	}
+	if versionErr.PeerOlder() {
+		t.Fatalf("expected the peer to be newer: %v", versionErr)
+	}
+	select {
+	case <-server.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected server to hang up")
+	}
+}
+
+func TestPing(t *testing.T) {
+	client := newTestPair(t, nil)
+	if err := client.Ping(2 * time.Second); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+}
+
+func TestKeepaliveClosesStalledConnection(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	// The peer answers the hello and then stops reading, like a link that
+	// went away without a reset.
+	peer := New(right, false)
+	go func() {
+		// Read the hello and the probe open that follows it.
+		for range 2 {
+			if _, _, _, err := peer.readFrame(); err != nil {
+				return
+			}
+		}
+		reply, _ := json.Marshal(peer.local)
+		_ = peer.writeFrame(frameHello, 0, reply)
+	}()
+	client := New(left, true)
+	client.Run()
+	if err := client.Handshake(2 * time.Second); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	client.Keepalive(50 * time.Millisecond)
+	select {
+	case <-client.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected keepalive to close the mux")
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mux
+
+import "io"
+
+// Pipe copies between stream and conn in both directions. EOF on either
+// side is passed on as a half-close, so the other direction keeps flowing
+// until it ends too or the stream is closed outright. The caller closes
+// both when Pipe returns.
+func Pipe(stream *Stream, conn io.ReadWriter) {
+	toConn := make(chan struct{})
+	go func() {
+		defer close(toConn)
+		_, _ = io.Copy(conn, stream)
+		if half, ok := conn.(interface{ CloseWrite() error }); ok {
+			_ = half.CloseWrite()
+		}
+	}()
+	toStream := make(chan struct{})
+	go func() {
+		defer close(toStream)
+		_, _ = io.Copy(stream, conn)
+		_ = stream.CloseWrite()
+	}()
+	select {
+	case <-toStream:
+	case <-stream.Done():
+	}
+	<-toConn
+}
@@ -6,76 +6,153 @@ package mux
 
 import (
 	"bytes"
+	"encoding/binary"
 	"io"
 	"sync"
 )
 
+const (
+	// initialWindow is how many data bytes a peer may send on a stream
+	// before it has to wait for a window update.
+	initialWindow = 256 * 1024
+	// windowUpdateThreshold is how many bytes a reader consumes before it
+	// hands the credit back to the sender.
+	windowUpdateThreshold = initialWindow / 2
+	// maxDataFrame caps a single data frame so one busy stream cannot hold
+	// the connection for long.
+	maxDataFrame = 32 * 1024
+	// maxUnflowedBuffer caps the unread data queued for a stream whose
+	// peer does not do flow control.
+	maxUnflowedBuffer = 4 * 1024 * 1024
+	// maxQueuedMsgBytes caps the unread messages queued for a stream.
+	// Messages carry control traffic and have no window, so this leaves
+	// room for two of the largest frames.
+	maxQueuedMsgBytes = 2 * maxFrameSize
+)
+
+// Stream is one logical connection on a Mux. Received data and messages
+// are queued on the stream, so a slow reader only stalls its own sender:
+// data is bounded by the send window the reader hands back as it reads,
+// and a peer that sends past it, or queues too many unread messages, has
+// the stream reset.
 type Stream struct {
 	mux     *Mux
 	id      uint32
-	dataCh  chan []byte
-	msgCh   chan []byte
 	closeCh chan struct{}
 	once    sync.Once
-	mu      sync.Mutex
-	buf     bytes.Buffer
-	closed  bool
+	// writeMu orders data frames ahead of the half-close frame.
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	msgs [][]byte
+	// msgBytes is the size of the queued messages.
+	msgBytes int
+	// closed is set when either side closes the stream outright.
+	closed bool
+	// overrun is set when the stream was reset for receiving too much
+	// data or too many messages.
+	overrun bool
+	// readClosed is set when the peer half-closes: no more data will
+	// arrive, but messages still may and this side may still write.
+	readClosed bool
+	// writeClosed is set after CloseWrite.
+	writeClosed bool
+	// flow is set when both sides negotiated flow control. Without it
+	// writes never wait and no window updates are sent.
+	flow       bool
+	sendWindow int
+	consumed   int
 }
 
 func newStream(m *Mux, id uint32) *Stream {
-	return &Stream{
-		mux:     m,
-		id:      id,
-		dataCh:  make(chan []byte, 8),
-		msgCh:   make(chan []byte, 8),
-		closeCh: make(chan struct{}),
+	s := &Stream{
+		mux:        m,
+		id:         id,
+		closeCh:    make(chan struct{}),
+		flow:       m.uses(CapFlowControl),
+		sendWindow: initialWindow,
 	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
 }
 
 func (s *Stream) ID() uint32 {
 	return s.id
 }
 
+// Done is closed once the stream is closed by either side.
+func (s *Stream) Done() <-chan struct{} {
+	return s.closeCh
+}
+
+// Read returns queued data, then io.EOF once the peer has closed or
+// half-closed the stream.
 func (s *Stream) Read(p []byte) (int, error) {
-	for {
-		s.mu.Lock()
-		if s.buf.Len() > 0 {
-			n, err := s.buf.Read(p)
-			s.mu.Unlock()
-			return n, err
-		}
-		if s.closed {
-			s.mu.Unlock()
-			return 0, io.EOF
-		}
+	s.mu.Lock()
+	for s.buf.Len() == 0 && !s.closed && !s.readClosed {
+		s.cond.Wait()
+	}
+	if s.buf.Len() == 0 {
+		overrun := s.overrun
 		s.mu.Unlock()
-		select {
-		case chunk, ok := <-s.dataCh:
-			if !ok {
-				return 0, io.EOF
-			}
-			s.mu.Lock()
-			_, _ = s.buf.Write(chunk)
-			s.mu.Unlock()
-		case <-s.closeCh:
-			return 0, io.EOF
+		if overrun {
+			return 0, errOverrun
 		}
+		return 0, io.EOF
+	}
+	n, _ := s.buf.Read(p)
+	s.consumed += n
+	credit := 0
+	if s.flow && s.consumed >= windowUpdateThreshold && !s.closed && !s.readClosed {
+		credit = s.consumed
+		s.consumed = 0
 	}
+	s.mu.Unlock()
+	if credit > 0 {
+		var payload [4]byte
+		binary.BigEndian.PutUint32(payload[:], uint32(credit))
+		_ = s.mux.writeFrame(frameWindow, s.id, payload[:])
+	}
+	return n, nil
 }
 
+// Write sends p as data frames, waiting for window updates when the peer
+// has not read what was already sent.
 func (s *Stream) Write(p []byte) (int, error) {
 	if len(p) == 0 {
 		return 0, nil
 	}
-	if s.isClosed() {
-		return 0, errClosed
-	}
-	if err := s.mux.writeFrame(frameData, s.id, p); err != nil {
-		return 0, err
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		s.mu.Lock()
+		for s.flow && s.sendWindow <= 0 && !s.closed && !s.writeClosed {
+			s.cond.Wait()
+		}
+		if s.closed || s.writeClosed {
+			s.mu.Unlock()
+			return written, errClosed
+		}
+		n := min(len(p), maxDataFrame)
+		if s.flow {
+			n = min(n, s.sendWindow)
+			s.sendWindow -= n
+		}
+		s.mu.Unlock()
+		if err := s.mux.writeFrame(frameData, s.id, p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
 	}
-	return len(p), nil
+	return written, nil
 }
 
+// SendMsg sends p as one message. Messages are not part of the data, so
+// they may still be sent after CloseWrite.
 func (s *Stream) SendMsg(p []byte) error {
 	if s.isClosed() {
 		return errClosed
@@ -83,66 +160,137 @@ func (s *Stream) SendMsg(p []byte) error {
 	return s.mux.writeFrame(frameMsg, s.id, p)
 }
 
+// ReceiveMsg returns the next queued message, then io.EOF once the stream
+// is closed. A half-close ends only the data, so messages keep arriving
+// after it.
 func (s *Stream) ReceiveMsg() ([]byte, error) {
-	select {
-	case msg, ok := <-s.msgCh:
-		if !ok {
-			return nil, io.EOF
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.msgs) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.msgs) == 0 {
+		if s.overrun {
+			return nil, errOverrun
 		}
-		return msg, nil
-	case <-s.closeCh:
 		return nil, io.EOF
 	}
+	msg := s.msgs[0]
+	s.msgs[0] = nil
+	s.msgs = s.msgs[1:]
+	s.msgBytes -= len(msg)
+	return msg, nil
+}
+
+// CloseWrite half-closes the stream: the peer reads io.EOF after the data
+// already sent, and this side can keep reading until the peer closes too.
+// Messages are unaffected in both directions.
+// A peer without half-close support gets a full Close instead.
+func (s *Stream) CloseWrite() error {
+	if !s.mux.uses(CapHalfClose) {
+		return s.Close()
+	}
+	s.mu.Lock()
+	if s.closed || s.writeClosed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.writeClosed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.mux.writeFrame(frameCloseWrite, s.id, nil)
 }
 
 func (s *Stream) Close() error {
-	var err error
 	s.once.Do(func() {
 		_ = s.mux.writeFrame(frameClose, s.id, nil)
-		close(s.closeCh)
-		s.mu.Lock()
-		s.closed = true
-		s.mu.Unlock()
-		close(s.dataCh)
-		close(s.msgCh)
+		s.markClosed()
 		s.mux.remove(s.id)
 	})
-	return err
+	return nil
 }
 
+// pushData queues payload for Read. With flow control the peer may have
+// at most initialWindow bytes unread or not yet credited back; without it
+// the queue is capped at maxUnflowedBuffer. A peer that goes past either
+// has the stream reset instead of growing the queue without bound.
 func (s *Stream) pushData(payload []byte) {
-	if s.isClosed() {
+	s.mu.Lock()
+	if s.closed || s.readClosed {
+		s.mu.Unlock()
 		return
 	}
-	buf := make([]byte, len(payload))
-	copy(buf, payload)
-	select {
-	case s.dataCh <- buf:
-	case <-s.closeCh:
+	queued, limit := s.buf.Len(), maxUnflowedBuffer
+	if s.flow {
+		queued, limit = queued+s.consumed, initialWindow
 	}
+	if queued+len(payload) > limit {
+		s.resetOverrun()
+		return
+	}
+	_, _ = s.buf.Write(payload)
+	s.cond.Broadcast()
+	s.mu.Unlock()
 }
 
+// pushMsg queues a message for ReceiveMsg. Messages have no window, so a
+// peer that queues more than maxQueuedMsgBytes unread has the stream
+// reset, as pushData does.
 func (s *Stream) pushMsg(payload []byte) {
-	if s.isClosed() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	if s.msgBytes+len(payload) > maxQueuedMsgBytes {
+		s.resetOverrun()
 		return
 	}
 	buf := make([]byte, len(payload))
 	copy(buf, payload)
-	select {
-	case s.msgCh <- buf:
-	case <-s.closeCh:
-	}
+	s.msgs = append(s.msgs, buf)
+	s.msgBytes += len(buf)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// resetOverrun drops everything queued and closes the stream. It is called
+// with s.mu held and releases it.
+func (s *Stream) resetOverrun() {
+	s.overrun = true
+	s.buf.Reset()
+	s.msgs = nil
+	s.msgBytes = 0
+	s.mu.Unlock()
+	_ = s.Close()
+}
+
+func (s *Stream) addWindow(credit int) {
+	s.mu.Lock()
+	s.sendWindow += credit
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *Stream) remoteCloseWrite() {
+	s.mu.Lock()
+	s.readClosed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
 }
 
 func (s *Stream) remoteClose() {
-	s.once.Do(func() {
-		close(s.closeCh)
-		s.mu.Lock()
-		s.closed = true
-		s.mu.Unlock()
-		close(s.dataCh)
-		close(s.msgCh)
-	})
+	s.once.Do(s.markClosed)
+}
+
+func (s *Stream) markClosed() {
+	close(s.closeCh)
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
 }
 
 func (s *Stream) isClosed() bool {
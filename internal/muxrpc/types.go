@@ -46,6 +46,49 @@ type PtyMeta struct {
 	Env    map[string]string `json:"env,omitempty"`
 }
 
+// CapExecStream is advertised by gateways that serve "exec" streams.
+const CapExecStream = "exec-stream"
+
+// ExecMeta opens an exec stream. Args run on the host, or inside the app's
+// container when App is set. Stdin and stdout are stream data, and the
+// client half-closes the stream to close stdin. Everything else is
+// messages: ExecInput from the client, ExecEvent from the gateway.
+type ExecMeta struct {
+	App  string            `json:"app,omitempty"`
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env,omitempty"`
+	// TTY runs the command on a pseudo-terminal, which merges stderr into
+	// stdout. Rows and Cols set its initial size.
+	TTY  bool `json:"tty,omitempty"`
+	Rows int  `json:"rows,omitempty"`
+	Cols int  `json:"cols,omitempty"`
+}
+
+// ExecInput is a client message on an exec stream.
+type ExecInput struct {
+	// Signal is a name such as INT, TERM, or HUP.
+	Signal string `json:"signal,omitempty"`
+	Rows   int    `json:"rows,omitempty"`
+	Cols   int    `json:"cols,omitempty"`
+}
+
+// ExecEvent is a gateway message on an exec stream. Stderr comes in
+// chunks of at most 32 KiB. Exit is the last event; the
+// gateway closes the stream after sending it.
+type ExecEvent struct {
+	Stderr []byte    `json:"stderr,omitempty"`
+	Exit   *ExecExit `json:"exit,omitempty"`
+}
+
+// ExecExit reports how the command ended. Code follows shell conventions:
+// 128+n when killed by signal n and 127 when the command could not start,
+// with Error saying why.
+type ExecExit struct {
+	Code   int    `json:"code"`
+	Signal string `json:"signal,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
 type ForwardMeta struct {
 	Host string `json:"host,omitempty"`
 	Port int    `json:"port"`
@@ -58,10 +101,64 @@ type UploadMeta struct {
 	Mode      int    `json:"mode,omitempty"`
 	Size      int64  `json:"size,omitempty"`
 	Sized     bool   `json:"sized,omitempty"`
+	// App and Name apply to the "app" target, which copies a tree into the
+	// app container. Name is the base name of the local source, used when
+	// Path is an existing directory.
+	App  string `json:"app,omitempty"`
+	Name string `json:"name,omitempty"`
 }
 
+// UploadResult ends an upload stream. For "app" uploads it also counts
+// what was written.
 type UploadResult struct {
 	Error string `json:"error,omitempty"`
+	Files int    `json:"files,omitempty"`
+	Bytes int64  `json:"bytes,omitempty"`
+}
+
+type DownloadMeta struct {
+	Target string `json:"target"`
+	Path   string `json:"path"`
+	// App selects the container for the "app" target, which sends Path as
+	// a tar archive after the client's CopyManifest.
+	App string `json:"app,omitempty"`
+}
+
+// DownloadInfo is the first message on a download stream. Size bytes of
+// data follow unless Error is set; the client closes the stream when done.
+// For "app" downloads the data is a tar archive holding Files files of
+// Size bytes in total, plus every directory and symlink.
+type DownloadInfo struct {
+	Size    int64  `json:"size,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Files   int    `json:"files,omitempty"`
+	Skipped int    `json:"skipped,omitempty"`
+	Dir     bool   `json:"dir,omitempty"`
+}
+
+// CapCopyStream is advertised by gateways that serve "app" uploads and
+// downloads.
+const CapCopyStream = "copy-stream"
+
+// CopyEntry describes one path of a copied tree. Path is relative to the
+// tree's root, which is ".", and uses forward slashes.
+type CopyEntry struct {
+	Path string `json:"path"`
+	Mode uint32 `json:"mode"`
+	Size int64  `json:"size,omitempty"`
+	// SHA256 is the hex digest of a regular file's content.
+	SHA256 string `json:"sha256,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// CopyManifest lists what already exists at a copy's destination so the
+// sender can skip unchanged files. The receiver sends it first: the client
+// on app downloads and the gateway on app uploads. Large manifests span
+// several messages, all but the last with More set.
+type CopyManifest struct {
+	Entries []CopyEntry `json:"entries,omitempty"`
+	More    bool        `json:"more,omitempty"`
+	Error   string      `json:"error,omitempty"`
 }
 
 type OpenEvent struct {
@@ -75,9 +172,15 @@ type ResizeEvent struct {
 	Cols int `json:"cols"`
 }
 
+// DiskWarnPercent is the home volume usage at which clients warn.
+const DiskWarnPercent = 90
+
 type AppSnapshot struct {
 	Name string `json:"name"`
 	Port int    `json:"port,omitempty"`
+	// DiskUsedPercent is how full the app's home volume is; zero when the
+	// volume is not mounted.
+	DiskUsedPercent int `json:"disk_used_percent,omitempty"`
 }
 
 type AppsEvent struct {
@@ -0,0 +1,243 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oidc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shayne/viberun/internal/oidc/oidctest"
+)
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	issuer, err := oidctest.NewIssuer("viberun", "s3cret")
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	defer issuer.Close()
+	issuer.SetClaims(map[string]any{"sub": "42", "email": "alice@example.com", "email_verified": true, "groups": []string{"team-a", "admin"}})
+
+	ctx := context.Background()
+	provider, err := Discover(ctx, nil, issuer.URL(), "viberun", "s3cret")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatalf("NewPKCE: %v", err)
+	}
+	redirectURL := "https://app.example.com/callback"
+	authURL := provider.AuthCodeURL(redirectURL, nil, "state-1", "nonce-1", challenge)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Query().Get("state") != "state-1" {
+		t.Fatalf("unexpected callback %q", resp.Header.Get("Location"))
+	}
+
+	if _, err := provider.Exchange(ctx, callback.Query().Get("code"), redirectURL, "wrong-verifier"); err == nil {
+		t.Fatalf("expected exchange with the wrong verifier to fail")
+	}
+	resp, err = client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, _ = url.Parse(resp.Header.Get("Location"))
+	raw, err := provider.Exchange(ctx, callback.Query().Get("code"), redirectURL, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.Verify(ctx, raw, "nonce-1", time.Now())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.String("email") != "alice@example.com" || len(claims.Strings("groups")) != 2 || !claims.EmailVerified(false) {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+	if _, err := provider.Verify(ctx, raw, "other-nonce", time.Now()); err == nil {
+		t.Fatalf("expected nonce mismatch to fail")
+	}
+	if _, err := provider.Verify(ctx, raw, "nonce-1", time.Now().Add(time.Hour)); err == nil {
+		t.Fatalf("expected expired token to fail")
+	}
+}
+
+func TestVerifyRejectsForeignTokens(t *testing.T) {
+	issuer, err := oidctest.NewIssuer("viberun", "")
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), nil, issuer.URL(), "viberun", "")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	exp := time.Now().Add(time.Minute).Unix()
+	cases := map[string]map[string]any{
+		"audience": {"iss": issuer.URL(), "aud": "someone-else", "sub": "1", "exp": exp},
+		"issuer":   {"iss": "https://evil.example.com", "aud": "viberun", "sub": "1", "exp": exp},
+		"subject":  {"iss": issuer.URL(), "aud": "viberun", "exp": exp},
+	}
+	for name, claims := range cases {
+		raw, err := issuer.SignIDToken(claims)
+		if err != nil {
+			t.Fatalf("SignIDToken: %v", err)
+		}
+		if _, err := provider.Verify(context.Background(), raw, "", time.Now()); err == nil {
+			t.Fatalf("%s: expected token to be rejected", name)
+		}
+	}
+	other, err := oidctest.NewIssuer("viberun", "")
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	defer other.Close()
+	forged, _ := other.SignIDToken(map[string]any{"iss": issuer.URL(), "aud": "viberun", "sub": "1", "exp": exp})
+	if _, err := provider.Verify(context.Background(), forged, "", time.Now()); err == nil {
+		t.Fatalf("expected token signed by another key to be rejected")
+	}
+}
+
+// unsignedToken builds a token with an arbitrary header and signature, as
+// an attacker could.
+func unsignedToken(t *testing.T, header map[string]string, claims map[string]any, sign func(string) []byte) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(signed))
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	issuer, err := oidctest.NewIssuer("viberun", "")
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	defer issuer.Close()
+	ctx := context.Background()
+	provider, err := Discover(ctx, nil, issuer.URL(), "viberun", "")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	now := time.Now()
+	claims := func(overrides map[string]any) map[string]any {
+		out := map[string]any{"iss": issuer.URL(), "aud": "viberun", "sub": "1", "nonce": "n", "exp": now.Add(5 * time.Minute).Unix()}
+		for k, v := range overrides {
+			if v == nil {
+				delete(out, k)
+				continue
+			}
+			out[k] = v
+		}
+		return out
+	}
+	sign := func(header map[string]string, overrides map[string]any) string {
+		raw, err := issuer.SignIDTokenWithHeader(header, claims(overrides))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return raw
+	}
+	rs256 := map[string]string{"alg": "RS256", "kid": "test-key"}
+	if _, err := provider.Verify(ctx, sign(rs256, nil), "n", now); err != nil {
+		t.Fatalf("expected baseline token to verify: %v", err)
+	}
+
+	// The verifier must never treat the RSA public key as an HMAC secret.
+	jwks, err := http.Get(issuer.URL() + "/jwks")
+	if err != nil {
+		t.Fatalf("jwks: %v", err)
+	}
+	var set struct {
+		Keys []struct {
+			N string `json:"n"`
+		} `json:"keys"`
+	}
+	_ = json.NewDecoder(jwks.Body).Decode(&set)
+	jwks.Body.Close()
+	hmacWithPublicKey := func(signed string) []byte {
+		mac := hmac.New(sha256.New, []byte(set.Keys[0].N))
+		mac.Write([]byte(signed))
+		return mac.Sum(nil)
+	}
+
+	cases := map[string]string{
+		"alg none":              unsignedToken(t, map[string]string{"alg": "none", "kid": "test-key"}, claims(nil), func(string) []byte { return nil }),
+		"alg none uppercase":    unsignedToken(t, map[string]string{"alg": "NONE"}, claims(nil), func(string) []byte { return nil }),
+		"HS256 with public key": unsignedToken(t, map[string]string{"alg": "HS256", "kid": "test-key"}, claims(nil), hmacWithPublicKey),
+		"ES256 header on RSA":   sign(map[string]string{"alg": "ES256", "kid": "test-key"}, nil),
+		"RS384 header":          sign(map[string]string{"alg": "RS384", "kid": "test-key"}, nil),
+		"missing alg":           sign(map[string]string{"kid": "test-key"}, nil),
+		"unknown kid":           sign(map[string]string{"alg": "RS256", "kid": "other"}, nil),
+		"wrong audience":        sign(rs256, map[string]any{"aud": "someone-else"}),
+		"audience list":         sign(rs256, map[string]any{"aud": []string{"a", "b"}}),
+		"missing audience":      sign(rs256, map[string]any{"aud": nil}),
+		"wrong issuer":          sign(rs256, map[string]any{"iss": issuer.URL() + ".evil.example"}),
+		"missing issuer":        sign(rs256, map[string]any{"iss": nil}),
+		"expired":               sign(rs256, map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}),
+		"missing exp":           sign(rs256, map[string]any{"exp": nil}),
+		"string exp":            sign(rs256, map[string]any{"exp": "9999999999"}),
+		"not yet valid":         sign(rs256, map[string]any{"nbf": now.Add(2 * time.Minute).Unix()}),
+		"missing nonce":         sign(rs256, map[string]any{"nonce": nil}),
+		"tampered":              strings.Replace(sign(rs256, nil), ".", ".e30", 1),
+		"two segments":          strings.Join(strings.Split(sign(rs256, nil), ".")[:2], "."),
+		"bad signature base64":  sign(rs256, nil) + "!",
+	}
+	for name, raw := range cases {
+		if _, err := provider.Verify(ctx, raw, "n", now); err == nil {
+			t.Fatalf("%s: expected token to be rejected", name)
+		}
+	}
+
+	within := map[string]map[string]any{
+		"audience list with client": {"aud": []string{"other", "viberun"}},
+		"nbf within skew":           {"nbf": now.Add(30 * time.Second).Unix()},
+		"exp within skew":           {"exp": now.Add(-30 * time.Second).Unix()},
+	}
+	for name, overrides := range within {
+		if _, err := provider.Verify(ctx, sign(rs256, overrides), "n", now); err != nil {
+			t.Fatalf("%s: expected token to verify: %v", name, err)
+		}
+	}
+}
+
+func TestEmailVerified(t *testing.T) {
+	cases := []struct {
+		value         any
+		assumeMissing bool
+		want          bool
+	}{
+		{true, false, true},
+		{"true", false, true},
+		{false, true, false},
+		{"false", true, false},
+		{"yes", true, false},
+		{1.0, true, false},
+		{nil, false, false},
+		{nil, true, true},
+	}
+	for _, tc := range cases {
+		claims := Claims{}
+		if tc.value != nil {
+			claims["email_verified"] = tc.value
+		}
+		if got := claims.EmailVerified(tc.assumeMissing); got != tc.want {
+			t.Fatalf("EmailVerified(%v) with %#v = %v, want %v", tc.assumeMissing, tc.value, got, tc.want)
+		}
+	}
+}
@@ -36,7 +36,10 @@ func EffectiveAppAccess(cfg Config, app string) AppAccess {
 		appCfg.Access = cfg.DefaultAccess
 	}
 	appCfg.AllowedUsers = normalizeUserList(appCfg.AllowedUsers)
+	appCfg.AllowedGroups = normalizeGroupList(appCfg.AllowedGroups)
 	appCfg.CustomDomain = strings.TrimSpace(appCfg.CustomDomain)
+	appCfg.AllowIPs = normalizeCIDRList(appCfg.AllowIPs)
+	appCfg.DenyIPs = normalizeCIDRList(appCfg.DenyIPs)
 	return appCfg
 }
 
@@ -51,6 +54,23 @@ func EffectiveAllowedUsers(cfg Config, app string) []string {
 	return users
 }
 
+// UserCanAccess reports whether user may open a private app, either by name
+// or through membership in one of the app's allowed groups.
+func UserCanAccess(cfg Config, app string, user AuthUser) bool {
+	for _, allowed := range EffectiveAllowedUsers(cfg, app) {
+		if allowed == user.Username {
+			return true
+		}
+	}
+	groups := normalizeGroupList(user.Groups)
+	for _, group := range EffectiveAppAccess(cfg, app).AllowedGroups {
+		if hasGroup(groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
 func PublicHostForApp(cfg Config, app string) string {
 	access := EffectiveAppAccess(cfg, app)
 	if access.Disabled {
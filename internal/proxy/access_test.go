@@ -17,6 +17,31 @@ func TestEffectiveAllowedUsersIncludesPrimary(t *testing.T) {
 	}
 }
 
+func TestUserCanAccess(t *testing.T) {
+	cfg := Config{PrimaryUser: "owner", DefaultAccess: AccessPrivate}
+	cfg.Apps = map[string]AppAccess{
+		"app": {AllowedUsers: []string{"alice"}, AllowedGroups: []string{"team-a"}},
+	}
+	cases := map[string]struct {
+		user AuthUser
+		want bool
+	}{
+		"primary":      {AuthUser{Username: "owner"}, true},
+		"listed":       {AuthUser{Username: "alice"}, true},
+		"group member": {AuthUser{Username: "bob", Groups: []string{"viewer", "team-a"}}, true},
+		"other group":  {AuthUser{Username: "carol", Groups: []string{"viewer"}}, false},
+		"no groups":    {AuthUser{Username: "dave"}, false},
+	}
+	for name, tc := range cases {
+		if got := UserCanAccess(cfg, "app", tc.user); got != tc.want {
+			t.Fatalf("%s: UserCanAccess() = %v, want %v", name, got, tc.want)
+		}
+	}
+	if UserCanAccess(cfg, "other", AuthUser{Username: "bob", Groups: []string{"team-a"}}) {
+		t.Fatalf("group access must not leak to other apps")
+	}
+}
+
 func TestPublicHostForApp(t *testing.T) {
 	cfg := Config{BaseDomain: "example.com", DefaultAccess: AccessPrivate}
 	if got := PublicHostForApp(cfg, "demo"); got != "demo.example.com" {
@@ -25,7 +25,7 @@ func TestBuildCaddyConfig(t *testing.T) {
 		"app":  {Access: AccessPrivate},
 		"zeta": {Access: AccessPublic},
 	}
-	data, err := BuildCaddyConfig(cfg, ports)
+	data, err := BuildCaddyConfig(cfg, ports, nil)
 	if err != nil {
 		t.Fatalf("BuildCaddyConfig: %v", err)
 	}
@@ -55,3 +55,35 @@ func TestBuildCaddyConfig(t *testing.T) {
 		t.Fatalf("did not expect authorize handler in config")
 	}
 }
+
+func TestBuildCaddyConfigIPRules(t *testing.T) {
+	cfg := Config{
+		BaseDomain: "example.com",
+		Auth:       AuthConfig{SigningKey: "test-key"},
+		Users:      []AuthUser{{Username: "primary", Password: "$2a$10$hash"}},
+		Apps: map[string]AppAccess{
+			"office": {Access: AccessPublic, AllowIPs: []string{"203.0.113.0/24"}, DenyIPs: []string{"203.0.113.9/32"}},
+			"open":   {Access: AccessPublic},
+		},
+	}
+	data, err := BuildCaddyConfig(cfg, map[string]int{"office": 8080, "open": 9000}, nil)
+	if err != nil {
+		t.Fatalf("BuildCaddyConfig: %v", err)
+	}
+	text := string(data.Body)
+	office := text[strings.Index(text, "office.example.com {"):strings.Index(text, "open.example.com {")]
+	for _, want := range []string{
+		"@viberun_auth {\n    path " + authPathPrefix + "/*\n    not remote_ip 203.0.113.9/32\n    remote_ip 203.0.113.0/24\n  }",
+		"@viberun_app {\n    not remote_ip 203.0.113.9/32\n    remote_ip 203.0.113.0/24\n  }",
+		"handle @viberun_auth {",
+		"handle @viberun_app {\n    reverse_proxy 127.0.0.1:8080",
+		"handle {\n    respond \"Forbidden\" 403",
+	} {
+		if !strings.Contains(office, want) {
+			t.Fatalf("expected %q in office block:\n%s", want, office)
+		}
+	}
+	if open := text[strings.Index(text, "open.example.com {"):]; strings.Contains(open, "remote_ip") || strings.Contains(open, "403") {
+		t.Fatalf("did not expect ip rules for open app:\n%s", open)
+	}
+}
@@ -22,12 +22,15 @@ type CaddyConfig struct {
 	ContentType string
 }
 
-func BuildCaddyConfig(cfg Config, ports map[string]int) (CaddyConfig, error) {
+// BuildCaddyConfig renders the Caddyfile for every app in ports. extraPorts
+// holds the host ports of each app's named extra ports, keyed by app and
+// then by port name; they are routed as described by the app's PortRoutes.
+func BuildCaddyConfig(cfg Config, ports map[string]int, extraPorts map[string]map[string]int) (CaddyConfig, error) {
 	cfg = applyDefaults(cfg)
 	if strings.TrimSpace(cfg.BaseDomain) == "" {
 		return CaddyConfig{}, fmt.Errorf("base domain is required")
 	}
-	if strings.TrimSpace(cfg.Auth.SigningKey) == "" {
+	if !HasSigningKey(cfg) {
 		return CaddyConfig{}, fmt.Errorf("auth signing key is required")
 	}
 	if len(cfg.Users) == 0 {
@@ -62,27 +65,122 @@ func BuildCaddyConfig(cfg Config, ports map[string]int) (CaddyConfig, error) {
 		if host == "" {
 			continue
 		}
-		b.WriteString(host + " {\n")
-		b.WriteString("  handle " + authPathPrefix + "/* {\n")
-		b.WriteString("    reverse_proxy " + authAddr + "\n")
-		b.WriteString("  }\n")
-		if access.Access == AccessPrivate {
-			b.WriteString("  handle {\n")
-			b.WriteString("    forward_auth " + authAddr + " {\n")
-			b.WriteString("      uri " + authVerifyPath + "\n")
-			b.WriteString("      copy_headers X-Viberun-User X-Viberun-Roles\n")
-			b.WriteString("      header_up X-Forwarded-Host {host}\n")
-			b.WriteString("      header_up X-Forwarded-Proto {scheme}\n")
-			b.WriteString("    }\n")
-			b.WriteString("    reverse_proxy 127.0.0.1:" + strconv.Itoa(port) + "\n")
-			b.WriteString("  }\n")
-		} else {
-			b.WriteString("  handle {\n")
-			b.WriteString("    reverse_proxy 127.0.0.1:" + strconv.Itoa(port) + "\n")
-			b.WriteString("  }\n")
+		var pathRoutes []caddyRoute
+		var hostRoutes []caddyRoute
+		for _, name := range sortedPortNames(extraPorts[app]) {
+			extraPort := extraPorts[app][name]
+			if extraPort <= 0 {
+				continue
+			}
+			route := caddyRoute{
+				name:    name,
+				path:    PortRouteForApp(cfg, app, name).Path,
+				private: EffectivePortAccess(cfg, app, name) == AccessPrivate,
+				port:    extraPort,
+			}
+			if route.path == "" {
+				hostRoutes = append(hostRoutes, route)
+			} else {
+				pathRoutes = append(pathRoutes, route)
+			}
+		}
+		// Caddy keeps the written order of these handles, so longer
+		// prefixes go first to win over the prefixes they extend.
+		sort.SliceStable(pathRoutes, func(i, j int) bool {
+			return len(pathRoutes[i].path) > len(pathRoutes[j].path)
+		})
+		main := caddyRoute{private: access.Access == AccessPrivate, port: port}
+		writeSite(&b, host, authAddr, access, main, pathRoutes)
+		for _, route := range hostRoutes {
+			writeSite(&b, route.name+"."+host, authAddr, access, route, nil)
 		}
-		b.WriteString("}\n\n")
 	}
 
 	return CaddyConfig{Body: b.Bytes(), ContentType: caddyfileContentType}, nil
 }
+
+// caddyRoute is one upstream on a site: the app itself, or a named extra
+// port served on its own sub-host or under a path prefix.
+type caddyRoute struct {
+	name    string
+	path    string
+	private bool
+	port    int
+}
+
+// writeSite writes a site block for host that serves the auth endpoints,
+// then each path route, then upstream for everything else.
+func writeSite(b *bytes.Buffer, host string, authAddr string, access AppAccess, upstream caddyRoute, pathRoutes []caddyRoute) {
+	b.WriteString(host + " {\n")
+	// With IP rules every handle gets a named matcher that also checks
+	// the client address, and a final catch-all rejects the rest.
+	filtered := len(access.AllowIPs) > 0 || len(access.DenyIPs) > 0
+	authHandle := authPathPrefix + "/*"
+	appHandle := ""
+	if filtered {
+		b.WriteString("  @viberun_auth {\n")
+		b.WriteString("    path " + authPathPrefix + "/*\n")
+		writeIPMatchers(b, access)
+		b.WriteString("  }\n")
+		b.WriteString("  @viberun_app {\n")
+		writeIPMatchers(b, access)
+		b.WriteString("  }\n")
+		authHandle = "@viberun_auth"
+		appHandle = "@viberun_app "
+	}
+	b.WriteString("  handle " + authHandle + " {\n")
+	b.WriteString("    reverse_proxy " + authAddr + "\n")
+	b.WriteString("  }\n")
+	for _, route := range pathRoutes {
+		matcher := "@viberun_port_" + route.name
+		b.WriteString("  " + matcher + " {\n")
+		b.WriteString("    path " + route.path + " " + route.path + "/*\n")
+		if filtered {
+			writeIPMatchers(b, access)
+		}
+		b.WriteString("  }\n")
+		writeProxyHandle(b, matcher+" ", authAddr, route)
+	}
+	writeProxyHandle(b, appHandle, authAddr, upstream)
+	if filtered {
+		b.WriteString("  handle {\n")
+		b.WriteString("    respond \"Forbidden\" 403\n")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n\n")
+}
+
+// writeProxyHandle writes a handle that proxies to route's local port,
+// stripping its path prefix and checking the session first when private.
+func writeProxyHandle(b *bytes.Buffer, matcher string, authAddr string, route caddyRoute) {
+	b.WriteString("  handle " + matcher + "{\n")
+	if route.path != "" {
+		b.WriteString("    uri strip_prefix " + route.path + "\n")
+	}
+	if route.private {
+		b.WriteString("    forward_auth " + authAddr + " {\n")
+		b.WriteString("      uri " + authVerifyPath + "\n")
+		b.WriteString("      copy_headers X-Viberun-User X-Viberun-Roles\n")
+		b.WriteString("      header_up X-Forwarded-Host {host}\n")
+		b.WriteString("      header_up X-Forwarded-Proto {scheme}\n")
+		if route.path != "" {
+			// Send the unstripped URI so login redirects back here.
+			b.WriteString("      header_up X-Forwarded-Uri {http.request.orig_uri}\n")
+		}
+		b.WriteString("    }\n")
+	}
+	b.WriteString("    reverse_proxy 127.0.0.1:" + strconv.Itoa(route.port) + "\n")
+	b.WriteString("  }\n")
+}
+
+// writeIPMatchers writes remote_ip lines for a named matcher. Lines in a
+// matcher block are ANDed: the client must be outside DenyIPs and, when
+// AllowIPs is set, inside it.
+func writeIPMatchers(b *bytes.Buffer, access AppAccess) {
+	if len(access.DenyIPs) > 0 {
+		b.WriteString("    not remote_ip " + strings.Join(access.DenyIPs, " ") + "\n")
+	}
+	if len(access.AllowIPs) > 0 {
+		b.WriteString("    remote_ip " + strings.Join(access.AllowIPs, " ") + "\n")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -28,20 +29,51 @@ const (
 )
 
 type AppAccess struct {
-	Access       string   `toml:"access"`
-	AllowedUsers []string `toml:"allowed_users"`
-	Disabled     bool     `toml:"disabled"`
-	CustomDomain string   `toml:"custom_domain"`
+	Access        string   `toml:"access"`
+	AllowedUsers  []string `toml:"allowed_users"`
+	AllowedGroups []string `toml:"allowed_groups"`
+	Disabled      bool     `toml:"disabled"`
+	CustomDomain  string   `toml:"custom_domain"`
+	// AllowIPs limits the app to clients in these CIDRs when set. DenyIPs
+	// blocks clients in these CIDRs. Both are enforced by Caddy before
+	// login, so they also apply to public apps.
+	AllowIPs []string    `toml:"allow_ips,omitempty"`
+	DenyIPs  []string    `toml:"deny_ips,omitempty"`
+	Shares   []ShareLink `toml:"shares,omitempty"`
+	// Ports routes the app's named extra ports. The host ports themselves
+	// are allocated in the server state.
+	Ports map[string]PortRoute `toml:"ports,omitempty"`
 }
 
 type AuthConfig struct {
-	ListenAddr   string `toml:"listen_addr"`
-	SigningKey   string `toml:"signing_key"`
-	CookieName   string `toml:"cookie_name"`
-	CookieTTL    string `toml:"cookie_ttl"`
-	CookieDomain string `toml:"cookie_domain"`
-	CookieSecure *bool  `toml:"cookie_secure"`
-	CookieSame   string `toml:"cookie_samesite"`
+	ListenAddr string `toml:"listen_addr"`
+	// SigningKey is the pre-keyring single key. It is moved into
+	// SigningKeys on load.
+	SigningKey   string       `toml:"signing_key,omitempty"`
+	SigningKeys  []SigningKey `toml:"signing_keys"`
+	CookieName   string       `toml:"cookie_name"`
+	CookieTTL    string       `toml:"cookie_ttl"`
+	CookieDomain string       `toml:"cookie_domain"`
+	CookieSecure *bool        `toml:"cookie_secure"`
+	CookieSame   string       `toml:"cookie_samesite"`
+	OIDC         OIDCConfig   `toml:"oidc"`
+}
+
+// OIDCConfig adds single sign-on through an OpenID Connect provider
+// alongside local password users. It is enabled when Issuer and ClientID
+// are set. RedirectURL defaults to the callback on the host being visited
+// and must be registered with the provider.
+type OIDCConfig struct {
+	Issuer       string   `toml:"issuer"`
+	ClientID     string   `toml:"client_id"`
+	ClientSecret string   `toml:"client_secret"`
+	RedirectURL  string   `toml:"redirect_url"`
+	Scopes       []string `toml:"scopes"`
+	GroupsClaim  string   `toml:"groups_claim"`
+	// AssumeEmailVerified accepts an email from a provider that does not
+	// send email_verified. Only set it for providers that never hand out
+	// unverified addresses, since emails are matched to local users.
+	AssumeEmailVerified bool `toml:"assume_email_verified,omitempty"`
 }
 
 type EnvConfig struct {
@@ -50,9 +82,15 @@ type EnvConfig struct {
 }
 
 type AuthUser struct {
-	Username string `toml:"username"`
-	Email    string `toml:"email"`
-	Password string `toml:"password"`
+	Username string   `toml:"username"`
+	Email    string   `toml:"email"`
+	Password string   `toml:"password"`
+	Groups   []string `toml:"groups"`
+	// TOTPSecret enables a second login step when set. RecoveryCodes holds
+	// hashes of the single-use codes printed at enrollment.
+	TOTPSecret    string     `toml:"totp_secret,omitempty"`
+	RecoveryCodes []string   `toml:"recovery_codes,omitempty"`
+	Tokens        []APIToken `toml:"tokens,omitempty"`
 }
 
 type Config struct {
@@ -77,23 +115,133 @@ func ConfigPath() (string, error) {
 	return defaultConfigPath, nil
 }
 
+// SessionStorePath returns the auth session store that lives next to the
+// proxy config at configPath. The directory is mounted read-write into the
+// proxy container.
+func SessionStorePath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "auth", "sessions.json")
+}
+
+// AuditLogPath returns the JSON lines log of logins and access denials
+// written by viberun-auth.
+func AuditLogPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "auth", "audit.jsonl")
+}
+
+// MFAStorePath returns where viberun-auth records used TOTP codes.
+func MFAStorePath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "auth", "mfa.json")
+}
+
+// HostSocketPath returns the socket the viberun-server daemon listens on
+// for viberun-auth. proxy.toml is read-only in the proxy container, so
+// viberun-auth asks the host to make the changes users request there.
+func HostSocketPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "auth", "host.sock")
+}
+
 func LoadConfig() (Config, string, error) {
 	path, err := ConfigPath()
 	if err != nil {
 		return Config{}, "", err
 	}
-	cfg, err := loadConfigFromPath(path)
+	cfg, err := LoadConfigFromPath(path)
 	if err != nil {
 		return Config{}, path, err
 	}
 	return cfg, path, nil
 }
 
+// LoadConfigFromPath reads the config at path under a shared config lock,
+// so it never sees a write in progress.
 func LoadConfigFromPath(path string) (Config, error) {
+	unlock, err := lockConfig(path, syscall.LOCK_SH)
+	if err != nil {
+		return Config{}, err
+	}
+	defer unlock()
 	return loadConfigFromPath(path)
 }
 
+// SaveConfig replaces the config at path. Prefer UpdateConfig for
+// read-modify-write changes.
 func SaveConfig(path string, cfg Config) error {
+	unlock, err := lockConfig(path, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeConfig(path, cfg)
+}
+
+// ConfigLockPath returns the lock file that serializes proxy.toml writes.
+// It lives in the auth state directory because that and proxy.toml itself
+// are all viberun-auth can see inside the proxy container.
+func ConfigLockPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "auth", "proxy.toml.lock")
+}
+
+// UpdateConfig loads the config at path, applies fn and saves the result
+// while holding the config lock, so viberun-auth and viberun-server do not
+// overwrite each other's changes.
+func UpdateConfig(path string, fn func(*Config) error) (Config, error) {
+	unlock, err := lockConfig(path, syscall.LOCK_EX)
+	if err != nil {
+		return Config{}, err
+	}
+	defer unlock()
+
+	cfg, err := loadConfigFromPath(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := fn(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := writeConfig(path, cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// EditConfig is UpdateConfig on the config at ConfigPath.
+func EditConfig(fn func(*Config) error) (Config, string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return Config{}, "", err
+	}
+	cfg, err := UpdateConfig(path, fn)
+	return cfg, path, err
+}
+
+// lockConfig takes the config lock in the given flock mode. The lock is
+// not reentrant: do not load or save the config while holding it.
+func lockConfig(path string, how int) (func(), error) {
+	lockPath := ConfigLockPath(path)
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o700); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
+
+// writeConfig stores cfg at path. proxy.toml is bind-mounted into the
+// proxy container as a single file, which would keep seeing the old inode
+// after a rename, so the file is rewritten in place. Readers take the
+// shared config lock, so nobody sees a partial write, and the new contents
+// are synced to proxy.toml.tmp first so a crash mid-write loses nothing.
+// Callers hold the exclusive lock.
+func writeConfig(path string, cfg Config) error {
 	cfg = applyDefaults(cfg)
 	data, err := toml.Marshal(cfg)
 	if err != nil {
@@ -102,7 +250,30 @@ func SaveConfig(path string, cfg Config) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := writeFileSync(path, data, 0o644); err != nil {
+		return err
+	}
+	return os.Remove(tmp)
+}
+
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
 func loadConfigFromPath(path string) (Config, error) {
@@ -167,14 +338,20 @@ func applyDefaults(cfg Config) Config {
 		app.Access = strings.TrimSpace(app.Access)
 		app.CustomDomain = strings.TrimSpace(app.CustomDomain)
 		app.AllowedUsers = normalizeUserList(app.AllowedUsers)
+		app.AllowedGroups = normalizeGroupList(app.AllowedGroups)
 		cfg.Apps[name] = app
 	}
 	cfg.Auth.ListenAddr = strings.TrimSpace(cfg.Auth.ListenAddr)
-	cfg.Auth.SigningKey = strings.TrimSpace(cfg.Auth.SigningKey)
+	cfg.Auth = normalizeSigningKeys(cfg.Auth)
 	cfg.Auth.CookieName = strings.TrimSpace(cfg.Auth.CookieName)
 	cfg.Auth.CookieTTL = strings.TrimSpace(cfg.Auth.CookieTTL)
 	cfg.Auth.CookieDomain = strings.TrimSpace(cfg.Auth.CookieDomain)
 	cfg.Auth.CookieSame = strings.TrimSpace(cfg.Auth.CookieSame)
+	cfg.Auth.OIDC.Issuer = strings.TrimSpace(cfg.Auth.OIDC.Issuer)
+	cfg.Auth.OIDC.ClientID = strings.TrimSpace(cfg.Auth.OIDC.ClientID)
+	cfg.Auth.OIDC.ClientSecret = strings.TrimSpace(cfg.Auth.OIDC.ClientSecret)
+	cfg.Auth.OIDC.RedirectURL = strings.TrimSpace(cfg.Auth.OIDC.RedirectURL)
+	cfg.Auth.OIDC.GroupsClaim = strings.TrimSpace(cfg.Auth.OIDC.GroupsClaim)
 	return cfg
 }
 
@@ -245,6 +422,8 @@ func normalizeUsers(users []AuthUser) []AuthUser {
 		user.Username = strings.TrimSpace(user.Username)
 		user.Email = strings.TrimSpace(user.Email)
 		user.Password = strings.TrimSpace(user.Password)
+		user.Groups = normalizeGroupList(user.Groups)
+		user.TOTPSecret = strings.TrimSpace(user.TOTPSecret)
 		if user.Username == "" || seen[user.Username] {
 			continue
 		}
@@ -5,8 +5,10 @@
 package proxy
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -84,3 +86,82 @@ func TestSaveAndLoadConfig(t *testing.T) {
 		t.Fatalf("expected proxy defaults to be applied")
 	}
 }
+
+func TestUpdateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.toml")
+	if err := SaveConfig(path, Config{BaseDomain: "example.com"}); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	updated, err := UpdateConfig(path, func(cfg *Config) error {
+		cfg.PublicIP = "1.2.3.4"
+		return nil
+	})
+	if err != nil || updated.PublicIP != "1.2.3.4" {
+		t.Fatalf("UpdateConfig: %+v %v", updated, err)
+	}
+	if _, err := UpdateConfig(path, func(cfg *Config) error {
+		cfg.PublicIP = "5.6.7.8"
+		return os.ErrPermission
+	}); err == nil {
+		t.Fatalf("expected fn error to be returned")
+	}
+	loaded, err := LoadConfigFromPath(path)
+	if err != nil || loaded.BaseDomain != "example.com" || loaded.PublicIP != "1.2.3.4" {
+		t.Fatalf("unexpected saved config: %+v %v", loaded, err)
+	}
+	if _, err := os.Stat(ConfigLockPath(path)); err != nil {
+		t.Fatalf("expected lock file in auth dir: %v", err)
+	}
+}
+
+func TestConcurrentConfigUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.toml")
+	if err := SaveConfig(path, Config{BaseDomain: "example.com"}); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, err := UpdateConfig(path, func(cfg *Config) error {
+				cfg.Apps[fmt.Sprintf("app%d", i)] = AppAccess{Access: AccessPublic}
+				return nil
+			})
+			errs <- err
+		}(i)
+		go func() {
+			defer wg.Done()
+			cfg, err := LoadConfigFromPath(path)
+			if err == nil && cfg.BaseDomain != "example.com" {
+				err = fmt.Errorf("read a partial config: %+v", cfg)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := LoadConfigFromPath(path)
+	if err != nil || len(loaded.Apps) != writers {
+		t.Fatalf("expected %d apps, got %d (%v)", writers, len(loaded.Apps), err)
+	}
+	// The proxy container bind-mounts this file, so it must be rewritten
+	// in place rather than replaced.
+	after, err := os.Stat(path)
+	if err != nil || !os.SameFile(before, after) {
+		t.Fatalf("expected proxy.toml to keep its inode (%v)", err)
+	}
+}
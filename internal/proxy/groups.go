@@ -0,0 +1,139 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Built-in roles sent in X-Viberun-Roles ahead of the user's groups.
+const (
+	RolePrimary = "primary"
+	RoleUser    = "user"
+)
+
+// NormalizeGroupName lowercases a group name and checks that it is safe to
+// send in a comma-separated header.
+func NormalizeGroupName(raw string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	if name == "" {
+		return "", fmt.Errorf("group name is required")
+	}
+	if len(name) > 63 {
+		return "", fmt.Errorf("group name %q is too long", name)
+	}
+	if name == RolePrimary || name == RoleUser {
+		return "", fmt.Errorf("group name %q is reserved", name)
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9':
+		case (r == '-' || r == '_') && i > 0:
+		default:
+			return "", fmt.Errorf("group name %q has invalid character %q", name, r)
+		}
+	}
+	return name, nil
+}
+
+// ParseGroupList parses a comma-separated list of group names.
+func ParseGroupList(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.EqualFold(raw, "none") {
+		return nil, nil
+	}
+	groups := []string{}
+	for _, part := range strings.Split(raw, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		group, err := NormalizeGroupName(part)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return normalizeGroupList(groups), nil
+}
+
+func normalizeGroupList(groups []string) []string {
+	if len(groups) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(groups))
+	for _, group := range groups {
+		group = strings.ToLower(strings.TrimSpace(group))
+		if group == "" || seen[group] {
+			continue
+		}
+		seen[group] = true
+		out = append(out, group)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetUserGroup adds or removes username from group. It reports whether the
+// membership changed.
+func SetUserGroup(cfg *Config, username string, group string, member bool) (bool, error) {
+	group, err := NormalizeGroupName(group)
+	if err != nil {
+		return false, err
+	}
+	user, err := findUserForUpdate(cfg, username)
+	if err != nil {
+		return false, err
+	}
+	if hasGroup(user.Groups, group) == member {
+		return false, nil
+	}
+	if member {
+		user.Groups = normalizeGroupList(append(user.Groups, group))
+		return true, nil
+	}
+	kept := make([]string, 0, len(user.Groups))
+	for _, existing := range user.Groups {
+		if existing != group {
+			kept = append(kept, existing)
+		}
+	}
+	user.Groups = normalizeGroupList(kept)
+	return true, nil
+}
+
+// KnownGroups returns every group that has a member or grants app access.
+func KnownGroups(cfg Config) []string {
+	groups := []string{}
+	for _, user := range cfg.Users {
+		groups = append(groups, user.Groups...)
+	}
+	for _, app := range cfg.Apps {
+		groups = append(groups, app.AllowedGroups...)
+	}
+	return normalizeGroupList(groups)
+}
+
+// UserRoles returns the roles reported to apps for user: primary or user,
+// followed by the user's groups.
+func UserRoles(cfg Config, user AuthUser) []string {
+	base := RoleUser
+	if primary := strings.TrimSpace(cfg.PrimaryUser); primary != "" && user.Username == primary {
+		base = RolePrimary
+	}
+	return append([]string{base}, normalizeGroupList(user.Groups)...)
+}
+
+func hasGroup(groups []string, group string) bool {
+	for _, existing := range groups {
+		if existing == group {
+			return true
+		}
+	}
+	return false
+}
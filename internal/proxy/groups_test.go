@@ -0,0 +1,59 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGroupList(t *testing.T) {
+	groups, err := ParseGroupList(" Team-A, admin,,team-a ")
+	if err != nil {
+		t.Fatalf("ParseGroupList: %v", err)
+	}
+	if !reflect.DeepEqual(groups, []string{"admin", "team-a"}) {
+		t.Fatalf("unexpected groups: %#v", groups)
+	}
+	if groups, err := ParseGroupList("none"); err != nil || groups != nil {
+		t.Fatalf("expected none to clear groups, got %#v %v", groups, err)
+	}
+	for _, raw := range []string{"primary", "user", "-team", "team a", "ops;admin"} {
+		if _, err := ParseGroupList(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestSetUserGroup(t *testing.T) {
+	cfg := Config{Users: []AuthUser{{Username: "alice"}, {Username: "bob"}}}
+	changed, err := SetUserGroup(&cfg, "alice", "Team-A", true)
+	if err != nil || !changed {
+		t.Fatalf("add group: %v %v", changed, err)
+	}
+	if changed, _ := SetUserGroup(&cfg, "alice", "team-a", true); changed {
+		t.Fatalf("expected adding an existing group to be a no-op")
+	}
+	if !reflect.DeepEqual(cfg.Users[0].Groups, []string{"team-a"}) || len(cfg.Users[1].Groups) != 0 {
+		t.Fatalf("unexpected users: %#v", cfg.Users)
+	}
+	if changed, err := SetUserGroup(&cfg, "alice", "team-a", false); err != nil || !changed || len(cfg.Users[0].Groups) != 0 {
+		t.Fatalf("remove group: %v %v %#v", changed, err, cfg.Users[0])
+	}
+	if _, err := SetUserGroup(&cfg, "carol", "team-a", true); err == nil {
+		t.Fatalf("expected error for unknown user")
+	}
+}
+
+func TestUserRoles(t *testing.T) {
+	cfg := Config{PrimaryUser: "owner"}
+	if got := UserRoles(cfg, AuthUser{Username: "owner"}); !reflect.DeepEqual(got, []string{"primary"}) {
+		t.Fatalf("unexpected primary roles: %#v", got)
+	}
+	got := UserRoles(cfg, AuthUser{Username: "alice", Groups: []string{"viewer", "admin"}})
+	if !reflect.DeepEqual(got, []string{"user", "admin", "viewer"}) {
+		t.Fatalf("unexpected roles: %#v", got)
+	}
+}
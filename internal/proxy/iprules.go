@@ -0,0 +1,121 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+const (
+	IPRuleAllow = "allow"
+	IPRuleDeny  = "deny"
+)
+
+// NormalizeCIDR accepts a CIDR or a single address and returns the masked
+// prefix, so 10.1.2.3/8 and 10.0.0.0/8 are stored the same way.
+func NormalizeCIDR(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", fmt.Errorf("cidr is required")
+	}
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid IP or CIDR %q", raw)
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()).String(), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid IP or CIDR %q", raw)
+	}
+	return prefix.Masked().String(), nil
+}
+
+func normalizeCIDRList(values []string) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		cidr, err := NormalizeCIDR(value)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[cidr]; ok {
+			continue
+		}
+		seen[cidr] = struct{}{}
+		out = append(out, cidr)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// AddAppIPRule adds cidr to app's allow or deny list. A CIDR lives on one
+// list at a time, so adding it to one removes it from the other.
+func AddAppIPRule(cfg *Config, app string, rule string, cidr string) (string, error) {
+	if cfg == nil {
+		return "", fmt.Errorf("config is nil")
+	}
+	if rule != IPRuleAllow && rule != IPRuleDeny {
+		return "", fmt.Errorf("ip rule must be %s or %s", IPRuleAllow, IPRuleDeny)
+	}
+	normalized, err := NormalizeCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Apps == nil {
+		cfg.Apps = map[string]AppAccess{}
+	}
+	appCfg := cfg.Apps[app]
+	appCfg.AllowIPs = removeCIDR(appCfg.AllowIPs, normalized)
+	appCfg.DenyIPs = removeCIDR(appCfg.DenyIPs, normalized)
+	if rule == IPRuleAllow {
+		appCfg.AllowIPs = normalizeCIDRList(append(appCfg.AllowIPs, normalized))
+	} else {
+		appCfg.DenyIPs = normalizeCIDRList(append(appCfg.DenyIPs, normalized))
+	}
+	cfg.Apps[app] = appCfg
+	return normalized, nil
+}
+
+// ClearAppIPRules removes cidr from both of app's lists, or every rule when
+// cidr is empty. It reports whether anything changed.
+func ClearAppIPRules(cfg *Config, app string, cidr string) (bool, error) {
+	if cfg == nil {
+		return false, fmt.Errorf("config is nil")
+	}
+	appCfg, ok := cfg.Apps[app]
+	if !ok {
+		return false, nil
+	}
+	before := len(appCfg.AllowIPs) + len(appCfg.DenyIPs)
+	if strings.TrimSpace(cidr) == "" {
+		appCfg.AllowIPs = nil
+		appCfg.DenyIPs = nil
+	} else {
+		normalized, err := NormalizeCIDR(cidr)
+		if err != nil {
+			return false, err
+		}
+		appCfg.AllowIPs = removeCIDR(appCfg.AllowIPs, normalized)
+		appCfg.DenyIPs = removeCIDR(appCfg.DenyIPs, normalized)
+	}
+	cfg.Apps[app] = appCfg
+	return len(appCfg.AllowIPs)+len(appCfg.DenyIPs) != before, nil
+}
+
+func removeCIDR(values []string, cidr string) []string {
+	var out []string
+	for _, value := range values {
+		if value != cidr {
+			out = append(out, value)
+		}
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	cases := map[string]string{
+		"10.1.2.3/8":      "10.0.0.0/8",
+		" 192.168.1.5 ":   "192.168.1.5/32",
+		"2001:db8::1":     "2001:db8::1/128",
+		"2001:db8::1/32":  "2001:db8::/32",
+		"::ffff:10.0.0.1": "10.0.0.1/32",
+		"203.0.113.0/24":  "203.0.113.0/24",
+	}
+	for input, want := range cases {
+		got, err := NormalizeCIDR(input)
+		if err != nil || got != want {
+			t.Fatalf("NormalizeCIDR(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "office", "10.0.0.0/33", "10.0.0.256"} {
+		if _, err := NormalizeCIDR(bad); err == nil {
+			t.Fatalf("NormalizeCIDR(%q): expected error", bad)
+		}
+	}
+}
+
+func TestAppIPRules(t *testing.T) {
+	cfg := Config{}
+	if _, err := AddAppIPRule(&cfg, "web", IPRuleAllow, "10.0.0.0/8"); err != nil {
+		t.Fatalf("allow: %v", err)
+	}
+	if _, err := AddAppIPRule(&cfg, "web", IPRuleAllow, "10.0.0.1/8"); err != nil {
+		t.Fatalf("allow duplicate: %v", err)
+	}
+	if _, err := AddAppIPRule(&cfg, "web", IPRuleDeny, "10.9.9.9"); err != nil {
+		t.Fatalf("deny: %v", err)
+	}
+	if _, err := AddAppIPRule(&cfg, "web", "block", "10.0.0.0/8"); err == nil {
+		t.Fatalf("expected unknown rule error")
+	}
+	access := EffectiveAppAccess(cfg, "web")
+	if !reflect.DeepEqual(access.AllowIPs, []string{"10.0.0.0/8"}) || !reflect.DeepEqual(access.DenyIPs, []string{"10.9.9.9/32"}) {
+		t.Fatalf("unexpected rules: allow %v deny %v", access.AllowIPs, access.DenyIPs)
+	}
+
+	if _, err := AddAppIPRule(&cfg, "web", IPRuleDeny, "10.0.0.0/8"); err != nil {
+		t.Fatalf("move to deny: %v", err)
+	}
+	if access := EffectiveAppAccess(cfg, "web"); len(access.AllowIPs) != 0 || len(access.DenyIPs) != 2 {
+		t.Fatalf("expected cidr to move lists: allow %v deny %v", access.AllowIPs, access.DenyIPs)
+	}
+
+	if changed, err := ClearAppIPRules(&cfg, "web", "10.9.9.9"); err != nil || !changed {
+		t.Fatalf("clear one: changed=%v err=%v", changed, err)
+	}
+	if changed, _ := ClearAppIPRules(&cfg, "web", "192.168.0.0/16"); changed {
+		t.Fatalf("clearing a missing cidr should not change anything")
+	}
+	if changed, err := ClearAppIPRules(&cfg, "web", ""); err != nil || !changed {
+		t.Fatalf("clear all: changed=%v err=%v", changed, err)
+	}
+	if access := EffectiveAppAccess(cfg, "web"); access.AllowIPs != nil || access.DenyIPs != nil {
+		t.Fatalf("expected no rules, got allow %v deny %v", access.AllowIPs, access.DenyIPs)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// SigningKey is one entry in the auth keyring. Keys are kept oldest first;
+// the last key signs new sessions and the rest only verify.
+type SigningKey struct {
+	ID        string    `toml:"id"`
+	Key       string    `toml:"key"`
+	CreatedAt time.Time `toml:"created_at"`
+}
+
+// signingKeyID derives a stable ID from the secret so a migrated
+// signing_key gets the same ID on every load.
+func signingKeyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+func normalizeSigningKeys(auth AuthConfig) AuthConfig {
+	if legacy := strings.TrimSpace(auth.SigningKey); legacy != "" {
+		found := false
+		for _, key := range auth.SigningKeys {
+			if strings.TrimSpace(key.Key) == legacy {
+				found = true
+				break
+			}
+		}
+		if !found {
+			auth.SigningKeys = append([]SigningKey{{Key: legacy}}, auth.SigningKeys...)
+		}
+		auth.SigningKey = ""
+	}
+	if len(auth.SigningKeys) == 0 {
+		auth.SigningKeys = nil
+		return auth
+	}
+	seen := map[string]bool{}
+	keys := make([]SigningKey, 0, len(auth.SigningKeys))
+	for _, key := range auth.SigningKeys {
+		key.Key = strings.TrimSpace(key.Key)
+		if key.Key == "" || seen[key.Key] {
+			continue
+		}
+		seen[key.Key] = true
+		key.ID = signingKeyID(key.Key)
+		keys = append(keys, key)
+	}
+	auth.SigningKeys = keys
+	return auth
+}
+
+// HasSigningKey reports whether the keyring has an active key.
+func HasSigningKey(cfg Config) bool {
+	_, ok := ActiveSigningKey(cfg)
+	return ok
+}
+
+// ActiveSigningKey returns the key used to sign new sessions.
+func ActiveSigningKey(cfg Config) (SigningKey, bool) {
+	keys := normalizeSigningKeys(cfg.Auth).SigningKeys
+	if len(keys) == 0 {
+		return SigningKey{}, false
+	}
+	return keys[len(keys)-1], true
+}
+
+// VerifySigningKeys returns the keys that may still verify a token at now:
+// the active key plus every older key whose successor became active less
+// than one cookie TTL ago. A share link and its cookies are signed with the
+// key active when they were issued and last until the link expires, so an
+// older key also stays while a share created before its successor is live.
+func VerifySigningKeys(cfg Config, now time.Time) []SigningKey {
+	keys := normalizeSigningKeys(cfg.Auth).SigningKeys
+	ttl := CookieTTL(cfg)
+	out := make([]SigningKey, 0, len(keys))
+	for i, key := range keys {
+		if i < len(keys)-1 && signingKeyExpired(cfg, keys[i+1], now, ttl) {
+			continue
+		}
+		out = append(out, key)
+	}
+	return out
+}
+
+func signingKeyExpired(cfg Config, successor SigningKey, now time.Time, ttl time.Duration) bool {
+	if successor.CreatedAt.IsZero() {
+		return false
+	}
+	if now.Sub(successor.CreatedAt) < ttl {
+		return false
+	}
+	for _, app := range cfg.Apps {
+		for _, link := range app.Shares {
+			if link.CreatedAt.Before(successor.CreatedAt) && now.Before(link.ExpiresAt) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AddSigningKey makes secret the active key. It is a no-op when secret is
+// already active.
+func AddSigningKey(cfg *Config, secret string, now time.Time) SigningKey {
+	cfg.Auth = normalizeSigningKeys(cfg.Auth)
+	secret = strings.TrimSpace(secret)
+	if active, ok := ActiveSigningKey(*cfg); ok && active.Key == secret {
+		return active
+	}
+	kept := make([]SigningKey, 0, len(cfg.Auth.SigningKeys)+1)
+	for _, key := range cfg.Auth.SigningKeys {
+		if key.Key != secret {
+			kept = append(kept, key)
+		}
+	}
+	key := SigningKey{ID: signingKeyID(secret), Key: secret, CreatedAt: now.UTC()}
+	cfg.Auth.SigningKeys = append(kept, key)
+	return key
+}
+
+// RotateSigningKey adds a new active key and drops verify-only keys that
+// can no longer match an unexpired cookie or share link. It returns the new key and the
+// retired ones.
+func RotateSigningKey(cfg *Config, now time.Time) (SigningKey, []SigningKey, error) {
+	secret, err := GenerateSigningKey()
+	if err != nil {
+		return SigningKey{}, nil, err
+	}
+	retired := RetireSigningKeys(cfg, now)
+	return AddSigningKey(cfg, secret, now), retired, nil
+}
+
+// RetireSigningKeys removes keys that VerifySigningKeys no longer accepts.
+func RetireSigningKeys(cfg *Config, now time.Time) []SigningKey {
+	cfg.Auth = normalizeSigningKeys(cfg.Auth)
+	live := VerifySigningKeys(*cfg, now)
+	keep := map[string]bool{}
+	for _, key := range live {
+		keep[key.ID] = true
+	}
+	retired := []SigningKey{}
+	for _, key := range cfg.Auth.SigningKeys {
+		if !keep[key.ID] {
+			retired = append(retired, key)
+		}
+	}
+	cfg.Auth.SigningKeys = live
+	return retired
+}
+
+// CookieTTL returns the parsed cookie lifetime, falling back to the default.
+func CookieTTL(cfg Config) time.Duration {
+	ttl, err := time.ParseDuration(strings.TrimSpace(cfg.Auth.CookieTTL))
+	if err != nil || ttl <= 0 {
+		ttl, _ = time.ParseDuration(defaultAuthCookieTTL)
+	}
+	return ttl
+}
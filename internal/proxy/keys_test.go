@@ -0,0 +1,94 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLegacySigningKeyMigrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.toml")
+	if err := os.WriteFile(path, []byte("[auth]\nsigning_key = \"legacy-secret\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfigFromPath(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFromPath: %v", err)
+	}
+	active, ok := ActiveSigningKey(cfg)
+	if !ok || active.Key != "legacy-secret" || active.ID != signingKeyID("legacy-secret") {
+		t.Fatalf("unexpected active key: %+v", active)
+	}
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "signing_key =") {
+		t.Fatalf("expected legacy field to be dropped on save:\n%s", data)
+	}
+	reloaded, err := LoadConfigFromPath(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if again, _ := ActiveSigningKey(reloaded); again.ID != active.ID {
+		t.Fatalf("key id changed across save: %s != %s", again.ID, active.ID)
+	}
+	added := AddSigningKey(&reloaded, "next-secret", time.Now())
+	if err := SaveConfig(path, reloaded); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	reloaded, _ = LoadConfigFromPath(path)
+	if got, _ := ActiveSigningKey(reloaded); got.ID != added.ID || got.CreatedAt.IsZero() {
+		t.Fatalf("expected new key with creation time to round-trip, got %+v", got)
+	}
+}
+
+func TestRotateSigningKey(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := applyDefaults(Config{Auth: AuthConfig{SigningKey: "first", CookieTTL: "1h"}})
+	second, retired, err := RotateSigningKey(&cfg, start)
+	if err != nil || len(retired) != 0 {
+		t.Fatalf("first rotation: %v %v", retired, err)
+	}
+	if active, _ := ActiveSigningKey(cfg); active.ID != second.ID {
+		t.Fatalf("expected new key to be active")
+	}
+	if keys := VerifySigningKeys(cfg, start.Add(30*time.Minute)); len(keys) != 2 {
+		t.Fatalf("expected old key to verify within the ttl, got %d keys", len(keys))
+	}
+	if keys := VerifySigningKeys(cfg, start.Add(time.Hour)); len(keys) != 1 || keys[0].ID != second.ID {
+		t.Fatalf("expected only the active key after the ttl, got %+v", keys)
+	}
+
+	third, retired, err := RotateSigningKey(&cfg, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("second rotation: %v", err)
+	}
+	if len(retired) != 1 || retired[0].Key != "first" {
+		t.Fatalf("expected the oldest key to be retired, got %+v", retired)
+	}
+	if len(cfg.Auth.SigningKeys) != 2 || cfg.Auth.SigningKeys[0].ID != second.ID || cfg.Auth.SigningKeys[1].ID != third.ID {
+		t.Fatalf("unexpected keyring: %+v", cfg.Auth.SigningKeys)
+	}
+}
+
+func TestVerifySigningKeysKeepsKeysForLiveShares(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := applyDefaults(Config{Auth: AuthConfig{SigningKey: "first", CookieTTL: "1h"}})
+	cfg.Apps["myapp"] = AppAccess{Shares: []ShareLink{{ID: "abc", CreatedAt: start.Add(-time.Minute), ExpiresAt: start.Add(7 * 24 * time.Hour)}}}
+	if _, _, err := RotateSigningKey(&cfg, start); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if keys := VerifySigningKeys(cfg, start.Add(3*24*time.Hour)); len(keys) != 2 {
+		t.Fatalf("expected old key to verify while the share is live, got %d keys", len(keys))
+	}
+	if keys := VerifySigningKeys(cfg, start.Add(7*24*time.Hour)); len(keys) != 1 {
+		t.Fatalf("expected old key to retire once the share expires, got %d keys", len(keys))
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import "strings"
+
+const defaultOIDCGroupsClaim = "groups"
+
+// OIDCEnabled reports whether single sign-on is configured.
+func OIDCEnabled(cfg Config) bool {
+	return cfg.Auth.OIDC.Issuer != "" && cfg.Auth.OIDC.ClientID != ""
+}
+
+// OIDCGroupsClaim returns the ID token claim that lists a user's groups.
+func OIDCGroupsClaim(cfg Config) string {
+	if claim := strings.TrimSpace(cfg.Auth.OIDC.GroupsClaim); claim != "" {
+		return claim
+	}
+	return defaultOIDCGroupsClaim
+}
+
+// OIDCUser maps a signed-in identity onto a proxy user. A local user with
+// the same email keeps their username and gains the provider's groups;
+// anyone else is known by their email, which is what AllowedUsers lists.
+// Provider groups that are not valid group names are dropped.
+func OIDCUser(cfg Config, email string, groups []string) AuthUser {
+	email = strings.TrimSpace(email)
+	valid := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if name, err := NormalizeGroupName(group); err == nil {
+			valid = append(valid, name)
+		}
+	}
+	for _, user := range cfg.Users {
+		if email != "" && (strings.EqualFold(user.Email, email) || user.Username == email) {
+			user.Groups = normalizeGroupList(append(append([]string{}, user.Groups...), valid...))
+			return user
+		}
+	}
+	return AuthUser{Username: email, Email: email, Groups: normalizeGroupList(valid)}
+}
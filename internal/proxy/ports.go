@@ -0,0 +1,196 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PortRoute says how a named extra port is reached. With an empty Path the
+// port gets its own sub-host, <name>.<app host>; otherwise it is served
+// under Path on the app host with the prefix stripped. An empty Access
+// inherits the app's access mode.
+type PortRoute struct {
+	Path   string `toml:"path,omitempty"`
+	Access string `toml:"access,omitempty"`
+}
+
+// NormalizePortName validates an extra port name. Names become DNS labels
+// for sub-host routes, so they follow the app name rules.
+func NormalizePortName(raw string) (string, error) {
+	name, err := NormalizeAppName(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid port name: %s", strings.TrimPrefix(err.Error(), "app name "))
+	}
+	return name, nil
+}
+
+// NormalizePortPath validates a path prefix such as /api. The trailing
+// slash is dropped and the auth prefix is reserved.
+func NormalizePortPath(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !strings.HasPrefix(value, "/") {
+		value = "/" + value
+	}
+	value = strings.TrimRight(value, "/")
+	if value == "" {
+		return "", fmt.Errorf("path must not be /")
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9':
+		case r == '/' || r == '-' || r == '_' || r == '.':
+		default:
+			return "", fmt.Errorf("path has invalid character %q", r)
+		}
+	}
+	if strings.Contains(value, "//") {
+		return "", fmt.Errorf("path must not contain empty segments")
+	}
+	if value == "/__viberun" || strings.HasPrefix(value, "/__viberun/") {
+		return "", fmt.Errorf("path %s is reserved", value)
+	}
+	return value, nil
+}
+
+// SetAppPortRoute stores route for app's extra port name after validating
+// it. Two ports of one app may not share a path.
+func SetAppPortRoute(cfg *Config, app string, name string, route PortRoute) (PortRoute, error) {
+	if cfg == nil {
+		return PortRoute{}, fmt.Errorf("config is nil")
+	}
+	name, err := NormalizePortName(name)
+	if err != nil {
+		return PortRoute{}, err
+	}
+	if strings.TrimSpace(route.Path) != "" {
+		path, err := NormalizePortPath(route.Path)
+		if err != nil {
+			return PortRoute{}, err
+		}
+		route.Path = path
+	} else {
+		route.Path = ""
+	}
+	route.Access = NormalizeAccessMode(route.Access)
+	if cfg.Apps == nil {
+		cfg.Apps = map[string]AppAccess{}
+	}
+	appCfg := cfg.Apps[app]
+	for other, existing := range appCfg.Ports {
+		if other != name && route.Path != "" && existing.Path == route.Path {
+			return PortRoute{}, fmt.Errorf("path %s is already used by port %s", route.Path, other)
+		}
+	}
+	if appCfg.Ports == nil {
+		appCfg.Ports = map[string]PortRoute{}
+	}
+	appCfg.Ports[name] = route
+	cfg.Apps[app] = appCfg
+	return route, nil
+}
+
+// RemoveAppPortRoute drops the route for app's extra port name and reports
+// whether it existed.
+func RemoveAppPortRoute(cfg *Config, app string, name string) bool {
+	if cfg == nil {
+		return false
+	}
+	appCfg, ok := cfg.Apps[app]
+	if !ok {
+		return false
+	}
+	if _, ok := appCfg.Ports[name]; !ok {
+		return false
+	}
+	delete(appCfg.Ports, name)
+	if len(appCfg.Ports) == 0 {
+		appCfg.Ports = nil
+	}
+	cfg.Apps[app] = appCfg
+	return true
+}
+
+// PortRouteForApp returns the route for app's extra port name. Ports
+// without a stored route use a sub-host with the app's access.
+func PortRouteForApp(cfg Config, app string, name string) PortRoute {
+	return cfg.Apps[app].Ports[name]
+}
+
+// EffectivePortAccess returns the access mode for app's extra port name.
+func EffectivePortAccess(cfg Config, app string, name string) string {
+	if access := NormalizeAccessMode(PortRouteForApp(cfg, app, name).Access); access != "" {
+		return access
+	}
+	return EffectiveAppAccess(cfg, app).Access
+}
+
+// PublicURLForPort returns the URL that reaches app's extra port name.
+func PublicURLForPort(cfg Config, app string, name string) string {
+	host := PublicHostForApp(cfg, app)
+	if host == "" {
+		return ""
+	}
+	route := PortRouteForApp(cfg, app, name)
+	if route.Path != "" {
+		return "https://" + host + route.Path
+	}
+	return "https://" + name + "." + host
+}
+
+// AppForPortHost maps a sub-host such as api.myapp.example.com to the app
+// that owns the extra port, or returns false when host is not a port
+// sub-host of a known app.
+func AppForPortHost(cfg Config, host string) (string, string, bool) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	name, rest, ok := strings.Cut(host, ".")
+	if !ok || name == "" {
+		return "", "", false
+	}
+	for _, app := range SortedAppNames(cfg.Apps) {
+		route, ok := cfg.Apps[app].Ports[name]
+		if !ok || route.Path != "" {
+			continue
+		}
+		if PublicHostForApp(cfg, app) == rest {
+			return app, name, true
+		}
+	}
+	return "", "", false
+}
+
+// PortForPath returns app's extra port whose path prefix covers uri, which
+// may carry a query string. The longest matching prefix wins.
+func PortForPath(cfg Config, app string, uri string) (string, bool) {
+	path, _, _ := strings.Cut(uri, "?")
+	best := ""
+	bestLen := 0
+	for name, route := range cfg.Apps[app].Ports {
+		if route.Path == "" || len(route.Path) <= bestLen {
+			continue
+		}
+		if path == route.Path || strings.HasPrefix(path, route.Path+"/") {
+			best = name
+			bestLen = len(route.Path)
+		}
+	}
+	return best, best != ""
+}
+
+func sortedPortNames(ports map[string]int) []string {
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
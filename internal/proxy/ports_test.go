@@ -0,0 +1,105 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizePortPath(t *testing.T) {
+	for raw, want := range map[string]string{"/api": "/api", "api/": "/api", " /v1/admin ": "/v1/admin"} {
+		got, err := NormalizePortPath(raw)
+		if err != nil || got != want {
+			t.Fatalf("NormalizePortPath(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"", "/", "/a b", "/a//b", "/__viberun", "/__viberun/auth"} {
+		if _, err := NormalizePortPath(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestAppPortRoutes(t *testing.T) {
+	cfg := Config{BaseDomain: "example.com", DefaultAccess: AccessPrivate}
+	if _, err := SetAppPortRoute(&cfg, "web", "api", PortRoute{}); err != nil {
+		t.Fatalf("SetAppPortRoute: %v", err)
+	}
+	if _, err := SetAppPortRoute(&cfg, "web", "admin", PortRoute{Path: "admin/", Access: AccessPublic}); err != nil {
+		t.Fatalf("SetAppPortRoute: %v", err)
+	}
+	if _, err := SetAppPortRoute(&cfg, "web", "other", PortRoute{Path: "/admin"}); err == nil {
+		t.Fatalf("expected duplicate path error")
+	}
+	if _, err := SetAppPortRoute(&cfg, "web", "Bad_Name", PortRoute{}); err == nil {
+		t.Fatalf("expected invalid name error")
+	}
+
+	if got := PublicURLForPort(cfg, "web", "api"); got != "https://api.web.example.com" {
+		t.Fatalf("unexpected sub-host url: %q", got)
+	}
+	if got := PublicURLForPort(cfg, "web", "admin"); got != "https://web.example.com/admin" {
+		t.Fatalf("unexpected path url: %q", got)
+	}
+	if EffectivePortAccess(cfg, "web", "api") != AccessPrivate || EffectivePortAccess(cfg, "web", "admin") != AccessPublic {
+		t.Fatalf("unexpected port access")
+	}
+	if app, port, ok := AppForPortHost(cfg, "api.web.example.com"); !ok || app != "web" || port != "api" {
+		t.Fatalf("AppForPortHost = %q %q %v", app, port, ok)
+	}
+	if _, _, ok := AppForPortHost(cfg, "admin.web.example.com"); ok {
+		t.Fatalf("path routes must not match as sub-hosts")
+	}
+	if port, ok := PortForPath(cfg, "web", "/admin/users?page=2"); !ok || port != "admin" {
+		t.Fatalf("PortForPath = %q %v", port, ok)
+	}
+	if _, ok := PortForPath(cfg, "web", "/administrator"); ok {
+		t.Fatalf("expected prefix match on whole segments only")
+	}
+
+	if !RemoveAppPortRoute(&cfg, "web", "api") || RemoveAppPortRoute(&cfg, "web", "api") {
+		t.Fatalf("expected remove to report once")
+	}
+}
+
+func TestBuildCaddyConfigExtraPorts(t *testing.T) {
+	cfg := Config{
+		BaseDomain: "example.com",
+		Auth:       AuthConfig{SigningKey: "test-key"},
+		Users:      []AuthUser{{Username: "primary", Password: "$2a$10$hash"}},
+		Apps: map[string]AppAccess{
+			"web": {Access: AccessPublic, Ports: map[string]PortRoute{
+				"api":   {},
+				"admin": {Path: "/admin", Access: AccessPrivate},
+				"deep":  {Path: "/admin/deep"},
+			}},
+		},
+	}
+	extra := map[string]map[string]int{"web": {"api": 8081, "admin": 8082, "deep": 8083}}
+	data, err := BuildCaddyConfig(cfg, map[string]int{"web": 8080}, extra)
+	if err != nil {
+		t.Fatalf("BuildCaddyConfig: %v", err)
+	}
+	text := string(data.Body)
+	for _, want := range []string{
+		"api.web.example.com {\n",
+		"  @viberun_port_admin {\n    path /admin /admin/*\n  }\n",
+		"  handle @viberun_port_admin {\n    uri strip_prefix /admin\n    forward_auth ",
+		"header_up X-Forwarded-Uri {http.request.orig_uri}",
+		"reverse_proxy 127.0.0.1:8082",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in config:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "@viberun_port_deep {") > strings.Index(text, "@viberun_port_admin {") {
+		t.Fatalf("expected longer prefix first:\n%s", text)
+	}
+	sub := text[strings.Index(text, "api.web.example.com {"):]
+	if !strings.Contains(sub, "reverse_proxy 127.0.0.1:8081") || strings.Contains(sub, "forward_auth") {
+		t.Fatalf("expected public sub-host for api:\n%s", sub)
+	}
+}
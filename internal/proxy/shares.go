@@ -0,0 +1,127 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultShareTTL is how long a share link lasts when no TTL is given.
+const DefaultShareTTL = 24 * time.Hour
+
+// ShareLink lets anyone holding the signed link open one app without an
+// account until ExpiresAt. Removing it revokes the link and every cookie
+// issued for it.
+type ShareLink struct {
+	ID        string    `toml:"id"`
+	Path      string    `toml:"path,omitempty"`
+	CreatedAt time.Time `toml:"created_at"`
+	ExpiresAt time.Time `toml:"expires_at"`
+}
+
+// NormalizeSharePath checks that path is a local path on the app host.
+func NormalizeSharePath(raw string) (string, error) {
+	path := strings.TrimSpace(raw)
+	if path == "" {
+		return "/", nil
+	}
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, " \t\r\n\\") {
+		return "", fmt.Errorf("share path must be an absolute path like /demo")
+	}
+	if _, err := url.ParseRequestURI(path); err != nil {
+		return "", fmt.Errorf("invalid share path %q", raw)
+	}
+	return path, nil
+}
+
+// AddShareLink creates a share link for app. Expired links are dropped at
+// the same time.
+func AddShareLink(cfg *Config, app string, path string, ttl time.Duration, now time.Time) (ShareLink, error) {
+	if cfg == nil {
+		return ShareLink{}, fmt.Errorf("config is nil")
+	}
+	app = strings.TrimSpace(app)
+	if app == "" {
+		return ShareLink{}, fmt.Errorf("app name is required")
+	}
+	if ttl <= 0 {
+		return ShareLink{}, fmt.Errorf("ttl must be positive")
+	}
+	path, err := NormalizeSharePath(path)
+	if err != nil {
+		return ShareLink{}, err
+	}
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return ShareLink{}, err
+	}
+	link := ShareLink{
+		ID:        hex.EncodeToString(buf),
+		Path:      path,
+		CreatedAt: now.UTC(),
+		ExpiresAt: now.UTC().Add(ttl),
+	}
+	if cfg.Apps == nil {
+		cfg.Apps = map[string]AppAccess{}
+	}
+	appCfg := cfg.Apps[app]
+	appCfg.Shares = append(ActiveShareLinks(*cfg, app, now), link)
+	cfg.Apps[app] = appCfg
+	return link, nil
+}
+
+// RemoveShareLink revokes the share link id on app and reports whether it
+// existed.
+func RemoveShareLink(cfg *Config, app string, id string) bool {
+	if cfg == nil {
+		return false
+	}
+	appCfg, ok := cfg.Apps[app]
+	if !ok {
+		return false
+	}
+	id = strings.TrimSpace(id)
+	kept := make([]ShareLink, 0, len(appCfg.Shares))
+	removed := false
+	for _, link := range appCfg.Shares {
+		if link.ID == id {
+			removed = true
+			continue
+		}
+		kept = append(kept, link)
+	}
+	if len(kept) == 0 {
+		kept = nil
+	}
+	appCfg.Shares = kept
+	cfg.Apps[app] = appCfg
+	return removed
+}
+
+// ActiveShareLinks returns app's unexpired share links.
+func ActiveShareLinks(cfg Config, app string, now time.Time) []ShareLink {
+	var out []ShareLink
+	for _, link := range cfg.Apps[app].Shares {
+		if now.Before(link.ExpiresAt) {
+			out = append(out, link)
+		}
+	}
+	return out
+}
+
+// FindShareLink returns the unexpired share link id on app.
+func FindShareLink(cfg Config, app string, id string, now time.Time) (ShareLink, bool) {
+	for _, link := range ActiveShareLinks(cfg, app, now) {
+		if link.ID == id {
+			return link, true
+		}
+	}
+	return ShareLink{}, false
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShareLinkLifecycle(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := applyDefaults(Config{})
+	old, err := AddShareLink(&cfg, "web", "", time.Hour, now)
+	if err != nil || old.Path != "/" {
+		t.Fatalf("AddShareLink: %+v %v", old, err)
+	}
+	later := now.Add(2 * time.Hour)
+	link, err := AddShareLink(&cfg, "web", "/demo?x=1", time.Hour, later)
+	if err != nil {
+		t.Fatalf("AddShareLink: %v", err)
+	}
+	if shares := cfg.Apps["web"].Shares; len(shares) != 1 || shares[0].ID != link.ID {
+		t.Fatalf("expected expired link to be pruned, got %+v", shares)
+	}
+	if _, ok := FindShareLink(cfg, "web", link.ID, later); !ok {
+		t.Fatalf("expected link to be active")
+	}
+	if _, ok := FindShareLink(cfg, "other", link.ID, later); ok {
+		t.Fatalf("share links are per app")
+	}
+	if _, ok := FindShareLink(cfg, "web", link.ID, link.ExpiresAt); ok {
+		t.Fatalf("expected link to expire")
+	}
+	if !RemoveShareLink(&cfg, "web", link.ID) || RemoveShareLink(&cfg, "web", link.ID) {
+		t.Fatalf("expected link to be removed exactly once")
+	}
+	for _, bad := range []string{"demo", "//evil.example.com", "/a b"} {
+		if _, err := AddShareLink(&cfg, "web", bad, time.Hour, now); err == nil {
+			t.Fatalf("expected path %q to be rejected", bad)
+		}
+	}
+}
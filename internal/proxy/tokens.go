@@ -0,0 +1,147 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// apiTokenPrefix marks a viberun API token so it is easy to spot in logs
+// and secret scanners.
+const apiTokenPrefix = "vbr_"
+
+// APIToken is a personal token for non-browser access to private apps. Only
+// a hash of the token is stored. An empty Apps list allows every app the
+// user can reach; a nil ExpiresAt never expires.
+type APIToken struct {
+	ID        string     `toml:"id"`
+	Name      string     `toml:"name,omitempty"`
+	Hash      string     `toml:"hash"`
+	Apps      []string   `toml:"apps,omitempty"`
+	CreatedAt time.Time  `toml:"created_at"`
+	ExpiresAt *time.Time `toml:"expires_at,omitempty"`
+}
+
+// Expired reports whether the token can no longer be used at now.
+func (t APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// AllowsApp reports whether the token's scope includes app.
+func (t APIToken) AllowsApp(app string) bool {
+	if len(t.Apps) == 0 {
+		return true
+	}
+	for _, allowed := range t.Apps {
+		if allowed == app {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIToken reports whether raw has the shape of a viberun API token, as
+// opposed to some other bearer token an app sends for itself.
+func IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, apiTokenPrefix)
+}
+
+// CreateAPIToken adds a token for username and returns it with the
+// plaintext value, which is not stored. A ttl of zero never expires.
+func CreateAPIToken(cfg *Config, username string, name string, apps []string, ttl time.Duration, now time.Time) (APIToken, string, error) {
+	user, err := findUserForUpdate(cfg, username)
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	if ttl < 0 {
+		return APIToken{}, "", fmt.Errorf("ttl must not be negative")
+	}
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return APIToken{}, "", err
+	}
+	secretBytes := make([]byte, 24)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return APIToken{}, "", err
+	}
+	id := hex.EncodeToString(idBytes)
+	raw := apiTokenPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+	token := APIToken{
+		ID:        id,
+		Name:      strings.TrimSpace(name),
+		Hash:      hashAPIToken(raw),
+		Apps:      normalizeUserList(apps),
+		CreatedAt: now.UTC(),
+	}
+	if ttl > 0 {
+		expires := now.UTC().Add(ttl)
+		token.ExpiresAt = &expires
+	}
+	user.Tokens = append(user.Tokens, token)
+	return token, raw, nil
+}
+
+// RevokeAPIToken removes the token with id from username and reports
+// whether it existed.
+func RevokeAPIToken(cfg *Config, username string, id string) (bool, error) {
+	user, err := findUserForUpdate(cfg, username)
+	if err != nil {
+		return false, err
+	}
+	id = strings.TrimSpace(id)
+	kept := make([]APIToken, 0, len(user.Tokens))
+	removed := false
+	for _, token := range user.Tokens {
+		if token.ID == id {
+			removed = true
+			continue
+		}
+		kept = append(kept, token)
+	}
+	if len(kept) == 0 {
+		kept = nil
+	}
+	user.Tokens = kept
+	return removed, nil
+}
+
+// LookupAPIToken returns the user and token that raw authenticates. Expired
+// tokens are rejected.
+func LookupAPIToken(cfg Config, raw string, now time.Time) (AuthUser, APIToken, bool) {
+	raw = strings.TrimSpace(raw)
+	rest, ok := strings.CutPrefix(raw, apiTokenPrefix)
+	if !ok {
+		return AuthUser{}, APIToken{}, false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok || id == "" {
+		return AuthUser{}, APIToken{}, false
+	}
+	hash := hashAPIToken(raw)
+	for _, user := range cfg.Users {
+		for _, token := range user.Tokens {
+			if token.ID != id {
+				continue
+			}
+			if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hash)) != 1 || token.Expired(now) {
+				return AuthUser{}, APIToken{}, false
+			}
+			return user, token, true
+		}
+	}
+	return AuthUser{}, APIToken{}, false
+}
+
+func hashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPITokenLifecycle(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := Config{Users: []AuthUser{{Username: "alice"}, {Username: "bob"}}}
+	token, raw, err := CreateAPIToken(&cfg, "alice", "ci", []string{"web", " web ", "api"}, time.Hour, now)
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	if !strings.HasPrefix(raw, apiTokenPrefix+token.ID+"_") || strings.Contains(token.Hash, raw) {
+		t.Fatalf("unexpected token %q hash %q", raw, token.Hash)
+	}
+	if len(token.Apps) != 2 || !token.AllowsApp("api") || token.AllowsApp("other") {
+		t.Fatalf("unexpected scope: %v", token.Apps)
+	}
+	user, found, ok := LookupAPIToken(cfg, raw, now)
+	if !ok || user.Username != "alice" || found.ID != token.ID {
+		t.Fatalf("expected token to authenticate alice, got %+v %+v %v", user, found, ok)
+	}
+	if _, _, ok := LookupAPIToken(cfg, raw+"x", now); ok {
+		t.Fatalf("expected tampered token to be rejected")
+	}
+	if _, _, ok := LookupAPIToken(cfg, raw, now.Add(time.Hour)); ok {
+		t.Fatalf("expected expired token to be rejected")
+	}
+	removed, err := RevokeAPIToken(&cfg, "alice", token.ID)
+	if err != nil || !removed {
+		t.Fatalf("RevokeAPIToken: %v %v", removed, err)
+	}
+	if _, _, ok := LookupAPIToken(cfg, raw, now); ok {
+		t.Fatalf("expected revoked token to be rejected")
+	}
+}
+
+func TestAPITokenRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.toml")
+	cfg := Config{Users: []AuthUser{{Username: "alice"}}}
+	_, raw, err := CreateAPIToken(&cfg, "alice", "", nil, 0, time.Now())
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), raw) || strings.Contains(string(data), "expires_at") {
+		t.Fatalf("expected only a hash and no expiry to be stored:\n%s", data)
+	}
+	reloaded, err := LoadConfigFromPath(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFromPath: %v", err)
+	}
+	if _, token, ok := LookupAPIToken(reloaded, raw, time.Now().Add(24*time.Hour)); !ok || !token.AllowsApp("anything") {
+		t.Fatalf("expected unscoped, non-expiring token to survive a reload")
+	}
+}
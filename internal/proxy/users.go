@@ -5,8 +5,11 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/shayne/viberun/internal/totp"
 )
 
 func UpsertUser(cfg *Config, username string, password string) error {
@@ -40,6 +43,36 @@ func UpsertUser(cfg *Config, username string, password string) error {
 	return nil
 }
 
+// ErrUserChanged is returned by ApplyPasswordChange when the user was
+// removed or given a new password after the old one was checked.
+var ErrUserChanged = errors.New("user changed since the password was checked")
+
+// PasswordChange is a password a user set on the account page. Current is
+// the hash viberun-auth checked their old password against.
+type PasswordChange struct {
+	Username string `json:"username"`
+	Current  string `json:"current"`
+	Password string `json:"password"`
+}
+
+// ApplyPasswordChange sets the user's new password, unless an admin reset
+// or removed them since viberun-auth checked the old one.
+func ApplyPasswordChange(cfg *Config, change PasswordChange) error {
+	if cfg == nil {
+		return fmt.Errorf("config is nil")
+	}
+	for _, user := range cfg.Users {
+		if user.Username != change.Username {
+			continue
+		}
+		if user.Password != change.Current {
+			return ErrUserChanged
+		}
+		return UpsertUser(cfg, change.Username, change.Password)
+	}
+	return ErrUserChanged
+}
+
 func RemoveUser(cfg *Config, username string) (bool, error) {
 	if cfg == nil {
 		return false, fmt.Errorf("config is nil")
@@ -61,6 +94,59 @@ func RemoveUser(cfg *Config, username string) (bool, error) {
 	return removed, nil
 }
 
+// EnrollTOTP gives username a new TOTP secret and recovery codes, replacing
+// any previous enrollment. The plaintext codes are returned once.
+func EnrollTOTP(cfg *Config, username string) (string, []string, error) {
+	user, err := findUserForUpdate(cfg, username)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	codes, err := totp.GenerateRecoveryCodes(10)
+	if err != nil {
+		return "", nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, totp.HashRecoveryCode(code))
+	}
+	user.TOTPSecret = secret
+	user.RecoveryCodes = hashes
+	return secret, codes, nil
+}
+
+// DisableTOTP removes username's TOTP enrollment and reports whether one
+// existed.
+func DisableTOTP(cfg *Config, username string) (bool, error) {
+	user, err := findUserForUpdate(cfg, username)
+	if err != nil {
+		return false, err
+	}
+	enrolled := user.TOTPSecret != ""
+	user.TOTPSecret = ""
+	user.RecoveryCodes = nil
+	return enrolled, nil
+}
+
+func findUserForUpdate(cfg *Config, username string) (*AuthUser, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	for i := range cfg.Users {
+		if cfg.Users[i].Username == username {
+			return &cfg.Users[i], nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
 func Usernames(cfg Config) []string {
 	if len(cfg.Users) == 0 {
 		return nil
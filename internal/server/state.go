@@ -9,6 +9,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -20,6 +21,15 @@ const (
 // State tracks persisted server allocations.
 type State struct {
 	Ports map[string]int `json:"ports"`
+	// ExtraPorts holds each app's named extra ports, keyed by app and then
+	// by port name.
+	ExtraPorts map[string]map[string]ExtraPort `json:"extra_ports,omitempty"`
+}
+
+// ExtraPort publishes a container port on its own host port.
+type ExtraPort struct {
+	ContainerPort int `json:"container_port"`
+	HostPort      int `json:"host_port"`
 }
 
 func LoadState() (State, string, error) {
@@ -80,17 +90,28 @@ func (s *State) AssignPort(app string) int {
 		return port
 	}
 
+	port := s.nextFreePort()
+	s.Ports[app] = port
+	return port
+}
+
+// nextFreePort returns the lowest port from basePort that is not allocated
+// to an app or an extra port.
+func (s *State) nextFreePort() int {
 	used := make(map[int]bool, len(s.Ports))
 	for _, port := range s.Ports {
 		used[port] = true
 	}
+	for _, ports := range s.ExtraPorts {
+		for _, extra := range ports {
+			used[extra.HostPort] = true
+		}
+	}
 
 	port := basePort
 	for used[port] {
 		port++
 	}
-
-	s.Ports[app] = port
 	return port
 }
 
@@ -109,9 +130,77 @@ func (s *State) RemoveApp(app string) bool {
 		return false
 	}
 	delete(s.Ports, app)
+	delete(s.ExtraPorts, app)
 	return true
 }
 
+// ExtraPortsForApp returns app's extra ports keyed by name.
+func (s *State) ExtraPortsForApp(app string) map[string]ExtraPort {
+	if s.ExtraPorts == nil {
+		return nil
+	}
+	return s.ExtraPorts[app]
+}
+
+// ExtraPortNames returns app's extra port names in sorted order.
+func (s *State) ExtraPortNames(app string) []string {
+	ports := s.ExtraPortsForApp(app)
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetExtraPort maps name to containerPort for app. The host port is kept
+// when name already exists and otherwise allocated like app ports.
+func (s *State) SetExtraPort(app string, name string, containerPort int) ExtraPort {
+	if s.ExtraPorts == nil {
+		s.ExtraPorts = map[string]map[string]ExtraPort{}
+	}
+	ports := s.ExtraPorts[app]
+	if ports == nil {
+		ports = map[string]ExtraPort{}
+		s.ExtraPorts[app] = ports
+	}
+	extra, ok := ports[name]
+	if !ok {
+		extra.HostPort = s.nextFreePort()
+	}
+	extra.ContainerPort = containerPort
+	ports[name] = extra
+	return extra
+}
+
+// RemoveExtraPort drops one of app's extra ports and reports whether it
+// existed.
+func (s *State) RemoveExtraPort(app string, name string) bool {
+	ports := s.ExtraPortsForApp(app)
+	if _, ok := ports[name]; !ok {
+		return false
+	}
+	delete(ports, name)
+	if len(ports) == 0 {
+		delete(s.ExtraPorts, app)
+	}
+	return true
+}
+
+// ExtraHostPorts returns the host port of every extra port, keyed by app
+// and then by port name.
+func (s *State) ExtraHostPorts() map[string]map[string]int {
+	out := make(map[string]map[string]int, len(s.ExtraPorts))
+	for app, ports := range s.ExtraPorts {
+		hostPorts := make(map[string]int, len(ports))
+		for name, extra := range ports {
+			hostPorts[name] = extra.HostPort
+		}
+		out[app] = hostPorts
+	}
+	return out
+}
+
 func statePath() (string, error) {
 	if value := strings.TrimSpace(os.Getenv("VIBERUN_STATE_PATH")); value != "" {
 		return value, nil
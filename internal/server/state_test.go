@@ -74,3 +74,33 @@ func TestStateRemoveApp(t *testing.T) {
 		t.Fatalf("expected app-a to be removed")
 	}
 }
+
+func TestStateExtraPorts(t *testing.T) {
+	state := State{}
+	state.AssignPort("app-one")
+	api := state.SetExtraPort("app-one", "api", 3000)
+	if api.HostPort != basePort+1 || api.ContainerPort != 3000 {
+		t.Fatalf("unexpected extra port: %+v", api)
+	}
+	if port := state.AssignPort("app-two"); port != basePort+2 {
+		t.Fatalf("expected app port to skip extra ports, got %d", port)
+	}
+	moved := state.SetExtraPort("app-one", "api", 4000)
+	if moved.HostPort != api.HostPort || moved.ContainerPort != 4000 {
+		t.Fatalf("expected host port to be kept, got %+v", moved)
+	}
+	state.SetExtraPort("app-one", "admin", 9000)
+	if names := state.ExtraPortNames("app-one"); len(names) != 2 || names[0] != "admin" || names[1] != "api" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if hostPorts := state.ExtraHostPorts(); hostPorts["app-one"]["api"] != api.HostPort {
+		t.Fatalf("unexpected host ports: %v", hostPorts)
+	}
+	if !state.RemoveExtraPort("app-one", "api") || state.RemoveExtraPort("app-one", "api") {
+		t.Fatalf("expected remove to report once")
+	}
+	state.RemoveApp("app-one")
+	if state.ExtraPortsForApp("app-one") != nil {
+		t.Fatalf("expected extra ports to be removed with the app")
+	}
+}
@@ -0,0 +1,484 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package serverapi defines the results viberun-server prints with --json.
+package serverapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Version is the schema version of every --json envelope. Bump it when a
+// result changes incompatibly; additive fields keep the same version.
+const Version = 1
+
+// Result kinds carried in Envelope.Kind.
+const (
+	KindError            = "error"
+	KindMessage          = "message"
+	KindVersion          = "version"
+	KindApps             = "apps"
+	KindAppExists        = "app_exists"
+	KindAppStatus        = "app_status"
+	KindAppPort          = "app_port"
+	KindAppPorts         = "app_ports"
+	KindSnapshot         = "snapshot"
+	KindSnapshots        = "snapshots"
+	KindSnapshotSchedule = "snapshot_schedule"
+	KindExport           = "export"
+	KindImport           = "import"
+	KindBackupConfig     = "backup_config"
+	KindBackupStatus     = "backup_status"
+	KindBackupRun        = "backup_run"
+	KindBackupRestore    = "backup_restore"
+	KindDiskUsage        = "disk_usage"
+	KindDiskGrow         = "disk_grow"
+	KindBranches         = "branches"
+	KindLimits           = "limits"
+	KindProxyConfig      = "proxy_config"
+	KindProxyInfo        = "proxy_info"
+	KindProxyURL         = "proxy_url"
+	KindProxyUsers       = "proxy_users"
+	KindProxySessions    = "proxy_sessions"
+	KindProxyRevoke      = "proxy_revoke"
+	KindProxyRotateKey   = "proxy_rotate_key"
+	KindProxyTOTP        = "proxy_totp"
+	KindProxyTokens      = "proxy_tokens"
+	KindProxyToken       = "proxy_token"
+	KindProxyShare       = "proxy_share"
+	KindProxyShares      = "proxy_shares"
+	KindProxyAudit       = "proxy_audit"
+)
+
+// FlagJSON is the global viberun-server flag that selects JSON output.
+const FlagJSON = "--json"
+
+// ErrOutdatedServer is returned when the server output is not a --json envelope.
+var ErrOutdatedServer = errors.New("remote viberun-server is out of date; rerun setup to update it")
+
+// Envelope wraps every --json result.
+type Envelope struct {
+	Version int             `json:"version"`
+	Kind    string          `json:"kind"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type Message struct {
+	Message string `json:"message,omitempty"`
+}
+
+type ServerVersion struct {
+	Version string `json:"version"`
+}
+
+type App struct {
+	Name string `json:"name"`
+	Port int    `json:"port,omitempty"`
+}
+
+type AppList struct {
+	Apps []App `json:"apps"`
+}
+
+type AppExists struct {
+	App    string `json:"app"`
+	Exists bool   `json:"exists"`
+}
+
+// App status values.
+const (
+	StatusRunning = "running"
+	StatusStopped = "stopped"
+	StatusMissing = "missing"
+)
+
+type AppStatus struct {
+	App    string `json:"app"`
+	Status string `json:"status"`
+}
+
+type AppPort struct {
+	App  string `json:"app"`
+	Port int    `json:"port"`
+}
+
+// Snapshot origins.
+const (
+	OriginManual = "manual"
+	OriginAuto   = "auto"
+)
+
+type SnapshotInfo struct {
+	Tag       string    `json:"tag"`
+	Name      string    `json:"name,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	// Origin is OriginAuto for scheduled snapshots and OriginManual otherwise.
+	Origin  string `json:"origin,omitempty"`
+	Creator string `json:"creator,omitempty"`
+	Agent   string `json:"agent,omitempty"`
+	ImageID string `json:"image_id,omitempty"`
+}
+
+type SnapshotList struct {
+	App       string         `json:"app"`
+	Snapshots []SnapshotInfo `json:"snapshots"`
+}
+
+type SnapshotCreated struct {
+	App  string `json:"app"`
+	Tag  string `json:"tag"`
+	Name string `json:"name,omitempty"`
+}
+
+type SnapshotSchedule struct {
+	App        string `json:"app"`
+	Every      string `json:"every,omitempty"`
+	KeepLast   int    `json:"keep_last,omitempty"`
+	KeepDaily  int    `json:"keep_daily,omitempty"`
+	KeepWeekly int    `json:"keep_weekly,omitempty"`
+}
+
+// ArchiveExport describes an archive written by `<app> export --output`.
+type ArchiveExport struct {
+	App      string `json:"app"`
+	Snapshot string `json:"snapshot"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+}
+
+// ArchiveImport describes an app rebuilt by `<app> import`.
+type ArchiveImport struct {
+	App string `json:"app"`
+	// Source is the app name recorded in the archive.
+	Source   string `json:"source,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	// DroppedUsers are allowed users that do not exist on this host and
+	// DroppedDomain a custom domain another app already uses. Neither was
+	// imported.
+	DroppedUsers  []string `json:"dropped_users,omitempty"`
+	DroppedDomain string   `json:"dropped_domain,omitempty"`
+}
+
+// Warnings describes the settings an import dropped, one line each.
+func (r ArchiveImport) Warnings() []string {
+	var lines []string
+	if len(r.DroppedUsers) > 0 {
+		lines = append(lines, fmt.Sprintf("warning: not allowing %s: no such user on this host", strings.Join(r.DroppedUsers, ", ")))
+	}
+	if r.DroppedDomain != "" {
+		lines = append(lines, fmt.Sprintf("warning: not using custom domain %s: another app already has it", r.DroppedDomain))
+	}
+	return lines
+}
+
+type BackupConfig struct {
+	Target string `json:"target,omitempty"`
+	Auto   bool   `json:"auto"`
+}
+
+// BackupSnapshot is one snapshot and its state on the backup target.
+type BackupSnapshot struct {
+	Tag  string `json:"tag"`
+	Name string `json:"name,omitempty"`
+	// Parent is the snapshot the stream is a delta against; empty for a
+	// full stream.
+	Parent     string    `json:"parent,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	BackedUpAt time.Time `json:"backed_up_at,omitempty"`
+	Local      bool      `json:"local"`
+	BackedUp   bool      `json:"backed_up"`
+}
+
+type AppBackupStatus struct {
+	App       string           `json:"app"`
+	Snapshots []BackupSnapshot `json:"snapshots"`
+}
+
+type BackupStatus struct {
+	Target string            `json:"target"`
+	Apps   []AppBackupStatus `json:"apps"`
+}
+
+type AppBackupRun struct {
+	App string `json:"app"`
+	// Sent lists the snapshots shipped in this run.
+	Sent  []BackupSnapshot `json:"sent"`
+	Error string           `json:"error,omitempty"`
+}
+
+type BackupRun struct {
+	Target string         `json:"target"`
+	Apps   []AppBackupRun `json:"apps"`
+}
+
+// BackupRestore describes an app rebuilt by `<app> backup restore`.
+type BackupRestore struct {
+	App      string `json:"app"`
+	Snapshot string `json:"snapshot"`
+	// Chain lists the streams received, oldest first.
+	Chain []string `json:"chain"`
+	Port  int      `json:"port,omitempty"`
+}
+
+// DiskUsage reports how full an app's home volume is.
+type DiskUsage struct {
+	App string `json:"app"`
+	// Size is the btrfs filesystem size; Used and Free come from btrfs and
+	// include snapshots.
+	Size        int64 `json:"size"`
+	Used        int64 `json:"used"`
+	Free        int64 `json:"free"`
+	UsedPercent int   `json:"used_percent"`
+	Warn        bool  `json:"warn"`
+	// HostFree is the free space on the host filesystem holding the sparse
+	// volume file, which can run out before the volume does.
+	HostFree  int64               `json:"host_free"`
+	Snapshots []SnapshotDiskUsage `json:"snapshots"`
+}
+
+// SnapshotDiskUsage is the space only a snapshot holds, which deleting it
+// would free.
+type SnapshotDiskUsage struct {
+	Tag       string `json:"tag"`
+	Name      string `json:"name,omitempty"`
+	Exclusive int64  `json:"exclusive"`
+}
+
+type DiskGrow struct {
+	App     string `json:"app"`
+	OldSize int64  `json:"old_size"`
+	NewSize int64  `json:"new_size"`
+}
+
+type BranchInfo struct {
+	Branch          string    `json:"branch"`
+	App             string    `json:"app"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+	BaseSnapshotRef string    `json:"base_snapshot_ref,omitempty"`
+}
+
+type BranchList struct {
+	App      string       `json:"app"`
+	Branches []BranchInfo `json:"branches"`
+}
+
+// AppPorts lists an app's named extra ports.
+type AppPorts struct {
+	App   string         `json:"app"`
+	Ports []AppExtraPort `json:"ports"`
+}
+
+// AppExtraPort is a container port published on its own host port. An
+// empty Path means the port is routed on its own sub-host.
+type AppExtraPort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port"`
+	Path          string `json:"path,omitempty"`
+	Access        string `json:"access"`
+	URL           string `json:"url,omitempty"`
+}
+
+type Limits struct {
+	Profile   string   `json:"profile"`
+	Memory    string   `json:"memory,omitempty"`
+	CPUs      string   `json:"cpus,omitempty"`
+	PidsLimit int      `json:"pids_limit,omitempty"`
+	CapAdd    []string `json:"cap_add,omitempty"`
+	// Overrides lists the keys set explicitly for this app (or host).
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+type ProxyConfig struct {
+	Enabled     bool     `json:"enabled"`
+	BaseDomain  string   `json:"base_domain"`
+	PublicIP    string   `json:"public_ip"`
+	PrimaryUser string   `json:"primary_user"`
+	ProxyImage  string   `json:"proxy_image"`
+	Users       []string `json:"users"`
+}
+
+type ProxyInfo struct {
+	App           string   `json:"app"`
+	URL           string   `json:"url"`
+	Access        string   `json:"access"`
+	Disabled      bool     `json:"disabled"`
+	CustomDomain  string   `json:"custom_domain"`
+	AllowedUsers  []string `json:"allowed_users"`
+	AllowedGroups []string `json:"allowed_groups,omitempty"`
+	AllowIPs      []string `json:"allow_ips,omitempty"`
+	DenyIPs       []string `json:"deny_ips,omitempty"`
+	PrimaryUser   string   `json:"primary_user"`
+	Users         []string `json:"users"`
+	Groups        []string `json:"groups,omitempty"`
+	BaseDomain    string   `json:"base_domain"`
+	PublicIP      string   `json:"public_ip"`
+	Enabled       bool     `json:"enabled"`
+}
+
+type ProxyURL struct {
+	App string `json:"app"`
+	URL string `json:"url"`
+}
+
+type ProxyUsers struct {
+	Users []string `json:"users"`
+	// Groups maps a username to its group memberships.
+	Groups map[string][]string `json:"groups,omitempty"`
+}
+
+type ProxySession struct {
+	ID        string    `json:"id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+type ProxySessions struct {
+	Username string         `json:"username"`
+	Sessions []ProxySession `json:"sessions"`
+}
+
+// ProxyAuditEvent is one entry of the auth audit log.
+type ProxyAuditEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	User    string    `json:"user,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	App     string    `json:"app,omitempty"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// ProxyAudit holds matching audit events, oldest first. Truncated is set
+// when older matches were left out to respect the limit.
+type ProxyAudit struct {
+	Events    []ProxyAuditEvent `json:"events"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+type ProxyRevoke struct {
+	Username string `json:"username"`
+	Revoked  int    `json:"revoked"`
+}
+
+// ProxyTOTP reports a TOTP enrollment. URI, Secret and RecoveryCodes are
+// only set by enroll and are never shown again.
+type ProxyTOTP struct {
+	Username      string   `json:"username"`
+	Enabled       bool     `json:"enabled"`
+	URI           string   `json:"uri,omitempty"`
+	Secret        string   `json:"secret,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// ProxyToken describes a personal API token. Token holds the secret value
+// and is only set when the token is created.
+type ProxyToken struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Apps      []string   `json:"apps,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Token     string     `json:"token,omitempty"`
+}
+
+type ProxyTokens struct {
+	Username string       `json:"username"`
+	Tokens   []ProxyToken `json:"tokens"`
+}
+
+// ProxyShare describes a share link. URL is only set when the link is
+// created; it cannot be shown again.
+type ProxyShare struct {
+	ID        string    `json:"id"`
+	App       string    `json:"app"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	URL       string    `json:"url,omitempty"`
+}
+
+type ProxyShares struct {
+	App    string       `json:"app"`
+	Shares []ProxyShare `json:"shares"`
+}
+
+// ProxyRotateKey lists signing key IDs after a rotation. Verify lists every
+// key still accepted, including the active one.
+type ProxyRotateKey struct {
+	Active  string   `json:"active"`
+	Verify  []string `json:"verify"`
+	Retired []string `json:"retired"`
+}
+
+// Write encodes result as a single-line envelope.
+func Write(out io.Writer, kind string, result any) error {
+	env := Envelope{Version: Version, Kind: kind}
+	if result != nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		env.Result = raw
+	}
+	return json.NewEncoder(out).Encode(env)
+}
+
+// WriteError encodes err as an error envelope.
+func WriteError(out io.Writer, err error) error {
+	return json.NewEncoder(out).Encode(Envelope{Version: Version, Kind: KindError, Error: err.Error()})
+}
+
+// Decode parses command output into result, checking the version and kind.
+// Lines before the envelope (for example warnings) are ignored.
+func Decode(output string, kind string, result any) error {
+	env, err := findEnvelope(output)
+	if err != nil {
+		return err
+	}
+	if env.Version > Version {
+		return fmt.Errorf("remote viberun-server uses JSON version %d (client supports %d); update viberun", env.Version, Version)
+	}
+	if env.Kind == KindError {
+		if strings.TrimSpace(env.Error) == "" {
+			return errors.New("remote command failed")
+		}
+		return errors.New(env.Error)
+	}
+	if env.Kind != kind {
+		return fmt.Errorf("unexpected result kind %q (want %q)", env.Kind, kind)
+	}
+	if result == nil || len(env.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Result, result)
+}
+
+func findEnvelope(output string) (Envelope, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var env Envelope
+		if err := json.Unmarshal([]byte(line), &env); err != nil || env.Version == 0 {
+			continue
+		}
+		return env, nil
+	}
+	return Envelope{}, ErrOutdatedServer
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverapi
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDecodeRoundTrip(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := Write(&buf, KindSnapshots, SnapshotList{App: "myapp", Snapshots: []SnapshotInfo{{Tag: "v1", CreatedAt: created, Size: 4096}}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected a single line, got %q", buf.String())
+	}
+	output := "warning: something noisy\n" + buf.String()
+	var list SnapshotList
+	if err := Decode(output, KindSnapshots, &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if list.App != "myapp" || len(list.Snapshots) != 1 {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+	got := list.Snapshots[0]
+	if got.Tag != "v1" || !got.CreatedAt.Equal(created) || got.Size != 4096 {
+		t.Fatalf("unexpected snapshot: %+v", got)
+	}
+}
+
+func TestDecodeErrorEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteError(&buf, errors.New("cannot snapshot: app container does not exist")); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	err := Decode(buf.String(), KindSnapshot, &SnapshotCreated{})
+	if err == nil || err.Error() != "cannot snapshot: app container does not exist" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDecodeRejectsWrongKind(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, KindAppPort, AppPort{App: "myapp", Port: 8080}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := Decode(buf.String(), KindAppStatus, &AppStatus{}); err == nil {
+		t.Fatalf("expected kind mismatch error")
+	}
+}
+
+func TestDecodeTextOutputReportsOutdatedServer(t *testing.T) {
+	if err := Decode("8080\n", KindAppPort, &AppPort{}); !errors.Is(err, ErrOutdatedServer) {
+		t.Fatalf("expected ErrOutdatedServer, got %v", err)
+	}
+}
+
+func TestDecodeRejectsNewerVersion(t *testing.T) {
+	output := `{"version":99,"kind":"app_port","result":{"app":"myapp","port":8080}}`
+	if err := Decode(output, KindAppPort, &AppPort{}); err == nil {
+		t.Fatalf("expected version error")
+	}
+}
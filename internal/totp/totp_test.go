@@ -0,0 +1,72 @@
+// Copyright (c) 2026 AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeRFC6238(t *testing.T) {
+	// RFC 6238 appendix B SHA-1 vectors, truncated to 6 digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+	for unix, want := range cases {
+		got, err := Code(secret, Step(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Fatalf("Code(%d) = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	previous, _ := Code(secret, Step(now)-1)
+	if step, ok := Validate(secret, previous, now); !ok || step != Step(now)-1 {
+		t.Fatalf("expected previous step to validate, got %d %v", step, ok)
+	}
+	old, _ := Code(secret, Step(now)-2)
+	if _, ok := Validate(secret, old, now); ok {
+		t.Fatalf("expected code two steps old to be rejected")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Fatalf("expected short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("viberun example.com", "alice", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/viberun%20example.com:alice?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+	if qr, err := QR(uri); err != nil || !strings.Contains(qr, "█") {
+		t.Fatalf("expected terminal QR code, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(3)
+	if err != nil || len(codes) != 3 {
+		t.Fatalf("GenerateRecoveryCodes: %v %v", codes, err)
+	}
+	hashes := []string{HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1])}
+	if hash, ok := MatchRecoveryCode(hashes, " "+strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))+" "); !ok || hash != hashes[1] {
+		t.Fatalf("expected formatting-insensitive match")
+	}
+	if _, ok := MatchRecoveryCode(hashes, codes[2]); ok {
+		t.Fatalf("expected unknown code to be rejected")
+	}
+}